@@ -3,9 +3,12 @@ package blackbox
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/atrariksa/awallet/configs"
@@ -13,10 +16,19 @@ import (
 	"github.com/atrariksa/awallet/models"
 )
 
+// testPassword is the password of every user created by the test cases.
+const testPassword = "password123"
+
 func createUserRequest(cfg *configs.Config, username string) (*http.Client, *http.Request) {
+	return createUserWithPinRequest(cfg, username, "")
+}
+
+func createUserWithPinRequest(cfg *configs.Config, username string, pin string) (*http.Client, *http.Request) {
 	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
 	body, _ := json.Marshal(&models.CreateUserRequest{
 		Username: username,
+		Password: testPassword,
+		Pin:      pin,
 	})
 	return &http.Client{Timeout: time.Second * 5},
 		&http.Request{
@@ -103,6 +115,12 @@ func topupBalance(cfg *configs.Config, token string, amount uint32) int {
 	return resp.StatusCode
 }
 
+func topupBalanceWithIdempotencyKeyRequest(cfg *configs.Config, token string, amount uint32, idempotencyKey string) (*http.Client, *http.Request) {
+	client, req := topupBalanceRequest(cfg, token, amount)
+	req.Header.Add(constants.IDEMPOTENCY_KEY_HEADER, idempotencyKey)
+	return client, req
+}
+
 func transferRequest(cfg *configs.Config, token string, amount uint32, usernameDest string) (*http.Client, *http.Request) {
 	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
 	body, _ := json.Marshal(&models.TransferRequest{
@@ -124,10 +142,32 @@ func transferRequest(cfg *configs.Config, token string, amount uint32, usernameD
 		}
 }
 
+func transferWithPinRequest(cfg *configs.Config, token string, amount uint32, usernameDest string, pin string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.TransferRequest{
+		Amount:     amount,
+		ToUsername: usernameDest,
+		Pin:        pin,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TRANSFER_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
 func transfer(cfg *configs.Config, token string, amount uint32, usernameDest string) int {
 	c, req := transferRequest(cfg, token, amount, usernameDest)
 	resp, _ := c.Do(req)
-	if resp.StatusCode != http.StatusNoContent {
+	if resp.StatusCode != http.StatusOK {
 		return 0
 	}
 	return resp.StatusCode
@@ -193,6 +233,213 @@ func getTopUsers(cfg *configs.Config, token string) models.TopUserResponse {
 	return topUsersResp
 }
 
+func mutationsRequest(cfg *configs.Config, token string, query url.Values) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodGet,
+			URL: &url.URL{
+				Scheme:   "http",
+				Host:     host,
+				Path:     constants.MUTATIONS_PATH,
+				RawQuery: query.Encode(),
+			},
+		}
+}
+
+func getMutations(cfg *configs.Config, token string, query url.Values) models.MutationHistoryResponse {
+	c, req := mutationsRequest(cfg, token, query)
+	resp, _ := c.Do(req)
+	if resp.StatusCode != http.StatusOK {
+		return models.MutationHistoryResponse{}
+	}
+	mutationsResp := models.MutationHistoryResponse{}
+	err := getStruct(resp, &mutationsResp)
+	if err != nil {
+		return models.MutationHistoryResponse{}
+	}
+	return mutationsResp
+}
+
+func createWalletRequest(cfg *configs.Config, token string, currency string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.CreateWalletRequest{
+		Currency: currency,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.WALLETS_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func topupBalanceInCurrencyRequest(cfg *configs.Config, token string, amount uint32, currency string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.TopupBalanceRequest{
+		Amount:   amount,
+		Currency: currency,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TOPUP_BALANCE_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func transferInCurrencyRequest(cfg *configs.Config, token string, amount uint32, currency string, usernameDest string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.TransferRequest{
+		Amount:     amount,
+		Currency:   currency,
+		ToUsername: usernameDest,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TRANSFER_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func fxQuoteRequest(cfg *configs.Config, token string, fromCurrency string, toCurrency string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.FXQuoteRequest{
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.FX_QUOTE_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func getFXQuote(cfg *configs.Config, token string, fromCurrency string, toCurrency string) models.FXQuoteResponse {
+	c, req := fxQuoteRequest(cfg, token, fromCurrency, toCurrency)
+	resp, _ := c.Do(req)
+	if resp.StatusCode != http.StatusCreated {
+		return models.FXQuoteResponse{}
+	}
+	quoteResp := models.FXQuoteResponse{}
+	err := getStruct(resp, &quoteResp)
+	if err != nil {
+		return models.FXQuoteResponse{}
+	}
+	return quoteResp
+}
+
+func transferWithQuoteRequest(cfg *configs.Config, token string, amount uint32, currency string, quoteID string, usernameDest string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.TransferRequest{
+		Amount:     amount,
+		Currency:   currency,
+		QuoteID:    quoteID,
+		ToUsername: usernameDest,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TRANSFER_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func withdrawRequest(cfg *configs.Config, token string, amount uint32) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.WithdrawRequest{
+		Amount: amount,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.WITHDRAW_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func withdraw(cfg *configs.Config, token string, amount uint32) models.WithdrawResponse {
+	c, req := withdrawRequest(cfg, token, amount)
+	resp, _ := c.Do(req)
+	if resp.StatusCode != http.StatusAccepted {
+		return models.WithdrawResponse{}
+	}
+	withdrawResp := models.WithdrawResponse{}
+	err := getStruct(resp, &withdrawResp)
+	if err != nil {
+		return models.WithdrawResponse{}
+	}
+	return withdrawResp
+}
+
+func withdrawalCallbackRequest(cfg *configs.Config, secret string, refID string, status models.MutationStatus) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.WithdrawalCallbackRequest{
+		RefID:  refID,
+		Status: status,
+	})
+	header := http.Header{}
+	header.Add(constants.INTERNAL_SECRET_HEADER, secret)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.WITHDRAWAL_CALLBACK_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
 func getStruct(resp *http.Response, respStruct interface{}) (err error) {
 	bodyByte, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -204,3 +451,780 @@ func getStruct(resp *http.Response, respStruct interface{}) (err error) {
 	}
 	return
 }
+
+func limitsRequest(cfg *configs.Config, token string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodGet,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.LIMITS_PATH,
+			},
+		}
+}
+
+func getLimits(cfg *configs.Config, token string) models.LimitsResponse {
+	c, req := limitsRequest(cfg, token)
+	resp, _ := c.Do(req)
+	limitsResp := models.LimitsResponse{}
+	if resp.StatusCode != http.StatusOK {
+		return limitsResp
+	}
+	getStruct(resp, &limitsResp)
+	return limitsResp
+}
+
+func refreshTokenRequest(cfg *configs.Config, refreshToken string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.RefreshTokenRequest{
+		RefreshToken: refreshToken,
+	})
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: http.Header{},
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TOKEN_REFRESH_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func refreshTokens(cfg *configs.Config, refreshToken string) models.TokenResponse {
+	c, req := refreshTokenRequest(cfg, refreshToken)
+	resp, _ := c.Do(req)
+	tokenResp := models.TokenResponse{}
+	if resp.StatusCode != http.StatusOK {
+		return tokenResp
+	}
+	getStruct(resp, &tokenResp)
+	return tokenResp
+}
+
+func logoutRequest(cfg *configs.Config, token string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.LOGOUT_PATH,
+			},
+		}
+}
+
+func jwksRequest(cfg *configs.Config) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: http.Header{},
+			Method: http.MethodGet,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.JWKS_PATH,
+			},
+		}
+}
+
+func loginRequest(cfg *configs.Config, username string, password string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.LoginRequest{
+		Username: username,
+		Password: password,
+	})
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: http.Header{},
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.LOGIN_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func credentialsRequest(cfg *configs.Config, token string, credentials models.UpdateCredentialsRequest) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&credentials)
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.CREDENTIALS_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func twoFactorEnrollRequest(cfg *configs.Config, token string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TWO_FACTOR_ENROLL_PATH,
+			},
+		}
+}
+
+func enrollTwoFactor(cfg *configs.Config, token string) models.TwoFactorEnrollResponse {
+	c, req := twoFactorEnrollRequest(cfg, token)
+	resp, _ := c.Do(req)
+	enrollResp := models.TwoFactorEnrollResponse{}
+	if resp.StatusCode != http.StatusCreated {
+		return enrollResp
+	}
+	getStruct(resp, &enrollResp)
+	return enrollResp
+}
+
+func twoFactorActivateRequest(cfg *configs.Config, token string, code string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.TwoFactorActivateRequest{
+		Code: code,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TWO_FACTOR_ACTIVATE_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+// activateTwoFactor enrolls the user and activates it with the current code of its secret.
+func activateTwoFactor(cfg *configs.Config, token string) models.RecoveryCodesResponse {
+	enrollResp := enrollTwoFactor(cfg, token)
+	code, _ := models.TOTPCode(enrollResp.Secret, models.TOTPStep(time.Now()))
+	c, req := twoFactorActivateRequest(cfg, token, code)
+	resp, _ := c.Do(req)
+	recoveryCodesResp := models.RecoveryCodesResponse{}
+	if resp.StatusCode != http.StatusOK {
+		return recoveryCodesResp
+	}
+	getStruct(resp, &recoveryCodesResp)
+	return recoveryCodesResp
+}
+
+func transferConfirmRequest(cfg *configs.Config, token string, challengeID string, code string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.TransferConfirmRequest{
+		ChallengeID: challengeID,
+		Code:        code,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TRANSFER_CONFIRM_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+// transferChallenge makes a transfer expected to be held by a two-factor challenge.
+func transferChallenge(cfg *configs.Config, token string, amount uint32, usernameDest string) models.TransferChallengeResponse {
+	c, req := transferRequest(cfg, token, amount, usernameDest)
+	resp, _ := c.Do(req)
+	challengeResp := models.TransferChallengeResponse{}
+	if resp.StatusCode != http.StatusAccepted {
+		return challengeResp
+	}
+	getStruct(resp, &challengeResp)
+	return challengeResp
+}
+
+func adminPath(path string, param string, value string) string {
+	return strings.Replace(path, "{"+param+"}", url.PathEscape(value), 1)
+}
+
+func adminUserRequest(cfg *configs.Config, token string, username string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodGet,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   adminPath(constants.ADMIN_USER_PATH, "username", username),
+			},
+		}
+}
+
+func adminFreezeRequest(cfg *configs.Config, token string, username string, scope string, reason string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.AdminFreezeRequest{
+		Scope:  scope,
+		Reason: reason,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   adminPath(constants.ADMIN_FREEZE_PATH, "username", username),
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func adminAdjustmentRequest(cfg *configs.Config, token string, username string, adjustment models.AdminAdjustmentRequest) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&adjustment)
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   adminPath(constants.ADMIN_ADJUSTMENTS_PATH, "username", username),
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func adminCloseRequest(cfg *configs.Config, token string, username string, payout bool) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.AdminCloseRequest{
+		Payout: payout,
+		Reason: "closed on request of the user",
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   adminPath(constants.ADMIN_CLOSE_PATH, "username", username),
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func adminUnfreezeRequest(cfg *configs.Config, token string, username string) (*http.Client, *http.Request) {
+	client, req := adminFreezeRequest(cfg, token, username, "", "cleared after review")
+	req.URL.Path = adminPath(constants.ADMIN_UNFREEZE_PATH, "username", username)
+	return client, req
+}
+
+func createWebhookRequest(cfg *configs.Config, token string, webhookURL string, events ...models.WebhookEvent) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.CreateWebhookRequest{
+		URL:    webhookURL,
+		Events: events,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.WEBHOOKS_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func createWebhook(cfg *configs.Config, token string, webhookURL string, events ...models.WebhookEvent) models.WebhookResponse {
+	c, req := createWebhookRequest(cfg, token, webhookURL, events...)
+	resp, _ := c.Do(req)
+	webhookResp := models.WebhookResponse{}
+	if resp.StatusCode != http.StatusCreated {
+		return webhookResp
+	}
+	getStruct(resp, &webhookResp)
+	return webhookResp
+}
+
+func deleteWebhookRequest(cfg *configs.Config, token string, webhookID uint) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodDelete,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   strings.Replace(constants.WEBHOOK_PATH, "{id}", fmt.Sprintf("%v", webhookID), 1),
+			},
+		}
+}
+
+func webhookDeliveriesRequest(cfg *configs.Config, token string, status models.WebhookDeliveryStatus) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodGet,
+			URL: &url.URL{
+				Scheme:   "http",
+				Host:     host,
+				Path:     constants.WEBHOOK_DELIVERIES_PATH,
+				RawQuery: query.Encode(),
+			},
+		}
+}
+
+func getWebhookDeliveries(cfg *configs.Config, token string, status models.WebhookDeliveryStatus) models.WebhookDeliveriesResponse {
+	c, req := webhookDeliveriesRequest(cfg, token, status)
+	resp, _ := c.Do(req)
+	deliveriesResp := models.WebhookDeliveriesResponse{}
+	if resp.StatusCode != http.StatusOK {
+		return deliveriesResp
+	}
+	getStruct(resp, &deliveriesResp)
+	return deliveriesResp
+}
+
+func webhookReplayRequest(cfg *configs.Config, token string, deliveryID string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   strings.Replace(constants.WEBHOOK_DELIVERY_REPLAY_PATH, "{delivery_id}", deliveryID, 1),
+			},
+		}
+}
+
+func createPaymentRequestRequest(cfg *configs.Config, token string, payerUsername string, amount uint32, expiresAt *time.Time) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&models.CreatePaymentRequestRequest{
+		PayerUsername: payerUsername,
+		Amount:        amount,
+		Memo:          "dinner",
+		ExpiresAt:     expiresAt,
+	})
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.PAYMENT_REQUESTS_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func createPaymentRequest(cfg *configs.Config, token string, payerUsername string, amount uint32) models.PaymentRequestResponse {
+	c, req := createPaymentRequestRequest(cfg, token, payerUsername, amount, nil)
+	resp, _ := c.Do(req)
+	paymentRequestResp := models.PaymentRequestResponse{}
+	if resp.StatusCode != http.StatusCreated {
+		return paymentRequestResp
+	}
+	getStruct(resp, &paymentRequestResp)
+	return paymentRequestResp
+}
+
+func incomingPaymentRequestsRequest(cfg *configs.Config, token string, status models.PaymentRequestStatus) (*http.Client, *http.Request) {
+	return paymentRequestsRequest(cfg, token, constants.PAYMENT_REQUESTS_INCOMING_PATH, status, 0, "")
+}
+
+// paymentRequestsRequest lists the incoming or outgoing payment requests at path, a
+// limit of 0 and an empty cursor are left out.
+func paymentRequestsRequest(cfg *configs.Config, token string, path string, status models.PaymentRequestStatus, limit int, cursor string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+	if limit > 0 {
+		query.Set("limit", fmt.Sprintf("%v", limit))
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodGet,
+			URL: &url.URL{
+				Scheme:   "http",
+				Host:     host,
+				Path:     path,
+				RawQuery: query.Encode(),
+			},
+		}
+}
+
+func getPaymentRequests(cfg *configs.Config, token string, path string, limit int, cursor string) models.PaymentRequestsResponse {
+	c, req := paymentRequestsRequest(cfg, token, path, "", limit, cursor)
+	resp, _ := c.Do(req)
+	paymentRequestsResp := models.PaymentRequestsResponse{}
+	if resp.StatusCode != http.StatusOK {
+		return paymentRequestsResp
+	}
+	getStruct(resp, &paymentRequestsResp)
+	return paymentRequestsResp
+}
+
+func getIncomingPaymentRequests(cfg *configs.Config, token string, status models.PaymentRequestStatus) models.PaymentRequestsResponse {
+	c, req := incomingPaymentRequestsRequest(cfg, token, status)
+	resp, _ := c.Do(req)
+	paymentRequestsResp := models.PaymentRequestsResponse{}
+	if resp.StatusCode != http.StatusOK {
+		return paymentRequestsResp
+	}
+	getStruct(resp, &paymentRequestsResp)
+	return paymentRequestsResp
+}
+
+// paymentRequestActionRequest posts to the accept, decline or cancel path of a payment request.
+func paymentRequestActionRequest(cfg *configs.Config, token string, path string, id string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   strings.Replace(path, "{id}", id, 1),
+			},
+		}
+}
+
+func createTransferScheduleRequest(cfg *configs.Config, token string, schedule models.CreateTransferScheduleRequest) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&schedule)
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TRANSFER_SCHEDULES_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func createTransferSchedule(cfg *configs.Config, token string, schedule models.CreateTransferScheduleRequest) models.TransferScheduleResponse {
+	c, req := createTransferScheduleRequest(cfg, token, schedule)
+	resp, _ := c.Do(req)
+	scheduleResp := models.TransferScheduleResponse{}
+	if resp.StatusCode != http.StatusCreated {
+		return scheduleResp
+	}
+	getStruct(resp, &scheduleResp)
+	return scheduleResp
+}
+
+func transferScheduleRequest(cfg *configs.Config, token string, method string, scheduleID uint, update *models.UpdateTransferScheduleRequest) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	req := &http.Request{
+		Header: header,
+		Method: method,
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   host,
+			Path:   strings.Replace(constants.TRANSFER_SCHEDULE_PATH, "{id}", fmt.Sprintf("%v", scheduleID), 1),
+		},
+	}
+	if update != nil {
+		body, _ := json.Marshal(update)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return &http.Client{Timeout: time.Second * 5}, req
+}
+
+func getTransferSchedule(cfg *configs.Config, token string, scheduleID uint) models.TransferScheduleResponse {
+	c, req := transferScheduleRequest(cfg, token, http.MethodGet, scheduleID, nil)
+	resp, _ := c.Do(req)
+	scheduleResp := models.TransferScheduleResponse{}
+	if resp.StatusCode != http.StatusOK {
+		return scheduleResp
+	}
+	getStruct(resp, &scheduleResp)
+	return scheduleResp
+}
+
+func createPotRequest(cfg *configs.Config, token string, pot models.CreatePotRequest) (*http.Client, *http.Request) {
+	return potRequest(cfg, token, http.MethodPost, constants.POTS_PATH, 0, &pot)
+}
+
+func createPot(cfg *configs.Config, token string, pot models.CreatePotRequest) models.PotResponse {
+	c, req := createPotRequest(cfg, token, pot)
+	resp, _ := c.Do(req)
+	potResp := models.PotResponse{}
+	if resp.StatusCode != http.StatusCreated {
+		return potResp
+	}
+	getStruct(resp, &potResp)
+	return potResp
+}
+
+// potRequest calls path of pot potID with body, when body is not nil.
+func potRequest(cfg *configs.Config, token string, method string, path string, potID uint, body interface{}) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	req := &http.Request{
+		Header: header,
+		Method: method,
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   host,
+			Path:   strings.Replace(path, "{id}", fmt.Sprintf("%v", potID), 1),
+		},
+	}
+	if body != nil {
+		bBody, _ := json.Marshal(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(bBody))
+	}
+	return &http.Client{Timeout: time.Second * 5}, req
+}
+
+func contributeToPot(cfg *configs.Config, token string, potID uint, amount uint32) int {
+	c, req := potRequest(cfg, token, http.MethodPost, constants.POT_CONTRIBUTIONS_PATH, potID, &models.ContributePotRequest{Amount: amount})
+	resp, _ := c.Do(req)
+	return resp.StatusCode
+}
+
+func createBulkTransferRequest(cfg *configs.Config, token string, bulkTransfer models.CreateBulkTransferRequest) (*http.Client, *http.Request) {
+	return bulkTransferRequest(cfg, token, http.MethodPost, constants.BULK_TRANSFERS_PATH, "", &bulkTransfer)
+}
+
+// uploadBulkTransferRequest posts the items of csv as the file of a multipart form
+// with fields.
+func uploadBulkTransferRequest(cfg *configs.Config, token string, csv string, fields map[string]string) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for k, v := range fields {
+		writer.WriteField(k, v)
+	}
+	file, _ := writer.CreateFormFile("file", "items.csv")
+	file.Write([]byte(csv))
+	writer.Close()
+
+	header := http.Header{}
+	header.Add("Authorization", token)
+	header.Add("Content-Type", writer.FormDataContentType())
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.BULK_TRANSFERS_PATH,
+			},
+			Body: ioutil.NopCloser(body),
+		}
+}
+
+// bulkTransferRequest calls path of bulk transfer id with body, when body is not nil.
+func bulkTransferRequest(cfg *configs.Config, token string, method string, path string, id string, body interface{}) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	req := &http.Request{
+		Header: header,
+		Method: method,
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   host,
+			Path:   strings.Replace(path, "{id}", id, 1),
+		},
+	}
+	if body != nil {
+		bBody, _ := json.Marshal(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(bBody))
+	}
+	return &http.Client{Timeout: time.Second * 5}, req
+}
+
+func createEscrowRequest(cfg *configs.Config, token string, escrow models.CreateEscrowRequest) (*http.Client, *http.Request) {
+	return escrowRequest(cfg, token, http.MethodPost, constants.ESCROWS_PATH, "", &escrow)
+}
+
+func createEscrow(cfg *configs.Config, token string, escrow models.CreateEscrowRequest) models.EscrowResponse {
+	c, req := createEscrowRequest(cfg, token, escrow)
+	resp, _ := c.Do(req)
+	escrowResp := models.EscrowResponse{}
+	if resp.StatusCode != http.StatusCreated {
+		return escrowResp
+	}
+	getStruct(resp, &escrowResp)
+	return escrowResp
+}
+
+// escrowRequest calls path of escrow refID with body, when body is not nil.
+func escrowRequest(cfg *configs.Config, token string, method string, path string, refID string, body interface{}) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	req := &http.Request{
+		Header: header,
+		Method: method,
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   host,
+			Path:   strings.Replace(path, "{id}", refID, 1),
+		},
+	}
+	if body != nil {
+		bBody, _ := json.Marshal(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(bBody))
+	}
+	return &http.Client{Timeout: time.Second * 5}, req
+}
+
+// merchantRequest calls path of the merchant API with body, when body is not nil.
+func merchantRequest(cfg *configs.Config, token string, method string, path string, body interface{}) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	header := http.Header{}
+	header.Add("Authorization", token)
+	req := &http.Request{
+		Header: header,
+		Method: method,
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   host,
+			Path:   path,
+		},
+	}
+	if body != nil {
+		bBody, _ := json.Marshal(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(bBody))
+	}
+	return &http.Client{Timeout: time.Second * 5}, req
+}
+
+// createPaymentCode returns the payload of a new payment QR code of the merchant in token.
+func createPaymentCode(cfg *configs.Config, token string, paymentCode models.CreatePaymentCodeRequest) string {
+	c, req := merchantRequest(cfg, token, http.MethodPost, constants.MERCHANT_PAYMENT_CODES_PATH, &paymentCode)
+	resp, _ := c.Do(req)
+	if resp.StatusCode != http.StatusCreated {
+		return ""
+	}
+	return resp.Header.Get(constants.PAYMENT_PAYLOAD_HEADER)
+}
+
+func payRequest(cfg *configs.Config, token string, payload string, amount uint32) (*http.Client, *http.Request) {
+	return merchantRequest(cfg, token, http.MethodPost, constants.PAY_PATH, &models.PayRequest{
+		Payload: payload,
+		Amount:  amount,
+	})
+}
+
+func payWithIdempotencyKeyRequest(cfg *configs.Config, token string, payload string, amount uint32, idempotencyKey string) (*http.Client, *http.Request) {
+	client, req := payRequest(cfg, token, payload, amount)
+	req.Header.Add(constants.IDEMPOTENCY_KEY_HEADER, idempotencyKey)
+	return client, req
+}
+
+func topupBalanceWithNoteRequest(cfg *configs.Config, token string, topup models.TopupBalanceRequest) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&topup)
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TOPUP_BALANCE_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
+
+func transferWithNoteRequest(cfg *configs.Config, token string, transfer models.TransferRequest) (*http.Client, *http.Request) {
+	var host string = cfg.APP.HOST + ":" + cfg.APP.PORT
+	body, _ := json.Marshal(&transfer)
+	header := http.Header{}
+	header.Add("Authorization", token)
+	return &http.Client{Timeout: time.Second * 5},
+		&http.Request{
+			Header: header,
+			Method: http.MethodPost,
+			URL: &url.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   constants.TRANSFER_PATH,
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}
+}
@@ -2,12 +2,15 @@ package blackbox
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"time"
 
 	"log"
@@ -15,7 +18,10 @@ import (
 	"github.com/atrariksa/awallet/configs"
 	"github.com/atrariksa/awallet/constants"
 	"github.com/atrariksa/awallet/drivers"
+	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/services"
 )
 
 type Blackbox struct {
@@ -36,17 +42,84 @@ func (b *Blackbox) Run() {
 	b.runTestAPITransferBalance()
 	b.runTestAPITopTransactionPerUser()
 	b.runTestAPITopUsers()
+	b.runTestAPIMutations()
+	b.runTestAPIWithdraw()
+	b.runTestAPIWallets()
+	b.runTestAPILimits()
+	b.runTestAPITokens()
+	b.runTestAPILogin()
+	b.runTestAPITwoFactor()
+	b.runTestAPIAdmin()
+	b.runTestAPIAccountStatus()
+	b.runTestAPIOutbox()
+	b.runTestAPIWebhooks()
+	b.runTestAPIPaymentRequests()
+	b.runTestAPITransferSchedules()
+	b.runTestAPIPots()
+	b.runTestAPIBulkTransfers()
+	b.runTestAPIEscrows()
+	b.runTestAPIMerchants()
+	b.runTestAPIMutationNotes()
 	b.cleanUp()
 }
 
 func (b *Blackbox) cleanUp() {
 	dbWrite := drivers.NewDBClientWrite(b.cfg)
+	// audit_logs is append-only, only a truncate empties it
+	dbWrite.Exec("TRUNCATE TABLE audit_logs")
+	dbWrite.Exec("DELETE FROM payment_codes")
+	dbWrite.Exec("DELETE FROM escrows")
+	dbWrite.Exec("DELETE FROM bulk_transfer_items")
+	dbWrite.Exec("ALTER TABLE bulk_transfer_items AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM bulk_transfers")
+	dbWrite.Exec("DELETE FROM pot_contributions")
+	dbWrite.Exec("ALTER TABLE pot_contributions AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM pot_members")
+	dbWrite.Exec("ALTER TABLE pot_members AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM pots")
+	dbWrite.Exec("ALTER TABLE pots AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM payment_requests")
+	dbWrite.Exec("DELETE FROM transfer_schedule_runs")
+	dbWrite.Exec("ALTER TABLE transfer_schedule_runs AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM transfer_schedules")
+	dbWrite.Exec("ALTER TABLE transfer_schedules AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM webhook_deliveries")
+	dbWrite.Exec("ALTER TABLE webhook_deliveries AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM webhooks")
+	dbWrite.Exec("ALTER TABLE webhooks AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM outbox_events")
+	dbWrite.Exec("ALTER TABLE outbox_events AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM postings")
+	dbWrite.Exec("ALTER TABLE postings AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM journal_entries")
+	dbWrite.Exec("ALTER TABLE journal_entries AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM transfer_challenges")
+	dbWrite.Exec("DELETE FROM recovery_codes")
+	dbWrite.Exec("ALTER TABLE recovery_codes AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM two_factors")
+	dbWrite.Exec("ALTER TABLE two_factors AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM refresh_tokens")
+	dbWrite.Exec("ALTER TABLE refresh_tokens AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM idempotency_keys")
+	dbWrite.Exec("ALTER TABLE idempotency_keys AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM mutation_tags")
+	dbWrite.Exec("ALTER TABLE mutation_tags AUTO_INCREMENT = 1")
 	dbWrite.Exec("DELETE FROM mutations")
 	dbWrite.Exec("ALTER TABLE mutations AUTO_INCREMENT = 1")
+	dbWrite.Exec("DELETE FROM fx_quotes")
+	dbWrite.Exec("DELETE FROM wallets")
+	dbWrite.Exec("ALTER TABLE wallets AUTO_INCREMENT = 1")
 	dbWrite.Exec("DELETE FROM user_total_outgoings")
 	dbWrite.Exec("ALTER TABLE user_total_outgoings AUTO_INCREMENT = 1")
 	dbWrite.Exec("DELETE FROM users")
 	dbWrite.Exec("ALTER TABLE users AUTO_INCREMENT = 1")
+
+	// user ids are reused after the reset above, so their limit counters go too
+	rc := drivers.GetRedisClient(b.cfg)
+	limitKeys, _ := rc.Keys("limit_*").Result()
+	if len(limitKeys) > 0 {
+		rc.Del(limitKeys...)
+	}
 }
 
 func (b *Blackbox) runTestAPICreateUser() {
@@ -109,6 +182,7 @@ func (b *Blackbox) runTestAPICreateUser() {
 
 				body, _ := json.Marshal(&models.CreateUserRequest{
 					Username: username,
+					Password: testPassword,
 				})
 
 				client := &http.Client{Timeout: time.Second * 1}
@@ -239,6 +313,51 @@ func (b *Blackbox) runTestAPITopupBalance() {
 				return nil
 			},
 		},
+		{
+			"BalanceTopup #204 Idempotent Replay: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				idempotencyKey := "key" + fmt.Sprintf("%v", rand.Int())
+				c, req := topupBalanceWithIdempotencyKeyRequest(b.cfg, newUser.Token, uint32(20000), idempotencyKey)
+				c.Do(req)
+				client, req := topupBalanceWithIdempotencyKeyRequest(b.cfg, newUser.Token, uint32(20000), idempotencyKey)
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				if resp.Header.Get(constants.IDEMPOTENT_REPLAYED_HEADER) != "true" {
+					return fmt.Errorf("Got %v, Want %v", resp.Header.Get(constants.IDEMPOTENT_REPLAYED_HEADER), "true")
+				}
+				newUserBalanceResp := getBalance(b.cfg, newUser.Token)
+				if newUserBalanceResp.Balance != 20000 {
+					return fmt.Errorf("Got %v, Want %v", newUserBalanceResp.Balance, 20000)
+				}
+				return nil
+			},
+		},
+		{
+			"BalanceTopup #422: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				idempotencyKey := "key" + fmt.Sprintf("%v", rand.Int())
+				c, req := topupBalanceWithIdempotencyKeyRequest(b.cfg, newUser.Token, uint32(20000), idempotencyKey)
+				c.Do(req)
+				client, req := topupBalanceWithIdempotencyKeyRequest(b.cfg, newUser.Token, uint32(30000), idempotencyKey)
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusUnprocessableEntity {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnprocessableEntity)
+				}
+				return nil
+			},
+		},
 		{
 			"BalanceTopup #400 : ",
 			func() (*http.Client, *http.Request, models.CreateUserResponse) {
@@ -293,7 +412,7 @@ func (b *Blackbox) runTestAPITransferBalance() {
 		expectedMet func(*http.Response, models.CreateUserResponse, models.CreateUserResponse) error
 	}{
 		{
-			"Transfer #204: ",
+			"Transfer #200: ",
 			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
 				rand.Seed(time.Now().UnixNano())
 
@@ -309,13 +428,23 @@ func (b *Blackbox) runTestAPITransferBalance() {
 				return client, req, sender, destUser
 			},
 			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
-				if resp.StatusCode != http.StatusNoContent {
-					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+
+				transferResp := models.TransferResponse{}
+				err := getStruct(resp, &transferResp)
+				if err != nil {
+					return err
+				}
+				if transferResp.RefID == "" || transferResp.Total != uint64(transferResp.Amount)+uint64(transferResp.Fee) {
+					return fmt.Errorf("Got %+v, Want ref id and total of amount and fee", transferResp)
 				}
 
 				senderBalanceResp := getBalance(b.cfg, sender.Token)
-				if senderBalanceResp.Balance != 30000 {
-					return fmt.Errorf("Got %v, Want %v", senderBalanceResp.Balance, 30000)
+				wantSenderBalance := 50000 - int64(transferResp.Total)
+				if senderBalanceResp.Balance != wantSenderBalance {
+					return fmt.Errorf("Got %v, Want %v", senderBalanceResp.Balance, wantSenderBalance)
 				}
 
 				destBalanceResp := getBalance(b.cfg, destUser.Token)
@@ -725,3 +854,3683 @@ func (b *Blackbox) runTestAPITopUsers() {
 		log.Println(v.testName, "PASS")
 	}
 }
+
+func (b *Blackbox) runTestAPIMutations() {
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse)
+		expectedMet func(*http.Response, models.CreateUserResponse, models.CreateUserResponse) error
+	}{
+		{
+			"Mutations #200: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+
+				senderUsername := "sender" + fmt.Sprintf("%v", rand.Int())
+				sender := getNewUser(b.cfg, senderUsername)
+
+				destUsername := "dest" + fmt.Sprintf("%v", rand.Int())
+				destUser := getNewUser(b.cfg, destUsername)
+
+				topupBalance(b.cfg, sender.Token, uint32(100000))
+				for i := 1; i < 4; i++ {
+					transfer(b.cfg, sender.Token, uint32(i*1000), destUsername)
+				}
+
+				query := url.Values{}
+				query.Set("type", string(models.OUTGOING))
+				query.Set("limit", "2")
+				client, req := mutationsRequest(b.cfg, sender.Token, query)
+				return client, req, sender, destUser
+			},
+			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+
+				firstPage := models.MutationHistoryResponse{}
+				err := getStruct(resp, &firstPage)
+				if err != nil {
+					return err
+				}
+				if len(firstPage.Data) != 2 {
+					return fmt.Errorf("Got %v, Want %v", len(firstPage.Data), 2)
+				}
+				if firstPage.NextCursor == "" {
+					return fmt.Errorf("Got empty next cursor")
+				}
+
+				query := url.Values{}
+				query.Set("type", string(models.OUTGOING))
+				query.Set("limit", "2")
+				query.Set("cursor", firstPage.NextCursor)
+				secondPage := getMutations(b.cfg, sender.Token, query)
+				if len(secondPage.Data) != 1 {
+					return fmt.Errorf("Got %v, Want %v", len(secondPage.Data), 1)
+				}
+				if secondPage.NextCursor != "" {
+					return fmt.Errorf("Got %v, Want empty next cursor", secondPage.NextCursor)
+				}
+
+				amounts := []uint32{3000, 2000, 1000}
+				items := append(firstPage.Data, secondPage.Data...)
+				for k, v := range items {
+					if v.Amount != amounts[k] {
+						return fmt.Errorf("Got %v, Want %v", v.Amount, amounts[k])
+					}
+					if v.Counterparty != destUser.UserDetails.Username {
+						return fmt.Errorf("Got %v, Want %v", v.Counterparty, destUser.UserDetails.Username)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			"Mutations #400: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+
+				query := url.Values{}
+				query.Set("cursor", "invalid cursor")
+				client, req := mutationsRequest(b.cfg, newUser.Token, query)
+				return client, req, newUser, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Mutations #400 Cursor With Trailing Bytes: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+
+				cursor := models.MutationCursor{CreatedAt: time.Now(), ID: 1}.Encode()
+				raw, _ := base64.RawURLEncoding.DecodeString(cursor)
+				query := url.Values{}
+				query.Set("cursor", base64.RawURLEncoding.EncodeToString(append(raw, "junk"...)))
+				client, req := mutationsRequest(b.cfg, newUser.Token, query)
+				return client, req, newUser, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Mutations #400 Min Amount Above Max Amount: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+
+				query := url.Values{}
+				query.Set("min_amount", "500")
+				query.Set("max_amount", "100")
+				client, req := mutationsRequest(b.cfg, newUser.Token, query)
+				return client, req, newUser, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Mutations #401: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				client, req := mutationsRequest(b.cfg, "", url.Values{})
+				return client, req, models.CreateUserResponse{}, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req, sender, destUser := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp, sender, destUser)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPIWithdraw() {
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request, models.CreateUserResponse)
+		expectedMet func(*http.Response, models.CreateUserResponse) error
+	}{
+		{
+			"Withdraw #202: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				topupBalance(b.cfg, newUser.Token, uint32(50000))
+				client, req := withdrawRequest(b.cfg, newUser.Token, uint32(20000))
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusAccepted {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusAccepted)
+				}
+				balanceResp := getBalance(b.cfg, newUser.Token)
+				if balanceResp.HeldBalance != 20000 {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.HeldBalance, 20000)
+				}
+				if balanceResp.AvailableBalance != 30000 {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.AvailableBalance, 30000)
+				}
+				return nil
+			},
+		},
+		{
+			"Withdraw #400: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				topupBalance(b.cfg, newUser.Token, uint32(50000))
+				withdraw(b.cfg, newUser.Token, uint32(40000))
+				client, req := withdrawRequest(b.cfg, newUser.Token, uint32(20000))
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Withdraw #403 PIN Required: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				newUser := b.newUserWithPin("123456")
+				topupBalance(b.cfg, newUser.Token, uint32(50000))
+				client, req := withdrawRequest(b.cfg, newUser.Token, uint32(20000))
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				balanceResp := getBalance(b.cfg, newUser.Token)
+				if balanceResp.HeldBalance != 0 {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.HeldBalance, 0)
+				}
+				return nil
+			},
+		},
+		{
+			"WithdrawalCallback SETTLED #204: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				topupBalance(b.cfg, newUser.Token, uint32(50000))
+				withdrawResp := withdraw(b.cfg, newUser.Token, uint32(20000))
+				client, req := withdrawalCallbackRequest(b.cfg, b.cfg.Internal.Secret, withdrawResp.RefID, models.SETTLED)
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				balanceResp := getBalance(b.cfg, newUser.Token)
+				if balanceResp.Balance != 30000 {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.Balance, 30000)
+				}
+				if balanceResp.HeldBalance != 0 {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.HeldBalance, 0)
+				}
+				return nil
+			},
+		},
+		{
+			"WithdrawalCallback FAILED #204: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				topupBalance(b.cfg, newUser.Token, uint32(50000))
+				withdrawResp := withdraw(b.cfg, newUser.Token, uint32(20000))
+				client, req := withdrawalCallbackRequest(b.cfg, b.cfg.Internal.Secret, withdrawResp.RefID, models.FAILED)
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				balanceResp := getBalance(b.cfg, newUser.Token)
+				if balanceResp.Balance != 50000 {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.Balance, 50000)
+				}
+				if balanceResp.HeldBalance != 0 {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.HeldBalance, 0)
+				}
+				return nil
+			},
+		},
+		{
+			"WithdrawalCallback #401: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				client, req := withdrawalCallbackRequest(b.cfg, "invalid secret", "any", models.SETTLED)
+				return client, req, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req, newUser := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp, newUser)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPIWallets() {
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse)
+		expectedMet func(*http.Response, models.CreateUserResponse, models.CreateUserResponse) error
+	}{
+		{
+			"CreateWallet #201: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				client, req := createWalletRequest(b.cfg, newUser.Token, "USD")
+				return client, req, newUser, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, newUser, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				return nil
+			},
+		},
+		{
+			"CreateWallet #400: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				client, req := createWalletRequest(b.cfg, newUser.Token, "JPY")
+				return client, req, newUser, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, newUser, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"CreateWallet #409: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				client, req := createWalletRequest(b.cfg, newUser.Token, b.cfg.Wallet.DefaultCurrency)
+				return client, req, newUser, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, newUser, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer USD #200: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				sender := getNewUser(b.cfg, "sender"+fmt.Sprintf("%v", rand.Int()))
+				destUser := getNewUser(b.cfg, "dest"+fmt.Sprintf("%v", rand.Int()))
+
+				c, req := topupBalanceInCurrencyRequest(b.cfg, sender.Token, 500, "USD")
+				c.Do(req)
+				c, req = createWalletRequest(b.cfg, destUser.Token, "USD")
+				c.Do(req)
+
+				client, req := transferInCurrencyRequest(b.cfg, sender.Token, 200, "USD", destUser.UserDetails.Username)
+				return client, req, sender, destUser
+			},
+			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				c, req := readBalanceRequest(b.cfg, destUser.Token)
+				req.URL.RawQuery = "currency=USD"
+				balanceResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				destBalance := models.ReadBalanceResponse{}
+				err = getStruct(balanceResp, &destBalance)
+				if err != nil {
+					return err
+				}
+				if destBalance.Balance != 200 {
+					return fmt.Errorf("Got %v, Want %v", destBalance.Balance, 200)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer USD to IDR #200: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				sender := getNewUser(b.cfg, "sender"+fmt.Sprintf("%v", rand.Int()))
+				destUser := getNewUser(b.cfg, "dest"+fmt.Sprintf("%v", rand.Int()))
+
+				c, req := topupBalanceInCurrencyRequest(b.cfg, sender.Token, 500, "USD")
+				c.Do(req)
+				quote := getFXQuote(b.cfg, sender.Token, "USD", "IDR")
+
+				client, req := transferWithQuoteRequest(b.cfg, sender.Token, 10, "USD", quote.QuoteID, destUser.UserDetails.Username)
+				return client, req, sender, destUser
+			},
+			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				destBalance := getBalance(b.cfg, destUser.Token)
+				if destBalance.Balance != 155000 {
+					return fmt.Errorf("Got %v, Want %v", destBalance.Balance, 155000)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer USD #400 Currency Mismatch: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				sender := getNewUser(b.cfg, "sender"+fmt.Sprintf("%v", rand.Int()))
+				destUser := getNewUser(b.cfg, "dest"+fmt.Sprintf("%v", rand.Int()))
+
+				c, req := topupBalanceInCurrencyRequest(b.cfg, sender.Token, 500, "USD")
+				c.Do(req)
+
+				client, req := transferInCurrencyRequest(b.cfg, sender.Token, 200, "USD", destUser.UserDetails.Username)
+				return client, req, sender, destUser
+			},
+			func(resp *http.Response, sender, destUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req, sender, destUser := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp, sender, destUser)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPILimits() {
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request, models.CreateUserResponse)
+		expectedMet func(*http.Response, models.CreateUserResponse) error
+	}{
+		{
+			"Limits #200: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				newUser := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, newUser.Token, uint32(50000))
+				client, req := limitsRequest(b.cfg, newUser.Token)
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				limitsResp := models.LimitsResponse{}
+				err := getStruct(resp, &limitsResp)
+				if err != nil {
+					return err
+				}
+				for _, v := range limitsResp.Limits {
+					if v.Type == models.TOPUP_VALUE && v.Used != 50000 {
+						return fmt.Errorf("Got %v, Want %v", v.Used, 50000)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			"BalanceTopup #429 Daily Limit: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				newUser := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				limits := getLimits(b.cfg, newUser.Token)
+				for _, v := range limits.Limits {
+					if v.Type != models.TOPUP_VALUE || v.Period != models.DAILY {
+						continue
+					}
+					for remaining := v.Remaining; remaining >= 9999999; remaining -= 9999999 {
+						topupBalance(b.cfg, newUser.Token, uint32(9999999))
+					}
+				}
+				client, req := topupBalanceRequest(b.cfg, newUser.Token, uint32(9999999))
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusTooManyRequests {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusTooManyRequests)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req, newUser := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp, newUser)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPITokens() {
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request, models.CreateUserResponse)
+		expectedMet func(*http.Response, models.CreateUserResponse) error
+	}{
+		{
+			"TokenRefresh #200: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				newUser := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				client, req := refreshTokenRequest(b.cfg, newUser.RefreshToken)
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				tokenResp := models.TokenResponse{}
+				err := getStruct(resp, &tokenResp)
+				if err != nil {
+					return err
+				}
+				if tokenResp.RefreshToken == newUser.RefreshToken {
+					return fmt.Errorf("Got same refresh token, Want rotated one")
+				}
+				c, req := readBalanceRequest(b.cfg, tokenResp.Token)
+				balanceResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				if balanceResp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.StatusCode, http.StatusOK)
+				}
+				return nil
+			},
+		},
+		{
+			"TokenRefresh #401 Reused: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				newUser := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				rotated := refreshTokens(b.cfg, newUser.RefreshToken)
+				newUser.Token = rotated.Token
+				client, req := refreshTokenRequest(b.cfg, newUser.RefreshToken)
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnauthorized)
+				}
+				c, req := readBalanceRequest(b.cfg, newUser.Token)
+				balanceResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				if balanceResp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.StatusCode, http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+		{
+			"Logout #204: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				rand.Seed(time.Now().UnixNano())
+				newUser := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				client, req := logoutRequest(b.cfg, newUser.Token)
+				return client, req, newUser
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				c, req := readBalanceRequest(b.cfg, newUser.Token)
+				balanceResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				if balanceResp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.StatusCode, http.StatusUnauthorized)
+				}
+				c, req = refreshTokenRequest(b.cfg, newUser.RefreshToken)
+				refreshResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				if refreshResp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", refreshResp.StatusCode, http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+		{
+			"JWKS #200: ",
+			func() (*http.Client, *http.Request, models.CreateUserResponse) {
+				client, req := jwksRequest(b.cfg)
+				return client, req, models.CreateUserResponse{}
+			},
+			func(resp *http.Response, newUser models.CreateUserResponse) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				jwks := models.JWKSet{}
+				err := getStruct(resp, &jwks)
+				if err != nil {
+					return err
+				}
+				if b.cfg.JWT.Algorithm != "" && b.cfg.JWT.Algorithm != "HS256" && len(jwks.Keys) == 0 {
+					return fmt.Errorf("Got no keys, Want keys for %v", b.cfg.JWT.Algorithm)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req, newUser := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp, newUser)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPILogin() {
+
+	var passwordUser models.CreateUserResponse
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Login #200: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				getNewUser(b.cfg, username)
+				return loginRequest(b.cfg, username, testPassword)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				tokenResp := models.TokenResponse{}
+				err := getStruct(resp, &tokenResp)
+				if err != nil {
+					return err
+				}
+				c, req := readBalanceRequest(b.cfg, tokenResp.Token)
+				balanceResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				if balanceResp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", balanceResp.StatusCode, http.StatusOK)
+				}
+				return nil
+			},
+		},
+		{
+			"Login #401: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				getNewUser(b.cfg, username)
+				return loginRequest(b.cfg, username, "wrong-password")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+		{
+			"Login #401 Unknown User: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				return loginRequest(b.cfg, "unknown"+fmt.Sprintf("%v", rand.Int()), testPassword)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+		{
+			"Login #423: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				getNewUser(b.cfg, username)
+				for i := 0; i < b.cfg.Auth.MaxFailedAttempts; i++ {
+					c, req := loginRequest(b.cfg, username, "wrong-password")
+					c.Do(req)
+				}
+				// the right password is refused too while locked
+				return loginRequest(b.cfg, username, testPassword)
+			},
+			func(resp *http.Response) error {
+				if b.cfg.Auth.MaxFailedAttempts == 0 {
+					return nil
+				}
+				if resp.StatusCode != http.StatusLocked {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusLocked)
+				}
+				return nil
+			},
+		},
+		{
+			"Credentials #204: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				return credentialsRequest(b.cfg, newUser.Token, models.UpdateCredentialsRequest{
+					CurrentPassword: testPassword,
+					Pin:             "123456",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				return nil
+			},
+		},
+		{
+			"Credentials #204 New Password Revokes Refresh Tokens: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				passwordUser = getNewUser(b.cfg, username)
+				return credentialsRequest(b.cfg, passwordUser.Token, models.UpdateCredentialsRequest{
+					CurrentPassword: testPassword,
+					Password:        "new-password",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				c, req := refreshTokenRequest(b.cfg, passwordUser.RefreshToken)
+				refreshResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				if refreshResp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v refreshing, Want %v", refreshResp.StatusCode, http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+		{
+			"Credentials #400 Password Over 72 Bytes: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				return credentialsRequest(b.cfg, newUser.Token, models.UpdateCredentialsRequest{
+					CurrentPassword: testPassword,
+					// 40 characters, 80 bytes
+					Password: strings.Repeat("é", 40),
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Credentials #401: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username := "any" + fmt.Sprintf("%v", rand.Int())
+				newUser := getNewUser(b.cfg, username)
+				return credentialsRequest(b.cfg, newUser.Token, models.UpdateCredentialsRequest{
+					CurrentPassword: "wrong-password",
+					Password:        "new-password",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusUnauthorized {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer #403 PIN Required: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				sender := b.newUserWithPin("123456")
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, sender.Token, 10000)
+				return transferRequest(b.cfg, sender.Token, 1000, recipient.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer #403 Invalid PIN: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				sender := b.newUserWithPin("123456")
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, sender.Token, 10000)
+				return transferWithPinRequest(b.cfg, sender.Token, 1000, recipient.UserDetails.Username, "654321")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer #200 With PIN: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				sender := b.newUserWithPin("123456")
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, sender.Token, 10000)
+				return transferWithPinRequest(b.cfg, sender.Token, 1000, recipient.UserDetails.Username, "123456")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) newUserWithPin(pin string) models.CreateUserResponse {
+	c, req := createUserWithPinRequest(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()), pin)
+	resp, err := c.Do(req)
+	createUser := models.CreateUserResponse{}
+	if err != nil || resp.StatusCode != http.StatusCreated {
+		return createUser
+	}
+	getStruct(resp, &createUser)
+	return createUser
+}
+
+func (b *Blackbox) runTestAPITwoFactor() {
+
+	// a user funded with enough to transfer just above the threshold
+	newFundedUser := func() models.CreateUserResponse {
+		rand.Seed(time.Now().UnixNano())
+		user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+		topupBalance(b.cfg, user.Token, 9999999)
+		topupBalance(b.cfg, user.Token, 9999999)
+		return user
+	}
+	aboveThreshold := uint32(b.cfg.TwoFactor.TransferThreshold + 1)
+
+	var tests = []struct {
+		testName    string
+		skip        bool
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"TwoFactorEnroll #201: ",
+			false,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return twoFactorEnrollRequest(b.cfg, user.Token)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				enrollResp := models.TwoFactorEnrollResponse{}
+				err := getStruct(resp, &enrollResp)
+				if err != nil {
+					return err
+				}
+				if enrollResp.Secret == "" || enrollResp.OTPAuthURI == "" {
+					return fmt.Errorf("Got empty secret or uri, Want both")
+				}
+				return nil
+			},
+		},
+		{
+			"TwoFactorActivate #200: ",
+			false,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				enrollResp := enrollTwoFactor(b.cfg, user.Token)
+				code, _ := models.TOTPCode(enrollResp.Secret, models.TOTPStep(time.Now()))
+				return twoFactorActivateRequest(b.cfg, user.Token, code)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				recoveryCodesResp := models.RecoveryCodesResponse{}
+				err := getStruct(resp, &recoveryCodesResp)
+				if err != nil {
+					return err
+				}
+				if len(recoveryCodesResp.RecoveryCodes) != models.RecoveryCodeCount {
+					return fmt.Errorf("Got %v recovery codes, Want %v", len(recoveryCodesResp.RecoveryCodes), models.RecoveryCodeCount)
+				}
+				return nil
+			},
+		},
+		{
+			"TwoFactorActivate #403: ",
+			false,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				enrollTwoFactor(b.cfg, user.Token)
+				return twoFactorActivateRequest(b.cfg, user.Token, "000000")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer #403 Two-Factor Required: ",
+			b.cfg.TwoFactor.TransferThreshold == 0,
+			func() (*http.Client, *http.Request) {
+				sender := newFundedUser()
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return transferRequest(b.cfg, sender.Token, aboveThreshold, recipient.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer #202 Challenge: ",
+			b.cfg.TwoFactor.TransferThreshold == 0,
+			func() (*http.Client, *http.Request) {
+				sender := newFundedUser()
+				activateTwoFactor(b.cfg, sender.Token)
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return transferRequest(b.cfg, sender.Token, aboveThreshold, recipient.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusAccepted {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusAccepted)
+				}
+				challengeResp := models.TransferChallengeResponse{}
+				err := getStruct(resp, &challengeResp)
+				if err != nil {
+					return err
+				}
+				if challengeResp.ChallengeID == "" {
+					return fmt.Errorf("Got empty challenge id, Want one")
+				}
+				return nil
+			},
+		},
+		{
+			"TransferConfirm #403: ",
+			b.cfg.TwoFactor.TransferThreshold == 0,
+			func() (*http.Client, *http.Request) {
+				sender := newFundedUser()
+				activateTwoFactor(b.cfg, sender.Token)
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				challenge := transferChallenge(b.cfg, sender.Token, aboveThreshold, recipient.UserDetails.Username)
+				return transferConfirmRequest(b.cfg, sender.Token, challenge.ChallengeID, "00000-00000")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"TransferConfirm #200 Recovery Code: ",
+			b.cfg.TwoFactor.TransferThreshold == 0,
+			func() (*http.Client, *http.Request) {
+				sender := newFundedUser()
+				recoveryCodes := activateTwoFactor(b.cfg, sender.Token)
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				challenge := transferChallenge(b.cfg, sender.Token, aboveThreshold, recipient.UserDetails.Username)
+				code := ""
+				if len(recoveryCodes.RecoveryCodes) > 0 {
+					code = recoveryCodes.RecoveryCodes[0]
+				}
+				return transferConfirmRequest(b.cfg, sender.Token, challenge.ChallengeID, code)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				transferResp := models.TransferResponse{}
+				err := getStruct(resp, &transferResp)
+				if err != nil {
+					return err
+				}
+				if transferResp.Amount != aboveThreshold {
+					return fmt.Errorf("Got amount %v, Want %v", transferResp.Amount, aboveThreshold)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		if v.skip {
+			log.Println(v.testName, "SKIPPED")
+			continue
+		}
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+// newAdmin creates a user holding role and logs it in again, so the token carries the role.
+func (b *Blackbox) newAdmin(role models.Role) string {
+	rand.Seed(time.Now().UnixNano())
+	username := "admin" + fmt.Sprintf("%v", rand.Int())
+	getNewUser(b.cfg, username)
+	dbWrite := drivers.NewDBClientWrite(b.cfg)
+	dbWrite.Exec("UPDATE users SET role = ? WHERE username = ?", role, username)
+	drivers.GetRedisClient(b.cfg).Del(username)
+
+	c, req := loginRequest(b.cfg, username, testPassword)
+	resp, err := c.Do(req)
+	tokenResp := models.TokenResponse{}
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	getStruct(resp, &tokenResp)
+	return tokenResp.Token
+}
+
+func (b *Blackbox) runTestAPIAdmin() {
+
+	var frozenUser models.CreateUserResponse
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"AdminUser #403: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return adminUserRequest(b.cfg, user.Token, user.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminUser #200: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, user.Token, 10000)
+				return adminUserRequest(b.cfg, b.newAdmin(models.VIEWER), user.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				userResp := models.AdminUserResponse{}
+				err := getStruct(resp, &userResp)
+				if err != nil {
+					return err
+				}
+				if len(userResp.Wallets) == 0 || userResp.Wallets[0].Balance != 10000 {
+					return fmt.Errorf("Got wallets %v, Want a balance of %v", userResp.Wallets, 10000)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminUser #404: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				return adminUserRequest(b.cfg, b.newAdmin(models.VIEWER), "unknown"+fmt.Sprintf("%v", rand.Int()))
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminFreeze #403 Viewer: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return adminFreezeRequest(b.cfg, b.newAdmin(models.VIEWER), user.UserDetails.Username, "", "suspicious activity")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminFreeze #400: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return adminFreezeRequest(b.cfg, b.newAdmin(models.SUPPORT), user.UserDetails.Username, "", "")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminFreeze #204: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, user.Token, 10000)
+				frozenUser = user
+				return adminFreezeRequest(b.cfg, b.newAdmin(models.SUPPORT), user.UserDetails.Username, "", "suspicious activity")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				// a frozen account can not send money
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				c, req := transferRequest(b.cfg, frozenUser.Token, 1000, recipient.UserDetails.Username)
+				transferResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				if transferResp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Transfer got %v, Want %v", transferResp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminFreeze #204 Finance: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return adminFreezeRequest(b.cfg, b.newAdmin(models.FINANCE), user.UserDetails.Username, "", "chargeback")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminAdjustment #201: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return adminAdjustmentRequest(b.cfg, b.newAdmin(models.FINANCE), user.UserDetails.Username, models.AdminAdjustmentRequest{
+					Amount:    5000,
+					Direction: models.CREDIT,
+					Reason:    "goodwill credit",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				adjustmentResp := models.AdjustmentResponse{}
+				err := getStruct(resp, &adjustmentResp)
+				if err != nil {
+					return err
+				}
+				if adjustmentResp.MutationType != models.ADJUSTMENT_CREDIT {
+					return fmt.Errorf("Got %v, Want %v", adjustmentResp.MutationType, models.ADJUSTMENT_CREDIT)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminAdjustment #403 Support: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return adminAdjustmentRequest(b.cfg, b.newAdmin(models.SUPPORT), user.UserDetails.Username, models.AdminAdjustmentRequest{
+					Amount:    5000,
+					Direction: models.CREDIT,
+					Reason:    "goodwill credit",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPIAccountStatus() {
+
+	// a user with some balance, frozen by support with scope
+	newFrozenUser := func(scope string) models.CreateUserResponse {
+		rand.Seed(time.Now().UnixNano())
+		user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+		topupBalance(b.cfg, user.Token, 10000)
+		c, req := adminFreezeRequest(b.cfg, b.newAdmin(models.SUPPORT), user.UserDetails.Username, scope, "suspicious activity")
+		c.Do(req)
+		return user
+	}
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Transfer #200 To Frozen Debit: ",
+			func() (*http.Client, *http.Request) {
+				recipient := newFrozenUser("debit")
+				sender := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, sender.Token, 10000)
+				return transferRequest(b.cfg, sender.Token, 1000, recipient.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer #403 From Frozen Debit: ",
+			func() (*http.Client, *http.Request) {
+				sender := newFrozenUser("debit")
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return transferRequest(b.cfg, sender.Token, 1000, recipient.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer #422 To Frozen All: ",
+			func() (*http.Client, *http.Request) {
+				recipient := newFrozenUser("all")
+				sender := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, sender.Token, 10000)
+				return transferRequest(b.cfg, sender.Token, 1000, recipient.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusUnprocessableEntity {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnprocessableEntity)
+				}
+				return nil
+			},
+		},
+		{
+			"Topup #403 Frozen All: ",
+			func() (*http.Client, *http.Request) {
+				user := newFrozenUser("all")
+				return topupBalanceRequest(b.cfg, user.Token, 1000)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminClose #409 Balance Not Zero: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, user.Token, 10000)
+				return adminCloseRequest(b.cfg, b.newAdmin(models.SUPPORT), user.UserDetails.Username, false)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminClose #200 Payout: ",
+			func() (*http.Client, *http.Request) {
+				user := newFrozenUser("all")
+				return adminCloseRequest(b.cfg, b.newAdmin(models.SUPPORT), user.UserDetails.Username, true)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				closureResp := models.AccountClosureResponse{}
+				err := getStruct(resp, &closureResp)
+				if err != nil {
+					return err
+				}
+				if len(closureResp.Payouts) != 1 || closureResp.Payouts[0].Amount != 10000 {
+					return fmt.Errorf("Got payouts %v, Want one of %v", closureResp.Payouts, 10000)
+				}
+				return nil
+			},
+		},
+		{
+			"AdminUnfreeze #409 Closed: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				admin := b.newAdmin(models.SUPPORT)
+				c, req := adminCloseRequest(b.cfg, admin, user.UserDetails.Username, false)
+				c.Do(req)
+				return adminUnfreezeRequest(b.cfg, admin, user.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+// outboxEventTypes lists the types of the outbox events keyed on username, oldest first.
+func (b *Blackbox) outboxEventTypes(username string) (eventTypes []models.EventType) {
+	dbWrite := drivers.NewDBClientWrite(b.cfg)
+	dbWrite.Raw(
+		"SELECT o.event_type FROM outbox_events o JOIN users u ON u.id = o.user_id WHERE u.username = ? ORDER BY o.id",
+		username,
+	).Scan(&eventTypes)
+	return
+}
+
+func (b *Blackbox) runTestAPIOutbox() {
+
+	expectEvents := func(username string, want ...models.EventType) error {
+		got := b.outboxEventTypes(username)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return fmt.Errorf("Got events %v, Want %v", got, want)
+		}
+		return nil
+	}
+
+	var username string
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Outbox UserCreated: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username = "any" + fmt.Sprintf("%v", rand.Int())
+				return createUserRequest(b.cfg, username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				return expectEvents(username, models.USER_CREATED)
+			},
+		},
+		{
+			"Outbox BalanceToppedUp: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username = "any" + fmt.Sprintf("%v", rand.Int())
+				user := getNewUser(b.cfg, username)
+				return topupBalanceRequest(b.cfg, user.Token, 10000)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				return expectEvents(username, models.USER_CREATED, models.BALANCE_TOPPED_UP)
+			},
+		},
+		{
+			"Outbox TransferCompleted: ",
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				username = "any" + fmt.Sprintf("%v", rand.Int())
+				sender := getNewUser(b.cfg, username)
+				recipient := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, sender.Token, 10000)
+				return transferRequest(b.cfg, sender.Token, 1000, recipient.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				return expectEvents(username, models.USER_CREATED, models.BALANCE_TOPPED_UP, models.TRANSFER_COMPLETED)
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+// webhookReceiver records the requests posted to it, path /fail answers 500.
+type webhookReceiver struct {
+	*httptest.Server
+	received chan webhookRequest
+}
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver() *webhookReceiver {
+	wr := &webhookReceiver{received: make(chan webhookRequest, 16)}
+	wr.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		wr.received <- webhookRequest{header: r.Header, body: body}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return wr
+}
+
+// waitFor polls done until it reports true or timeout passes.
+func waitFor(timeout time.Duration, done func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if done() {
+			return true
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return false
+}
+
+func (b *Blackbox) runTestAPIWebhooks() {
+
+	receiver := newWebhookReceiver()
+	defer receiver.Close()
+	// deliveries need the dispatcher of the server
+	wait := 10 * b.cfg.Webhook.PollInterval
+	if wait < 5*time.Second {
+		wait = 5 * time.Second
+	}
+
+	var user models.CreateUserResponse
+	var webhook models.WebhookResponse
+
+	var tests = []struct {
+		testName    string
+		skip        bool
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Webhook #201: ",
+			false,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user = getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return createWebhookRequest(b.cfg, user.Token, receiver.URL+"/ok", models.WEBHOOK_TOPUP)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				webhookResp := models.WebhookResponse{}
+				err := getStruct(resp, &webhookResp)
+				if err != nil {
+					return err
+				}
+				if webhookResp.Secret == "" {
+					return fmt.Errorf("Got empty secret, Want one")
+				}
+				return nil
+			},
+		},
+		{
+			"Webhook #400 Invalid Event: ",
+			false,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return createWebhookRequest(b.cfg, user.Token, receiver.URL+"/ok", models.WebhookEvent("transfer.outgoing"))
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Webhook Delivery Signed: ",
+			!b.cfg.Webhook.Dispatcher,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user = getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				sender := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				topupBalance(b.cfg, sender.Token, 10000)
+				webhook = createWebhook(b.cfg, user.Token, receiver.URL+"/ok", models.WEBHOOK_TRANSFER_INCOMING)
+				return transferRequest(b.cfg, sender.Token, 1000, user.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				select {
+				case req := <-receiver.received:
+					if req.header.Get(models.WebhookEventHeader) != string(models.WEBHOOK_TRANSFER_INCOMING) {
+						return fmt.Errorf("Got event %v, Want %v", req.header.Get(models.WebhookEventHeader), models.WEBHOOK_TRANSFER_INCOMING)
+					}
+					return models.VerifyWebhookSignature(
+						webhook.Secret,
+						req.header.Get(models.WebhookTimestampHeader),
+						req.body,
+						req.header.Get(models.WebhookSignatureHeader),
+						5*time.Minute,
+					)
+				case <-time.After(wait):
+					return fmt.Errorf("Got no delivery within %v", wait)
+				}
+			},
+		},
+		{
+			"WebhookReplay #202: ",
+			!b.cfg.Webhook.Dispatcher,
+			func() (*http.Client, *http.Request) {
+				deliveryID := ""
+				waitFor(wait, func() bool {
+					deliveries := getWebhookDeliveries(b.cfg, user.Token, models.DELIVERY_DELIVERED)
+					if len(deliveries.Deliveries) > 0 {
+						deliveryID = deliveries.Deliveries[0].ID
+					}
+					return deliveryID != ""
+				})
+				return webhookReplayRequest(b.cfg, user.Token, deliveryID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusAccepted {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusAccepted)
+				}
+				select {
+				case <-receiver.received:
+					return nil
+				case <-time.After(wait):
+					return fmt.Errorf("Got no replayed delivery within %v", wait)
+				}
+			},
+		},
+		{
+			"WebhookReplay #404: ",
+			false,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user := getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				return webhookReplayRequest(b.cfg, user.Token, "unknown")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"WebhookDeliveries #200 Retried: ",
+			!b.cfg.Webhook.Dispatcher,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user = getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				createWebhook(b.cfg, user.Token, receiver.URL+"/fail", models.WEBHOOK_TOPUP)
+				topupBalance(b.cfg, user.Token, 10000)
+				waitFor(wait, func() bool {
+					deliveries := getWebhookDeliveries(b.cfg, user.Token, "")
+					return len(deliveries.Deliveries) > 0 && deliveries.Deliveries[0].Attempts > 0
+				})
+				return webhookDeliveriesRequest(b.cfg, user.Token, "")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				deliveriesResp := models.WebhookDeliveriesResponse{}
+				err := getStruct(resp, &deliveriesResp)
+				if err != nil {
+					return err
+				}
+				if len(deliveriesResp.Deliveries) != 1 {
+					return fmt.Errorf("Got %v deliveries, Want 1", len(deliveriesResp.Deliveries))
+				}
+				delivery := deliveriesResp.Deliveries[0]
+				if delivery.LastStatusCode != http.StatusInternalServerError || delivery.Status == models.DELIVERY_DELIVERED {
+					return fmt.Errorf("Got status %v (%v), Want a failed attempt", delivery.Status, delivery.LastStatusCode)
+				}
+				return nil
+			},
+		},
+		{
+			"Webhook Delete #204 With Deliveries: ",
+			false,
+			func() (*http.Client, *http.Request) {
+				rand.Seed(time.Now().UnixNano())
+				user = getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+				webhook = createWebhook(b.cfg, user.Token, receiver.URL+"/fail", models.WEBHOOK_TOPUP)
+				topupBalance(b.cfg, user.Token, 10000)
+				return deleteWebhookRequest(b.cfg, user.Token, webhook.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				deliveries := getWebhookDeliveries(b.cfg, user.Token, models.DELIVERY_DEAD)
+				if len(deliveries.Deliveries) != 1 {
+					return fmt.Errorf("Got %v dead deliveries, Want 1", len(deliveries.Deliveries))
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		if v.skip {
+			log.Println(v.testName, "SKIPPED")
+			continue
+		}
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) paymentRequestStatus(id string) (status models.PaymentRequestStatus) {
+	dbWrite := drivers.NewDBClientWrite(b.cfg)
+	dbWrite.Raw("SELECT status FROM payment_requests WHERE id = ?", id).Scan(&status)
+	return
+}
+
+func (b *Blackbox) runTestAPIPaymentRequests() {
+
+	newPair := func() (requester models.CreateUserResponse, payer models.CreateUserResponse) {
+		rand.Seed(time.Now().UnixNano())
+		requester = getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+		payer = getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+		return
+	}
+	expectStatus := func(id string, want models.PaymentRequestStatus) error {
+		got := b.paymentRequestStatus(id)
+		if got != want {
+			return fmt.Errorf("Got status %v, Want %v", got, want)
+		}
+		return nil
+	}
+
+	var requester, payer models.CreateUserResponse
+	var paymentRequest models.PaymentRequestResponse
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Payment Request #201: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				return createPaymentRequestRequest(b.cfg, requester.Token, payer.UserDetails.Username, 1000, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				paymentRequestResp := models.PaymentRequestResponse{}
+				err := getStruct(resp, &paymentRequestResp)
+				if err != nil {
+					return err
+				}
+				if paymentRequestResp.Status != models.PAYMENT_REQUEST_PENDING {
+					return fmt.Errorf("Got status %v, Want %v", paymentRequestResp.Status, models.PAYMENT_REQUEST_PENDING)
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request #404 Payer Not Found: ",
+			func() (*http.Client, *http.Request) {
+				requester, _ = newPair()
+				return createPaymentRequestRequest(b.cfg, requester.Token, "nobody"+fmt.Sprintf("%v", rand.Int()), 1000, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request #400 Expiry In The Past: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				expiresAt := time.Now().Add(-time.Minute)
+				return createPaymentRequestRequest(b.cfg, requester.Token, payer.UserDetails.Username, 1000, &expiresAt)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request Incoming #200: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				paymentRequest = createPaymentRequest(b.cfg, requester.Token, payer.UserDetails.Username, 1000)
+				return incomingPaymentRequestsRequest(b.cfg, payer.Token, models.PAYMENT_REQUEST_PENDING)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				paymentRequestsResp := models.PaymentRequestsResponse{}
+				err := getStruct(resp, &paymentRequestsResp)
+				if err != nil {
+					return err
+				}
+				if len(paymentRequestsResp.PaymentRequests) != 1 ||
+					paymentRequestsResp.PaymentRequests[0].ID != paymentRequest.ID ||
+					paymentRequestsResp.PaymentRequests[0].Requester != requester.UserDetails.Username {
+					return fmt.Errorf("Got %+v, Want request %v", paymentRequestsResp.PaymentRequests, paymentRequest.ID)
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request Outgoing #200 Paged: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				for i := 0; i < 3; i++ {
+					createPaymentRequest(b.cfg, requester.Token, payer.UserDetails.Username, 1000)
+				}
+				return paymentRequestsRequest(b.cfg, requester.Token, constants.PAYMENT_REQUESTS_OUTGOING_PATH, "", 2, "")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				firstPage := models.PaymentRequestsResponse{}
+				err := getStruct(resp, &firstPage)
+				if err != nil {
+					return err
+				}
+				if len(firstPage.PaymentRequests) != 2 || firstPage.NextCursor == "" {
+					return fmt.Errorf("Got %v requests and cursor %q, Want 2 and a cursor", len(firstPage.PaymentRequests), firstPage.NextCursor)
+				}
+				if firstPage.PaymentRequests[0].Payer != payer.UserDetails.Username {
+					return fmt.Errorf("Got payer %v, Want %v", firstPage.PaymentRequests[0].Payer, payer.UserDetails.Username)
+				}
+				secondPage := getPaymentRequests(b.cfg, requester.Token, constants.PAYMENT_REQUESTS_OUTGOING_PATH, 2, firstPage.NextCursor)
+				if len(secondPage.PaymentRequests) != 1 || secondPage.NextCursor != "" {
+					return fmt.Errorf("Got %v requests and cursor %q, Want 1 and no cursor", len(secondPage.PaymentRequests), secondPage.NextCursor)
+				}
+				for _, v := range firstPage.PaymentRequests {
+					if v.ID == secondPage.PaymentRequests[0].ID {
+						return fmt.Errorf("Got request %v on both pages", v.ID)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request Incoming #400 Invalid Cursor: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				return paymentRequestsRequest(b.cfg, payer.Token, constants.PAYMENT_REQUESTS_INCOMING_PATH, "", 0, "not-a-cursor")
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request Accept #200: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				topupBalance(b.cfg, payer.Token, 5000)
+				paymentRequest = createPaymentRequest(b.cfg, requester.Token, payer.UserDetails.Username, 1000)
+				return paymentRequestActionRequest(b.cfg, payer.Token, constants.PAYMENT_REQUEST_ACCEPT_PATH, paymentRequest.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				balance := getBalance(b.cfg, requester.Token)
+				if balance.Balance != 1000 {
+					return fmt.Errorf("Got requester balance %v, Want %v", balance.Balance, 1000)
+				}
+				return expectStatus(paymentRequest.ID, models.PAYMENT_REQUEST_PAID)
+			},
+		},
+		{
+			"Payment Request Accept #409 Already Paid: ",
+			func() (*http.Client, *http.Request) {
+				return paymentRequestActionRequest(b.cfg, payer.Token, constants.PAYMENT_REQUEST_ACCEPT_PATH, paymentRequest.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				balance := getBalance(b.cfg, payer.Token)
+				if balance.Balance != 4000 {
+					return fmt.Errorf("Got payer balance %v, Want %v", balance.Balance, 4000)
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request Accept #400 Insufficient Balance: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				paymentRequest = createPaymentRequest(b.cfg, requester.Token, payer.UserDetails.Username, 1000)
+				return paymentRequestActionRequest(b.cfg, payer.Token, constants.PAYMENT_REQUEST_ACCEPT_PATH, paymentRequest.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				// the failed transfer leaves the request pending
+				return expectStatus(paymentRequest.ID, models.PAYMENT_REQUEST_PENDING)
+			},
+		},
+		{
+			"Payment Request Accept #404 Not The Payer: ",
+			func() (*http.Client, *http.Request) {
+				return paymentRequestActionRequest(b.cfg, requester.Token, constants.PAYMENT_REQUEST_ACCEPT_PATH, paymentRequest.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request Decline #204: ",
+			func() (*http.Client, *http.Request) {
+				return paymentRequestActionRequest(b.cfg, payer.Token, constants.PAYMENT_REQUEST_DECLINE_PATH, paymentRequest.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				return expectStatus(paymentRequest.ID, models.PAYMENT_REQUEST_DECLINED)
+			},
+		},
+		{
+			"Payment Request Cancel #409 Declined: ",
+			func() (*http.Client, *http.Request) {
+				return paymentRequestActionRequest(b.cfg, requester.Token, constants.PAYMENT_REQUEST_CANCEL_PATH, paymentRequest.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return nil
+			},
+		},
+		{
+			"Payment Request Cancel #204: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				paymentRequest = createPaymentRequest(b.cfg, requester.Token, payer.UserDetails.Username, 1000)
+				return paymentRequestActionRequest(b.cfg, requester.Token, constants.PAYMENT_REQUEST_CANCEL_PATH, paymentRequest.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				return expectStatus(paymentRequest.ID, models.PAYMENT_REQUEST_CANCELLED)
+			},
+		},
+		{
+			"Payment Request Sweep Expired: ",
+			func() (*http.Client, *http.Request) {
+				requester, payer = newPair()
+				topupBalance(b.cfg, payer.Token, 5000)
+				paymentRequest = createPaymentRequest(b.cfg, requester.Token, payer.UserDetails.Username, 1000)
+
+				dbWrite := drivers.NewDBClientWrite(b.cfg)
+				dbWrite.Exec("UPDATE payment_requests SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), paymentRequest.ID)
+				sweeper := services.PaymentRequestSweeper{
+					PaymentRequestWrite: &repos.PaymentRequestRepoWrite{DBWrite: dbWrite},
+				}
+				sweeper.SweepOnce()
+				return paymentRequestActionRequest(b.cfg, payer.Token, constants.PAYMENT_REQUEST_ACCEPT_PATH, paymentRequest.ID)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return expectStatus(paymentRequest.ID, models.PAYMENT_REQUEST_EXPIRED)
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+// newTransferScheduler returns a scheduler running in the blackbox process, no other
+// scheduler may lead meanwhile.
+func (b *Blackbox) newTransferScheduler() *services.TransferScheduler {
+	rc := drivers.GetRedisClient(b.cfg)
+	cacheRepo := repos.NewCache(b.cfg, rc)
+	dbRead := drivers.NewDBClientRead(b.cfg)
+	dbWrite := drivers.NewDBClientWrite(b.cfg)
+
+	rand.Seed(time.Now().UnixNano())
+	return &services.TransferScheduler{
+		TransferScheduleRepo: &repos.TransferScheduleRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		UserBalanceService: &services.UserBalanceService{
+			UserRepoRead:     &repos.UserRepoRead{DBRead: dbRead, Cache: cacheRepo},
+			UserBalanceWrite: &repos.UserBalanceRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+			WalletRead:       &repos.WalletRepoRead{DBRead: dbRead, Cache: cacheRepo},
+			DefaultCurrency:  b.cfg.Wallet.DefaultCurrency,
+			Currencies:       b.cfg.Wallet.Currencies,
+		},
+		InstanceID:    rand.Int63(),
+		MaxAttempts:   2,
+		RetryInterval: time.Hour,
+	}
+}
+
+// runDueSchedule makes a schedule due now and runs the scheduler once.
+func (b *Blackbox) runDueSchedule(scheduler *services.TransferScheduler, scheduleID uint) {
+	dbWrite := drivers.NewDBClientWrite(b.cfg)
+	dueAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	dbWrite.Exec("UPDATE transfer_schedules SET next_run_at = ?, due_at = ? WHERE id = ?", dueAt, dueAt, scheduleID)
+	scheduler.RunOnce()
+}
+
+func (b *Blackbox) runTestAPITransferSchedules() {
+
+	scheduler := b.newTransferScheduler()
+	defer scheduler.TransferScheduleRepo.ReleaseLeadership(scheduler.InstanceID)
+
+	newPair := func() (sender models.CreateUserResponse, recipient models.CreateUserResponse) {
+		rand.Seed(time.Now().UnixNano())
+		sender = getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+		recipient = getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+		return
+	}
+	oneOff := func(recipient models.CreateUserResponse, amount uint32) models.CreateTransferScheduleRequest {
+		runAt := time.Now().Add(time.Hour)
+		return models.CreateTransferScheduleRequest{
+			ToUsername: recipient.UserDetails.Username,
+			Amount:     amount,
+			RunAt:      &runAt,
+		}
+	}
+
+	var sender, recipient models.CreateUserResponse
+	var schedule models.TransferScheduleResponse
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Schedule One-off #201: ",
+			func() (*http.Client, *http.Request) {
+				sender, recipient = newPair()
+				return createTransferScheduleRequest(b.cfg, sender.Token, oneOff(recipient, 1000))
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				scheduleResp := models.TransferScheduleResponse{}
+				err := getStruct(resp, &scheduleResp)
+				if err != nil {
+					return err
+				}
+				if scheduleResp.Status != models.SCHEDULE_ACTIVE || scheduleResp.NextRunAt == nil {
+					return fmt.Errorf("Got %+v, Want an active schedule with its next run", scheduleResp)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule Recurring #201: ",
+			func() (*http.Client, *http.Request) {
+				sender, recipient = newPair()
+				return createTransferScheduleRequest(b.cfg, sender.Token, models.CreateTransferScheduleRequest{
+					ToUsername: recipient.UserDetails.Username,
+					Amount:     1000,
+					Cron:       "0 9 * * 1",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				scheduleResp := models.TransferScheduleResponse{}
+				err := getStruct(resp, &scheduleResp)
+				if err != nil {
+					return err
+				}
+				next := scheduleResp.NextRunAt
+				if next == nil || next.UTC().Weekday() != time.Monday || next.UTC().Hour() != 9 || next.UTC().Minute() != 0 {
+					return fmt.Errorf("Got next run %v, Want a Monday at 09:00 UTC", next)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule #400 Both Run At And Cron: ",
+			func() (*http.Client, *http.Request) {
+				sender, recipient = newPair()
+				req := oneOff(recipient, 1000)
+				req.Cron = "@daily"
+				return createTransferScheduleRequest(b.cfg, sender.Token, req)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule #400 Invalid Cron: ",
+			func() (*http.Client, *http.Request) {
+				sender, recipient = newPair()
+				return createTransferScheduleRequest(b.cfg, sender.Token, models.CreateTransferScheduleRequest{
+					ToUsername: recipient.UserDetails.Username,
+					Amount:     1000,
+					Cron:       "0 25 * * *",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule Pause #200 Without PIN: ",
+			func() (*http.Client, *http.Request) {
+				sender = b.newUserWithPin("123456")
+				_, recipient = newPair()
+				create := oneOff(recipient, 1000)
+				create.Pin = "123456"
+				schedule = createTransferSchedule(b.cfg, sender.Token, create)
+				return transferScheduleRequest(b.cfg, sender.Token, http.MethodPatch, schedule.ID, &models.UpdateTransferScheduleRequest{
+					Status: models.SCHEDULE_PAUSED,
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule Update Amount #403 Without PIN: ",
+			func() (*http.Client, *http.Request) {
+				return transferScheduleRequest(b.cfg, sender.Token, http.MethodPatch, schedule.ID, &models.UpdateTransferScheduleRequest{
+					Amount: 5000,
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule Pause #200: ",
+			func() (*http.Client, *http.Request) {
+				sender, recipient = newPair()
+				schedule = createTransferSchedule(b.cfg, sender.Token, oneOff(recipient, 1000))
+				return transferScheduleRequest(b.cfg, sender.Token, http.MethodPatch, schedule.ID, &models.UpdateTransferScheduleRequest{
+					Status: models.SCHEDULE_PAUSED,
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				scheduleResp := models.TransferScheduleResponse{}
+				err := getStruct(resp, &scheduleResp)
+				if err != nil {
+					return err
+				}
+				if scheduleResp.Status != models.SCHEDULE_PAUSED {
+					return fmt.Errorf("Got status %v, Want %v", scheduleResp.Status, models.SCHEDULE_PAUSED)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule Delete #204: ",
+			func() (*http.Client, *http.Request) {
+				return transferScheduleRequest(b.cfg, sender.Token, http.MethodDelete, schedule.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNoContent {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNoContent)
+				}
+				c, req := transferScheduleRequest(b.cfg, sender.Token, http.MethodGet, schedule.ID, nil)
+				getResp, err := c.Do(req)
+				if err != nil {
+					return err
+				}
+				if getResp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v after delete, Want %v", getResp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule Run Completes One-off: ",
+			func() (*http.Client, *http.Request) {
+				sender, recipient = newPair()
+				topupBalance(b.cfg, sender.Token, 5000)
+				schedule = createTransferSchedule(b.cfg, sender.Token, oneOff(recipient, 1000))
+				b.runDueSchedule(scheduler, schedule.ID)
+				return transferScheduleRequest(b.cfg, sender.Token, http.MethodGet, schedule.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				scheduleResp := models.TransferScheduleResponse{}
+				err := getStruct(resp, &scheduleResp)
+				if err != nil {
+					return err
+				}
+				if scheduleResp.Status != models.SCHEDULE_COMPLETED || scheduleResp.LastRefID == "" {
+					return fmt.Errorf("Got %+v, Want a completed schedule with its transfer", scheduleResp)
+				}
+				balance := getBalance(b.cfg, recipient.Token)
+				if balance.Balance != 1000 {
+					return fmt.Errorf("Got recipient balance %v, Want %v", balance.Balance, 1000)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule Run Records Failure For Retry: ",
+			func() (*http.Client, *http.Request) {
+				sender, recipient = newPair()
+				schedule = createTransferSchedule(b.cfg, sender.Token, oneOff(recipient, 1000))
+				b.runDueSchedule(scheduler, schedule.ID)
+				return transferScheduleRequest(b.cfg, sender.Token, http.MethodGet, schedule.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				scheduleResp := models.TransferScheduleResponse{}
+				err := getStruct(resp, &scheduleResp)
+				if err != nil {
+					return err
+				}
+				if scheduleResp.Status != models.SCHEDULE_ACTIVE || scheduleResp.Attempts != 1 || scheduleResp.RetryAt == nil {
+					return fmt.Errorf("Got %+v, Want an active schedule waiting for its retry", scheduleResp)
+				}
+				if len(scheduleResp.Runs) != 1 || scheduleResp.Runs[0].Error != errs.ErrInsufficientBalance.Error() {
+					return fmt.Errorf("Got runs %+v, Want one failed with %v", scheduleResp.Runs, errs.ErrInsufficientBalance)
+				}
+				return nil
+			},
+		},
+		{
+			"Schedule Run Fails After Max Attempts: ",
+			func() (*http.Client, *http.Request) {
+				b.runDueSchedule(scheduler, schedule.ID)
+				return transferScheduleRequest(b.cfg, sender.Token, http.MethodGet, schedule.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				scheduleResp := models.TransferScheduleResponse{}
+				err := getStruct(resp, &scheduleResp)
+				if err != nil {
+					return err
+				}
+				if scheduleResp.Status != models.SCHEDULE_FAILED || len(scheduleResp.Runs) != 2 {
+					return fmt.Errorf("Got %+v, Want a failed schedule with 2 runs", scheduleResp)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+
+	// a second scheduler does not lead while the first one does
+	testName := "Schedule Leader Election: "
+	follower := b.newTransferScheduler()
+	leads, err := follower.TransferScheduleRepo.AcquireLeadership(follower.InstanceID, time.Minute)
+	if err != nil || leads {
+		log.Println(testName, "FAILED", fmt.Errorf("Got lead %v %v, Want none", leads, err))
+	} else {
+		log.Println(testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPIPots() {
+
+	newUser := func() models.CreateUserResponse {
+		rand.Seed(time.Now().UnixNano())
+		return getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+	}
+	expectBalance := func(user models.CreateUserResponse, want int64) error {
+		balance := getBalance(b.cfg, user.Token)
+		if balance.Balance != want {
+			return fmt.Errorf("Got %v balance %v, Want %v", user.UserDetails.Username, balance.Balance, want)
+		}
+		return nil
+	}
+
+	var owner, member1, member2 models.CreateUserResponse
+	var pot models.PotResponse
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Pot Create #201: ",
+			func() (*http.Client, *http.Request) {
+				owner, member1, member2 = newUser(), newUser(), newUser()
+				return createPotRequest(b.cfg, owner.Token, models.CreatePotRequest{
+					Name:            "dinner",
+					TargetAmount:    3000,
+					MemberUsernames: []string{member1.UserDetails.Username, member2.UserDetails.Username},
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				err := getStruct(resp, &pot)
+				if err != nil {
+					return err
+				}
+				if pot.Status != models.POT_OPEN || len(pot.Members) != 3 {
+					return fmt.Errorf("Got %+v, Want an open pot with 3 members", pot)
+				}
+				return nil
+			},
+		},
+		{
+			"Pot Create #404 Member Not Found: ",
+			func() (*http.Client, *http.Request) {
+				return createPotRequest(b.cfg, owner.Token, models.CreatePotRequest{
+					Name:            "dinner",
+					MemberUsernames: []string{"nobody" + fmt.Sprintf("%v", rand.Int())},
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"Pot Contribute #201: ",
+			func() (*http.Client, *http.Request) {
+				topupBalance(b.cfg, member1.Token, 5000)
+				return potRequest(b.cfg, member1.Token, http.MethodPost, constants.POT_CONTRIBUTIONS_PATH, pot.ID, &models.ContributePotRequest{Amount: 1000})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				return expectBalance(member1, 4000)
+			},
+		},
+		{
+			"Pot Contribute #404 Not A Member: ",
+			func() (*http.Client, *http.Request) {
+				outsider := newUser()
+				topupBalance(b.cfg, outsider.Token, 5000)
+				return potRequest(b.cfg, outsider.Token, http.MethodPost, constants.POT_CONTRIBUTIONS_PATH, pot.ID, &models.ContributePotRequest{Amount: 1000})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"Pot Contribute #400 Insufficient Balance: ",
+			func() (*http.Client, *http.Request) {
+				return potRequest(b.cfg, member2.Token, http.MethodPost, constants.POT_CONTRIBUTIONS_PATH, pot.ID, &models.ContributePotRequest{Amount: 500})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Pot Contributions #200: ",
+			func() (*http.Client, *http.Request) {
+				return potRequest(b.cfg, member2.Token, http.MethodGet, constants.POT_CONTRIBUTIONS_PATH, pot.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				contributionsResp := models.PotContributionsResponse{}
+				err := getStruct(resp, &contributionsResp)
+				if err != nil {
+					return err
+				}
+				if len(contributionsResp.Contributions) != 1 ||
+					contributionsResp.Contributions[0].Username != member1.UserDetails.Username ||
+					contributionsResp.Contributions[0].Amount != 1000 {
+					return fmt.Errorf("Got %+v, Want 1000 from %v", contributionsResp.Contributions, member1.UserDetails.Username)
+				}
+				return nil
+			},
+		},
+		{
+			"Pot Get #200: ",
+			func() (*http.Client, *http.Request) {
+				return potRequest(b.cfg, owner.Token, http.MethodGet, constants.POT_PATH, pot.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				potResp := models.PotResponse{}
+				err := getStruct(resp, &potResp)
+				if err != nil {
+					return err
+				}
+				if potResp.Balance != 1000 {
+					return fmt.Errorf("Got pot balance %v, Want %v", potResp.Balance, 1000)
+				}
+				for _, v := range potResp.Members {
+					if v.Username == member1.UserDetails.Username && v.Contributed != 1000 {
+						return fmt.Errorf("Got contributed %v, Want %v", v.Contributed, 1000)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			"Pot Disburse #403 Not The Owner: ",
+			func() (*http.Client, *http.Request) {
+				return potRequest(b.cfg, member1.Token, http.MethodPost, constants.POT_DISBURSE_PATH, pot.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Pot Disburse #200 Partial: ",
+			func() (*http.Client, *http.Request) {
+				topupBalance(b.cfg, member2.Token, 3000)
+				contributeToPot(b.cfg, member2.Token, pot.ID, 2000)
+				return potRequest(b.cfg, owner.Token, http.MethodPost, constants.POT_DISBURSE_PATH, pot.ID, &models.DisbursePotRequest{Amount: 600})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				disbursementResp := models.PotDisbursementResponse{}
+				err := getStruct(resp, &disbursementResp)
+				if err != nil {
+					return err
+				}
+				if disbursementResp.Balance != 2400 || disbursementResp.Status != models.POT_OPEN {
+					return fmt.Errorf("Got %+v, Want an open pot with 2400 left", disbursementResp)
+				}
+				return expectBalance(owner, 600)
+			},
+		},
+		{
+			"Pot Close #200 Proportional Refunds: ",
+			func() (*http.Client, *http.Request) {
+				return potRequest(b.cfg, owner.Token, http.MethodPost, constants.POT_CLOSE_PATH, pot.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				// 2400 left of 1000 and 2000 contributed
+				err := expectBalance(member1, 4800)
+				if err != nil {
+					return err
+				}
+				return expectBalance(member2, 2600)
+			},
+		},
+		{
+			"Pot Contribute #409 Closed: ",
+			func() (*http.Client, *http.Request) {
+				return potRequest(b.cfg, member1.Token, http.MethodPost, constants.POT_CONTRIBUTIONS_PATH, pot.ID, &models.ContributePotRequest{Amount: 1000})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return expectBalance(member1, 4800)
+			},
+		},
+		{
+			"Pot Disburse #200 Whole Balance: ",
+			func() (*http.Client, *http.Request) {
+				owner, member1 = newUser(), newUser()
+				pot = createPot(b.cfg, owner.Token, models.CreatePotRequest{
+					Name:            "gift",
+					MemberUsernames: []string{member1.UserDetails.Username},
+				})
+				topupBalance(b.cfg, member1.Token, 1500)
+				contributeToPot(b.cfg, member1.Token, pot.ID, 1500)
+				return potRequest(b.cfg, owner.Token, http.MethodPost, constants.POT_DISBURSE_PATH, pot.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				disbursementResp := models.PotDisbursementResponse{}
+				err := getStruct(resp, &disbursementResp)
+				if err != nil {
+					return err
+				}
+				if disbursementResp.Amount != 1500 || disbursementResp.Status != models.POT_DISBURSED {
+					return fmt.Errorf("Got %+v, Want 1500 disbursed", disbursementResp)
+				}
+				return expectBalance(owner, 1500)
+			},
+		},
+		{
+			"AdminClose #409 Open Pot Contributor: ",
+			func() (*http.Client, *http.Request) {
+				owner, member1 = newUser(), newUser()
+				pot = createPot(b.cfg, owner.Token, models.CreatePotRequest{
+					Name:            "trip",
+					MemberUsernames: []string{member1.UserDetails.Username},
+				})
+				topupBalance(b.cfg, member1.Token, 1500)
+				contributeToPot(b.cfg, member1.Token, pot.ID, 1000)
+				return adminCloseRequest(b.cfg, b.newAdmin(models.SUPPORT), member1.UserDetails.Username, true)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return expectBalance(member1, 500)
+			},
+		},
+		{
+			"Pot Close #409 Contributor Frozen: ",
+			func() (*http.Client, *http.Request) {
+				c, req := adminFreezeRequest(b.cfg, b.newAdmin(models.SUPPORT), member1.UserDetails.Username, "all", "suspicious activity")
+				c.Do(req)
+				return potRequest(b.cfg, owner.Token, http.MethodPost, constants.POT_CLOSE_PATH, pot.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return expectBalance(member1, 500)
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) newBulkTransferWorker() *services.BulkTransferWorker {
+	rc := drivers.GetRedisClient(b.cfg)
+	cacheRepo := repos.NewCache(b.cfg, rc)
+	dbRead := drivers.NewDBClientRead(b.cfg)
+	dbWrite := drivers.NewDBClientWrite(b.cfg)
+
+	bulkTransferRepo := repos.BulkTransferRepo{DBWrite: dbWrite, Cache: cacheRepo}
+	return &services.BulkTransferWorker{
+		BulkTransferRepo: &bulkTransferRepo,
+		BulkTransferService: &services.BulkTransferService{
+			BulkTransferRepo: &bulkTransferRepo,
+			UserBalanceService: &services.UserBalanceService{
+				UserRepoRead:     &repos.UserRepoRead{DBRead: dbRead, Cache: cacheRepo},
+				UserBalanceWrite: &repos.UserBalanceRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+				WalletRead:       &repos.WalletRepoRead{DBRead: dbRead, Cache: cacheRepo},
+				DefaultCurrency:  b.cfg.Wallet.DefaultCurrency,
+				Currencies:       b.cfg.Wallet.Currencies,
+			},
+		},
+	}
+}
+
+func (b *Blackbox) runTestAPIBulkTransfers() {
+
+	newUser := func() models.CreateUserResponse {
+		rand.Seed(time.Now().UnixNano())
+		return getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+	}
+	expectBalance := func(user models.CreateUserResponse, want int64) error {
+		balance := getBalance(b.cfg, user.Token)
+		if balance.Balance != want {
+			return fmt.Errorf("Got %v balance %v, Want %v", user.UserDetails.Username, balance.Balance, want)
+		}
+		return nil
+	}
+	getBulkTransfer := func(resp *http.Response, want int) (bulkTransfer models.BulkTransferResponse, err error) {
+		if resp.StatusCode != want {
+			return bulkTransfer, fmt.Errorf("Got %v, Want %v", resp.StatusCode, want)
+		}
+		err = getStruct(resp, &bulkTransfer)
+		return
+	}
+
+	var sender, receiver1, receiver2 models.CreateUserResponse
+	var asyncBulkTransfer models.BulkTransferResponse
+	nobody := "nobody" + fmt.Sprintf("%v", rand.Int())
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Bulk Transfer All Or Nothing #200: ",
+			func() (*http.Client, *http.Request) {
+				sender, receiver1, receiver2 = newUser(), newUser(), newUser()
+				topupBalance(b.cfg, sender.Token, 5000)
+				return createBulkTransferRequest(b.cfg, sender.Token, models.CreateBulkTransferRequest{
+					Mode: models.ALL_OR_NOTHING,
+					Items: []models.BulkTransferItemRequest{
+						{ToUsername: receiver1.UserDetails.Username, Amount: 1000, Reference: "salary-1"},
+						{ToUsername: receiver2.UserDetails.Username, Amount: 2000, Reference: "salary-2"},
+					},
+				})
+			},
+			func(resp *http.Response) error {
+				bulkTransfer, err := getBulkTransfer(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if bulkTransfer.Status != models.BULK_TRANSFER_COMPLETED || bulkTransfer.SucceededItems != 2 {
+					return fmt.Errorf("Got %+v, Want 2 items transferred", bulkTransfer)
+				}
+				if bulkTransfer.Items[0].RefID == "" || bulkTransfer.Items[0].Reference != "salary-1" {
+					return fmt.Errorf("Got %+v, Want a transferred item with its reference", bulkTransfer.Items[0])
+				}
+				err = expectBalance(sender, 2000)
+				if err != nil {
+					return err
+				}
+				err = expectBalance(receiver1, 1000)
+				if err != nil {
+					return err
+				}
+				return expectBalance(receiver2, 2000)
+			},
+		},
+		{
+			"Bulk Transfer All Or Nothing #200 Failed On Invalid Item: ",
+			func() (*http.Client, *http.Request) {
+				return createBulkTransferRequest(b.cfg, sender.Token, models.CreateBulkTransferRequest{
+					Mode: models.ALL_OR_NOTHING,
+					Items: []models.BulkTransferItemRequest{
+						{ToUsername: receiver1.UserDetails.Username, Amount: 1000},
+						{ToUsername: nobody, Amount: 500},
+					},
+				})
+			},
+			func(resp *http.Response) error {
+				bulkTransfer, err := getBulkTransfer(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if bulkTransfer.Status != models.BULK_TRANSFER_FAILED || bulkTransfer.FailedItems != 2 {
+					return fmt.Errorf("Got %+v, Want every item failed", bulkTransfer)
+				}
+				if bulkTransfer.Items[1].Error != errs.ErrDestinationUserNotFound.Error() {
+					return fmt.Errorf("Got %v, Want %v", bulkTransfer.Items[1].Error, errs.ErrDestinationUserNotFound)
+				}
+				err = expectBalance(sender, 2000)
+				if err != nil {
+					return err
+				}
+				return expectBalance(receiver1, 1000)
+			},
+		},
+		{
+			"Bulk Transfer Best Effort #200 Partial: ",
+			func() (*http.Client, *http.Request) {
+				return createBulkTransferRequest(b.cfg, sender.Token, models.CreateBulkTransferRequest{
+					Mode: models.BEST_EFFORT,
+					Items: []models.BulkTransferItemRequest{
+						{ToUsername: receiver1.UserDetails.Username, Amount: 500},
+						{ToUsername: nobody, Amount: 100},
+						{ToUsername: receiver2.UserDetails.Username, Amount: 5000},
+					},
+				})
+			},
+			func(resp *http.Response) error {
+				bulkTransfer, err := getBulkTransfer(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if bulkTransfer.Status != models.BULK_TRANSFER_COMPLETED ||
+					bulkTransfer.SucceededItems != 1 || bulkTransfer.FailedItems != 2 {
+					return fmt.Errorf("Got %+v, Want 1 item transferred and 2 failed", bulkTransfer)
+				}
+				if bulkTransfer.Items[2].Error != errs.ErrInsufficientBalance.Error() {
+					return fmt.Errorf("Got %v, Want %v", bulkTransfer.Items[2].Error, errs.ErrInsufficientBalance)
+				}
+				err = expectBalance(sender, 1500)
+				if err != nil {
+					return err
+				}
+				return expectBalance(receiver1, 1500)
+			},
+		},
+		{
+			"Bulk Transfer CSV Upload #200: ",
+			func() (*http.Client, *http.Request) {
+				csv := "username,amount,reference\n" +
+					receiver1.UserDetails.Username + ",100,inv-1\n" +
+					receiver2.UserDetails.Username + ",200,inv-2\n"
+				return uploadBulkTransferRequest(b.cfg, sender.Token, csv, map[string]string{"mode": string(models.BEST_EFFORT)})
+			},
+			func(resp *http.Response) error {
+				bulkTransfer, err := getBulkTransfer(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if bulkTransfer.SucceededItems != 2 || bulkTransfer.Items[1].Reference != "inv-2" {
+					return fmt.Errorf("Got %+v, Want the 2 items of the file transferred", bulkTransfer)
+				}
+				return expectBalance(sender, 1200)
+			},
+		},
+		{
+			"Bulk Transfer Async #202: ",
+			func() (*http.Client, *http.Request) {
+				return createBulkTransferRequest(b.cfg, sender.Token, models.CreateBulkTransferRequest{
+					Mode:  models.BEST_EFFORT,
+					Async: true,
+					Items: []models.BulkTransferItemRequest{
+						{ToUsername: receiver1.UserDetails.Username, Amount: 200},
+					},
+				})
+			},
+			func(resp *http.Response) (err error) {
+				asyncBulkTransfer, err = getBulkTransfer(resp, http.StatusAccepted)
+				if err != nil {
+					return err
+				}
+				if asyncBulkTransfer.Status != models.BULK_TRANSFER_PENDING || asyncBulkTransfer.ID == "" {
+					return fmt.Errorf("Got %+v, Want a pending bulk transfer", asyncBulkTransfer)
+				}
+				return nil
+			},
+		},
+		{
+			"Bulk Transfer Get #200 Async Completed: ",
+			func() (*http.Client, *http.Request) {
+				worker := b.newBulkTransferWorker()
+				// the worker of the server may take the bulk transfer first
+				waitFor(10*time.Second, func() bool {
+					worker.RunOnce()
+					c, req := bulkTransferRequest(b.cfg, sender.Token, http.MethodGet, constants.BULK_TRANSFER_PATH, asyncBulkTransfer.ID, nil)
+					resp, err := c.Do(req)
+					if err != nil {
+						return false
+					}
+					bulkTransfer, err := getBulkTransfer(resp, http.StatusOK)
+					return err == nil && bulkTransfer.Status == models.BULK_TRANSFER_COMPLETED
+				})
+				return bulkTransferRequest(b.cfg, sender.Token, http.MethodGet, constants.BULK_TRANSFER_PATH, asyncBulkTransfer.ID, nil)
+			},
+			func(resp *http.Response) error {
+				bulkTransfer, err := getBulkTransfer(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if bulkTransfer.Status != models.BULK_TRANSFER_COMPLETED || bulkTransfer.ProcessedItems != 1 {
+					return fmt.Errorf("Got %+v, Want the bulk transfer completed", bulkTransfer)
+				}
+				return expectBalance(sender, 1000)
+			},
+		},
+		{
+			"Bulk Transfer Get #404 Of Another User: ",
+			func() (*http.Client, *http.Request) {
+				return bulkTransferRequest(b.cfg, receiver1.Token, http.MethodGet, constants.BULK_TRANSFER_PATH, asyncBulkTransfer.ID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"Bulk Transfer #400 Too Many Items: ",
+			func() (*http.Client, *http.Request) {
+				items := []models.BulkTransferItemRequest{}
+				for i := 0; i <= b.cfg.BulkTransfer.MaxItems; i++ {
+					items = append(items, models.BulkTransferItemRequest{ToUsername: receiver1.UserDetails.Username, Amount: 1})
+				}
+				return createBulkTransferRequest(b.cfg, sender.Token, models.CreateBulkTransferRequest{
+					Mode:  models.BEST_EFFORT,
+					Items: items,
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return expectBalance(sender, 1000)
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) newEscrowSweeper() *services.EscrowSweeper {
+	rc := drivers.GetRedisClient(b.cfg)
+	cacheRepo := repos.NewCache(b.cfg, rc)
+	dbRead := drivers.NewDBClientRead(b.cfg)
+	dbWrite := drivers.NewDBClientWrite(b.cfg)
+
+	return &services.EscrowSweeper{
+		EscrowService: &services.EscrowService{
+			EscrowRead:       &repos.EscrowRepoRead{DBRead: dbRead, Cache: cacheRepo},
+			UserBalanceWrite: &repos.UserBalanceRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+		},
+	}
+}
+
+func (b *Blackbox) runTestAPIEscrows() {
+
+	newUser := func() models.CreateUserResponse {
+		rand.Seed(time.Now().UnixNano())
+		return getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+	}
+	expectBalance := func(user models.CreateUserResponse, want int64) error {
+		balance := getBalance(b.cfg, user.Token)
+		if balance.Balance != want {
+			return fmt.Errorf("Got %v balance %v, Want %v", user.UserDetails.Username, balance.Balance, want)
+		}
+		return nil
+	}
+	getEscrow := func(resp *http.Response, want int) (escrow models.EscrowResponse, err error) {
+		if resp.StatusCode != want {
+			return escrow, fmt.Errorf("Got %v, Want %v", resp.StatusCode, want)
+		}
+		err = getStruct(resp, &escrow)
+		return
+	}
+
+	var payer, payee models.CreateUserResponse
+	var escrow, disputed, due, stuck models.EscrowResponse
+	arbitratorToken := b.newAdmin(models.ARBITRATOR)
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Escrow Create #201: ",
+			func() (*http.Client, *http.Request) {
+				payer, payee = newUser(), newUser()
+				topupBalance(b.cfg, payer.Token, 10000)
+				return createEscrowRequest(b.cfg, payer.Token, models.CreateEscrowRequest{
+					PayeeUsername: payee.UserDetails.Username,
+					Amount:        2000,
+					Memo:          "second hand bike",
+				})
+			},
+			func(resp *http.Response) error {
+				var err error
+				escrow, err = getEscrow(resp, http.StatusCreated)
+				if err != nil {
+					return err
+				}
+				if escrow.Status != models.ESCROW_HELD {
+					return fmt.Errorf("Got %+v, Want a held escrow", escrow)
+				}
+				return expectBalance(payer, 8000)
+			},
+		},
+		{
+			"Escrow Create #400 Invalid Release Time: ",
+			func() (*http.Client, *http.Request) {
+				releaseAt := time.Now().Add(-time.Hour)
+				return createEscrowRequest(b.cfg, payer.Token, models.CreateEscrowRequest{
+					PayeeUsername: payee.UserDetails.Username,
+					Amount:        2000,
+					ReleaseAt:     &releaseAt,
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return expectBalance(payer, 8000)
+			},
+		},
+		{
+			"Escrow Refund #403 By Payer: ",
+			func() (*http.Client, *http.Request) {
+				return escrowRequest(b.cfg, payer.Token, http.MethodPost, constants.ESCROW_REFUND_PATH, escrow.RefID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Escrow Get #404 Of Another User: ",
+			func() (*http.Client, *http.Request) {
+				return escrowRequest(b.cfg, newUser().Token, http.MethodGet, constants.ESCROW_PATH, escrow.RefID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			"Escrow Release #200 By Payer: ",
+			func() (*http.Client, *http.Request) {
+				return escrowRequest(b.cfg, payer.Token, http.MethodPost, constants.ESCROW_RELEASE_PATH, escrow.RefID, nil)
+			},
+			func(resp *http.Response) error {
+				released, err := getEscrow(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if released.Status != models.ESCROW_RELEASED || released.SettledBy != models.ESCROW_PAYER {
+					return fmt.Errorf("Got %+v, Want the escrow released by its payer", released)
+				}
+				return expectBalance(payee, 2000)
+			},
+		},
+		{
+			"Escrow Release #409 Already Released: ",
+			func() (*http.Client, *http.Request) {
+				return escrowRequest(b.cfg, payer.Token, http.MethodPost, constants.ESCROW_RELEASE_PATH, escrow.RefID, nil)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return expectBalance(payee, 2000)
+			},
+		},
+		{
+			"Escrow Refund #200 By Payee: ",
+			func() (*http.Client, *http.Request) {
+				escrow = createEscrow(b.cfg, payer.Token, models.CreateEscrowRequest{
+					PayeeUsername: payee.UserDetails.Username,
+					Amount:        1000,
+				})
+				return escrowRequest(b.cfg, payee.Token, http.MethodPost, constants.ESCROW_REFUND_PATH, escrow.RefID, nil)
+			},
+			func(resp *http.Response) error {
+				refunded, err := getEscrow(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if refunded.Status != models.ESCROW_REFUNDED {
+					return fmt.Errorf("Got %+v, Want the escrow refunded", refunded)
+				}
+				return expectBalance(payer, 8000)
+			},
+		},
+		{
+			"Escrow Dispute #200: ",
+			func() (*http.Client, *http.Request) {
+				disputed = createEscrow(b.cfg, payer.Token, models.CreateEscrowRequest{
+					PayeeUsername: payee.UserDetails.Username,
+					Amount:        3000,
+				})
+				return escrowRequest(b.cfg, payee.Token, http.MethodPost, constants.ESCROW_DISPUTE_PATH, disputed.RefID, nil)
+			},
+			func(resp *http.Response) error {
+				escrow, err := getEscrow(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if escrow.Status != models.ESCROW_DISPUTED || escrow.DisputedBy != models.ESCROW_PAYEE {
+					return fmt.Errorf("Got %+v, Want the escrow disputed by its payee", escrow)
+				}
+				return expectBalance(payer, 5000)
+			},
+		},
+		{
+			"Escrow Admin Resolve #403 Not An Arbitrator: ",
+			func() (*http.Client, *http.Request) {
+				return escrowRequest(b.cfg, b.newAdmin(models.FINANCE), http.MethodPost, constants.ADMIN_ESCROW_RESOLVE_PATH, disputed.RefID, &models.AdminEscrowResolveRequest{
+					Outcome: models.ESCROW_REFUNDED,
+					Reason:  "item never shipped",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Escrow Admin Resolve #200 Refunded: ",
+			func() (*http.Client, *http.Request) {
+				return escrowRequest(b.cfg, arbitratorToken, http.MethodPost, constants.ADMIN_ESCROW_RESOLVE_PATH, disputed.RefID, &models.AdminEscrowResolveRequest{
+					Outcome: models.ESCROW_REFUNDED,
+					Reason:  "item never shipped",
+				})
+			},
+			func(resp *http.Response) error {
+				escrow, err := getEscrow(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if escrow.Status != models.ESCROW_REFUNDED || escrow.SettledBy != models.ESCROW_ARBITRATOR {
+					return fmt.Errorf("Got %+v, Want the escrow refunded by the arbitrator", escrow)
+				}
+				return expectBalance(payer, 8000)
+			},
+		},
+		{
+			"Escrow Sweeper Releases Due Escrow: ",
+			func() (*http.Client, *http.Request) {
+				due = createEscrow(b.cfg, payer.Token, models.CreateEscrowRequest{
+					PayeeUsername: payee.UserDetails.Username,
+					Amount:        500,
+				})
+				dbWrite := drivers.NewDBClientWrite(b.cfg)
+				dbWrite.Exec("UPDATE escrows SET release_at = ? WHERE ref_id = ?", time.Now().UTC().Add(-time.Minute), due.RefID)
+				b.newEscrowSweeper().SweepOnce()
+				return escrowRequest(b.cfg, payee.Token, http.MethodGet, constants.ESCROW_PATH, due.RefID, nil)
+			},
+			func(resp *http.Response) error {
+				escrow, err := getEscrow(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if escrow.Status != models.ESCROW_RELEASED || escrow.SettledBy != models.ESCROW_DEADLINE {
+					return fmt.Errorf("Got %+v, Want the escrow released at its deadline", escrow)
+				}
+				return expectBalance(payee, 2500)
+			},
+		},
+		{
+			"Escrow Sweeper Skips Frozen Payee: ",
+			func() (*http.Client, *http.Request) {
+				frozenPayee := newUser()
+				stuck = createEscrow(b.cfg, payer.Token, models.CreateEscrowRequest{
+					PayeeUsername: frozenPayee.UserDetails.Username,
+					Amount:        300,
+				})
+				due = createEscrow(b.cfg, payer.Token, models.CreateEscrowRequest{
+					PayeeUsername: payee.UserDetails.Username,
+					Amount:        200,
+				})
+				c, req := adminFreezeRequest(b.cfg, b.newAdmin(models.SUPPORT), frozenPayee.UserDetails.Username, "all", "suspicious activity")
+				c.Do(req)
+				dbWrite := drivers.NewDBClientWrite(b.cfg)
+				dbWrite.Exec("UPDATE escrows SET release_at = ? WHERE ref_id = ?", time.Now().UTC().Add(-2*time.Minute), stuck.RefID)
+				dbWrite.Exec("UPDATE escrows SET release_at = ? WHERE ref_id = ?", time.Now().UTC().Add(-time.Minute), due.RefID)
+				b.newEscrowSweeper().SweepOnce()
+				return escrowRequest(b.cfg, payee.Token, http.MethodGet, constants.ESCROW_PATH, due.RefID, nil)
+			},
+			func(resp *http.Response) error {
+				escrow, err := getEscrow(resp, http.StatusOK)
+				if err != nil {
+					return err
+				}
+				if escrow.Status != models.ESCROW_RELEASED {
+					return fmt.Errorf("Got %+v, Want the escrow behind the frozen payee's released", escrow)
+				}
+				var held models.Escrow
+				drivers.NewDBClientRead(b.cfg).Where("ref_id = ?", stuck.RefID).First(&held)
+				if held.Status != models.ESCROW_HELD || held.ReleaseAttemptedAt == nil {
+					return fmt.Errorf("Got %v attempted at %v, Want the frozen payee's escrow held with the attempt recorded", held.Status, held.ReleaseAttemptedAt)
+				}
+				return expectBalance(payee, 2700)
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPIMerchants() {
+
+	newUser := func() models.CreateUserResponse {
+		rand.Seed(time.Now().UnixNano())
+		return getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+	}
+	expectBalance := func(user models.CreateUserResponse, want int64) error {
+		balance := getBalance(b.cfg, user.Token)
+		if balance.Balance != want {
+			return fmt.Errorf("Got %v balance %v, Want %v", user.UserDetails.Username, balance.Balance, want)
+		}
+		return nil
+	}
+
+	var merchant, payer models.CreateUserResponse
+	var dynamicPayload, staticPayload string
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Merchant Payment Code #403 Not A Merchant: ",
+			func() (*http.Client, *http.Request) {
+				merchant, payer = newUser(), newUser()
+				return merchantRequest(b.cfg, merchant.Token, http.MethodPost, constants.MERCHANT_PAYMENT_CODES_PATH, &models.CreatePaymentCodeRequest{Amount: 1500})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusForbidden {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			"Merchant Register #200: ",
+			func() (*http.Client, *http.Request) {
+				return merchantRequest(b.cfg, merchant.Token, http.MethodPut, constants.MERCHANT_PATH, &models.MerchantRequest{DisplayName: "Corner Coffee"})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				merchantResp := models.MerchantResponse{}
+				err := getStruct(resp, &merchantResp)
+				if err != nil {
+					return err
+				}
+				if merchantResp.DisplayName != "Corner Coffee" || merchantResp.SettlementCurrency != b.cfg.Wallet.DefaultCurrency {
+					return fmt.Errorf("Got %+v, Want the merchant settled in %v", merchantResp, b.cfg.Wallet.DefaultCurrency)
+				}
+				return nil
+			},
+		},
+		{
+			"Merchant Payment Code #201 Dynamic: ",
+			func() (*http.Client, *http.Request) {
+				return merchantRequest(b.cfg, merchant.Token, http.MethodPost, constants.MERCHANT_PAYMENT_CODES_PATH, &models.CreatePaymentCodeRequest{
+					Amount:   1500,
+					OrderRef: "order-1001",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusCreated {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusCreated)
+				}
+				if resp.Header.Get("Content-Type") != "image/png" {
+					return fmt.Errorf("Got %v, Want a PNG", resp.Header.Get("Content-Type"))
+				}
+				dynamicPayload = resp.Header.Get(constants.PAYMENT_PAYLOAD_HEADER)
+				if dynamicPayload == "" {
+					return fmt.Errorf("Want a payment payload")
+				}
+				return nil
+			},
+		},
+		{
+			"Merchant Payment Code #400 Order Ref Without Amount: ",
+			func() (*http.Client, *http.Request) {
+				return merchantRequest(b.cfg, merchant.Token, http.MethodPost, constants.MERCHANT_PAYMENT_CODES_PATH, &models.CreatePaymentCodeRequest{
+					OrderRef: "order-1002",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Pay #400 Tampered Payload: ",
+			func() (*http.Client, *http.Request) {
+				topupBalance(b.cfg, payer.Token, 5000)
+				return payRequest(b.cfg, payer.Token, dynamicPayload+"x", 0)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return expectBalance(payer, 5000)
+			},
+		},
+		{
+			"Pay #400 Amount Mismatch: ",
+			func() (*http.Client, *http.Request) {
+				return payRequest(b.cfg, payer.Token, dynamicPayload, 100)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return expectBalance(payer, 5000)
+			},
+		},
+		{
+			"Pay #200 Dynamic: ",
+			func() (*http.Client, *http.Request) {
+				return payRequest(b.cfg, payer.Token, dynamicPayload, 0)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				payResp := models.PayResponse{}
+				err := getStruct(resp, &payResp)
+				if err != nil {
+					return err
+				}
+				if payResp.Amount != 1500 || payResp.OrderRef != "order-1001" || payResp.DisplayName != "Corner Coffee" {
+					return fmt.Errorf("Got %+v, Want order-1001 paid to Corner Coffee", payResp)
+				}
+				err = expectBalance(merchant, 1500)
+				if err != nil {
+					return err
+				}
+				mutations := getMutations(b.cfg, merchant.Token, url.Values{})
+				if len(mutations.Data) == 0 || mutations.Data[0].OrderRef != "order-1001" {
+					return fmt.Errorf("Got %+v, Want the payment recorded with order-1001", mutations.Data)
+				}
+				return nil
+			},
+		},
+		{
+			"Pay #409 Payment Code Already Paid: ",
+			func() (*http.Client, *http.Request) {
+				return payRequest(b.cfg, payer.Token, dynamicPayload, 0)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				return expectBalance(payer, 3500)
+			},
+		},
+		{
+			"Pay #400 Static Without Amount: ",
+			func() (*http.Client, *http.Request) {
+				staticPayload = createPaymentCode(b.cfg, merchant.Token, models.CreatePaymentCodeRequest{})
+				return payRequest(b.cfg, payer.Token, staticPayload, 0)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Pay #200 Static Twice: ",
+			func() (*http.Client, *http.Request) {
+				c, req := payRequest(b.cfg, payer.Token, staticPayload, 500)
+				c.Do(req)
+				return payRequest(b.cfg, payer.Token, staticPayload, 500)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				return expectBalance(merchant, 2500)
+			},
+		},
+		{
+			"Pay #200 Idempotent Replay: ",
+			func() (*http.Client, *http.Request) {
+				c, req := payWithIdempotencyKeyRequest(b.cfg, payer.Token, staticPayload, 300, "pay-"+payer.UserDetails.Username)
+				c.Do(req)
+				return payWithIdempotencyKeyRequest(b.cfg, payer.Token, staticPayload, 300, "pay-"+payer.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				if resp.Header.Get(constants.IDEMPOTENT_REPLAYED_HEADER) != "true" {
+					return fmt.Errorf("Got %v, Want %v", resp.Header.Get(constants.IDEMPOTENT_REPLAYED_HEADER), "true")
+				}
+				payResp := models.PayResponse{}
+				err := getStruct(resp, &payResp)
+				if err != nil {
+					return err
+				}
+				if payResp.Amount != 300 || payResp.Merchant != merchant.UserDetails.Username {
+					return fmt.Errorf("Got %+v, Want the first payment of 300", payResp)
+				}
+				return expectBalance(merchant, 2800)
+			},
+		},
+		{
+			"Pay #422 Idempotency Key Reused: ",
+			func() (*http.Client, *http.Request) {
+				return payWithIdempotencyKeyRequest(b.cfg, payer.Token, staticPayload, 400, "pay-"+payer.UserDetails.Username)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusUnprocessableEntity {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusUnprocessableEntity)
+				}
+				return expectBalance(merchant, 2800)
+			},
+		},
+		{
+			"Pay #400 To Yourself: ",
+			func() (*http.Client, *http.Request) {
+				return payRequest(b.cfg, merchant.Token, staticPayload, 500)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
+
+func (b *Blackbox) runTestAPIMutationNotes() {
+
+	newUser := func() models.CreateUserResponse {
+		rand.Seed(time.Now().UnixNano())
+		return getNewUser(b.cfg, "any"+fmt.Sprintf("%v", rand.Int()))
+	}
+	expectOne := func(data []models.MutationHistoryItem) error {
+		if len(data) != 1 {
+			return fmt.Errorf("Got %v mutations, Want 1", len(data))
+		}
+		mutation := data[0]
+		if mutation.Memo != "Dinner split" || mutation.ExternalRef != "inv-2024-001" ||
+			len(mutation.Tags) != 2 || mutation.Tags[0] != "food" || mutation.Tags[1] != "friends" {
+			return fmt.Errorf("Got %+v, Want the noted transfer", mutation)
+		}
+		return nil
+	}
+
+	var sender, receiver models.CreateUserResponse
+
+	var tests = []struct {
+		testName    string
+		prepare     func() (*http.Client, *http.Request)
+		expectedMet func(*http.Response) error
+	}{
+		{
+			"Transfer With Note #200: ",
+			func() (*http.Client, *http.Request) {
+				sender, receiver = newUser(), newUser()
+				topupBalance(b.cfg, sender.Token, 10000)
+				return transferWithNoteRequest(b.cfg, sender.Token, models.TransferRequest{
+					Amount:      2500,
+					ToUsername:  receiver.UserDetails.Username,
+					Memo:        "  Dinner\t split\x07 ",
+					Tags:        []string{"Food", "friends", "food"},
+					ExternalRef: "inv-2024-001",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				return nil
+			},
+		},
+		{
+			"Mutations By Tag #200: ",
+			func() (*http.Client, *http.Request) {
+				return mutationsRequest(b.cfg, sender.Token, url.Values{"tag": []string{"FOOD"}})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				mutationsResp := models.MutationHistoryResponse{}
+				err := getStruct(resp, &mutationsResp)
+				if err != nil {
+					return err
+				}
+				return expectOne(mutationsResp.Data)
+			},
+		},
+		{
+			"Mutations By External Reference #200: ",
+			func() (*http.Client, *http.Request) {
+				return mutationsRequest(b.cfg, sender.Token, url.Values{"external_ref": []string{"inv-2024-001"}})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				mutationsResp := models.MutationHistoryResponse{}
+				err := getStruct(resp, &mutationsResp)
+				if err != nil {
+					return err
+				}
+				err = expectOne(mutationsResp.Data)
+				if err != nil {
+					return err
+				}
+				// the receiver sees the memo, not the tags and reference of the sender
+				received := getMutations(b.cfg, receiver.Token, url.Values{})
+				if len(received.Data) != 1 || received.Data[0].Memo != "Dinner split" ||
+					len(received.Data[0].Tags) != 0 || received.Data[0].ExternalRef != "" {
+					return fmt.Errorf("Got %+v, Want only the memo on the receiver side", received.Data)
+				}
+				return nil
+			},
+		},
+		{
+			"Top Transactions With Note #200: ",
+			func() (*http.Client, *http.Request) {
+				return topTransactionPerUserRequest(b.cfg, sender.Token)
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				topTransactionPerUser := []models.TopTransactionsPerUser{}
+				err := getStruct(resp, &topTransactionPerUser)
+				if err != nil {
+					return err
+				}
+				for _, v := range topTransactionPerUser {
+					if v.Amount == -2500 && v.Memo == "Dinner split" && v.ExternalRef == "inv-2024-001" && len(v.Tags) == 2 {
+						return nil
+					}
+				}
+				return fmt.Errorf("Got %+v, Want the noted transfer", topTransactionPerUser)
+			},
+		},
+		{
+			"Transfer With Note #409 Duplicate External Reference: ",
+			func() (*http.Client, *http.Request) {
+				return transferWithNoteRequest(b.cfg, sender.Token, models.TransferRequest{
+					Amount:      1000,
+					ToUsername:  receiver.UserDetails.Username,
+					ExternalRef: "inv-2024-001",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusConflict {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusConflict)
+				}
+				balance := getBalance(b.cfg, sender.Token)
+				if balance.Balance != 7500 {
+					return fmt.Errorf("Got balance %v, Want %v", balance.Balance, 7500)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer With Note #400 Invalid Tag: ",
+			func() (*http.Client, *http.Request) {
+				return transferWithNoteRequest(b.cfg, sender.Token, models.TransferRequest{
+					Amount:     1000,
+					ToUsername: receiver.UserDetails.Username,
+					Tags:       []string{"not a tag"},
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Transfer With Note #400 Memo Too Long: ",
+			func() (*http.Client, *http.Request) {
+				return transferWithNoteRequest(b.cfg, sender.Token, models.TransferRequest{
+					Amount:     1000,
+					ToUsername: receiver.UserDetails.Username,
+					Memo:       strings.Repeat("a", models.MaxMemoLength+1),
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusBadRequest {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusBadRequest)
+				}
+				return nil
+			},
+		},
+		{
+			"Topup With Note #200: ",
+			func() (*http.Client, *http.Request) {
+				return topupBalanceWithNoteRequest(b.cfg, receiver.Token, models.TopupBalanceRequest{
+					Amount:      500,
+					Memo:        "Pocket money",
+					Tags:        []string{"allowance"},
+					ExternalRef: "inv-2024-001",
+				})
+			},
+			func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("Got %v, Want %v", resp.StatusCode, http.StatusOK)
+				}
+				// external references are unique per user, the sender's one is free here
+				mutations := getMutations(b.cfg, receiver.Token, url.Values{"tag": []string{"allowance"}})
+				if len(mutations.Data) != 1 || mutations.Data[0].Memo != "Pocket money" || mutations.Data[0].ExternalRef != "inv-2024-001" {
+					return fmt.Errorf("Got %+v, Want the noted topup", mutations.Data)
+				}
+				return nil
+			},
+		},
+	}
+	for _, v := range tests {
+		client, req := v.prepare()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		err = v.expectedMet(resp)
+		if err != nil {
+			log.Println(v.testName, "FAILED", err)
+			continue
+		}
+		log.Println(v.testName, "PASS")
+	}
+}
@@ -29,8 +29,154 @@ type Config struct {
 		CacheDuration time.Duration `mapstructure:"CACHE_DURATION"`
 	} `mapstructure:"CACHE"`
 
-	JWT struct {
+	Wallet struct {
+		DefaultCurrency string   `mapstructure:"DEFAULT_CURRENCY"`
+		Currencies      []string `mapstructure:"CURRENCIES"`
+	} `mapstructure:"WALLET"`
+
+	FX struct {
+		RatesFile string        `mapstructure:"RATES_FILE"`
+		QuoteTTL  time.Duration `mapstructure:"QUOTE_TTL"`
+	} `mapstructure:"FX"`
+
+	// Fee is the schedule of transfer fees, in minor units of the transfer currency.
+	// TYPE is one of none, flat, percentage or tiered. TIERS lists "min_amount:fee"
+	// pairs where fee is either flat or a percentage like "0.5%". MIN and MAX cap
+	// the computed fee, a MAX of 0 means uncapped.
+	Fee struct {
+		Type       string   `mapstructure:"TYPE"`
+		Flat       uint32   `mapstructure:"FLAT"`
+		Percentage string   `mapstructure:"PERCENTAGE"`
+		Tiers      []string `mapstructure:"TIERS"`
+		Min        uint32   `mapstructure:"MIN"`
+		Max        uint32   `mapstructure:"MAX"`
+	} `mapstructure:"FEE"`
+
+	// Limits caps the outgoing value and count and the topup value of a user per
+	// UTC day and month, by the tier of the user. Values are in minor units of
+	// WALLET.DEFAULT_CURRENCY, 0 means unlimited.
+	Limits struct {
+		DefaultTier string                `mapstructure:"DEFAULT_TIER"`
+		Tiers       map[string]TierLimits `mapstructure:"TIERS"`
+	} `mapstructure:"LIMITS"`
+
+	// Auth locks a password or PIN for LOCKOUT_DURATION after MAX_FAILED_ATTEMPTS
+	// consecutive failures, a MAX_FAILED_ATTEMPTS of 0 disables lockout.
+	Auth struct {
+		MaxFailedAttempts int           `mapstructure:"MAX_FAILED_ATTEMPTS"`
+		LockoutDuration   time.Duration `mapstructure:"LOCKOUT_DURATION"`
+	} `mapstructure:"AUTH"`
+
+	// TwoFactor holds transfers above TRANSFER_THRESHOLD (in minor units of
+	// WALLET.DEFAULT_CURRENCY, 0 disables it) until confirmed with a TOTP code.
+	TwoFactor struct {
+		Issuer            string        `mapstructure:"ISSUER"`
+		TransferThreshold uint64        `mapstructure:"TRANSFER_THRESHOLD"`
+		ChallengeTTL      time.Duration `mapstructure:"CHALLENGE_TTL"`
+		MaxAttempts       int           `mapstructure:"MAX_ATTEMPTS"`
+		LockoutDuration   time.Duration `mapstructure:"LOCKOUT_DURATION"`
+	} `mapstructure:"TWO_FACTOR"`
+
+	// Outbox relays domain events to SINK, one of stdout, file (appending to FILE)
+	// or http (posting to URL within TIMEOUT). BATCH_SIZE events are read per batch,
+	// every POLL_INTERVAL when idle. LOCK_TTL must outlast a batch. A failed event is
+	// retried after RETRY_INTERVAL and fails for good after MAX_ATTEMPTS.
+	Outbox struct {
+		Sink          string        `mapstructure:"SINK"`
+		File          string        `mapstructure:"FILE"`
+		URL           string        `mapstructure:"URL"`
+		Timeout       time.Duration `mapstructure:"TIMEOUT"`
+		BatchSize     int           `mapstructure:"BATCH_SIZE"`
+		PollInterval  time.Duration `mapstructure:"POLL_INTERVAL"`
+		LockTTL       time.Duration `mapstructure:"LOCK_TTL"`
+		MaxAttempts   int           `mapstructure:"MAX_ATTEMPTS"`
+		RetryInterval time.Duration `mapstructure:"RETRY_INTERVAL"`
+	} `mapstructure:"OUTBOX"`
+
+	// Webhook sends webhook deliveries from the server when DISPATCHER is true. A
+	// failed attempt is retried after BASE_BACKOFF, doubled for every attempt up to
+	// MAX_BACKOFF, until MAX_ATTEMPTS moves the delivery to the dead letters.
+	// Webhooks only reach public addresses unless ALLOW_PRIVATE_URLS, meant for local
+	// development.
+	Webhook struct {
+		Dispatcher       bool          `mapstructure:"DISPATCHER"`
+		Timeout          time.Duration `mapstructure:"TIMEOUT"`
+		BatchSize        int           `mapstructure:"BATCH_SIZE"`
+		PollInterval     time.Duration `mapstructure:"POLL_INTERVAL"`
+		MaxAttempts      int           `mapstructure:"MAX_ATTEMPTS"`
+		BaseBackoff      time.Duration `mapstructure:"BASE_BACKOFF"`
+		MaxBackoff       time.Duration `mapstructure:"MAX_BACKOFF"`
+		AllowPrivateURLs bool          `mapstructure:"ALLOW_PRIVATE_URLS"`
+	} `mapstructure:"WEBHOOK"`
+
+	// PaymentRequest expires a request after DEFAULT_TTL unless it sets its expiry,
+	// at most MAX_TTL ahead (0 means unbounded). The server expires the requests
+	// past their expiry every SWEEP_INTERVAL.
+	PaymentRequest struct {
+		DefaultTTL    time.Duration `mapstructure:"DEFAULT_TTL"`
+		MaxTTL        time.Duration `mapstructure:"MAX_TTL"`
+		SweepInterval time.Duration `mapstructure:"SWEEP_INTERVAL"`
+	} `mapstructure:"PAYMENT_REQUEST"`
+
+	// Scheduler runs the due scheduled transfers from command scheduler. The leader of
+	// the running schedulers holds its lead for LOCK_TTL and renews it every
+	// POLL_INTERVAL, which must be shorter. A failed transfer is retried after
+	// RETRY_INTERVAL, MAX_ATTEMPTS times in all.
+	Scheduler struct {
+		BatchSize     int           `mapstructure:"BATCH_SIZE"`
+		PollInterval  time.Duration `mapstructure:"POLL_INTERVAL"`
+		LockTTL       time.Duration `mapstructure:"LOCK_TTL"`
+		MaxAttempts   int           `mapstructure:"MAX_ATTEMPTS"`
+		RetryInterval time.Duration `mapstructure:"RETRY_INTERVAL"`
+	} `mapstructure:"SCHEDULER"`
+
+	// BulkTransfer takes at most MAX_ITEMS items in a bulk transfer. The server
+	// processes the asynchronous ones every POLL_INTERVAL, BATCH_SIZE at a time, and
+	// takes over one that made no progress for STALE_AFTER.
+	BulkTransfer struct {
+		MaxItems     int           `mapstructure:"MAX_ITEMS"`
+		BatchSize    int           `mapstructure:"BATCH_SIZE"`
+		PollInterval time.Duration `mapstructure:"POLL_INTERVAL"`
+		StaleAfter   time.Duration `mapstructure:"STALE_AFTER"`
+	} `mapstructure:"BULK_TRANSFER"`
+
+	// Escrow releases an escrow to its payee after DEFAULT_RELEASE_AFTER unless it sets
+	// its release time, at most MAX_RELEASE_AFTER ahead (0 means unbounded). The server
+	// releases the escrows past their release time every SWEEP_INTERVAL, one it failed
+	// to release is tried again after RELEASE_RETRY_INTERVAL.
+	Escrow struct {
+		DefaultReleaseAfter  time.Duration `mapstructure:"DEFAULT_RELEASE_AFTER"`
+		MaxReleaseAfter      time.Duration `mapstructure:"MAX_RELEASE_AFTER"`
+		SweepInterval        time.Duration `mapstructure:"SWEEP_INTERVAL"`
+		ReleaseRetryInterval time.Duration `mapstructure:"RELEASE_RETRY_INTERVAL"`
+	} `mapstructure:"ESCROW"`
+
+	// Merchant signs payment payloads with PAYLOAD_SECRET. A dynamic payment code
+	// expires after DYNAMIC_TTL unless it sets its expiry, at most MAX_TTL ahead (0
+	// means unbounded). QR_SIZE is the default width of a QR code in pixels.
+	Merchant struct {
+		PayloadSecret string        `mapstructure:"PAYLOAD_SECRET"`
+		DynamicTTL    time.Duration `mapstructure:"DYNAMIC_TTL"`
+		MaxTTL        time.Duration `mapstructure:"MAX_TTL"`
+		QRSize        int           `mapstructure:"QR_SIZE"`
+	} `mapstructure:"MERCHANT"`
+
+	Internal struct {
 		Secret string `mapstructure:"SECRET"`
+	} `mapstructure:"INTERNAL"`
+
+	Reversal struct {
+		AllowNegativeBalance bool `mapstructure:"ALLOW_NEGATIVE_BALANCE"`
+	} `mapstructure:"REVERSAL"`
+
+	// JWT signs tokens with SECRET (HS256), or with the PEM private keys of KEYS_DIR
+	// when ALGORITHM is RS256 or ES256.
+	JWT struct {
+		Algorithm       string        `mapstructure:"ALGORITHM"`
+		KeysDir         string        `mapstructure:"KEYS_DIR"`
+		Secret          string        `mapstructure:"SECRET"`
+		ExpiresMinutes  int           `mapstructure:"EXPIRES_MINUTES"`
+		RefreshTokenTTL time.Duration `mapstructure:"REFRESH_TOKEN_TTL"`
 	} `mapstructure:"JWT"`
 
 	DB struct {
@@ -61,6 +207,15 @@ type Config struct {
 	} `mapstructure:"DB"`
 }
 
+type TierLimits struct {
+	DailyOutgoingValue   uint64 `mapstructure:"DAILY_OUTGOING_VALUE"`
+	MonthlyOutgoingValue uint64 `mapstructure:"MONTHLY_OUTGOING_VALUE"`
+	DailyOutgoingCount   uint64 `mapstructure:"DAILY_OUTGOING_COUNT"`
+	MonthlyOutgoingCount uint64 `mapstructure:"MONTHLY_OUTGOING_COUNT"`
+	DailyTopupValue      uint64 `mapstructure:"DAILY_TOPUP_VALUE"`
+	MonthlyTopupValue    uint64 `mapstructure:"MONTHLY_TOPUP_VALUE"`
+}
+
 var (
 	conf Config
 	once sync.Once
@@ -2,9 +2,74 @@ package constants
 
 const (
 	CREATE_USER_PATH               = "/create_user"
+	LOGIN_PATH                     = "/login"
+	CREDENTIALS_PATH               = "/credentials"
 	READ_BALANCE_PATH              = "/balance_read"
 	TOPUP_BALANCE_PATH             = "/balance_topup"
 	TRANSFER_PATH                  = "/transfer"
+	TRANSFER_CONFIRM_PATH          = "/transfer/confirm"
+	TWO_FACTOR_ENROLL_PATH         = "/2fa/enroll"
+	TWO_FACTOR_ACTIVATE_PATH       = "/2fa/activate"
 	TOP_TRANSACTIONS_PER_USER_PATH = "/top_transactions_per_user"
 	TOP_USERS_PATH                 = "/top_users"
+	MUTATIONS_PATH                 = "/mutations"
+	WALLETS_PATH                   = "/wallets"
+	FX_QUOTE_PATH                  = "/fx/quote"
+	WITHDRAW_PATH                  = "/withdraw"
+	LIMITS_PATH                    = "/limits"
+	TOKEN_REFRESH_PATH             = "/token/refresh"
+	LOGOUT_PATH                    = "/logout"
+	JWKS_PATH                      = "/.well-known/jwks.json"
+	WITHDRAWAL_CALLBACK_PATH       = "/internal/withdrawal_callback"
+
+	WEBHOOKS_PATH                = "/webhooks"
+	WEBHOOK_PATH                 = "/webhooks/{id}"
+	WEBHOOK_DELIVERIES_PATH      = "/webhooks/deliveries"
+	WEBHOOK_DELIVERY_REPLAY_PATH = "/webhooks/deliveries/{delivery_id}/replay"
+
+	PAYMENT_REQUESTS_PATH          = "/payment_requests"
+	PAYMENT_REQUESTS_INCOMING_PATH = "/payment_requests/incoming"
+	PAYMENT_REQUESTS_OUTGOING_PATH = "/payment_requests/outgoing"
+	PAYMENT_REQUEST_ACCEPT_PATH    = "/payment_requests/{id}/accept"
+	PAYMENT_REQUEST_DECLINE_PATH   = "/payment_requests/{id}/decline"
+	PAYMENT_REQUEST_CANCEL_PATH    = "/payment_requests/{id}/cancel"
+
+	TRANSFER_SCHEDULES_PATH = "/schedules"
+	TRANSFER_SCHEDULE_PATH  = "/schedules/{id}"
+
+	POTS_PATH              = "/pots"
+	POT_PATH               = "/pots/{id}"
+	POT_CONTRIBUTIONS_PATH = "/pots/{id}/contributions"
+	POT_DISBURSE_PATH      = "/pots/{id}/disburse"
+	POT_CLOSE_PATH         = "/pots/{id}/close"
+
+	BULK_TRANSFERS_PATH = "/bulk_transfers"
+	BULK_TRANSFER_PATH  = "/bulk_transfers/{id}"
+
+	ESCROWS_PATH        = "/escrows"
+	ESCROW_PATH         = "/escrows/{id}"
+	ESCROW_RELEASE_PATH = "/escrows/{id}/release"
+	ESCROW_REFUND_PATH  = "/escrows/{id}/refund"
+	ESCROW_DISPUTE_PATH = "/escrows/{id}/dispute"
+
+	MERCHANT_PATH               = "/merchant"
+	MERCHANT_PAYMENT_CODES_PATH = "/merchant/payment_codes"
+	PAY_PATH                    = "/pay"
+
+	ADMIN_USER_PATH             = "/admin/users/{username}"
+	ADMIN_MUTATIONS_PATH        = "/admin/users/{username}/mutations"
+	ADMIN_FREEZE_PATH           = "/admin/users/{username}/freeze"
+	ADMIN_UNFREEZE_PATH         = "/admin/users/{username}/unfreeze"
+	ADMIN_CLOSE_PATH            = "/admin/users/{username}/close"
+	ADMIN_ADJUSTMENTS_PATH      = "/admin/users/{username}/adjustments"
+	ADMIN_REVERSE_TRANSFER_PATH = "/admin/transfers/{ref_id}/reverse"
+	ADMIN_ESCROW_PATH           = "/admin/escrows/{id}"
+	ADMIN_ESCROW_RESOLVE_PATH   = "/admin/escrows/{id}/resolve"
+)
+
+const (
+	IDEMPOTENCY_KEY_HEADER     = "Idempotency-Key"
+	IDEMPOTENT_REPLAYED_HEADER = "Idempotent-Replayed"
+	INTERNAL_SECRET_HEADER     = "X-Internal-Secret"
+	PAYMENT_PAYLOAD_HEADER     = "Payment-Payload"
 )
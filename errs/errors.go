@@ -4,8 +4,110 @@ import "errors"
 
 var (
 	ErrUserAlreadyExists       error = errors.New("User Already Exist")
+	ErrUserNotFound            error = errors.New("User not found")
 	ErrUnauthorized            error = errors.New("Unauthorized")
+	ErrForbidden               error = errors.New("Forbidden")
+	ErrAccountFrozen           error = errors.New("Account frozen")
+	ErrAccountClosed           error = errors.New("Account closed")
+	ErrInvalidAccountStatus    error = errors.New("Invalid account status change")
+	ErrBalanceNotZero          error = errors.New("Account balance is not zero")
+	ErrAccountHasOpenPots      error = errors.New("Account owns or contributed to open pots")
+	ErrAccountHasOpenEscrows   error = errors.New("Account is a party of open escrows")
+	ErrInvalidRole             error = errors.New("Invalid role")
 	ErrInternalServer          error = errors.New("Internal Server Error")
 	ErrInsufficientBalance     error = errors.New("Insufficient balance")
 	ErrDestinationUserNotFound error = errors.New("Destination user not found")
+	ErrDestinationUnavailable  error = errors.New("Destination account can not receive money")
+	ErrIdempotencyReplay       error = errors.New("Idempotent request replayed")
+	ErrIdempotencyKeyMismatch  error = errors.New("Idempotency key already used with a different request")
+	ErrInvalidCursor           error = errors.New("Invalid cursor")
+	ErrTransferNotFound        error = errors.New("Transfer not found")
+	ErrTransferAlreadyReversed error = errors.New("Transfer already reversed")
+
+	ErrWithdrawalNotFound         error = errors.New("Withdrawal not found")
+	ErrWithdrawalAlreadyCompleted error = errors.New("Withdrawal already completed")
+
+	ErrUnsupportedCurrency error = errors.New("Unsupported currency")
+	ErrCurrencyMismatch    error = errors.New("Destination user has no wallet in this currency")
+	ErrWalletNotFound      error = errors.New("Wallet not found")
+	ErrWalletAlreadyExists error = errors.New("Wallet already exists")
+
+	ErrFXRateNotFound            error = errors.New("Exchange rate not found")
+	ErrFXQuoteNotFound           error = errors.New("Quote not found")
+	ErrFXQuoteExpired            error = errors.New("Quote expired")
+	ErrFXQuoteUsed               error = errors.New("Quote already used")
+	ErrFXQuoteMismatch           error = errors.New("Quote does not match transfer currency")
+	ErrConvertedAmountOutOfRange error = errors.New("Converted amount out of range")
+
+	ErrUnbalancedJournalEntry error = errors.New("Unbalanced journal entry")
+
+	ErrLimitExceeded error = errors.New("Transaction limit exceeded")
+
+	ErrInvalidRefreshToken error = errors.New("Invalid refresh token")
+	ErrRefreshTokenReused  error = errors.New("Refresh token reused, session revoked")
+	ErrTokenRevoked        error = errors.New("Token revoked")
+
+	ErrInvalidCredentials error = errors.New("Invalid username or password")
+	ErrAccountLocked      error = errors.New("Too many failed login attempts, try again later")
+	ErrPinRequired        error = errors.New("PIN required")
+	ErrInvalidPin         error = errors.New("Invalid PIN")
+	ErrPinLocked          error = errors.New("Too many failed PIN attempts, try again later")
+	ErrNoCredentials      error = errors.New("No credentials to update")
+	ErrInvalidPassword    error = errors.New("Invalid password")
+
+	ErrTwoFactorAlreadyEnabled error = errors.New("Two-factor authentication already enabled")
+	ErrTwoFactorNotEnrolled    error = errors.New("Two-factor authentication not enrolled")
+	ErrTwoFactorRequired       error = errors.New("Two-factor authentication required for this amount")
+	ErrInvalidTwoFactorCode    error = errors.New("Invalid two-factor code")
+	ErrTwoFactorLocked         error = errors.New("Too many invalid two-factor codes, try again later")
+	ErrChallengeNotFound       error = errors.New("Challenge not found")
+	ErrChallengeExpired        error = errors.New("Challenge expired")
+	ErrChallengeUsed           error = errors.New("Challenge already confirmed")
+
+	ErrInvalidWebhookEvent  error = errors.New("Invalid webhook event")
+	ErrWebhookURLNotAllowed error = errors.New("Webhook url must be a public http or https address")
+	ErrWebhookNotFound      error = errors.New("Webhook not found")
+	ErrDeliveryNotFound     error = errors.New("Webhook delivery not found")
+	ErrDeliveryPending      error = errors.New("Webhook delivery still pending")
+
+	ErrPaymentRequestNotFound   error = errors.New("Payment request not found")
+	ErrPaymentRequestNotPending error = errors.New("Payment request is no longer pending")
+	ErrPaymentRequestExpired    error = errors.New("Payment request expired")
+	ErrInvalidExpiry            error = errors.New("Invalid expiry")
+
+	ErrScheduleNotFound      error = errors.New("Schedule not found")
+	ErrInvalidSchedule       error = errors.New("Set either run_at in the future or cron")
+	ErrInvalidCronExpression error = errors.New("Invalid cron expression")
+	ErrInvalidScheduleStatus error = errors.New("Invalid schedule status change")
+
+	ErrPotNotFound          error = errors.New("Pot not found")
+	ErrPotNotOpen           error = errors.New("Pot is no longer open")
+	ErrNotPotOwner          error = errors.New("Only the owner can do this with the pot")
+	ErrPotMemberNotFound    error = errors.New("Pot member not found")
+	ErrInsufficientPotFunds error = errors.New("Insufficient pot balance")
+	ErrPotRefundUnavailable error = errors.New("A pot member can not receive its refund")
+
+	ErrBulkTransferNotFound     error = errors.New("Bulk transfer not found")
+	ErrEmptyBulkTransfer        error = errors.New("Bulk transfer has no items")
+	ErrTooManyBulkTransferItems error = errors.New("Too many bulk transfer items")
+	ErrBulkTransferItemsInvalid error = errors.New("Some bulk transfer items are invalid")
+	ErrTransferToSelf           error = errors.New("Can not transfer to yourself")
+
+	ErrEscrowNotFound         error = errors.New("Escrow not found")
+	ErrEscrowNotHeld          error = errors.New("Escrow is no longer held")
+	ErrEscrowActionNotAllowed error = errors.New("Escrow action not allowed")
+	ErrInvalidReleaseAt       error = errors.New("Invalid release time")
+
+	ErrNotMerchant           error = errors.New("Account is not a merchant")
+	ErrInvalidPaymentPayload error = errors.New("Invalid payment payload")
+	ErrPaymentPayloadExpired error = errors.New("Payment payload expired")
+	ErrPaymentCodeUsed       error = errors.New("Payment code already paid")
+	ErrPaymentAmountMismatch error = errors.New("Payment amount does not match the payment code")
+	ErrOrderRefWithoutAmount error = errors.New("Order reference requires an amount")
+
+	ErrInvalidMemo          error = errors.New("Invalid memo")
+	ErrInvalidTag           error = errors.New("Invalid tag")
+	ErrTooManyTags          error = errors.New("Too many tags")
+	ErrInvalidExternalRef   error = errors.New("Invalid external reference")
+	ErrDuplicateExternalRef error = errors.New("External reference already used")
 )
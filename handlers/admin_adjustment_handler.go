@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminAdjustmentHandler struct {
+	AdminService services.IAdminService
+}
+
+func (aah *AdminAdjustmentHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	req, err := aah.validateAndGetAdjustmentPayload(r)
+	if err != nil {
+		aah.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := aah.AdminService.Adjust(claims.Username, chi.URLParam(r, "username"), req)
+	if err != nil {
+		if err.Error() == errs.ErrUserNotFound.Error() {
+			aah.errNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrInsufficientBalance.Error() ||
+			err.Error() == errs.ErrUnsupportedCurrency.Error() {
+			aah.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountClosed.Error() {
+			aah.errConflict(w, err.Error())
+			return
+		}
+		aah.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+func (aah *AdminAdjustmentHandler) validateAndGetAdjustmentPayload(r *http.Request) (req models.AdminAdjustmentRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (aah *AdminAdjustmentHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (aah *AdminAdjustmentHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (aah *AdminAdjustmentHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (aah *AdminAdjustmentHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminCloseHandler struct {
+	AdminService services.IAdminService
+}
+
+func (ach *AdminCloseHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	req, err := ach.validateAndGetClosePayload(r)
+	if err != nil {
+		ach.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := ach.AdminService.CloseAccount(claims.Username, chi.URLParam(r, "username"), req.Payout, req.Reason)
+	if err != nil {
+		if err.Error() == errs.ErrUserNotFound.Error() {
+			ach.errNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountClosed.Error() ||
+			err.Error() == errs.ErrBalanceNotZero.Error() ||
+			err.Error() == errs.ErrAccountHasOpenPots.Error() ||
+			err.Error() == errs.ErrAccountHasOpenEscrows.Error() {
+			ach.errConflict(w, err.Error())
+			return
+		}
+		ach.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (ach *AdminCloseHandler) validateAndGetClosePayload(r *http.Request) (req models.AdminCloseRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (ach *AdminCloseHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (ach *AdminCloseHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (ach *AdminCloseHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (ach *AdminCloseHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminEscrowHandler struct {
+	EscrowService services.IEscrowService
+}
+
+// Get returns any escrow for an arbitrator to decide on.
+func (aeh *AdminEscrowHandler) Get(w http.ResponseWriter, r *http.Request) {
+	resp, err := aeh.EscrowService.Inspect(chi.URLParam(r, "id"))
+	if err != nil {
+		aeh.writeEscrowError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Resolve releases an escrow to its payee or refunds it to its payer.
+func (aeh *AdminEscrowHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	req, err := aeh.validateAndGetResolvePayload(r)
+	if err != nil {
+		aeh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := aeh.EscrowService.Resolve(claims.Username, chi.URLParam(r, "id"), req.Outcome, req.Reason)
+	if err != nil {
+		aeh.writeEscrowError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (aeh *AdminEscrowHandler) writeEscrowError(w http.ResponseWriter, err error) {
+	if err.Error() == errs.ErrEscrowNotFound.Error() {
+		aeh.errNotFound(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrEscrowNotHeld.Error() ||
+		err.Error() == errs.ErrDestinationUnavailable.Error() {
+		aeh.errConflict(w, err.Error())
+		return
+	}
+	aeh.errInternal(w, err.Error())
+}
+
+func (aeh *AdminEscrowHandler) validateAndGetResolvePayload(r *http.Request) (req models.AdminEscrowResolveRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	return
+}
+
+func (aeh *AdminEscrowHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (aeh *AdminEscrowHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (aeh *AdminEscrowHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (aeh *AdminEscrowHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
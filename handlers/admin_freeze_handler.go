@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminFreezeHandler freezes an account, or unfreezes it when Frozen is false.
+type AdminFreezeHandler struct {
+	AdminService services.IAdminService
+	Frozen       bool
+}
+
+func (afh *AdminFreezeHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	req, err := afh.validateAndGetFreezePayload(r)
+	if err != nil {
+		afh.errBadRequest(w, err.Error())
+		return
+	}
+
+	status := models.ACTIVE
+	if afh.Frozen {
+		status = models.FROZEN_ALL
+		if req.Scope == "debit" {
+			status = models.FROZEN_DEBIT
+		}
+	}
+
+	err = afh.AdminService.SetStatus(claims.Username, chi.URLParam(r, "username"), status, req.Reason)
+	if err != nil {
+		if err.Error() == errs.ErrUserNotFound.Error() {
+			afh.errNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountClosed.Error() ||
+			err.Error() == errs.ErrInvalidAccountStatus.Error() {
+			afh.errConflict(w, err.Error())
+			return
+		}
+		afh.errInternal(w, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (afh *AdminFreezeHandler) validateAndGetFreezePayload(r *http.Request) (req models.AdminFreezeRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (afh *AdminFreezeHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (afh *AdminFreezeHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (afh *AdminFreezeHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (afh *AdminFreezeHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
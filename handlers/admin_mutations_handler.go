@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminMutationsHandler struct {
+	AdminService services.IAdminService
+}
+
+func (amh *AdminMutationsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	filter, err := validateAndGetMutationHistoryFilter(r)
+	if err != nil {
+		amh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := amh.AdminService.GetMutations(chi.URLParam(r, "username"), filter)
+	if err != nil {
+		if err.Error() == errs.ErrUserNotFound.Error() {
+			amh.errNotFound(w, err.Error())
+			return
+		}
+		amh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (amh *AdminMutationsHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (amh *AdminMutationsHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (amh *AdminMutationsHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
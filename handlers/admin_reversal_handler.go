@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminReversalHandler struct {
+	AdminService services.IAdminService
+}
+
+func (arh *AdminReversalHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	req, err := arh.validateAndGetReversalPayload(r)
+	if err != nil {
+		arh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := arh.AdminService.ReverseTransfer(claims.Username, chi.URLParam(r, "ref_id"), req.Reason)
+	if err != nil {
+		if err.Error() == errs.ErrTransferNotFound.Error() {
+			arh.errNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrTransferAlreadyReversed.Error() ||
+			err.Error() == errs.ErrAccountClosed.Error() ||
+			err.Error() == errs.ErrWalletNotFound.Error() {
+			arh.errConflict(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrInsufficientBalance.Error() {
+			arh.errBadRequest(w, err.Error())
+			return
+		}
+		arh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+func (arh *AdminReversalHandler) validateAndGetReversalPayload(r *http.Request) (req models.AdminActionRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (arh *AdminReversalHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (arh *AdminReversalHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (arh *AdminReversalHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (arh *AdminReversalHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminUserHandler struct {
+	AdminService services.IAdminService
+}
+
+func (auh *AdminUserHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	resp, err := auh.AdminService.GetUser(chi.URLParam(r, "username"))
+	if err != nil {
+		if err.Error() == errs.ErrUserNotFound.Error() {
+			auh.errNotFound(w, err.Error())
+			return
+		}
+		auh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (auh *AdminUserHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (auh *AdminUserHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
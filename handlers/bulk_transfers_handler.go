@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxBulkTransferUpload is the most of an uploaded CSV of bulk transfer items kept in
+// memory, a larger file is buffered on disk.
+const maxBulkTransferUpload = 4 << 20
+
+type BulkTransfersHandler struct {
+	UserService         services.IUserService
+	BulkTransferService services.IBulkTransferService
+	TwoFactorService    services.ITwoFactorService
+}
+
+// Create pays several users from the account in the token. The items come in a JSON
+// body, or as the "file" CSV of a multipart form whose other fields are those of the
+// JSON body. An asynchronous bulk transfer is answered with 202 and polled by its ID.
+func (bh *BulkTransfersHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := bh.getUser(r)
+
+	req, err := bh.validateAndGetCreatePayload(r)
+	if err != nil {
+		bh.errBadRequest(w, err.Error())
+		return
+	}
+
+	var total uint64
+	for _, v := range req.Items {
+		total += uint64(v.Amount)
+	}
+	if total > math.MaxUint32 {
+		total = math.MaxUint32
+	}
+	authorizer := transferAuthorizer{UserService: bh.UserService, TwoFactorService: bh.TwoFactorService}
+	if !authorizer.authorize(w, user, req.Pin, req.Code, uint32(total), req.Currency) {
+		return
+	}
+
+	resp, err := bh.BulkTransferService.Create(user, req)
+	if err != nil {
+		bh.writeBulkTransferError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	if req.Async {
+		w.WriteHeader(202)
+	} else {
+		w.WriteHeader(200)
+	}
+	w.Write(bResp)
+}
+
+// Get returns a bulk transfer of the account in the token with its progress.
+func (bh *BulkTransfersHandler) Get(w http.ResponseWriter, r *http.Request) {
+	resp, err := bh.BulkTransferService.Get(bh.getUser(r), chi.URLParam(r, "id"))
+	if err != nil {
+		bh.writeBulkTransferError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (bh *BulkTransfersHandler) writeBulkTransferError(w http.ResponseWriter, err error) {
+	if err.Error() == errs.ErrBulkTransferNotFound.Error() {
+		bh.errNotFound(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrEmptyBulkTransfer.Error() ||
+		err.Error() == errs.ErrTooManyBulkTransferItems.Error() ||
+		err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+		err.Error() == errs.ErrWalletNotFound.Error() {
+		bh.errBadRequest(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrUnauthorized.Error() {
+		bh.errUnauthorized(w, err.Error())
+		return
+	}
+	bh.errInternal(w, err.Error())
+}
+
+func (bh *BulkTransfersHandler) getUser(r *http.Request) models.User {
+	claims := r.Context().Value("token").(*models.JwtClaims)
+	return models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+}
+
+func (bh *BulkTransfersHandler) validateAndGetCreatePayload(r *http.Request) (req models.CreateBulkTransferRequest, err error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		req, err = bh.getCSVPayload(r)
+	} else {
+		var bodyByte []byte
+		bodyByte, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(bodyByte, &req)
+	}
+	if err != nil {
+		return
+	}
+
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	for i, v := range req.Items {
+		_, err = govalidator.ValidateStruct(v)
+		if err != nil {
+			err = fmt.Errorf("item %v: %v", i+1, err)
+			return
+		}
+	}
+	return
+}
+
+// getCSVPayload reads a bulk transfer uploaded as a CSV file.
+func (bh *BulkTransfersHandler) getCSVPayload(r *http.Request) (req models.CreateBulkTransferRequest, err error) {
+	err = r.ParseMultipartForm(maxBulkTransferUpload)
+	if err != nil {
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	req.Items, err = models.ParseBulkTransferCSV(file, bh.BulkTransferService.ItemLimit())
+	if err != nil {
+		return
+	}
+
+	req.Mode = models.BulkTransferMode(r.FormValue("mode"))
+	req.Currency = r.FormValue("currency")
+	req.Pin = r.FormValue("pin")
+	req.Code = r.FormValue("code")
+	if async := r.FormValue("async"); async != "" {
+		req.Async, err = strconv.ParseBool(async)
+	}
+	return
+}
+
+func (bh *BulkTransfersHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (bh *BulkTransfersHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (bh *BulkTransfersHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (bh *BulkTransfersHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type CreateWalletHandler struct {
+	UserBalanceService services.IUserBalanceService
+}
+
+func (cwh *CreateWalletHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	req, err := cwh.validateAndGetCreateWalletPayload(r)
+	if err != nil {
+		cwh.errBadRequest(w, err.Error())
+		return
+	}
+
+	wallet, err := cwh.UserBalanceService.CreateWallet(user, req.Currency)
+	if err != nil {
+		if err.Error() == errs.ErrUnsupportedCurrency.Error() {
+			cwh.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrWalletAlreadyExists.Error() {
+			cwh.errConflict(w, err.Error())
+			return
+		}
+		cwh.errInternal(w, err.Error())
+		return
+	}
+
+	resp := models.WalletResponse{
+		Currency:         wallet.Currency,
+		Balance:          wallet.Balance,
+		HeldBalance:      wallet.HeldBalance,
+		AvailableBalance: wallet.AvailableBalance(),
+	}
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+func (cwh *CreateWalletHandler) validateAndGetCreateWalletPayload(r *http.Request) (req models.CreateWalletRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (cwh *CreateWalletHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (cwh *CreateWalletHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (cwh *CreateWalletHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type CredentialsHandler struct {
+	UserService services.IUserService
+}
+
+func (ch *CredentialsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	req, err := ch.validateAndGetCredentialsPayload(r)
+	if err != nil {
+		ch.errBadRequest(w, err.Error())
+		return
+	}
+
+	err = ch.UserService.UpdateCredentials(user, req)
+	if err != nil {
+		if err.Error() == errs.ErrNoCredentials.Error() {
+			ch.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrInvalidCredentials.Error() ||
+			err.Error() == errs.ErrUnauthorized.Error() {
+			ch.errUnauthorized(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountLocked.Error() {
+			ch.errLocked(w, err.Error())
+			return
+		}
+		ch.errInternal(w, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (ch *CredentialsHandler) validateAndGetCredentialsPayload(r *http.Request) (req models.UpdateCredentialsRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	if len(req.Password) > models.MaxPasswordBytes {
+		err = errs.ErrInvalidPassword
+	}
+	return
+}
+
+func (ch *CredentialsHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (ch *CredentialsHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (ch *CredentialsHandler) errLocked(w http.ResponseWriter, message string) {
+	w.WriteHeader(423)
+	w.Write([]byte(message))
+}
+
+func (ch *CredentialsHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type EscrowsHandler struct {
+	UserService      services.IUserService
+	EscrowService    services.IEscrowService
+	TwoFactorService services.ITwoFactorService
+}
+
+// Create holds money of the account in the token for a payee until it is released.
+func (eh *EscrowsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := eh.getUser(r)
+
+	req, err := eh.validateAndGetCreatePayload(r)
+	if err != nil {
+		eh.errBadRequest(w, err.Error())
+		return
+	}
+
+	authorizer := transferAuthorizer{UserService: eh.UserService, TwoFactorService: eh.TwoFactorService}
+	if !authorizer.authorize(w, user, req.Pin, req.Code, req.Amount, req.Currency) {
+		return
+	}
+
+	resp, err := eh.EscrowService.Create(user, req)
+	if err != nil {
+		eh.writeEscrowError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+// List returns the latest escrows the account in the token pays or is paid by,
+// filtered by query parameter status.
+func (eh *EscrowsHandler) List(w http.ResponseWriter, r *http.Request) {
+	status := models.EscrowStatus(r.URL.Query().Get("status"))
+	if status != "" && !status.IsValid() {
+		eh.errBadRequest(w, "Invalid status")
+		return
+	}
+
+	resp, err := eh.EscrowService.List(eh.getUser(r), status)
+	if err != nil {
+		eh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Get returns an escrow the account in the token pays or is paid by.
+func (eh *EscrowsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	resp, err := eh.EscrowService.Get(eh.getUser(r), chi.URLParam(r, "id"))
+	eh.writeEscrow(w, resp, err)
+}
+
+// Release pays an escrow to its payee, by its payer.
+func (eh *EscrowsHandler) Release(w http.ResponseWriter, r *http.Request) {
+	resp, err := eh.EscrowService.Release(eh.getUser(r), chi.URLParam(r, "id"))
+	eh.writeEscrow(w, resp, err)
+}
+
+// Refund pays an escrow back to its payer, by its payee.
+func (eh *EscrowsHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	resp, err := eh.EscrowService.Refund(eh.getUser(r), chi.URLParam(r, "id"))
+	eh.writeEscrow(w, resp, err)
+}
+
+// Dispute leaves an escrow to an arbitrator instead of releasing it at its deadline.
+func (eh *EscrowsHandler) Dispute(w http.ResponseWriter, r *http.Request) {
+	resp, err := eh.EscrowService.Dispute(eh.getUser(r), chi.URLParam(r, "id"))
+	eh.writeEscrow(w, resp, err)
+}
+
+func (eh *EscrowsHandler) writeEscrow(w http.ResponseWriter, resp models.EscrowResponse, err error) {
+	if err != nil {
+		eh.writeEscrowError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (eh *EscrowsHandler) writeEscrowError(w http.ResponseWriter, err error) {
+	if err.Error() == errs.ErrEscrowNotFound.Error() {
+		eh.errNotFound(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrEscrowActionNotAllowed.Error() ||
+		err.Error() == errs.ErrDestinationUnavailable.Error() ||
+		err.Error() == errs.ErrAccountFrozen.Error() ||
+		err.Error() == errs.ErrAccountClosed.Error() {
+		eh.errForbidden(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrEscrowNotHeld.Error() {
+		eh.errConflict(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrInvalidReleaseAt.Error() ||
+		err.Error() == errs.ErrTransferToSelf.Error() ||
+		err.Error() == errs.ErrDestinationUserNotFound.Error() ||
+		err.Error() == errs.ErrCurrencyMismatch.Error() ||
+		err.Error() == errs.ErrInsufficientBalance.Error() ||
+		err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+		err.Error() == errs.ErrWalletNotFound.Error() {
+		eh.errBadRequest(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrLimitExceeded.Error() {
+		eh.errLimitExceeded(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrUnauthorized.Error() {
+		eh.errUnauthorized(w, err.Error())
+		return
+	}
+	eh.errInternal(w, err.Error())
+}
+
+func (eh *EscrowsHandler) getUser(r *http.Request) models.User {
+	claims := r.Context().Value("token").(*models.JwtClaims)
+	return models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+}
+
+func (eh *EscrowsHandler) validateAndGetCreatePayload(r *http.Request) (req models.CreateEscrowRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	return
+}
+
+func (eh *EscrowsHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (eh *EscrowsHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (eh *EscrowsHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
+func (eh *EscrowsHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (eh *EscrowsHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (eh *EscrowsHandler) errLimitExceeded(w http.ResponseWriter, message string) {
+	w.WriteHeader(429)
+	w.Write([]byte(message))
+}
+
+func (eh *EscrowsHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
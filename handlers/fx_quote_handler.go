@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type FXQuoteHandler struct {
+	FXService services.IFXService
+}
+
+func (fqh *FXQuoteHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	req, err := fqh.validateAndGetQuotePayload(r)
+	if err != nil {
+		fqh.errBadRequest(w, err.Error())
+		return
+	}
+
+	quote, err := fqh.FXService.CreateQuote(user, req.FromCurrency, req.ToCurrency)
+	if err != nil {
+		if err.Error() == errs.ErrUnsupportedCurrency.Error() {
+			fqh.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrFXRateNotFound.Error() {
+			fqh.errNotFound(w, err.Error())
+			return
+		}
+		fqh.errInternal(w, err.Error())
+		return
+	}
+
+	resp := models.FXQuoteResponse{
+		QuoteID:      quote.ID,
+		FromCurrency: quote.FromCurrency,
+		ToCurrency:   quote.ToCurrency,
+		Rate:         quote.Rate,
+		ExpiresAt:    quote.ExpiresAt,
+	}
+	if req.Amount > 0 {
+		resp.Amount = req.Amount
+		resp.ConvertedAmount, err = models.ConvertAmount(req.Amount, quote.Rate)
+		if err != nil {
+			fqh.errBadRequest(w, err.Error())
+			return
+		}
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+func (fqh *FXQuoteHandler) validateAndGetQuotePayload(r *http.Request) (req models.FXQuoteRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (fqh *FXQuoteHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (fqh *FXQuoteHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (fqh *FXQuoteHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/atrariksa/awallet/constants"
+	"github.com/atrariksa/awallet/models"
+)
+
+const maxIdempotencyKeyLength = 191
+
+// getIdempotencyKey builds the idempotency record of a request from its Idempotency-Key header.
+// It returns nil when the client does not send the header.
+func getIdempotencyKey(r *http.Request, user models.User, req interface{}) (*models.IdempotencyKey, error) {
+	return newIdempotencyKey(r.Header.Get(constants.IDEMPOTENCY_KEY_HEADER), r.URL.Path, user, req)
+}
+
+// newIdempotencyKey builds the idempotency record of a request replayed later, such as a
+// transfer confirmed after a two-factor challenge. It returns nil when key is empty.
+func newIdempotencyKey(key string, path string, user models.User, req interface{}) (*models.IdempotencyKey, error) {
+	if key == "" {
+		return nil, nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, errors.New("Invalid idempotency key")
+	}
+	return models.NewIdempotencyKey(user, key, path, req)
+}
+
+func writeIdempotentReplay(w http.ResponseWriter, idempotencyKey *models.IdempotencyKey) {
+	w.Header().Set(constants.IDEMPOTENT_REPLAYED_HEADER, "true")
+	w.WriteHeader(idempotencyKey.ResponseCode)
+	w.Write(idempotencyKey.ResponseBody)
+}
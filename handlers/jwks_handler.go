@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/atrariksa/awallet/services"
+)
+
+type JWKSHandler struct {
+	TokenService services.ITokenService
+}
+
+func (jh *JWKSHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	resp, err := jh.TokenService.JWKS()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
@@ -13,7 +13,8 @@ type ListTopUserHandler struct {
 
 func (ltu *ListTopUserHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
-	resp, err := ltu.UserService.GetListTopUser()
+	currency := r.URL.Query().Get("currency")
+	resp, err := ltu.UserService.GetListTopUser(currency)
 	if err != nil {
 		w.WriteHeader(500)
 		w.Write([]byte(err.Error()))
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type LoginHandler struct {
+	UserService  services.IUserService
+	TokenService services.ITokenService
+}
+
+func (lh *LoginHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	req, err := lh.validateAndGetLoginPayload(r)
+	if err != nil {
+		lh.errBadRequest(w, err.Error())
+		return
+	}
+
+	user, err := lh.UserService.Login(req.Username, req.Password)
+	if err != nil {
+		if err.Error() == errs.ErrInvalidCredentials.Error() {
+			lh.errUnauthorized(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountLocked.Error() {
+			lh.errLocked(w, err.Error())
+			return
+		}
+		lh.errInternal(w, err.Error())
+		return
+	}
+
+	resp, err := lh.TokenService.IssueTokens(user)
+	if err != nil {
+		lh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (lh *LoginHandler) validateAndGetLoginPayload(r *http.Request) (req models.LoginRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (lh *LoginHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (lh *LoginHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (lh *LoginHandler) errLocked(w http.ResponseWriter, message string) {
+	w.WriteHeader(423)
+	w.Write([]byte(message))
+}
+
+func (lh *LoginHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/constants"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const defaultQRSize = 256
+
+type MerchantHandler struct {
+	MerchantService services.IMerchantService
+	// QRSize is the width in pixels of a QR code whose request does not set it.
+	QRSize int
+}
+
+// Register makes the account in the token a merchant, or changes its settings.
+func (mh *MerchantHandler) Register(w http.ResponseWriter, r *http.Request) {
+	req, err := mh.validateAndGetMerchantPayload(r)
+	if err != nil {
+		mh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := mh.MerchantService.Register(mh.getUser(r), req)
+	if err != nil {
+		mh.writeMerchantError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Get returns the merchant settings of the account in the token.
+func (mh *MerchantHandler) Get(w http.ResponseWriter, r *http.Request) {
+	resp, err := mh.MerchantService.Get(mh.getUser(r))
+	if err != nil {
+		mh.writeMerchantError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// CreatePaymentCode answers with a payment QR code of the merchant in the token as a
+// PNG image. The payload the code holds is also sent in header Payment-Payload.
+func (mh *MerchantHandler) CreatePaymentCode(w http.ResponseWriter, r *http.Request) {
+	req, err := mh.validateAndGetPaymentCodePayload(r)
+	if err != nil {
+		mh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := mh.MerchantService.CreatePaymentCode(mh.getUser(r), req)
+	if err != nil {
+		mh.writeMerchantError(w, err)
+		return
+	}
+
+	size := req.Size
+	if size == 0 {
+		size = mh.qrSize()
+	}
+	png, err := qrcode.Encode(resp.Payload, qrcode.Medium, size)
+	if err != nil {
+		mh.errInternal(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set(constants.PAYMENT_PAYLOAD_HEADER, resp.Payload)
+	w.WriteHeader(201)
+	w.Write(png)
+}
+
+func (mh *MerchantHandler) qrSize() int {
+	if mh.QRSize <= 0 {
+		return defaultQRSize
+	}
+	return mh.QRSize
+}
+
+func (mh *MerchantHandler) writeMerchantError(w http.ResponseWriter, err error) {
+	if err.Error() == errs.ErrNotMerchant.Error() ||
+		err.Error() == errs.ErrAccountFrozen.Error() ||
+		err.Error() == errs.ErrAccountClosed.Error() {
+		mh.errForbidden(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrInvalidExpiry.Error() ||
+		err.Error() == errs.ErrOrderRefWithoutAmount.Error() ||
+		err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+		err.Error() == errs.ErrWalletNotFound.Error() {
+		mh.errBadRequest(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrUnauthorized.Error() {
+		mh.errUnauthorized(w, err.Error())
+		return
+	}
+	mh.errInternal(w, err.Error())
+}
+
+func (mh *MerchantHandler) getUser(r *http.Request) models.User {
+	claims := r.Context().Value("token").(*models.JwtClaims)
+	return models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+}
+
+func (mh *MerchantHandler) validateAndGetMerchantPayload(r *http.Request) (req models.MerchantRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	return
+}
+
+func (mh *MerchantHandler) validateAndGetPaymentCodePayload(r *http.Request) (req models.CreatePaymentCodeRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if len(bodyByte) > 0 {
+		err = json.Unmarshal(bodyByte, &req)
+		if err != nil {
+			return
+		}
+	}
+	_, err = govalidator.ValidateStruct(req)
+	return
+}
+
+func (mh *MerchantHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (mh *MerchantHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (mh *MerchantHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
+func (mh *MerchantHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
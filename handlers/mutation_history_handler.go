@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+const (
+	defaultMutationHistoryLimit = 20
+	maxMutationHistoryLimit     = 100
+)
+
+type MutationHistoryHandler struct {
+	UserBalanceService services.IUserBalanceService
+}
+
+func (mhh *MutationHistoryHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	filter, err := validateAndGetMutationHistoryFilter(r)
+	if err != nil {
+		mhh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := mhh.UserBalanceService.GetMutations(user, filter)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+	} else {
+		bResp, _ := json.Marshal(&resp)
+		w.WriteHeader(200)
+		w.Write(bResp)
+	}
+}
+
+// validateAndGetMutationHistoryFilter reads the filter of a mutation history from the query string.
+func validateAndGetMutationHistoryFilter(r *http.Request) (filter models.MutationHistoryFilter, err error) {
+	query := r.URL.Query()
+
+	filter.Limit = defaultMutationHistoryLimit
+	if v := query.Get("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxMutationHistoryLimit {
+			return filter, errors.New("Invalid limit")
+		}
+	}
+
+	if v := query.Get("type"); v != "" {
+		filter.MutationType = models.MutationType(v)
+		if !filter.MutationType.IsValid() {
+			return filter, errors.New("Invalid type")
+		}
+	}
+
+	filter.Currency = query.Get("currency")
+
+	if v := query.Get("from"); v != "" {
+		from, errParse := time.Parse(time.RFC3339, v)
+		if errParse != nil {
+			return filter, errors.New("Invalid from")
+		}
+		filter.From = &from
+	}
+
+	if v := query.Get("to"); v != "" {
+		to, errParse := time.Parse(time.RFC3339, v)
+		if errParse != nil {
+			return filter, errors.New("Invalid to")
+		}
+		filter.To = &to
+	}
+
+	if v := query.Get("min_amount"); v != "" {
+		minAmount, errParse := strconv.ParseUint(v, 10, 32)
+		if errParse != nil {
+			return filter, errors.New("Invalid min_amount")
+		}
+		amount := uint32(minAmount)
+		filter.MinAmount = &amount
+	}
+
+	if v := query.Get("max_amount"); v != "" {
+		maxAmount, errParse := strconv.ParseUint(v, 10, 32)
+		if errParse != nil {
+			return filter, errors.New("Invalid max_amount")
+		}
+		amount := uint32(maxAmount)
+		filter.MaxAmount = &amount
+	}
+
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return filter, errors.New("Invalid amount range")
+	}
+
+	filter.Tag = models.NormalizeTag(query.Get("tag"))
+	filter.ExternalRef = query.Get("external_ref")
+
+	if v := query.Get("cursor"); v != "" {
+		filter.Cursor, err = models.DecodeMutationCursor(v)
+		if err != nil {
+			return
+		}
+	}
+
+	return filter, nil
+}
+
+func (mhh *MutationHistoryHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
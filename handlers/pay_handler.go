@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/constants"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type PayHandler struct {
+	UserService      services.IUserService
+	MerchantService  services.IMerchantService
+	TwoFactorService services.ITwoFactorService
+}
+
+// Handle pays the merchant of a payment payload from the account in the token.
+func (ph *PayHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	req, err := ph.validateAndGetPayPayload(r)
+	if err != nil {
+		ph.errBadRequest(w, err.Error())
+		return
+	}
+
+	params, merchant, err := ph.MerchantService.PreparePayment(user, req)
+	if err != nil {
+		ph.writePayError(w, err)
+		return
+	}
+
+	authorizer := transferAuthorizer{UserService: ph.UserService, TwoFactorService: ph.TwoFactorService}
+	if !authorizer.authorize(w, user, req.Pin, req.Code, params.Amount, params.Currency) {
+		return
+	}
+
+	// the PIN and the code are not part of the request a retry must repeat
+	req.Pin, req.Code = "", ""
+	params.IdempotencyKey, err = getIdempotencyKey(r, user, req)
+	if err != nil {
+		ph.errBadRequest(w, err.Error())
+		return
+	}
+	if params.IdempotencyKey != nil {
+		params.IdempotencyKey.ResponseCode = 200
+	}
+
+	resp, err := ph.MerchantService.Pay(user, merchant, params)
+	if err != nil {
+		if err.Error() == errs.ErrIdempotencyReplay.Error() {
+			w.Header().Set(constants.IDEMPOTENT_REPLAYED_HEADER, "true")
+		} else {
+			ph.writePayError(w, err)
+			return
+		}
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (ph *PayHandler) writePayError(w http.ResponseWriter, err error) {
+	if err.Error() == errs.ErrInvalidPaymentPayload.Error() ||
+		err.Error() == errs.ErrPaymentPayloadExpired.Error() ||
+		err.Error() == errs.ErrPaymentAmountMismatch.Error() ||
+		err.Error() == errs.ErrTransferToSelf.Error() ||
+		err.Error() == errs.ErrInsufficientBalance.Error() ||
+		err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+		err.Error() == errs.ErrCurrencyMismatch.Error() ||
+		err.Error() == errs.ErrWalletNotFound.Error() {
+		ph.errBadRequest(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrIdempotencyKeyMismatch.Error() {
+		ph.errUnprocessable(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrAccountFrozen.Error() ||
+		err.Error() == errs.ErrAccountClosed.Error() {
+		ph.errForbidden(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrPaymentCodeUsed.Error() {
+		ph.errConflict(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrDestinationUnavailable.Error() {
+		ph.errUnprocessable(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrLimitExceeded.Error() {
+		ph.errLimitExceeded(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrUnauthorized.Error() {
+		ph.errUnauthorized(w, err.Error())
+		return
+	}
+	ph.errInternal(w, err.Error())
+}
+
+func (ph *PayHandler) validateAndGetPayPayload(r *http.Request) (req models.PayRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	return
+}
+
+func (ph *PayHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (ph *PayHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (ph *PayHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
+func (ph *PayHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (ph *PayHandler) errUnprocessable(w http.ResponseWriter, message string) {
+	w.WriteHeader(422)
+	w.Write([]byte(message))
+}
+
+func (ph *PayHandler) errLimitExceeded(w http.ResponseWriter, message string) {
+	w.WriteHeader(429)
+	w.Write([]byte(message))
+}
+
+func (ph *PayHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
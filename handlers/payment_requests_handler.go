@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultPaymentRequestsLimit = 20
+	maxPaymentRequestsLimit     = 100
+)
+
+type PaymentRequestsHandler struct {
+	UserService           services.IUserService
+	PaymentRequestService services.IPaymentRequestService
+	TwoFactorService      services.ITwoFactorService
+}
+
+// Create asks the payer of the request to pay the account in the token.
+func (prh *PaymentRequestsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := prh.getUser(r)
+
+	req, err := prh.validateAndGetCreatePayload(r)
+	if err != nil {
+		prh.errBadRequest(w, err.Error())
+		return
+	}
+
+	if user.Username == req.PayerUsername {
+		prh.errBadRequest(w, http.StatusText(http.StatusBadRequest))
+		return
+	}
+
+	resp, err := prh.PaymentRequestService.Create(user, req)
+	if err != nil {
+		if err.Error() == errs.ErrInvalidExpiry.Error() ||
+			err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+			err.Error() == errs.ErrWalletNotFound.Error() {
+			prh.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrDestinationUserNotFound.Error() {
+			prh.errNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrUnauthorized.Error() {
+			prh.errUnauthorized(w, err.Error())
+			return
+		}
+		prh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+// ListIncoming returns the payment requests addressed to the account in the token,
+// filtered by query parameter status, a page of limit from cursor.
+func (prh *PaymentRequestsHandler) ListIncoming(w http.ResponseWriter, r *http.Request) {
+	filter, err := validateAndGetPaymentRequestFilter(r)
+	if err != nil {
+		prh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := prh.PaymentRequestService.ListIncoming(prh.getUser(r), filter)
+	if err != nil {
+		prh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// ListOutgoing returns the payment requests of the account in the token, filtered
+// like ListIncoming.
+func (prh *PaymentRequestsHandler) ListOutgoing(w http.ResponseWriter, r *http.Request) {
+	filter, err := validateAndGetPaymentRequestFilter(r)
+	if err != nil {
+		prh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := prh.PaymentRequestService.ListOutgoing(prh.getUser(r), filter)
+	if err != nil {
+		prh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// validateAndGetPaymentRequestFilter reads the status, limit and cursor of a list of
+// payment requests from the query string.
+func validateAndGetPaymentRequestFilter(r *http.Request) (filter models.PaymentRequestFilter, err error) {
+	query := r.URL.Query()
+
+	filter.Limit = defaultPaymentRequestsLimit
+	if v := query.Get("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxPaymentRequestsLimit {
+			return filter, errors.New("Invalid limit")
+		}
+	}
+
+	filter.Status = models.PaymentRequestStatus(query.Get("status"))
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return filter, errors.New("Invalid status")
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		filter.Cursor, err = models.DecodePaymentRequestCursor(v)
+		if err != nil {
+			return
+		}
+	}
+
+	return filter, nil
+}
+
+// Accept pays a payment request with a transfer to its requester. The transfer PIN
+// and, above the two-factor threshold, a two-factor code are checked like for a
+// regular transfer.
+func (prh *PaymentRequestsHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	user := prh.getUser(r)
+
+	req, err := prh.validateAndGetAcceptPayload(r)
+	if err != nil {
+		prh.errBadRequest(w, err.Error())
+		return
+	}
+
+	paymentRequest, err := prh.PaymentRequestService.GetPending(user, chi.URLParam(r, "id"))
+	if err != nil {
+		prh.writePaymentRequestError(w, err)
+		return
+	}
+
+	authorizer := transferAuthorizer{UserService: prh.UserService, TwoFactorService: prh.TwoFactorService}
+	if !authorizer.authorize(w, user, req.Pin, req.Code, paymentRequest.Amount, paymentRequest.Currency) {
+		return
+	}
+
+	resp, err := prh.PaymentRequestService.Accept(user, paymentRequest)
+	if err != nil {
+		if err.Error() == errs.ErrPaymentRequestNotPending.Error() {
+			prh.errConflict(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrLimitExceeded.Error() {
+			prh.errLimitExceeded(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountFrozen.Error() ||
+			err.Error() == errs.ErrAccountClosed.Error() {
+			prh.errForbidden(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrInsufficientBalance.Error() ||
+			err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+			err.Error() == errs.ErrCurrencyMismatch.Error() {
+			prh.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrDestinationUserNotFound.Error() ||
+			err.Error() == errs.ErrDestinationUnavailable.Error() {
+			prh.errUnprocessable(w, err.Error())
+			return
+		}
+		prh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Decline refuses a payment request addressed to the account in the token.
+func (prh *PaymentRequestsHandler) Decline(w http.ResponseWriter, r *http.Request) {
+	err := prh.PaymentRequestService.Decline(prh.getUser(r), chi.URLParam(r, "id"))
+	if err != nil {
+		prh.writePaymentRequestError(w, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Cancel withdraws a payment request made by the account in the token.
+func (prh *PaymentRequestsHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	err := prh.PaymentRequestService.Cancel(prh.getUser(r), chi.URLParam(r, "id"))
+	if err != nil {
+		prh.writePaymentRequestError(w, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (prh *PaymentRequestsHandler) writePaymentRequestError(w http.ResponseWriter, err error) {
+	if err.Error() == errs.ErrPaymentRequestNotFound.Error() {
+		prh.errNotFound(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrPaymentRequestNotPending.Error() ||
+		err.Error() == errs.ErrPaymentRequestExpired.Error() {
+		prh.errConflict(w, err.Error())
+		return
+	}
+	prh.errInternal(w, err.Error())
+}
+
+func (prh *PaymentRequestsHandler) getUser(r *http.Request) models.User {
+	claims := r.Context().Value("token").(*models.JwtClaims)
+	return models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+}
+
+func (prh *PaymentRequestsHandler) validateAndGetCreatePayload(r *http.Request) (req models.CreatePaymentRequestRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// validateAndGetAcceptPayload allows an empty body, for a payer without PIN.
+func (prh *PaymentRequestsHandler) validateAndGetAcceptPayload(r *http.Request) (req models.AcceptPaymentRequestRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil || len(bodyByte) == 0 {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (prh *PaymentRequestsHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (prh *PaymentRequestsHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (prh *PaymentRequestsHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
+func (prh *PaymentRequestsHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (prh *PaymentRequestsHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (prh *PaymentRequestsHandler) errUnprocessable(w http.ResponseWriter, message string) {
+	w.WriteHeader(422)
+	w.Write([]byte(message))
+}
+
+func (prh *PaymentRequestsHandler) errLimitExceeded(w http.ResponseWriter, message string) {
+	w.WriteHeader(429)
+	w.Write([]byte(message))
+}
+
+func (prh *PaymentRequestsHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
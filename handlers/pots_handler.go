@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type PotsHandler struct {
+	UserService      services.IUserService
+	PotService       services.IPotService
+	TwoFactorService services.ITwoFactorService
+}
+
+// Create opens a pot owned by the account in the token.
+func (ph *PotsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	req, err := ph.validateAndGetCreatePayload(r)
+	if err != nil {
+		ph.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := ph.PotService.Create(ph.getUser(r), req)
+	if err != nil {
+		ph.writePotError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+// List returns the pots the account in the token is a member of.
+func (ph *PotsHandler) List(w http.ResponseWriter, r *http.Request) {
+	resp, err := ph.PotService.List(ph.getUser(r))
+	if err != nil {
+		ph.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Get returns a pot with what each of its members contributed.
+func (ph *PotsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	potID, ok := ph.getPotID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := ph.PotService.Get(ph.getUser(r), potID)
+	if err != nil {
+		ph.writePotError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Contributions returns the latest contributions to a pot.
+func (ph *PotsHandler) Contributions(w http.ResponseWriter, r *http.Request) {
+	potID, ok := ph.getPotID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := ph.PotService.GetContributions(ph.getUser(r), potID)
+	if err != nil {
+		ph.writePotError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Contribute moves money of the account in the token into a pot. The transfer PIN
+// and, above the two-factor threshold, a two-factor code are checked like for a
+// regular transfer.
+func (ph *PotsHandler) Contribute(w http.ResponseWriter, r *http.Request) {
+	user := ph.getUser(r)
+
+	potID, ok := ph.getPotID(w, r)
+	if !ok {
+		return
+	}
+
+	req, err := ph.validateAndGetContributePayload(r)
+	if err != nil {
+		ph.errBadRequest(w, err.Error())
+		return
+	}
+
+	pot, err := ph.PotService.Get(user, potID)
+	if err != nil {
+		ph.writePotError(w, err)
+		return
+	}
+
+	authorizer := transferAuthorizer{UserService: ph.UserService, TwoFactorService: ph.TwoFactorService}
+	if !authorizer.authorize(w, user, req.Pin, req.Code, req.Amount, pot.Currency) {
+		return
+	}
+
+	resp, err := ph.PotService.Contribute(user, potID, req.Amount)
+	if err != nil {
+		ph.writePotError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+// Disburse pays money of a pot to the wallet of its owner.
+func (ph *PotsHandler) Disburse(w http.ResponseWriter, r *http.Request) {
+	potID, ok := ph.getPotID(w, r)
+	if !ok {
+		return
+	}
+
+	req, err := ph.validateAndGetDisbursePayload(r)
+	if err != nil {
+		ph.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := ph.PotService.Disburse(ph.getUser(r), potID, req.Amount)
+	if err != nil {
+		ph.writePotError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Close refunds the balance of a pot to its contributors and closes it.
+func (ph *PotsHandler) Close(w http.ResponseWriter, r *http.Request) {
+	potID, ok := ph.getPotID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := ph.PotService.Close(ph.getUser(r), potID)
+	if err != nil {
+		ph.writePotError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (ph *PotsHandler) writePotError(w http.ResponseWriter, err error) {
+	if err.Error() == errs.ErrPotNotFound.Error() ||
+		err.Error() == errs.ErrPotMemberNotFound.Error() {
+		ph.errNotFound(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrNotPotOwner.Error() ||
+		err.Error() == errs.ErrAccountFrozen.Error() ||
+		err.Error() == errs.ErrAccountClosed.Error() {
+		ph.errForbidden(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrPotNotOpen.Error() ||
+		err.Error() == errs.ErrPotRefundUnavailable.Error() {
+		ph.errConflict(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrInsufficientBalance.Error() ||
+		err.Error() == errs.ErrInsufficientPotFunds.Error() ||
+		err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+		err.Error() == errs.ErrWalletNotFound.Error() {
+		ph.errBadRequest(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrLimitExceeded.Error() {
+		ph.errLimitExceeded(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrUnauthorized.Error() {
+		ph.errUnauthorized(w, err.Error())
+		return
+	}
+	ph.errInternal(w, err.Error())
+}
+
+func (ph *PotsHandler) getPotID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	potID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		ph.errNotFound(w, errs.ErrPotNotFound.Error())
+		return 0, false
+	}
+	return uint(potID), true
+}
+
+func (ph *PotsHandler) getUser(r *http.Request) models.User {
+	claims := r.Context().Value("token").(*models.JwtClaims)
+	return models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+}
+
+func (ph *PotsHandler) validateAndGetCreatePayload(r *http.Request) (req models.CreatePotRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (ph *PotsHandler) validateAndGetContributePayload(r *http.Request) (req models.ContributePotRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// validateAndGetDisbursePayload accepts an empty body, which disburses the whole balance.
+func (ph *PotsHandler) validateAndGetDisbursePayload(r *http.Request) (req models.DisbursePotRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil || len(bodyByte) == 0 {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	return
+}
+
+func (ph *PotsHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (ph *PotsHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (ph *PotsHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
+func (ph *PotsHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (ph *PotsHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (ph *PotsHandler) errLimitExceeded(w http.ResponseWriter, message string) {
+	w.WriteHeader(429)
+	w.Write([]byte(message))
+}
+
+func (ph *PotsHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
 	"github.com/atrariksa/awallet/services"
 )
@@ -16,12 +17,24 @@ type ReadBalanceHandler struct {
 func (rbh *ReadBalanceHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	claims := ctx.Value("token").(*models.JwtClaims)
-	balance, err := rbh.UserBalanceService.GetBalanceByUsername(claims.Username)
+	currency := r.URL.Query().Get("currency")
+	wallet, err := rbh.UserBalanceService.GetBalanceByUsername(claims.Username, currency)
 	if err != nil {
-		w.WriteHeader(500)
+		if err.Error() == errs.ErrUnsupportedCurrency.Error() {
+			w.WriteHeader(400)
+		} else if err.Error() == errs.ErrWalletNotFound.Error() {
+			w.WriteHeader(404)
+		} else {
+			w.WriteHeader(500)
+		}
 		w.Write([]byte(err.Error()))
 	} else {
-		resp := models.ReadBalanceResponse{Balance: balance}
+		resp := models.ReadBalanceResponse{
+			Currency:         wallet.Currency,
+			Balance:          wallet.Balance,
+			HeldBalance:      wallet.HeldBalance,
+			AvailableBalance: wallet.AvailableBalance(),
+		}
 		bResp, _ := json.Marshal(&resp)
 		log.Println(string(bResp))
 		w.WriteHeader(200)
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type RefreshTokenHandler struct {
+	TokenService services.ITokenService
+}
+
+func (rth *RefreshTokenHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	req, err := rth.validateAndGetRefreshTokenPayload(r)
+	if err != nil {
+		rth.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := rth.TokenService.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		if err.Error() == errs.ErrInvalidRefreshToken.Error() ||
+			err.Error() == errs.ErrRefreshTokenReused.Error() {
+			rth.errUnauthorized(w, err.Error())
+			return
+		}
+		rth.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (rth *RefreshTokenHandler) validateAndGetRefreshTokenPayload(r *http.Request) (req models.RefreshTokenRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (rth *RefreshTokenHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (rth *RefreshTokenHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (rth *RefreshTokenHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
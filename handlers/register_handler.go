@@ -22,18 +22,20 @@ func (rh *RegisterHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		rh.errInvalidPayload(w)
 		return
 	}
-	user, err := rh.UserService.CreateUser(req.Username)
+	user, err := rh.UserService.CreateUser(req.Username, req.Password, req.Pin)
 	if err != nil {
 		rh.errCreateUser(w, err.Error())
 		return
 	}
-	token, err := rh.TokenService.CreateToken(user)
+	tokens, err := rh.TokenService.IssueTokens(user)
 	if err != nil {
 		rh.errInternal(w, err.Error())
 		return
 	}
 	resp := models.CreateUserResponse{
-		Token: token,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 		UserDetails: models.UserDetails{
 			ID:       user.ID,
 			Username: user.Username,
@@ -57,6 +59,9 @@ func (rh *RegisterHandler) validateAndGetCreateUserPayload(r *http.Request) (req
 	if err != nil {
 		return
 	}
+	if len(req.Password) > models.MaxPasswordBytes {
+		err = errs.ErrInvalidPassword
+	}
 	return
 }
 
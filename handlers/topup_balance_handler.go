@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
 	"github.com/atrariksa/awallet/services"
 )
@@ -29,8 +30,55 @@ func (tbh *TopupBalanceHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = tbh.UserBalanceService.TopupBalance(user, req.Amount)
+	idempotencyKey, err := getIdempotencyKey(r, user, req)
 	if err != nil {
+		tbh.errBadRequest(w, err.Error())
+		return
+	}
+	if idempotencyKey != nil {
+		idempotencyKey.ResponseCode = 204
+	}
+
+	err = tbh.UserBalanceService.TopupBalance(user, models.TopupParams{
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		IdempotencyKey: idempotencyKey,
+		Note: models.MutationNote{
+			Memo:        req.Memo,
+			Tags:        req.Tags,
+			ExternalRef: req.ExternalRef,
+		},
+	})
+	if err != nil {
+		if err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+			err.Error() == errs.ErrInvalidMemo.Error() ||
+			err.Error() == errs.ErrInvalidTag.Error() ||
+			err.Error() == errs.ErrTooManyTags.Error() ||
+			err.Error() == errs.ErrInvalidExternalRef.Error() {
+			tbh.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrDuplicateExternalRef.Error() {
+			tbh.errConflict(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrIdempotencyReplay.Error() {
+			writeIdempotentReplay(w, idempotencyKey)
+			return
+		}
+		if err.Error() == errs.ErrIdempotencyKeyMismatch.Error() {
+			tbh.errUnprocessable(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrLimitExceeded.Error() {
+			tbh.errLimitExceeded(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountFrozen.Error() ||
+			err.Error() == errs.ErrAccountClosed.Error() {
+			tbh.errForbidden(w, err.Error())
+			return
+		}
 		tbh.errInternal(w, err.Error())
 		return
 	}
@@ -58,6 +106,31 @@ func (tbh *TopupBalanceHandler) errInvalidAmount(w http.ResponseWriter) {
 	w.Write([]byte("Invalid topup amount"))
 }
 
+func (tbh *TopupBalanceHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (tbh *TopupBalanceHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (tbh *TopupBalanceHandler) errUnprocessable(w http.ResponseWriter, message string) {
+	w.WriteHeader(422)
+	w.Write([]byte(message))
+}
+
+func (tbh *TopupBalanceHandler) errLimitExceeded(w http.ResponseWriter, message string) {
+	w.WriteHeader(429)
+	w.Write([]byte(message))
+}
+
+func (tbh *TopupBalanceHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
 func (tbh *TopupBalanceHandler) errInternal(w http.ResponseWriter, message string) {
 	w.WriteHeader(500)
 	w.Write([]byte(message))
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+// transferAuthorizer checks what a user proves before money leaves its account, for
+// every flow other than /transfer, which holds a transfer on a two-factor challenge
+// instead of taking the code in the request.
+type transferAuthorizer struct {
+	UserService      services.IUserService
+	TwoFactorService services.ITwoFactorService
+}
+
+// authorize checks the transfer PIN, and a two-factor code when amount is above the
+// threshold. It writes the answer and returns false when a check fails.
+func (ta transferAuthorizer) authorize(w http.ResponseWriter, user models.User, pin string, code string, amount uint32, currency string) bool {
+	err := ta.UserService.VerifyTransferPin(user, pin)
+	if err != nil {
+		if err.Error() == errs.ErrPinRequired.Error() ||
+			err.Error() == errs.ErrInvalidPin.Error() {
+			ta.writeError(w, 403, err.Error())
+			return false
+		}
+		if err.Error() == errs.ErrPinLocked.Error() {
+			ta.writeError(w, 423, err.Error())
+			return false
+		}
+		if err.Error() == errs.ErrUnauthorized.Error() {
+			ta.writeError(w, 401, err.Error())
+			return false
+		}
+		ta.writeError(w, 500, err.Error())
+		return false
+	}
+
+	if ta.TwoFactorService == nil {
+		return true
+	}
+	required, err := ta.TwoFactorService.RequiresTransferChallenge(user, amount, currency)
+	if err != nil {
+		if err.Error() == errs.ErrFXRateNotFound.Error() {
+			ta.writeError(w, 400, err.Error())
+			return false
+		}
+		ta.writeError(w, 500, err.Error())
+		return false
+	}
+	if !required {
+		return true
+	}
+
+	err = ta.TwoFactorService.VerifyTransferCode(user, code)
+	if err != nil {
+		if err.Error() == errs.ErrTwoFactorRequired.Error() ||
+			err.Error() == errs.ErrInvalidTwoFactorCode.Error() {
+			ta.writeError(w, 403, err.Error())
+			return false
+		}
+		if err.Error() == errs.ErrTwoFactorLocked.Error() {
+			ta.writeError(w, 423, err.Error())
+			return false
+		}
+		ta.writeError(w, 500, err.Error())
+		return false
+	}
+	return true
+}
+
+func (ta transferAuthorizer) writeError(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	w.Write([]byte(message))
+}
@@ -6,13 +6,16 @@ import (
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/constants"
 	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
 	"github.com/atrariksa/awallet/services"
 )
 
 type TransferHandler struct {
+	UserService        services.IUserService
 	UserBalanceService services.IUserBalanceService
+	TwoFactorService   services.ITwoFactorService
 }
 
 func (tbh *TransferHandler) Handle(w http.ResponseWriter, r *http.Request) {
@@ -35,20 +38,190 @@ func (tbh *TransferHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = tbh.UserBalanceService.Transfer(user, req.Amount, req.ToUsername)
+	err = tbh.UserService.VerifyTransferPin(user, req.Pin)
 	if err != nil {
-		if err.Error() == errs.ErrInsufficientBalance.Error() {
+		if err.Error() == errs.ErrPinRequired.Error() ||
+			err.Error() == errs.ErrInvalidPin.Error() {
+			tbh.errForbidden(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrPinLocked.Error() {
+			tbh.errLocked(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrUnauthorized.Error() {
+			tbh.errUnauthorized(w, err.Error())
+			return
+		}
+		tbh.errInternal(w, err.Error())
+		return
+	}
+	// the PIN is not part of the request a retry must repeat
+	req.Pin = ""
+
+	idempotencyKey, err := getIdempotencyKey(r, user, req)
+	if err != nil {
+		tbh.errBadRequest(w, err.Error())
+		return
+	}
+
+	if tbh.TwoFactorService != nil {
+		required, err := tbh.TwoFactorService.RequiresTransferChallenge(user, req.Amount, req.Currency)
+		if err != nil {
+			if err.Error() == errs.ErrFXRateNotFound.Error() {
+				tbh.errBadRequest(w, err.Error())
+				return
+			}
+			tbh.errInternal(w, err.Error())
+			return
+		}
+		if required {
+			tbh.challenge(w, r, user, req)
+			return
+		}
+	}
+
+	tbh.transfer(w, user, req, idempotencyKey)
+}
+
+// Confirm releases a transfer held by a two-factor challenge.
+func (tbh *TransferHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	confirmReq, err := tbh.validateAndGetConfirmPayload(r)
+	if err != nil {
+		tbh.errBadRequest(w, err.Error())
+		return
+	}
+
+	req, key, err := tbh.TwoFactorService.ConfirmTransferChallenge(user, confirmReq.ChallengeID, confirmReq.Code)
+	if err != nil {
+		if err.Error() == errs.ErrChallengeNotFound.Error() {
+			tbh.errUserNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrChallengeExpired.Error() ||
+			err.Error() == errs.ErrChallengeUsed.Error() {
+			tbh.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrInvalidTwoFactorCode.Error() ||
+			err.Error() == errs.ErrTwoFactorRequired.Error() {
+			tbh.errForbidden(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrTwoFactorLocked.Error() {
+			tbh.errLocked(w, err.Error())
+			return
+		}
+		tbh.errInternal(w, err.Error())
+		return
+	}
+
+	idempotencyKey, err := newIdempotencyKey(key, constants.TRANSFER_PATH, user, req)
+	if err != nil {
+		tbh.errBadRequest(w, err.Error())
+		return
+	}
+	tbh.transfer(w, user, req, idempotencyKey)
+}
+
+// challenge holds a transfer above the two-factor threshold and answers with the
+// challenge to confirm it with.
+func (tbh *TransferHandler) challenge(w http.ResponseWriter, r *http.Request, user models.User, req models.TransferRequest) {
+	resp, err := tbh.TwoFactorService.CreateTransferChallenge(user, req, r.Header.Get(constants.IDEMPOTENCY_KEY_HEADER))
+	if err != nil {
+		if err.Error() == errs.ErrTwoFactorRequired.Error() {
+			tbh.errForbidden(w, err.Error())
+			return
+		}
+		tbh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(202)
+	w.Write(bResp)
+}
+
+func (tbh *TransferHandler) transfer(w http.ResponseWriter, user models.User, req models.TransferRequest, idempotencyKey *models.IdempotencyKey) {
+	if idempotencyKey != nil {
+		idempotencyKey.ResponseCode = 200
+	}
+
+	resp, err := tbh.UserBalanceService.Transfer(user, models.TransferParams{
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		ToUsername:     req.ToUsername,
+		QuoteID:        req.QuoteID,
+		IdempotencyKey: idempotencyKey,
+		Note: models.MutationNote{
+			Memo:        req.Memo,
+			Tags:        req.Tags,
+			ExternalRef: req.ExternalRef,
+		},
+	})
+	if err != nil {
+		if err.Error() == errs.ErrIdempotencyReplay.Error() {
+			writeIdempotentReplay(w, idempotencyKey)
+			return
+		}
+		if err.Error() == errs.ErrIdempotencyKeyMismatch.Error() {
+			tbh.errUnprocessable(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrLimitExceeded.Error() {
+			tbh.errLimitExceeded(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountFrozen.Error() ||
+			err.Error() == errs.ErrAccountClosed.Error() {
+			tbh.errForbidden(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrDuplicateExternalRef.Error() {
+			tbh.errConflict(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrInsufficientBalance.Error() ||
+			err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+			err.Error() == errs.ErrInvalidMemo.Error() ||
+			err.Error() == errs.ErrInvalidTag.Error() ||
+			err.Error() == errs.ErrTooManyTags.Error() ||
+			err.Error() == errs.ErrInvalidExternalRef.Error() ||
+			err.Error() == errs.ErrCurrencyMismatch.Error() ||
+			err.Error() == errs.ErrFXQuoteExpired.Error() ||
+			err.Error() == errs.ErrFXQuoteUsed.Error() ||
+			err.Error() == errs.ErrFXQuoteMismatch.Error() ||
+			err.Error() == errs.ErrConvertedAmountOutOfRange.Error() {
 			tbh.errBadRequest(w, err.Error())
 			return
 		}
+		if err.Error() == errs.ErrFXQuoteNotFound.Error() {
+			tbh.errUserNotFound(w, err.Error())
+			return
+		}
 		if err.Error() == errs.ErrDestinationUserNotFound.Error() {
 			tbh.errUserNotFound(w, err.Error())
 			return
 		}
+		if err.Error() == errs.ErrDestinationUnavailable.Error() {
+			tbh.errUnprocessable(w, err.Error())
+			return
+		}
 		tbh.errInternal(w, err.Error())
 		return
 	}
-	w.WriteHeader(204)
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
 }
 
 func (tbh *TransferHandler) validateAndGetTransferPayload(r *http.Request) (req models.TransferRequest, err error) {
@@ -67,16 +240,62 @@ func (tbh *TransferHandler) validateAndGetTransferPayload(r *http.Request) (req
 	return
 }
 
+func (tbh *TransferHandler) validateAndGetConfirmPayload(r *http.Request) (req models.TransferConfirmRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
 func (tbh *TransferHandler) errBadRequest(w http.ResponseWriter, message string) {
 	w.WriteHeader(400)
 	w.Write([]byte(message))
 }
 
+func (tbh *TransferHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (tbh *TransferHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
 func (tbh *TransferHandler) errUserNotFound(w http.ResponseWriter, message string) {
 	w.WriteHeader(404)
 	w.Write([]byte(message))
 }
 
+func (tbh *TransferHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (tbh *TransferHandler) errUnprocessable(w http.ResponseWriter, message string) {
+	w.WriteHeader(422)
+	w.Write([]byte(message))
+}
+
+func (tbh *TransferHandler) errLocked(w http.ResponseWriter, message string) {
+	w.WriteHeader(423)
+	w.Write([]byte(message))
+}
+
+func (tbh *TransferHandler) errLimitExceeded(w http.ResponseWriter, message string) {
+	w.WriteHeader(429)
+	w.Write([]byte(message))
+}
+
 func (tbh *TransferHandler) errInternal(w http.ResponseWriter, message string) {
 	w.WriteHeader(500)
 	w.Write([]byte(message))
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type TransferSchedulesHandler struct {
+	UserService             services.IUserService
+	TransferScheduleService services.ITransferScheduleService
+	TwoFactorService        services.ITwoFactorService
+}
+
+// Create schedules transfers of the account in the token. The transfer PIN and,
+// above the two-factor threshold, a two-factor code are checked once here, the
+// scheduled transfers run without them.
+func (tsh *TransferSchedulesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := tsh.getUser(r)
+
+	req, err := tsh.validateAndGetCreatePayload(r)
+	if err != nil {
+		tsh.errBadRequest(w, err.Error())
+		return
+	}
+
+	if user.Username == req.ToUsername {
+		tsh.errBadRequest(w, http.StatusText(http.StatusBadRequest))
+		return
+	}
+
+	authorizer := transferAuthorizer{UserService: tsh.UserService, TwoFactorService: tsh.TwoFactorService}
+	if !authorizer.authorize(w, user, req.Pin, req.Code, req.Amount, req.Currency) {
+		return
+	}
+
+	resp, err := tsh.TransferScheduleService.Create(user, req)
+	if err != nil {
+		tsh.writeScheduleError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+func (tsh *TransferSchedulesHandler) List(w http.ResponseWriter, r *http.Request) {
+	resp, err := tsh.TransferScheduleService.List(tsh.getUser(r))
+	if err != nil {
+		tsh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Get returns a schedule with its latest runs.
+func (tsh *TransferSchedulesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		tsh.errNotFound(w, errs.ErrScheduleNotFound.Error())
+		return
+	}
+
+	resp, err := tsh.TransferScheduleService.Get(tsh.getUser(r), uint(scheduleID))
+	if err != nil {
+		tsh.writeScheduleError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Update changes, pauses or resumes a schedule. A new amount is checked like the
+// amount of a new schedule.
+func (tsh *TransferSchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	user := tsh.getUser(r)
+
+	scheduleID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		tsh.errNotFound(w, errs.ErrScheduleNotFound.Error())
+		return
+	}
+
+	req, err := tsh.validateAndGetUpdatePayload(r)
+	if err != nil {
+		tsh.errBadRequest(w, err.Error())
+		return
+	}
+
+	schedule, err := tsh.TransferScheduleService.Get(user, uint(scheduleID))
+	if err != nil {
+		tsh.writeScheduleError(w, err)
+		return
+	}
+
+	// pausing moves no money, a change of amount or timing, resuming included, is
+	// authorized like a new schedule
+	if req.Amount > 0 || req.RunAt != nil || req.Cron != "" || req.Status == models.SCHEDULE_ACTIVE {
+		amount := req.Amount
+		if amount == 0 {
+			amount = schedule.Amount
+		}
+		authorizer := transferAuthorizer{UserService: tsh.UserService, TwoFactorService: tsh.TwoFactorService}
+		if !authorizer.authorize(w, user, req.Pin, req.Code, amount, schedule.Currency) {
+			return
+		}
+	}
+
+	resp, err := tsh.TransferScheduleService.Update(user, uint(scheduleID), req)
+	if err != nil {
+		tsh.writeScheduleError(w, err)
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (tsh *TransferSchedulesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		tsh.errNotFound(w, errs.ErrScheduleNotFound.Error())
+		return
+	}
+
+	err = tsh.TransferScheduleService.Delete(tsh.getUser(r), uint(scheduleID))
+	if err != nil {
+		tsh.writeScheduleError(w, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (tsh *TransferSchedulesHandler) writeScheduleError(w http.ResponseWriter, err error) {
+	if err.Error() == errs.ErrScheduleNotFound.Error() ||
+		err.Error() == errs.ErrDestinationUserNotFound.Error() {
+		tsh.errNotFound(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrInvalidSchedule.Error() ||
+		err.Error() == errs.ErrInvalidCronExpression.Error() ||
+		err.Error() == errs.ErrUnsupportedCurrency.Error() ||
+		err.Error() == errs.ErrWalletNotFound.Error() {
+		tsh.errBadRequest(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrInvalidScheduleStatus.Error() {
+		tsh.errConflict(w, err.Error())
+		return
+	}
+	if err.Error() == errs.ErrUnauthorized.Error() {
+		tsh.errUnauthorized(w, err.Error())
+		return
+	}
+	tsh.errInternal(w, err.Error())
+}
+
+func (tsh *TransferSchedulesHandler) getUser(r *http.Request) models.User {
+	claims := r.Context().Value("token").(*models.JwtClaims)
+	return models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+}
+
+func (tsh *TransferSchedulesHandler) validateAndGetCreatePayload(r *http.Request) (req models.CreateTransferScheduleRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (tsh *TransferSchedulesHandler) validateAndGetUpdatePayload(r *http.Request) (req models.UpdateTransferScheduleRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (tsh *TransferSchedulesHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (tsh *TransferSchedulesHandler) errUnauthorized(w http.ResponseWriter, message string) {
+	w.WriteHeader(401)
+	w.Write([]byte(message))
+}
+
+func (tsh *TransferSchedulesHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (tsh *TransferSchedulesHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (tsh *TransferSchedulesHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
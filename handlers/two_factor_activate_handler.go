@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type TwoFactorActivateHandler struct {
+	TwoFactorService services.ITwoFactorService
+}
+
+func (tah *TwoFactorActivateHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	req, err := tah.validateAndGetActivatePayload(r)
+	if err != nil {
+		tah.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := tah.TwoFactorService.Activate(user, req.Code)
+	if err != nil {
+		if err.Error() == errs.ErrTwoFactorNotEnrolled.Error() {
+			tah.errNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrTwoFactorAlreadyEnabled.Error() {
+			tah.errConflict(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrInvalidTwoFactorCode.Error() {
+			tah.errForbidden(w, err.Error())
+			return
+		}
+		tah.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (tah *TwoFactorActivateHandler) validateAndGetActivatePayload(r *http.Request) (req models.TwoFactorActivateRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (tah *TwoFactorActivateHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (tah *TwoFactorActivateHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
+func (tah *TwoFactorActivateHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (tah *TwoFactorActivateHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (tah *TwoFactorActivateHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
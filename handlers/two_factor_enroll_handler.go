@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type TwoFactorEnrollHandler struct {
+	TwoFactorService services.ITwoFactorService
+}
+
+func (teh *TwoFactorEnrollHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	resp, err := teh.TwoFactorService.Enroll(user)
+	if err != nil {
+		if err.Error() == errs.ErrTwoFactorAlreadyEnabled.Error() {
+			teh.errConflict(w, err.Error())
+			return
+		}
+		teh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+func (teh *TwoFactorEnrollHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (teh *TwoFactorEnrollHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
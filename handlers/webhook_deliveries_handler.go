@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhookDeliveriesHandler struct {
+	WebhookService services.IWebhookService
+}
+
+// List returns the latest deliveries, filtered by query parameter status.
+// Status DEAD lists the dead letters.
+func (wdh *WebhookDeliveriesHandler) List(w http.ResponseWriter, r *http.Request) {
+	status := models.WebhookDeliveryStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.DELIVERY_PENDING, models.DELIVERY_DELIVERED, models.DELIVERY_DEAD:
+	default:
+		wdh.errBadRequest(w, "Invalid status")
+		return
+	}
+
+	resp, err := wdh.WebhookService.ListDeliveries(wdh.getUser(r), status)
+	if err != nil {
+		wdh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+// Replay sends a delivered or dead delivery again.
+func (wdh *WebhookDeliveriesHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	resp, err := wdh.WebhookService.Replay(wdh.getUser(r), chi.URLParam(r, "delivery_id"))
+	if err != nil {
+		if err.Error() == errs.ErrDeliveryNotFound.Error() ||
+			err.Error() == errs.ErrWebhookNotFound.Error() {
+			wdh.errNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrDeliveryPending.Error() {
+			wdh.errConflict(w, err.Error())
+			return
+		}
+		wdh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(202)
+	w.Write(bResp)
+}
+
+func (wdh *WebhookDeliveriesHandler) getUser(r *http.Request) models.User {
+	claims := r.Context().Value("token").(*models.JwtClaims)
+	return models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+}
+
+func (wdh *WebhookDeliveriesHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (wdh *WebhookDeliveriesHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (wdh *WebhookDeliveriesHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (wdh *WebhookDeliveriesHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhooksHandler struct {
+	WebhookService services.IWebhookService
+}
+
+// Create registers a webhook for the account in the token.
+func (wh *WebhooksHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := wh.getUser(r)
+
+	req, err := wh.validateAndGetWebhookPayload(r)
+	if err != nil {
+		wh.errBadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := wh.WebhookService.Register(user, req)
+	if err != nil {
+		if err.Error() == errs.ErrInvalidWebhookEvent.Error() ||
+			err.Error() == errs.ErrWebhookURLNotAllowed.Error() {
+			wh.errBadRequest(w, err.Error())
+			return
+		}
+		wh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(201)
+	w.Write(bResp)
+}
+
+func (wh *WebhooksHandler) List(w http.ResponseWriter, r *http.Request) {
+	resp, err := wh.WebhookService.List(wh.getUser(r))
+	if err != nil {
+		wh.errInternal(w, err.Error())
+		return
+	}
+
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(200)
+	w.Write(bResp)
+}
+
+func (wh *WebhooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		wh.errNotFound(w, errs.ErrWebhookNotFound.Error())
+		return
+	}
+
+	err = wh.WebhookService.Delete(wh.getUser(r), uint(webhookID))
+	if err != nil {
+		if err.Error() == errs.ErrWebhookNotFound.Error() {
+			wh.errNotFound(w, err.Error())
+			return
+		}
+		wh.errInternal(w, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (wh *WebhooksHandler) getUser(r *http.Request) models.User {
+	claims := r.Context().Value("token").(*models.JwtClaims)
+	return models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+}
+
+func (wh *WebhooksHandler) validateAndGetWebhookPayload(r *http.Request) (req models.CreateWebhookRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (wh *WebhooksHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (wh *WebhooksHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (wh *WebhooksHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
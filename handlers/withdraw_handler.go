@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type WithdrawHandler struct {
+	UserService        services.IUserService
+	UserBalanceService services.IUserBalanceService
+	TwoFactorService   services.ITwoFactorService
+}
+
+func (wh *WithdrawHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := ctx.Value("token").(*models.JwtClaims)
+
+	user := models.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+	}
+
+	req, err := wh.validateAndGetWithdrawPayload(r)
+	if err != nil {
+		wh.errBadRequest(w, err.Error())
+		return
+	}
+
+	authorizer := transferAuthorizer{UserService: wh.UserService, TwoFactorService: wh.TwoFactorService}
+	if !authorizer.authorize(w, user, req.Pin, req.Code, req.Amount, req.Currency) {
+		return
+	}
+
+	mutation, err := wh.UserBalanceService.Withdraw(user, req.Amount, req.Currency)
+	if err != nil {
+		if err.Error() == errs.ErrInsufficientBalance.Error() ||
+			err.Error() == errs.ErrUnsupportedCurrency.Error() {
+			wh.errBadRequest(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrAccountFrozen.Error() ||
+			err.Error() == errs.ErrAccountClosed.Error() {
+			wh.errForbidden(w, err.Error())
+			return
+		}
+		wh.errInternal(w, err.Error())
+		return
+	}
+
+	resp := models.WithdrawResponse{
+		RefID:    mutation.RefID,
+		Currency: mutation.Currency,
+		Amount:   mutation.Value,
+		Status:   mutation.Status,
+	}
+	bResp, _ := json.Marshal(&resp)
+	w.WriteHeader(202)
+	w.Write(bResp)
+}
+
+func (wh *WithdrawHandler) validateAndGetWithdrawPayload(r *http.Request) (req models.WithdrawRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (wh *WithdrawHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (wh *WithdrawHandler) errForbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(403)
+	w.Write([]byte(message))
+}
+
+func (wh *WithdrawHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
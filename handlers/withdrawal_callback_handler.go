@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/services"
+)
+
+type WithdrawalCallbackHandler struct {
+	UserBalanceService services.IUserBalanceService
+}
+
+func (wch *WithdrawalCallbackHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	req, err := wch.validateAndGetCallbackPayload(r)
+	if err != nil {
+		wch.errBadRequest(w, err.Error())
+		return
+	}
+
+	err = wch.UserBalanceService.CompleteWithdrawal(req.RefID, req.Status)
+	if err != nil {
+		if err.Error() == errs.ErrWithdrawalNotFound.Error() {
+			wch.errNotFound(w, err.Error())
+			return
+		}
+		if err.Error() == errs.ErrWithdrawalAlreadyCompleted.Error() {
+			wch.errConflict(w, err.Error())
+			return
+		}
+		wch.errInternal(w, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (wch *WithdrawalCallbackHandler) validateAndGetCallbackPayload(r *http.Request) (req models.WithdrawalCallbackRequest, err error) {
+	bodyByte, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bodyByte, &req)
+	if err != nil {
+		return
+	}
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (wch *WithdrawalCallbackHandler) errBadRequest(w http.ResponseWriter, message string) {
+	w.WriteHeader(400)
+	w.Write([]byte(message))
+}
+
+func (wch *WithdrawalCallbackHandler) errNotFound(w http.ResponseWriter, message string) {
+	w.WriteHeader(404)
+	w.Write([]byte(message))
+}
+
+func (wch *WithdrawalCallbackHandler) errConflict(w http.ResponseWriter, message string) {
+	w.WriteHeader(409)
+	w.Write([]byte(message))
+}
+
+func (wch *WithdrawalCallbackHandler) errInternal(w http.ResponseWriter, message string) {
+	w.WriteHeader(500)
+	w.Write([]byte(message))
+}
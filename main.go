@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,10 +19,12 @@ import (
 	"github.com/atrariksa/awallet/handlers"
 	"github.com/atrariksa/awallet/middlewares"
 	"github.com/atrariksa/awallet/migrations"
+	"github.com/atrariksa/awallet/models"
 	"github.com/atrariksa/awallet/repos"
 	"github.com/atrariksa/awallet/services"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -28,9 +32,17 @@ func main() {
 	cmdMessage :=
 		`
 	Please use following commands :
-	1. use "migrate up" to migrate tables
+	1. use "migrate up" to migrate tables, "migrate ledger" to backfill journal entries
+	   from mutations and "migrate balances" to recompute wallet balances from the ledger
 	2. use "server" to run service
 	3. use "blackbox" to run test cases
+	4. use "reverse <ref_id>" to reverse a transfer
+	5. use "reconcile [--fix] [--format json|csv] [--output file] [--batch-size n]"
+	   to check balances and total outgoings against mutations
+	6. use "grant-role <username> <viewer|support|finance|arbitrator|none>" to give or take
+	   the admin role of a user
+	7. use "relay" to publish domain events of the outbox to OUTBOX.SINK
+	8. use "scheduler" to run the due scheduled transfers
 	`
 	if len(os.Args) == 1 {
 		log.Fatalln(cmdMessage)
@@ -43,6 +55,16 @@ func main() {
 		migrate(os.Args)
 	case "blackbox":
 		runBlackbox()
+	case "reverse":
+		reverse(os.Args)
+	case "reconcile":
+		reconcile(os.Args)
+	case "grant-role":
+		grantRole(os.Args)
+	case "relay":
+		relay()
+	case "scheduler":
+		scheduler()
 	default:
 		log.Println(fmt.Sprintf(`Unknown command "%v". %v`, command, cmdMessage))
 	}
@@ -65,7 +87,8 @@ func server() {
 		<-sig
 
 		// Shutdown signal with grace period of 30 seconds
-		shutdownCtx, _ := context.WithTimeout(serverCtx, 30*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(serverCtx, 30*time.Second)
+		defer cancel()
 
 		go func() {
 			<-shutdownCtx.Done()
@@ -82,6 +105,13 @@ func server() {
 		serverStopCtx()
 	}()
 
+	if cfg.Webhook.Dispatcher {
+		go newWebhookDispatcher(cfg).Run(serverCtx)
+	}
+	go newPaymentRequestSweeper(cfg).Run(serverCtx)
+	go newBulkTransferWorker(cfg).Run(serverCtx)
+	go newEscrowSweeper(cfg).Run(serverCtx)
+
 	// Run the server
 	err := server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
@@ -92,6 +122,73 @@ func server() {
 	<-serverCtx.Done()
 }
 
+// newWebhookDispatcher sends webhook deliveries in the background of the server.
+func newWebhookDispatcher(cfg *configs.Config) *services.WebhookDispatcher {
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	return &services.WebhookDispatcher{
+		WebhookRepo:  &repos.WebhookRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		Client:       services.NewWebhookClient(cfg.Webhook.Timeout, cfg.Webhook.AllowPrivateURLs),
+		BatchSize:    cfg.Webhook.BatchSize,
+		PollInterval: cfg.Webhook.PollInterval,
+		MaxAttempts:  cfg.Webhook.MaxAttempts,
+		BaseBackoff:  cfg.Webhook.BaseBackoff,
+		MaxBackoff:   cfg.Webhook.MaxBackoff,
+	}
+}
+
+// newPaymentRequestSweeper expires payment requests in the background of the server.
+func newPaymentRequestSweeper(cfg *configs.Config) *services.PaymentRequestSweeper {
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	return &services.PaymentRequestSweeper{
+		PaymentRequestWrite: &repos.PaymentRequestRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+		Interval:            cfg.PaymentRequest.SweepInterval,
+	}
+}
+
+// newBulkTransferWorker processes asynchronous bulk transfers in the background of the server.
+func newBulkTransferWorker(cfg *configs.Config) *services.BulkTransferWorker {
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbRead := drivers.NewDBClientRead(cfg)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	bulkTransferRepo := repos.BulkTransferRepo{DBWrite: dbWrite, Cache: cacheRepo}
+	return &services.BulkTransferWorker{
+		BulkTransferRepo: &bulkTransferRepo,
+		BulkTransferService: &services.BulkTransferService{
+			BulkTransferRepo:   &bulkTransferRepo,
+			UserBalanceService: newTransferUserBalanceService(cfg, cacheRepo, dbRead, dbWrite),
+			MaxItems:           cfg.BulkTransfer.MaxItems,
+		},
+		BatchSize:    cfg.BulkTransfer.BatchSize,
+		PollInterval: cfg.BulkTransfer.PollInterval,
+		StaleAfter:   cfg.BulkTransfer.StaleAfter,
+	}
+}
+
+// newEscrowSweeper releases due escrows in the background of the server.
+func newEscrowSweeper(cfg *configs.Config) *services.EscrowSweeper {
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbRead := drivers.NewDBClientRead(cfg)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	return &services.EscrowSweeper{
+		EscrowService: &services.EscrowService{
+			EscrowRead:           &repos.EscrowRepoRead{DBRead: dbRead, Cache: cacheRepo},
+			UserBalanceWrite:     &repos.UserBalanceRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+			ReleaseRetryInterval: cfg.Escrow.ReleaseRetryInterval,
+		},
+		Interval: cfg.Escrow.SweepInterval,
+	}
+}
+
 func setupService(cfg *configs.Config) http.Handler {
 	r := chi.NewRouter()
 
@@ -109,11 +206,19 @@ func setupService(cfg *configs.Config) http.Handler {
 	userRepoWrite := repos.UserRepoWrite{DBWrite: dbWrite, Cache: cacheRepo}
 
 	userService := services.UserService{
-		UserRepoRead:  &userRepoRead,
-		UserRepoWrite: &userRepoWrite,
+		UserRepoRead:      &userRepoRead,
+		UserRepoWrite:     &userRepoWrite,
+		DefaultCurrency:   cfg.Wallet.DefaultCurrency,
+		MaxFailedAttempts: cfg.Auth.MaxFailedAttempts,
+		LockoutDuration:   cfg.Auth.LockoutDuration,
 	}
 
-	tokenService := services.NewTokenService(cfg)
+	tokenRepo := repos.TokenRepo{DBWrite: dbWrite, Cache: cacheRepo}
+	signingKeys, err := services.NewSigningKeyProvider(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	tokenService := services.NewTokenService(cfg, &tokenRepo, signingKeys)
 
 	registerHandler := handlers.RegisterHandler{
 		UserService:  &userService,
@@ -122,41 +227,347 @@ func setupService(cfg *configs.Config) http.Handler {
 
 	r.Post(constants.CREATE_USER_PATH, registerHandler.Handle)
 
+	loginHandler := handlers.LoginHandler{
+		UserService:  &userService,
+		TokenService: &tokenService,
+	}
+	r.Post(constants.LOGIN_PATH, loginHandler.Handle)
+
+	refreshTokenHandler := handlers.RefreshTokenHandler{TokenService: &tokenService}
+	r.Post(constants.TOKEN_REFRESH_PATH, refreshTokenHandler.Handle)
+
+	jwksHandler := handlers.JWKSHandler{TokenService: &tokenService}
+	r.Get(constants.JWKS_PATH, jwksHandler.Handle)
+
+	userBalanceWrite := repos.UserBalanceRepoWrite{DBWrite: dbWrite, Cache: cacheRepo}
+	userBalanceRead := repos.UserBalanceRepoRead{DBRead: dbRead, Cache: cacheRepo}
+	walletRead := repos.WalletRepoRead{DBRead: dbRead, Cache: cacheRepo}
+	walletWrite := repos.WalletRepoWrite{DBWrite: dbWrite, Cache: cacheRepo}
+	fxRead := repos.FXRepoRead{DBRead: dbRead, Cache: cacheRepo}
+	fxWrite := repos.FXRepoWrite{DBWrite: dbWrite, Cache: cacheRepo}
+	limitRepo := repos.LimitRepo{DBWrite: dbWrite, Cache: cacheRepo}
+	fxRateProvider := services.NewFileFXRateProvider(cfg.FX.RatesFile)
+	feeSchedule, err := services.NewFeeSchedule(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	limitService := services.LimitService{
+		UserRepoRead:    &userRepoRead,
+		LimitRepo:       &limitRepo,
+		FXRateProvider:  fxRateProvider,
+		DefaultCurrency: cfg.Wallet.DefaultCurrency,
+		DefaultTier:     cfg.Limits.DefaultTier,
+		Tiers:           cfg.Limits.Tiers,
+	}
+	userBalanceService := services.UserBalanceService{
+		UserRepoRead:          &userRepoRead,
+		UserBalanceWrite:      &userBalanceWrite,
+		UserBalanceRead:       &userBalanceRead,
+		WalletRead:            &walletRead,
+		WalletWrite:           &walletWrite,
+		FXRead:                &fxRead,
+		FeeCalculator:         feeSchedule,
+		LimitService:          &limitService,
+		DefaultCurrency:       cfg.Wallet.DefaultCurrency,
+		Currencies:            cfg.Wallet.Currencies,
+		AllowNegativeReversal: cfg.Reversal.AllowNegativeBalance,
+	}
+
+	twoFactorRepo := repos.TwoFactorRepo{DBWrite: dbWrite, Cache: cacheRepo}
+	twoFactorService := services.TwoFactorService{
+		TwoFactorRepo:     &twoFactorRepo,
+		FXRateProvider:    fxRateProvider,
+		DefaultCurrency:   cfg.Wallet.DefaultCurrency,
+		Issuer:            cfg.TwoFactor.Issuer,
+		TransferThreshold: cfg.TwoFactor.TransferThreshold,
+		ChallengeTTL:      cfg.TwoFactor.ChallengeTTL,
+		MaxAttempts:       cfg.TwoFactor.MaxAttempts,
+		LockoutDuration:   cfg.TwoFactor.LockoutDuration,
+	}
+
+	webhookService := services.WebhookService{
+		WebhookRepo:      &repos.WebhookRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		AllowPrivateURLs: cfg.Webhook.AllowPrivateURLs,
+	}
+
+	paymentRequestService := services.PaymentRequestService{
+		UserRepoRead:        &userRepoRead,
+		PaymentRequestRead:  &repos.PaymentRequestRepoRead{DBRead: dbRead, Cache: cacheRepo},
+		PaymentRequestWrite: &repos.PaymentRequestRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+		UserBalanceService:  &userBalanceService,
+		DefaultTTL:          cfg.PaymentRequest.DefaultTTL,
+		MaxTTL:              cfg.PaymentRequest.MaxTTL,
+	}
+
+	transferScheduleService := services.TransferScheduleService{
+		UserRepoRead:         &userRepoRead,
+		TransferScheduleRepo: &repos.TransferScheduleRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		UserBalanceService:   &userBalanceService,
+	}
+
+	potService := services.PotService{
+		UserRepoRead:       &userRepoRead,
+		PotRead:            &repos.PotRepoRead{DBRead: dbRead, Cache: cacheRepo},
+		PotWrite:           &repos.PotRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+		UserBalanceService: &userBalanceService,
+		LimitService:       &limitService,
+	}
+
+	bulkTransferService := services.BulkTransferService{
+		BulkTransferRepo:   &repos.BulkTransferRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		UserBalanceService: &userBalanceService,
+		MaxItems:           cfg.BulkTransfer.MaxItems,
+	}
+
+	escrowService := services.EscrowService{
+		UserRepoRead:        &userRepoRead,
+		EscrowRead:          &repos.EscrowRepoRead{DBRead: dbRead, Cache: cacheRepo},
+		UserBalanceWrite:    &userBalanceWrite,
+		UserBalanceService:  &userBalanceService,
+		LimitService:        &limitService,
+		DefaultReleaseAfter: cfg.Escrow.DefaultReleaseAfter,
+		MaxReleaseAfter:     cfg.Escrow.MaxReleaseAfter,
+	}
+
+	if cfg.Merchant.PayloadSecret == "" {
+		log.Fatalln("MERCHANT.PAYLOAD_SECRET is required to sign payment payloads")
+	}
+	merchantService := services.MerchantService{
+		UserRepoRead:       &userRepoRead,
+		UserRepoWrite:      &userRepoWrite,
+		PaymentCodeRepo:    &repos.PaymentCodeRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		UserBalanceService: &userBalanceService,
+		PayloadSecret:      cfg.Merchant.PayloadSecret,
+		DynamicTTL:         cfg.Merchant.DynamicTTL,
+		MaxTTL:             cfg.Merchant.MaxTTL,
+	}
+
+	adminService := services.AdminService{
+		UserRepoRead:       &userRepoRead,
+		UserRepoWrite:      &userRepoWrite,
+		UserBalanceService: &userBalanceService,
+	}
+
+	fxService := services.FXService{
+		FXRateProvider: fxRateProvider,
+		FXRepoWrite:    &fxWrite,
+		Currencies:     cfg.Wallet.Currencies,
+		QuoteTTL:       cfg.FX.QuoteTTL,
+	}
+
 	r.Group(func(r chi.Router) {
-		r.Use(middlewares.AuthMiddlewareHandler(cfg))
-
-		userBalanceWrite := repos.UserBalanceRepoWrite{DBWrite: dbWrite, Cache: cacheRepo}
-		userBalanceRead := repos.UserBalanceRepoRead{DBRead: dbRead, Cache: cacheRepo}
-		userBalanceService := services.UserBalanceService{
-			UserRepoRead:     &userRepoRead,
-			UserBalanceWrite: &userBalanceWrite,
-			UserBalanceRead:  &userBalanceRead,
-		}
+		r.Use(middlewares.InternalMiddlewareHandler(cfg))
+
+		withdrawalCallbackHandler := handlers.WithdrawalCallbackHandler{UserBalanceService: &userBalanceService}
+		r.Post(constants.WITHDRAWAL_CALLBACK_PATH, withdrawalCallbackHandler.Handle)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.AuthMiddlewareHandler(&tokenService))
+
+		logoutHandler := handlers.LogoutHandler{TokenService: &tokenService}
+		r.Post(constants.LOGOUT_PATH, logoutHandler.Handle)
+
+		credentialsHandler := handlers.CredentialsHandler{UserService: &userService}
+		r.Post(constants.CREDENTIALS_PATH, credentialsHandler.Handle)
 
 		readBalanceHandler := handlers.ReadBalanceHandler{UserBalanceService: &userBalanceService}
 		r.Get(constants.READ_BALANCE_PATH, readBalanceHandler.Handle)
 
+		listWalletsHandler := handlers.ListWalletsHandler{UserBalanceService: &userBalanceService}
+		r.Get(constants.WALLETS_PATH, listWalletsHandler.Handle)
+
+		createWalletHandler := handlers.CreateWalletHandler{UserBalanceService: &userBalanceService}
+		r.Post(constants.WALLETS_PATH, createWalletHandler.Handle)
+
 		topupBalanceHandler := handlers.TopupBalanceHandler{UserBalanceService: &userBalanceService}
 		r.Post(constants.TOPUP_BALANCE_PATH, topupBalanceHandler.Handle)
 
-		transferHandler := handlers.TransferHandler{UserBalanceService: &userBalanceService}
+		transferHandler := handlers.TransferHandler{
+			UserService:        &userService,
+			UserBalanceService: &userBalanceService,
+			TwoFactorService:   &twoFactorService,
+		}
 		r.Post(constants.TRANSFER_PATH, transferHandler.Handle)
+		r.Post(constants.TRANSFER_CONFIRM_PATH, transferHandler.Confirm)
+
+		twoFactorEnrollHandler := handlers.TwoFactorEnrollHandler{TwoFactorService: &twoFactorService}
+		r.Post(constants.TWO_FACTOR_ENROLL_PATH, twoFactorEnrollHandler.Handle)
+
+		twoFactorActivateHandler := handlers.TwoFactorActivateHandler{TwoFactorService: &twoFactorService}
+		r.Post(constants.TWO_FACTOR_ACTIVATE_PATH, twoFactorActivateHandler.Handle)
+
+		webhooksHandler := handlers.WebhooksHandler{WebhookService: &webhookService}
+		r.Post(constants.WEBHOOKS_PATH, webhooksHandler.Create)
+		r.Get(constants.WEBHOOKS_PATH, webhooksHandler.List)
+		r.Delete(constants.WEBHOOK_PATH, webhooksHandler.Delete)
+
+		webhookDeliveriesHandler := handlers.WebhookDeliveriesHandler{WebhookService: &webhookService}
+		r.Get(constants.WEBHOOK_DELIVERIES_PATH, webhookDeliveriesHandler.List)
+		r.Post(constants.WEBHOOK_DELIVERY_REPLAY_PATH, webhookDeliveriesHandler.Replay)
+
+		paymentRequestsHandler := handlers.PaymentRequestsHandler{
+			UserService:           &userService,
+			PaymentRequestService: &paymentRequestService,
+			TwoFactorService:      &twoFactorService,
+		}
+		r.Post(constants.PAYMENT_REQUESTS_PATH, paymentRequestsHandler.Create)
+		r.Get(constants.PAYMENT_REQUESTS_INCOMING_PATH, paymentRequestsHandler.ListIncoming)
+		r.Get(constants.PAYMENT_REQUESTS_OUTGOING_PATH, paymentRequestsHandler.ListOutgoing)
+		r.Post(constants.PAYMENT_REQUEST_ACCEPT_PATH, paymentRequestsHandler.Accept)
+		r.Post(constants.PAYMENT_REQUEST_DECLINE_PATH, paymentRequestsHandler.Decline)
+		r.Post(constants.PAYMENT_REQUEST_CANCEL_PATH, paymentRequestsHandler.Cancel)
+
+		transferSchedulesHandler := handlers.TransferSchedulesHandler{
+			UserService:             &userService,
+			TransferScheduleService: &transferScheduleService,
+			TwoFactorService:        &twoFactorService,
+		}
+		r.Post(constants.TRANSFER_SCHEDULES_PATH, transferSchedulesHandler.Create)
+		r.Get(constants.TRANSFER_SCHEDULES_PATH, transferSchedulesHandler.List)
+		r.Get(constants.TRANSFER_SCHEDULE_PATH, transferSchedulesHandler.Get)
+		r.Patch(constants.TRANSFER_SCHEDULE_PATH, transferSchedulesHandler.Update)
+		r.Delete(constants.TRANSFER_SCHEDULE_PATH, transferSchedulesHandler.Delete)
+
+		potsHandler := handlers.PotsHandler{
+			UserService:      &userService,
+			PotService:       &potService,
+			TwoFactorService: &twoFactorService,
+		}
+		r.Post(constants.POTS_PATH, potsHandler.Create)
+		r.Get(constants.POTS_PATH, potsHandler.List)
+		r.Get(constants.POT_PATH, potsHandler.Get)
+		r.Get(constants.POT_CONTRIBUTIONS_PATH, potsHandler.Contributions)
+		r.Post(constants.POT_CONTRIBUTIONS_PATH, potsHandler.Contribute)
+		r.Post(constants.POT_DISBURSE_PATH, potsHandler.Disburse)
+		r.Post(constants.POT_CLOSE_PATH, potsHandler.Close)
+
+		bulkTransfersHandler := handlers.BulkTransfersHandler{
+			UserService:         &userService,
+			BulkTransferService: &bulkTransferService,
+			TwoFactorService:    &twoFactorService,
+		}
+		r.Post(constants.BULK_TRANSFERS_PATH, bulkTransfersHandler.Create)
+		r.Get(constants.BULK_TRANSFER_PATH, bulkTransfersHandler.Get)
+
+		escrowsHandler := handlers.EscrowsHandler{
+			UserService:      &userService,
+			EscrowService:    &escrowService,
+			TwoFactorService: &twoFactorService,
+		}
+		r.Post(constants.ESCROWS_PATH, escrowsHandler.Create)
+		r.Get(constants.ESCROWS_PATH, escrowsHandler.List)
+		r.Get(constants.ESCROW_PATH, escrowsHandler.Get)
+		r.Post(constants.ESCROW_RELEASE_PATH, escrowsHandler.Release)
+		r.Post(constants.ESCROW_REFUND_PATH, escrowsHandler.Refund)
+		r.Post(constants.ESCROW_DISPUTE_PATH, escrowsHandler.Dispute)
+
+		merchantHandler := handlers.MerchantHandler{MerchantService: &merchantService, QRSize: cfg.Merchant.QRSize}
+		r.Put(constants.MERCHANT_PATH, merchantHandler.Register)
+		r.Get(constants.MERCHANT_PATH, merchantHandler.Get)
+		r.Post(constants.MERCHANT_PAYMENT_CODES_PATH, merchantHandler.CreatePaymentCode)
+
+		payHandler := handlers.PayHandler{
+			UserService:      &userService,
+			MerchantService:  &merchantService,
+			TwoFactorService: &twoFactorService,
+		}
+		r.Post(constants.PAY_PATH, payHandler.Handle)
+
+		fxQuoteHandler := handlers.FXQuoteHandler{FXService: &fxService}
+		r.Post(constants.FX_QUOTE_PATH, fxQuoteHandler.Handle)
 
 		topTransactionsPerUserHandler := handlers.TopTransactionsPerUserHandler{UserBalanceService: &userBalanceService}
 		r.Get(constants.TOP_TRANSACTIONS_PER_USER_PATH, topTransactionsPerUserHandler.Handle)
 
 		topUserHandler := handlers.ListTopUserHandler{UserService: &userService}
 		r.Get(constants.TOP_USERS_PATH, topUserHandler.Handle)
+
+		mutationHistoryHandler := handlers.MutationHistoryHandler{UserBalanceService: &userBalanceService}
+		r.Get(constants.MUTATIONS_PATH, mutationHistoryHandler.Handle)
+
+		withdrawHandler := handlers.WithdrawHandler{
+			UserService:        &userService,
+			UserBalanceService: &userBalanceService,
+			TwoFactorService:   &twoFactorService,
+		}
+		r.Post(constants.WITHDRAW_PATH, withdrawHandler.Handle)
+
+		limitsHandler := handlers.LimitsHandler{LimitService: &limitService}
+		r.Get(constants.LIMITS_PATH, limitsHandler.Handle)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RoleMiddlewareHandler(models.VIEWER, models.SUPPORT, models.FINANCE))
+
+			adminUserHandler := handlers.AdminUserHandler{AdminService: &adminService}
+			r.Get(constants.ADMIN_USER_PATH, adminUserHandler.Handle)
+
+			adminMutationsHandler := handlers.AdminMutationsHandler{AdminService: &adminService}
+			r.Get(constants.ADMIN_MUTATIONS_PATH, adminMutationsHandler.Handle)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RoleMiddlewareHandler(models.SUPPORT, models.FINANCE))
+
+			adminFreezeHandler := handlers.AdminFreezeHandler{AdminService: &adminService, Frozen: true}
+			r.Post(constants.ADMIN_FREEZE_PATH, adminFreezeHandler.Handle)
+
+			adminUnfreezeHandler := handlers.AdminFreezeHandler{AdminService: &adminService, Frozen: false}
+			r.Post(constants.ADMIN_UNFREEZE_PATH, adminUnfreezeHandler.Handle)
+
+			adminCloseHandler := handlers.AdminCloseHandler{AdminService: &adminService}
+			r.Post(constants.ADMIN_CLOSE_PATH, adminCloseHandler.Handle)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RoleMiddlewareHandler(models.FINANCE))
+
+			adminAdjustmentHandler := handlers.AdminAdjustmentHandler{AdminService: &adminService}
+			r.Post(constants.ADMIN_ADJUSTMENTS_PATH, adminAdjustmentHandler.Handle)
+
+			adminReversalHandler := handlers.AdminReversalHandler{AdminService: &adminService}
+			r.Post(constants.ADMIN_REVERSE_TRANSFER_PATH, adminReversalHandler.Handle)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RoleMiddlewareHandler(models.ARBITRATOR))
+
+			adminEscrowHandler := handlers.AdminEscrowHandler{EscrowService: &escrowService}
+			r.Get(constants.ADMIN_ESCROW_PATH, adminEscrowHandler.Get)
+			r.Post(constants.ADMIN_ESCROW_RESOLVE_PATH, adminEscrowHandler.Resolve)
+		})
 	})
 
 	return r
 }
 
-func migrate([]string) {
+func migrate(args []string) {
 	cfg := configs.Get()
 	dbWrite := drivers.NewDBClientWrite(cfg)
-	m := migrations.Migrator{DB: dbWrite}
-	m.MigrateUp()
+	m := migrations.Migrator{DB: dbWrite, DefaultCurrency: cfg.Wallet.DefaultCurrency}
+
+	subCommand := "up"
+	if len(args) > 2 {
+		subCommand = args[2]
+	}
+	switch subCommand {
+	case "up":
+		m.MigrateUp()
+	case "ledger":
+		posted, err := m.BackfillLedger()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println(fmt.Sprintf("%v journal entries posted", posted))
+	case "balances":
+		err := m.RecomputeBalances()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("Wallet balances recomputed from ledger")
+	default:
+		log.Fatalln(fmt.Sprintf(`Unknown migrate command "%v", use "up", "ledger" or "balances"`, subCommand))
+	}
 }
 
 func runBlackbox() {
@@ -164,3 +575,225 @@ func runBlackbox() {
 	bb := blackbox.NewBlackBox(cfg)
 	bb.Run()
 }
+
+// ReverseActor and GrantRoleActor record the commands in the audit log.
+const (
+	ReverseActor   = "reverse"
+	GrantRoleActor = "grant-role"
+)
+
+func reverse(args []string) {
+	if len(args) < 3 {
+		log.Fatalln(`Please provide ref id of the transfer, example : "reverse <ref_id>"`)
+	}
+	cfg := configs.Get()
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	userBalanceService := services.UserBalanceService{
+		UserBalanceWrite:      &repos.UserBalanceRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+		AllowNegativeReversal: cfg.Reversal.AllowNegativeBalance,
+	}
+
+	audit := models.NewAuditLog(ReverseActor, models.ADMIN_REVERSAL, 0, "", map[string]interface{}{
+		"reversal_of": args[2],
+	})
+	reversalRefID, err := userBalanceService.ReverseTransfer(args[2], &audit)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Println(fmt.Sprintf(`Transfer "%v" reversed with ref id "%v"`, args[2], reversalRefID))
+}
+
+func reconcile(args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "repair drifted balances and total outgoings")
+	format := flags.String("format", "json", "report format, json or csv")
+	output := flags.String("output", "", "report file, defaults to stdout")
+	batchSize := flags.Int("batch-size", services.DefaultReconcileBatchSize, "wallets per batch")
+	flags.Parse(args[2:])
+
+	cfg := configs.Get()
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	reconcileService := services.ReconcileService{
+		ReconcileRepo: &repos.ReconcileRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		BatchSize:     *batchSize,
+	}
+
+	// the report must be writable before the books are touched
+	err := services.CheckReconcileReportFormat(*format)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer w.Close()
+	}
+
+	drifts, err := reconcileService.Reconcile(*fix)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	err = services.WriteReconcileReport(w, *format, drifts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Println(fmt.Sprintf("%v drifts found", len(drifts)))
+}
+
+func grantRole(args []string) {
+	if len(args) < 4 {
+		log.Fatalln(`Please provide username and role, example : "grant-role <username> <viewer|support|finance|arbitrator|none>"`)
+	}
+	role := models.Role(args[3])
+	if role == "none" {
+		role = ""
+	}
+
+	cfg := configs.Get()
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	adminService := services.AdminService{
+		UserRepoRead:  &repos.UserRepoRead{DBRead: dbWrite, Cache: cacheRepo},
+		UserRepoWrite: &repos.UserRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+	}
+
+	err := adminService.GrantRole(GrantRoleActor, args[2], role)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Println(fmt.Sprintf(`Role of "%v" set to "%v", it applies to tokens issued from now on`, args[2], args[3]))
+}
+
+func relay() {
+	cfg := configs.Get()
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	publisher, closePublisher, err := newEventPublisher(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer closePublisher()
+
+	rand.Seed(time.Now().UnixNano())
+	outboxRelay := services.OutboxRelay{
+		OutboxRepo:    &repos.OutboxRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		Publisher:     publisher,
+		InstanceID:    rand.Int63(),
+		BatchSize:     cfg.Outbox.BatchSize,
+		PollInterval:  cfg.Outbox.PollInterval,
+		LockTTL:       cfg.Outbox.LockTTL,
+		MaxAttempts:   cfg.Outbox.MaxAttempts,
+		RetryInterval: cfg.Outbox.RetryInterval,
+	}
+
+	// stop after the current batch on interrupt
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	log.Println(fmt.Sprintf(`Relaying outbox events to "%v"`, cfg.Outbox.Sink))
+	outboxRelay.Run(ctx)
+}
+
+// scheduler runs the due scheduled transfers. Several schedulers can run, only their
+// leader transfers.
+func scheduler() {
+	cfg := configs.Get()
+	c := drivers.GetRedisClient(cfg)
+	cacheRepo := repos.NewCache(cfg, c)
+	dbRead := drivers.NewDBClientRead(cfg)
+	dbWrite := drivers.NewDBClientWrite(cfg)
+
+	userBalanceService := newTransferUserBalanceService(cfg, cacheRepo, dbRead, dbWrite)
+
+	rand.Seed(time.Now().UnixNano())
+	transferScheduler := services.TransferScheduler{
+		TransferScheduleRepo: &repos.TransferScheduleRepo{DBWrite: dbWrite, Cache: cacheRepo},
+		UserBalanceService:   userBalanceService,
+		InstanceID:           rand.Int63(),
+		BatchSize:            cfg.Scheduler.BatchSize,
+		PollInterval:         cfg.Scheduler.PollInterval,
+		LockTTL:              cfg.Scheduler.LockTTL,
+		MaxAttempts:          cfg.Scheduler.MaxAttempts,
+		RetryInterval:        cfg.Scheduler.RetryInterval,
+	}
+
+	// stop after the current batch on interrupt, handing the lead over
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	log.Println(fmt.Sprintf(`Running scheduled transfers as instance %v`, transferScheduler.InstanceID))
+	transferScheduler.Run(ctx)
+}
+
+// newTransferUserBalanceService returns the balance service making the transfers of
+// a background job, charging fees and counting limits like a transfer request.
+func newTransferUserBalanceService(cfg *configs.Config, cacheRepo repos.ICache, dbRead *gorm.DB, dbWrite *gorm.DB) *services.UserBalanceService {
+	userRepoRead := repos.UserRepoRead{DBRead: dbRead, Cache: cacheRepo}
+	feeSchedule, err := services.NewFeeSchedule(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return &services.UserBalanceService{
+		UserRepoRead:     &userRepoRead,
+		UserBalanceWrite: &repos.UserBalanceRepoWrite{DBWrite: dbWrite, Cache: cacheRepo},
+		WalletRead:       &repos.WalletRepoRead{DBRead: dbRead, Cache: cacheRepo},
+		FXRead:           &repos.FXRepoRead{DBRead: dbRead, Cache: cacheRepo},
+		FeeCalculator:    feeSchedule,
+		LimitService: &services.LimitService{
+			UserRepoRead:    &userRepoRead,
+			LimitRepo:       &repos.LimitRepo{DBWrite: dbWrite, Cache: cacheRepo},
+			FXRateProvider:  services.NewFileFXRateProvider(cfg.FX.RatesFile),
+			DefaultCurrency: cfg.Wallet.DefaultCurrency,
+			DefaultTier:     cfg.Limits.DefaultTier,
+			Tiers:           cfg.Limits.Tiers,
+		},
+		DefaultCurrency: cfg.Wallet.DefaultCurrency,
+		Currencies:      cfg.Wallet.Currencies,
+	}
+}
+
+// newEventPublisher returns the publisher of OUTBOX.SINK and a func releasing it.
+func newEventPublisher(cfg *configs.Config) (publisher services.EventPublisher, closePublisher func(), err error) {
+	closePublisher = func() {}
+	switch cfg.Outbox.Sink {
+	case "", "stdout":
+		publisher = &services.WriterPublisher{Writer: os.Stdout}
+	case "file":
+		var f *os.File
+		f, err = os.OpenFile(cfg.Outbox.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		publisher = &services.WriterPublisher{Writer: f}
+		closePublisher = func() { f.Close() }
+	case "http":
+		publisher = services.NewHTTPPublisher(cfg.Outbox.URL, cfg.Outbox.Timeout)
+	default:
+		err = fmt.Errorf(`Unknown outbox sink "%v", use "stdout", "file" or "http"`, cfg.Outbox.Sink)
+	}
+	return
+}
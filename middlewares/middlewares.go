@@ -2,51 +2,42 @@ package middlewares
 
 import (
 	"context"
-	"fmt"
+	"crypto/subtle"
 	"net/http"
 
 	"github.com/atrariksa/awallet/configs"
+	"github.com/atrariksa/awallet/constants"
+	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
-	"github.com/golang-jwt/jwt"
+	"github.com/atrariksa/awallet/services"
 )
 
-func AuthMiddlewareHandler(cfg *configs.Config) func(next http.Handler) http.Handler {
+// AuthMiddlewareHandler rejects requests without a valid access token. Expired and
+// revoked tokens are rejected by the token service.
+func AuthMiddlewareHandler(tokenService services.ITokenService) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return isAuthorized(next, cfg)
+		return isAuthorized(next, tokenService)
 	}
 }
 
-func isAuthorized(next http.Handler, cfg *configs.Config) http.Handler {
+func isAuthorized(next http.Handler, tokenService services.ITokenService) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header["Authorization"] != nil {
 
-			token, err := jwt.ParseWithClaims(r.Header["Authorization"][0], &models.JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("Error Handling Token")
-				}
-				return []byte(cfg.JWT.Secret), nil
-			})
-
+			claims, err := tokenService.ValidateToken(r.Header["Authorization"][0])
 			if err != nil {
+				if err == errs.ErrInternalServer {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+					return
+				}
 				w.WriteHeader(http.StatusUnauthorized)
 				w.Write([]byte(http.StatusText(http.StatusUnauthorized)))
 				return
 			}
 
-			if token == nil {
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(http.StatusText(http.StatusUnauthorized)))
-				return
-			}
-
-			if claims, ok := token.Claims.(*models.JwtClaims); ok && token.Valid {
-				ctx := context.WithValue(r.Context(), "token", claims)
-				next.ServeHTTP(w, r.WithContext(ctx))
-			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
-				return
-			}
+			ctx := context.WithValue(r.Context(), "token", claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
 
 		} else {
 			w.WriteHeader(http.StatusUnauthorized)
@@ -55,3 +46,43 @@ func isAuthorized(next http.Handler, cfg *configs.Config) http.Handler {
 		}
 	})
 }
+
+// InternalMiddlewareHandler guards endpoints called by internal services, such as
+// the payout provider callback, with a shared secret.
+func InternalMiddlewareHandler(cfg *configs.Config) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := r.Header.Get(constants.INTERNAL_SECRET_HEADER)
+			if cfg.Internal.Secret == "" ||
+				subtle.ConstantTimeCompare([]byte(secret), []byte(cfg.Internal.Secret)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(http.StatusText(http.StatusUnauthorized)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RoleMiddlewareHandler lets through authenticated requests whose token carries one
+// of roles. It must run after AuthMiddlewareHandler.
+func RoleMiddlewareHandler(roles ...models.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value("token").(*models.JwtClaims)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(http.StatusText(http.StatusUnauthorized)))
+				return
+			}
+			for _, role := range roles {
+				if claims.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(http.StatusText(http.StatusForbidden)))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package migrations
+
+import (
+	"log"
+
+	"github.com/atrariksa/awallet/models"
+)
+
+const LedgerBackfillBatchSize = 500
+
+type ledgerRef struct {
+	RefID   string
+	FirstID uint
+}
+
+// BackfillLedger posts a journal entry for every movement recorded in mutations
+// before the ledger existed. Refs already posted are skipped, so it is safe to run
+// more than once. Pending and failed withdrawals have no entry, as nothing moved.
+func (m *Migrator) BackfillLedger() (posted int, err error) {
+	var lastID uint
+	for {
+		var refs []ledgerRef
+		err = m.DB.Debug().
+			Table("mutations as m").
+			Select("m.ref_id, MIN(m.id) as first_id").
+			Joins("left join journal_entries j on j.ref_id = m.ref_id").
+			Where("j.id IS NULL").
+			Where("m.mutation_type != ? OR m.status = ?", models.WITHDRAWAL, models.SETTLED).
+			Group("m.ref_id").
+			Having("MIN(m.id) > ?", lastID).
+			Order("first_id").
+			Limit(LedgerBackfillBatchSize).
+			Scan(&refs).Error
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		if len(refs) == 0 {
+			return
+		}
+
+		refIDs := make([]string, 0, len(refs))
+		for _, v := range refs {
+			refIDs = append(refIDs, v.RefID)
+		}
+		lastID = refs[len(refs)-1].FirstID
+
+		var mutations []models.Mutation
+		err = m.DB.Debug().Where("ref_id IN ?", refIDs).Order("id").Find(&mutations).Error
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		byRefID := map[string][]models.Mutation{}
+		for _, v := range mutations {
+			byRefID[v.RefID] = append(byRefID[v.RefID], v)
+		}
+
+		for _, refID := range refIDs {
+			entry, entryErr := models.NewJournalEntry(byRefID[refID]...)
+			if entryErr != nil {
+				log.Println(refID, entryErr)
+				continue
+			}
+
+			err = m.DB.Debug().Create(&entry).Error
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			posted++
+		}
+	}
+}
+
+// RecomputeBalances rebuilds the balance of every wallet from the postings of its
+// user account. Held balances are not part of the ledger and are left untouched.
+func (m *Migrator) RecomputeBalances() (err error) {
+	err = m.DB.Debug().Exec(
+		"UPDATE wallets w LEFT JOIN (" +
+			"SELECT p.user_id, p.currency, " +
+			"SUM(CASE WHEN p.direction = 'CREDIT' THEN p.amount ELSE -CAST(p.amount AS SIGNED) END) AS balance " +
+			"FROM postings p WHERE p.user_id != 0 GROUP BY p.user_id, p.currency" +
+			") p ON p.user_id = w.user_id AND p.currency = w.currency " +
+			"SET w.balance = COALESCE(p.balance, 0)",
+	).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
@@ -1,18 +1,112 @@
 package migrations
 
 import (
+	"log"
+	"strings"
+
 	"github.com/atrariksa/awallet/models"
 	"gorm.io/gorm"
 )
 
 type Migrator struct {
-	DB *gorm.DB
+	DB              *gorm.DB
+	DefaultCurrency string
 }
 
 func (m *Migrator) MigrateUp() {
 	m.DB.AutoMigrate(
 		&models.User{},
+		&models.Wallet{},
 		&models.Mutation{},
 		&models.UserTotalOutgoing{},
+		&models.IdempotencyKey{},
+		&models.FXQuote{},
+		&models.JournalEntry{},
+		&models.Posting{},
+		&models.AuditLog{},
+		&models.RefreshToken{},
+		&models.TwoFactor{},
+		&models.RecoveryCode{},
+		&models.TransferChallenge{},
+		&models.OutboxEvent{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.PaymentRequest{},
+		&models.TransferSchedule{},
+		&models.TransferScheduleRun{},
+		&models.Pot{},
+		&models.PotMember{},
+		&models.PotContribution{},
+		&models.BulkTransfer{},
+		&models.BulkTransferItem{},
+		&models.Escrow{},
+		&models.PaymentCode{},
+		&models.MutationTag{},
 	)
+	m.migrateWallets()
+	m.protectAuditLogs()
+}
+
+// protectAuditLogs makes table audit_logs append-only. Updates and deletes fail,
+// only TRUNCATE (used by the blackbox clean up) gets through.
+func (m *Migrator) protectAuditLogs() {
+	for _, event := range []string{"UPDATE", "DELETE"} {
+		trigger := "audit_logs_no_" + strings.ToLower(event)
+		err := m.DB.Exec("DROP TRIGGER IF EXISTS " + trigger).Error
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		err = m.DB.Exec(
+			"CREATE TRIGGER " + trigger + " BEFORE " + event + " ON audit_logs FOR EACH ROW " +
+				"SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only'",
+		).Error
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// migrateWallets moves balances kept on users before multi-currency wallets into a
+// wallet of the default currency, and assigns that currency to existing mutations
+// and total outgoings. It is safe to run more than once, with another default
+// currency too: a user who has any wallet already is skipped, and the legacy balance
+// columns of users, left in place, are zeroed in the transaction that copies them.
+func (m *Migrator) migrateWallets() {
+	migrator := m.DB.Migrator()
+	if migrator.HasColumn(&models.User{}, "balance") {
+		heldBalance := "0"
+		zeroBalances := "UPDATE users SET balance = 0"
+		if migrator.HasColumn(&models.User{}, "held_balance") {
+			heldBalance = "u.held_balance"
+			zeroBalances += ", held_balance = 0"
+		}
+		tx := m.DB.Begin()
+		err := tx.Exec(
+			"INSERT INTO wallets (user_id, currency, balance, held_balance) "+
+				"SELECT u.id, ?, COALESCE(u.balance, 0), COALESCE("+heldBalance+", 0) FROM users u "+
+				"WHERE NOT EXISTS (SELECT 1 FROM wallets w WHERE w.user_id = u.id)",
+			m.DefaultCurrency,
+		).Error
+		if err == nil {
+			err = tx.Exec(zeroBalances).Error
+		}
+		if err == nil {
+			err = tx.Commit().Error
+		}
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+		}
+	}
+
+	err := m.DB.Exec("UPDATE mutations SET currency = ? WHERE currency = '' OR currency IS NULL", m.DefaultCurrency).Error
+	if err != nil {
+		log.Println(err)
+	}
+
+	err = m.DB.Exec("UPDATE user_total_outgoings SET currency = ? WHERE currency = '' OR currency IS NULL", m.DefaultCurrency).Error
+	if err != nil {
+		log.Println(err)
+	}
 }
@@ -0,0 +1,43 @@
+package models
+
+// AccountStatus is the lifecycle state of an account. Accounts start ACTIVE and
+// CLOSED is final.
+type AccountStatus string
+
+const (
+	ACTIVE AccountStatus = "active"
+	// FROZEN_DEBIT accounts can receive money but not send or withdraw it.
+	FROZEN_DEBIT AccountStatus = "frozen-debit"
+	// FROZEN_ALL accounts can neither receive nor send money.
+	FROZEN_ALL AccountStatus = "frozen-all"
+	// CLOSED accounts hold no money and take no part in any movement anymore.
+	CLOSED AccountStatus = "closed"
+)
+
+func (as AccountStatus) IsValid() bool {
+	switch as {
+	case ACTIVE, FROZEN_DEBIT, FROZEN_ALL, CLOSED:
+		return true
+	}
+	return false
+}
+
+// CanDebit reports whether money can leave the account. Users stored before
+// statuses existed have an empty status and are active.
+func (as AccountStatus) CanDebit() bool {
+	return as == ACTIVE || as == ""
+}
+
+// CanCredit reports whether money can come into the account.
+func (as AccountStatus) CanCredit() bool {
+	return as == ACTIVE || as == FROZEN_DEBIT || as == ""
+}
+
+// CanBecome reports whether an account can move from as to next. Any open
+// account can be frozen, unfrozen or closed, a closed account stays closed.
+func (as AccountStatus) CanBecome(next AccountStatus) bool {
+	if !next.IsValid() || as == next {
+		return false
+	}
+	return as != CLOSED
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+)
+
+type AuditAction string
+
+const (
+	RECONCILE_FIX    AuditAction = "RECONCILE_FIX"
+	ROLE_GRANT       AuditAction = "ROLE_GRANT"
+	ADMIN_FREEZE     AuditAction = "ADMIN_FREEZE"
+	ADMIN_UNFREEZE   AuditAction = "ADMIN_UNFREEZE"
+	ADMIN_CLOSE      AuditAction = "ADMIN_CLOSE"
+	ADMIN_ADJUSTMENT AuditAction = "ADMIN_ADJUSTMENT"
+	ADMIN_REVERSAL   AuditAction = "ADMIN_REVERSAL"
+	ESCROW_RESOLVE   AuditAction = "ESCROW_RESOLVE"
+)
+
+// AuditLog is an append-only record of a change made outside the regular flows,
+// e.g. a balance repaired by the reconcile command or an admin action. Detail holds
+// the change as JSON. Updates and deletes are refused by the database.
+type AuditLog struct {
+	ID     uint
+	Actor  string      `gorm:"index:idx_actor"`
+	Action AuditAction `gorm:"index:idx_action"`
+	UserID uint        `gorm:"index:idx_user_id"`
+	// RefID is the ref id of the mutations the action made, if any.
+	RefID     string `gorm:"index:idx_ref_id"`
+	Reason    string `gorm:"type:text"`
+	Detail    string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+func NewAuditLog(actor string, action AuditAction, userID uint, reason string, detail interface{}) AuditLog {
+	bDetail, _ := json.Marshal(detail)
+	return AuditLog{
+		Actor:     actor,
+		Action:    action,
+		UserID:    userID,
+		Reason:    reason,
+		Detail:    string(bDetail),
+		CreatedAt: utils.TimeNowUTC(),
+	}
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/utils"
+)
+
+type BulkTransferMode string
+
+const (
+	// ALL_OR_NOTHING makes every transfer of a bulk transfer in one transaction, or none.
+	ALL_OR_NOTHING BulkTransferMode = "ALL_OR_NOTHING"
+	// BEST_EFFORT makes every transfer on its own and reports the result of each.
+	BEST_EFFORT BulkTransferMode = "BEST_EFFORT"
+)
+
+type BulkTransferStatus string
+
+const (
+	BULK_TRANSFER_PENDING    BulkTransferStatus = "PENDING"
+	BULK_TRANSFER_PROCESSING BulkTransferStatus = "PROCESSING"
+	BULK_TRANSFER_COMPLETED  BulkTransferStatus = "COMPLETED"
+	BULK_TRANSFER_FAILED     BulkTransferStatus = "FAILED"
+)
+
+type BulkTransferItemStatus string
+
+const (
+	BULK_ITEM_PENDING   BulkTransferItemStatus = "PENDING"
+	BULK_ITEM_SUCCEEDED BulkTransferItemStatus = "SUCCEEDED"
+	BULK_ITEM_FAILED    BulkTransferItemStatus = "FAILED"
+)
+
+// BulkTransfer is a job paying several users from the wallet of User in Currency. A
+// job run asynchronously is PENDING until a worker takes it, PROCESSING while its
+// items are transferred, then COMPLETED, or FAILED when an all-or-nothing job made
+// no transfer.
+type BulkTransfer struct {
+	ID             string `gorm:"primaryKey;size:36"`
+	User           User
+	UserID         uint               `gorm:"index:idx_user_id"`
+	Mode           BulkTransferMode   `gorm:"size:16"`
+	Currency       string             `gorm:"size:3"`
+	Status         BulkTransferStatus `gorm:"size:16;index:idx_status_updated_at,priority:1"`
+	TotalItems     int
+	SucceededItems int
+	FailedItems    int
+	TotalAmount    uint64
+	Error          string
+	Items          []BulkTransferItem
+	CreatedAt      time.Time
+	UpdatedAt      time.Time `gorm:"index:idx_status_updated_at,priority:2"`
+}
+
+// BulkTransferItem is one transfer of a bulk transfer. Line is its position in the
+// request, starting at 1.
+type BulkTransferItem struct {
+	ID             uint
+	BulkTransferID string `gorm:"size:36;index:idx_bulk_transfer_id_line,unique,priority:1"`
+	Line           int    `gorm:"index:idx_bulk_transfer_id_line,unique,priority:2"`
+	ToUsername     string
+	Amount         uint32
+	Reference      string                 `gorm:"size:64"`
+	Status         BulkTransferItemStatus `gorm:"size:16"`
+	RefID          string                 `gorm:"size:36"`
+	Fee            uint32
+	Error          string
+	UpdatedAt      time.Time
+}
+
+func NewBulkTransfer(user User, mode BulkTransferMode, currency string, items []BulkTransferItemRequest) BulkTransfer {
+	now := utils.TimeNowUTC()
+	bulkTransfer := BulkTransfer{
+		ID:         utils.NewUUIDString(),
+		UserID:     user.ID,
+		Mode:       mode,
+		Currency:   currency,
+		Status:     BULK_TRANSFER_PENDING,
+		TotalItems: len(items),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	for i, v := range items {
+		bulkTransfer.TotalAmount += uint64(v.Amount)
+		bulkTransfer.Items = append(bulkTransfer.Items, BulkTransferItem{
+			Line:       i + 1,
+			ToUsername: v.ToUsername,
+			Amount:     v.Amount,
+			Reference:  v.Reference,
+			Status:     BULK_ITEM_PENDING,
+			UpdatedAt:  now,
+		})
+	}
+	return bulkTransfer
+}
+
+// IdempotencyKey keeps the transfers of an all-or-nothing job from being made twice.
+func (bt BulkTransfer) IdempotencyKey() string {
+	return fmt.Sprintf("bulk:%v", bt.ID)
+}
+
+// ItemIdempotencyKey keeps the transfer of an item of a best-effort job from being
+// made twice.
+func (bt BulkTransfer) ItemIdempotencyKey(item BulkTransferItem) string {
+	return fmt.Sprintf("bulk:%v:%v", bt.ID, item.Line)
+}
+
+// Succeed records the transfer made for the item at index i.
+func (bt *BulkTransfer) Succeed(i int, resp TransferResponse) {
+	bt.Items[i].Status = BULK_ITEM_SUCCEEDED
+	bt.Items[i].RefID = resp.RefID
+	bt.Items[i].Fee = resp.Fee
+	bt.Items[i].Error = ""
+	bt.Items[i].UpdatedAt = utils.TimeNowUTC()
+	bt.SucceededItems++
+}
+
+// Fail records why the item at index i was not transferred.
+func (bt *BulkTransfer) Fail(i int, cause error) {
+	bt.Items[i].Status = BULK_ITEM_FAILED
+	bt.Items[i].Error = cause.Error()
+	bt.Items[i].UpdatedAt = utils.TimeNowUTC()
+	bt.FailedItems++
+}
+
+// ParseBulkTransferCSV reads the items of a bulk transfer from CSV rows of username,
+// amount and an optional reference. A first row starting with "username" or
+// "to_username" is a header and skipped. At most maxItems rows are read, 0 means no
+// maximum.
+func ParseBulkTransferCSV(r io.Reader, maxItems int) (items []BulkTransferItemRequest, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if line == 1 && len(record) > 0 {
+			header := strings.ToLower(strings.TrimSpace(record[0]))
+			if header == "username" || header == "to_username" {
+				continue
+			}
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("line %v: want username, amount and an optional reference", line)
+		}
+
+		amount, err := strconv.ParseUint(strings.TrimSpace(record[1]), 10, 32)
+		if err != nil || amount == 0 {
+			return nil, fmt.Errorf("line %v: invalid amount", line)
+		}
+		item := BulkTransferItemRequest{
+			ToUsername: strings.TrimSpace(record[0]),
+			Amount:     uint32(amount),
+		}
+		if len(record) == 3 {
+			item.Reference = strings.TrimSpace(record[2])
+		}
+
+		items = append(items, item)
+		if maxItems > 0 && len(items) > maxItems {
+			return nil, errs.ErrTooManyBulkTransferItems
+		}
+	}
+}
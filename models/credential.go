@@ -0,0 +1,75 @@
+package models
+
+import (
+	"github.com/atrariksa/awallet/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Credential is a secret a user proves its identity with. Each one has its own
+// failure counter and lockout.
+type Credential string
+
+const (
+	PASSWORD Credential = "PASSWORD"
+	PIN      Credential = "PIN"
+)
+
+// AttemptsColumn and LockedUntilColumn name the columns of users tracking failures of the credential.
+func (c Credential) AttemptsColumn() string {
+	if c == PIN {
+		return "failed_pin_attempts"
+	}
+	return "failed_login_attempts"
+}
+
+func (c Credential) LockedUntilColumn() string {
+	if c == PIN {
+		return "pin_locked_until"
+	}
+	return "login_locked_until"
+}
+
+// MaxPasswordBytes is the longest password bcrypt hashes, counted in bytes where the
+// request validation counts characters.
+const MaxPasswordBytes = 72
+
+// HashCredential hashes a password or PIN with bcrypt.
+func HashCredential(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckCredential reports whether secret matches hash. An empty hash never matches.
+func CheckCredential(hash string, secret string) bool {
+	if hash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+func (u User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+func (u User) HasPin() bool {
+	return u.PinHash != ""
+}
+
+// IsLocked reports whether the credential is locked out after too many failures.
+func (u User) IsLocked(credential Credential) bool {
+	lockedUntil := u.LoginLockedUntil
+	if credential == PIN {
+		lockedUntil = u.PinLockedUntil
+	}
+	return lockedUntil != nil && utils.TimeNowUTC().Before(*lockedUntil)
+}
+
+func (u User) FailedAttempts(credential Credential) int {
+	if credential == PIN {
+		return u.FailedPinAttempts
+	}
+	return u.FailedLoginAttempts
+}
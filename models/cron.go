@@ -0,0 +1,146 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+// cronHorizon bounds the search for the next run, an expression like "0 0 31 2 *"
+// never matches.
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// CronSchedule is a parsed five field cron expression: minute, hour, day of month,
+// month and day of week (0 is Sunday). Fields take *, values, ranges a-b, lists
+// and steps like */15. Times are matched in UTC.
+type CronSchedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	// as in cron, a day matches either restricted day field when both are restricted
+	anyDay     bool
+	anyWeekday bool
+}
+
+func ParseCron(expr string) (cs CronSchedule, err error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cs, errs.ErrInvalidCronExpression
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	sets := [5]map[int]bool{}
+	for i, field := range fields {
+		sets[i], err = parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return
+		}
+	}
+
+	return CronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, errs.ErrInvalidCronExpression
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errs.ErrInvalidCronExpression
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errs.ErrInvalidCronExpression
+				}
+			} else if step > 1 {
+				// "5/15" runs from 5 on
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, errs.ErrInvalidCronExpression
+		}
+
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time after t the schedule matches, or the zero time when
+// it does not match within the next five years.
+func (cs CronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	end := t.Add(cronHorizon)
+
+	for t.Before(end) {
+		if !cs.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !cs.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !cs.hours[t.Hour()] {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !cs.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (cs CronSchedule) matchDay(t time.Time) bool {
+	day := cs.days[t.Day()]
+	weekday := cs.weekdays[int(t.Weekday())]
+	if cs.anyDay || cs.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
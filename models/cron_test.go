@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+func TestParseCron(t *testing.T) {
+	var tests = []struct {
+		expr    string
+		wantErr error
+	}{
+		{"* * * * *", nil},
+		{"*/15 9-17 * * 1-5", nil},
+		{"0 0 1,15 * *", nil},
+		{"5/15 * * * *", nil},
+		{"@daily", nil},
+		{"  @hourly  ", nil},
+		{"", errs.ErrInvalidCronExpression},
+		{"* * * *", errs.ErrInvalidCronExpression},
+		{"* * * * * *", errs.ErrInvalidCronExpression},
+		{"60 * * * *", errs.ErrInvalidCronExpression},
+		{"* 24 * * *", errs.ErrInvalidCronExpression},
+		{"* * 0 * *", errs.ErrInvalidCronExpression},
+		{"* * * 13 *", errs.ErrInvalidCronExpression},
+		{"* * * * 7", errs.ErrInvalidCronExpression},
+		{"10-5 * * * *", errs.ErrInvalidCronExpression},
+		{"*/0 * * * *", errs.ErrInvalidCronExpression},
+		{"a * * * *", errs.ErrInvalidCronExpression},
+		{"@every", errs.ErrInvalidCronExpression},
+	}
+	for _, v := range tests {
+		_, err := ParseCron(v.expr)
+		if err != v.wantErr {
+			t.Errorf("ParseCron(%q) got %v, want %v", v.expr, err, v.wantErr)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2024, 1, 31, 10, 7, 30, 0, time.UTC)
+
+	var tests = []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", from, time.Date(2024, 1, 31, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", from, time.Date(2024, 1, 31, 10, 15, 0, 0, time.UTC)},
+		{"5/15 * * * *", from, time.Date(2024, 1, 31, 10, 20, 0, 0, time.UTC)},
+		{"0 9 * * *", from, time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"@hourly", from, time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", from, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// the 30th is skipped in February
+		{"0 0 30 * *", from, time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)},
+		// leap day
+		{"0 0 29 2 *", from, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 1-5", time.Date(2024, 2, 2, 13, 0, 0, 0, time.UTC), time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC)},
+		// both day fields restricted, either one matches
+		{"0 0 15 * 5", from, time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		// a time exactly on the schedule runs at the next match
+		{"0 9 * * *", time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC), time.Date(2024, 2, 2, 9, 0, 0, 0, time.UTC)},
+		// other time zones are matched in UTC
+		{"0 9 * * *", time.Date(2024, 2, 1, 15, 0, 0, 0, time.FixedZone("WIB", 7*3600)), time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", from, time.Time{}},
+	}
+	for _, v := range tests {
+		cs, err := ParseCron(v.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) got %v", v.expr, err)
+		}
+		got := cs.Next(v.from)
+		if !got.Equal(v.want) {
+			t.Errorf("Next of %q from %v got %v, want %v", v.expr, v.from, got, v.want)
+		}
+	}
+}
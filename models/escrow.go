@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+type EscrowStatus string
+
+const (
+	ESCROW_HELD     EscrowStatus = "HELD"
+	ESCROW_DISPUTED EscrowStatus = "DISPUTED"
+	ESCROW_RELEASED EscrowStatus = "RELEASED"
+	ESCROW_REFUNDED EscrowStatus = "REFUNDED"
+)
+
+func (es EscrowStatus) IsValid() bool {
+	switch es {
+	case ESCROW_HELD, ESCROW_DISPUTED, ESCROW_RELEASED, ESCROW_REFUNDED:
+		return true
+	}
+	return false
+}
+
+// EscrowParty is who settles or disputes an escrow.
+type EscrowParty string
+
+const (
+	ESCROW_PAYER      EscrowParty = "PAYER"
+	ESCROW_PAYEE      EscrowParty = "PAYEE"
+	ESCROW_ARBITRATOR EscrowParty = "ARBITRATOR"
+	// ESCROW_DEADLINE is the sweeper releasing an escrow at its deadline.
+	ESCROW_DEADLINE EscrowParty = "DEADLINE"
+)
+
+// Escrow holds money of Payer for Payee in its own ledger account, ESCROW:<ref id>,
+// RefID being the one of the hold. The payer releases it to the payee, the payee
+// refunds it to the payer, and it is released at ReleaseAt unless either of them
+// disputed it, which leaves it to an arbitrator.
+type Escrow struct {
+	RefID           string `gorm:"primaryKey;size:36"`
+	Payer           User
+	PayerID         uint `gorm:"index:idx_payer_id"`
+	Payee           User
+	PayeeID         uint   `gorm:"index:idx_payee_id"`
+	Currency        string `gorm:"size:3"`
+	Amount          uint32
+	Memo            string       `gorm:"size:140"`
+	Status          EscrowStatus `gorm:"size:16;index:idx_status_release_at,priority:1"`
+	ReleaseAt       time.Time    `gorm:"index:idx_status_release_at,priority:2"`
+	DisputedBy      EscrowParty  `gorm:"size:16"`
+	SettledBy       EscrowParty  `gorm:"size:16"`
+	SettlementRefID string       `gorm:"size:36"`
+	SettledAt       *time.Time
+	// ReleaseAttemptedAt is the last failed release at the deadline, the next one
+	// waits a retry interval so the escrows after it are released meanwhile.
+	ReleaseAttemptedAt *time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// EscrowParams holds amount of the payer for payeeUsername until releaseAt.
+type EscrowParams struct {
+	PayeeUsername string
+	Amount        uint32
+	Currency      string
+	Memo          string
+	ReleaseAt     time.Time
+}
+
+// EscrowAccount is the ledger account of the escrow held under refID.
+func EscrowAccount(refID string) string {
+	return fmt.Sprintf("ESCROW:%s", refID)
+}
+
+// PartyOf tells whether userID pays or is paid by the escrow. ok is false for anyone else.
+func (e Escrow) PartyOf(userID uint) (party EscrowParty, ok bool) {
+	switch userID {
+	case e.PayerID:
+		return ESCROW_PAYER, true
+	case e.PayeeID:
+		return ESCROW_PAYEE, true
+	}
+	return "", false
+}
+
+func (e Escrow) IsOpen() bool {
+	return e.Status == ESCROW_HELD || e.Status == ESCROW_DISPUTED
+}
+
+// CheckSettlement tells whether party may settle the escrow with outcome at now. Each
+// party may only give the money up: the payer releases it and the payee refunds it,
+// even when disputed. The arbitrator decides either way, and the deadline only
+// releases an undisputed escrow once ReleaseAt is past.
+func (e Escrow) CheckSettlement(party EscrowParty, outcome EscrowStatus, now time.Time) error {
+	if !e.IsOpen() {
+		return errs.ErrEscrowNotHeld
+	}
+
+	switch party {
+	case ESCROW_PAYER:
+		if outcome == ESCROW_RELEASED {
+			return nil
+		}
+	case ESCROW_PAYEE:
+		if outcome == ESCROW_REFUNDED {
+			return nil
+		}
+	case ESCROW_ARBITRATOR:
+		if outcome == ESCROW_RELEASED || outcome == ESCROW_REFUNDED {
+			return nil
+		}
+	case ESCROW_DEADLINE:
+		if outcome == ESCROW_RELEASED && e.Status == ESCROW_HELD && !now.Before(e.ReleaseAt) {
+			return nil
+		}
+	}
+	return errs.ErrEscrowActionNotAllowed
+}
+
+// CheckDispute tells whether party may dispute the escrow. Only a held escrow is
+// disputed, by its payer or payee.
+func (e Escrow) CheckDispute(party EscrowParty) error {
+	if e.Status != ESCROW_HELD {
+		return errs.ErrEscrowNotHeld
+	}
+	if party != ESCROW_PAYER && party != ESCROW_PAYEE {
+		return errs.ErrEscrowActionNotAllowed
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/utils"
+)
+
+// FXRateDecimals is the precision a quoted rate is locked with.
+const FXRateDecimals = 8
+
+type FXQuote struct {
+	ID           string `gorm:"primaryKey;size:36"`
+	User         User
+	UserID       uint   `gorm:"index:idx_user_id"`
+	FromCurrency string `gorm:"size:3"`
+	ToCurrency   string `gorm:"size:3"`
+	Rate         string
+	ExpiresAt    time.Time
+	UsedAt       *time.Time
+	CreatedAt    time.Time
+}
+
+func NewFXQuote(user User, fromCurrency string, toCurrency string, rate *big.Rat, ttl time.Duration) FXQuote {
+	now := utils.TimeNowUTC()
+	return FXQuote{
+		ID:           utils.NewUUIDString(),
+		UserID:       user.ID,
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		Rate:         rate.FloatString(FXRateDecimals),
+		ExpiresAt:    now.Add(ttl),
+		CreatedAt:    now,
+	}
+}
+
+func (q FXQuote) IsExpired() bool {
+	return !utils.TimeNowUTC().Before(q.ExpiresAt)
+}
+
+// ConvertAmount converts amount with a locked rate, rounding down so the
+// wallet never credits more than the quoted value.
+func ConvertAmount(amount uint32, rate string) (uint32, error) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok {
+		return 0, errs.ErrFXRateNotFound
+	}
+	converted := new(big.Rat).Mul(r, new(big.Rat).SetInt64(int64(amount)))
+	result := new(big.Int).Quo(converted.Num(), converted.Denom())
+	if !result.IsUint64() || result.Uint64() > math.MaxUint32 || result.Uint64() == 0 {
+		return 0, errs.ErrConvertedAmountOutOfRange
+	}
+	return uint32(result.Uint64()), nil
+}
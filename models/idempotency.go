@@ -0,0 +1,36 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+)
+
+type IdempotencyKey struct {
+	ID           uint
+	User         User
+	UserID       uint   `gorm:"index:idx_user_id_key,unique"`
+	Key          string `gorm:"index:idx_user_id_key,unique"`
+	RequestHash  string
+	ResponseCode int
+	ResponseBody []byte
+	CreatedAt    time.Time
+}
+
+func NewIdempotencyKey(user User, key string, path string, request interface{}) (*IdempotencyKey, error) {
+	bRequest, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(append([]byte(path+":"), bRequest...))
+	idempotencyKey := &IdempotencyKey{
+		UserID:      user.ID,
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash[:]),
+		CreatedAt:   utils.TimeNowUTC(),
+	}
+	return idempotencyKey, nil
+}
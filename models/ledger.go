@@ -0,0 +1,210 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+const (
+	TopupFundingAccount = "SYSTEM:TOPUP_FUNDING"
+	WithdrawalsAccount  = "SYSTEM:WITHDRAWALS"
+	FXAccount           = "SYSTEM:FX"
+	// FeesAccount is the house account collecting transfer fees.
+	FeesAccount = "SYSTEM:FEES"
+	// AdjustmentsAccount balances manual adjustments made by finance admins.
+	AdjustmentsAccount = "SYSTEM:ADJUSTMENTS"
+)
+
+func UserAccount(userID uint) string {
+	return fmt.Sprintf("USER:%d", userID)
+}
+
+// PotAccount is the wallet account of a group pot.
+func PotAccount(potID uint) string {
+	return fmt.Sprintf("POT:%d", potID)
+}
+
+type JournalEntryType string
+
+const (
+	TOPUP_ENTRY      JournalEntryType = "TOPUP"
+	TRANSFER_ENTRY   JournalEntryType = "TRANSFER"
+	REVERSAL_ENTRY   JournalEntryType = "REVERSAL"
+	WITHDRAWAL_ENTRY JournalEntryType = "WITHDRAWAL"
+	ADJUSTMENT_ENTRY JournalEntryType = "ADJUSTMENT"
+	POT_ENTRY        JournalEntryType = "POT"
+	ESCROW_ENTRY     JournalEntryType = "ESCROW"
+)
+
+type PostingDirection string
+
+const (
+	DEBIT  PostingDirection = "DEBIT"
+	CREDIT PostingDirection = "CREDIT"
+)
+
+// JournalEntry groups the postings of one money movement. Entries are unique per
+// RefID, so a movement can not be posted twice.
+type JournalEntry struct {
+	ID        uint
+	RefID     string `gorm:"index:idx_ref_id,unique"`
+	EntryType JournalEntryType
+	Postings  []Posting
+	CreatedAt time.Time
+}
+
+// Posting moves Amount in or out of an account. User accounts are credited when
+// money comes in, so a wallet balance is the sum of its credits minus its debits.
+type Posting struct {
+	ID             uint
+	JournalEntryID uint   `gorm:"index:idx_journal_entry_id"`
+	Account        string `gorm:"index:idx_account_currency,priority:1"`
+	UserID         uint   `gorm:"index:idx_user_id_currency,priority:1"`
+	Currency       string `gorm:"size:3;index:idx_account_currency,priority:2;index:idx_user_id_currency,priority:2"`
+	Direction      PostingDirection
+	Amount         uint64
+	CreatedAt      time.Time
+}
+
+// NewJournalEntry builds the balanced journal entry of mutations sharing a RefID.
+// Every mutation posts to the wallet account of its user, topups and withdrawals
+// are balanced against their system account, and a conversion is balanced per
+// currency through the FX account.
+func NewJournalEntry(mutations ...Mutation) (entry JournalEntry, err error) {
+	return newJournalEntry(systemCounterAccount, mutations)
+}
+
+// NewPotJournalEntry builds the journal entry of mutations moving money between
+// wallets and the account of pot potID, which balances every one of them.
+func NewPotJournalEntry(potID uint, mutations ...Mutation) (entry JournalEntry, err error) {
+	return newJournalEntry(func(MutationType) string { return PotAccount(potID) }, mutations)
+}
+
+// NewEscrowJournalEntry builds the journal entry of mutations moving money into or out
+// of the escrow held under escrowRefID, whose account balances every one of them.
+func NewEscrowJournalEntry(escrowRefID string, mutations ...Mutation) (entry JournalEntry, err error) {
+	return newJournalEntry(func(MutationType) string { return EscrowAccount(escrowRefID) }, mutations)
+}
+
+func newJournalEntry(counterAccountOf func(MutationType) string, mutations []Mutation) (entry JournalEntry, err error) {
+	if len(mutations) == 0 {
+		return entry, errs.ErrUnbalancedJournalEntry
+	}
+
+	entry = JournalEntry{
+		RefID:     mutations[0].RefID,
+		EntryType: journalEntryType(mutations[0].MutationType),
+		CreatedAt: mutations[0].CreatedAt,
+	}
+
+	hasFXRate := false
+	for _, m := range mutations {
+		direction := CREDIT
+		if m.MutationType.IsDebit() {
+			direction = DEBIT
+		}
+		entry.Postings = append(entry.Postings, newPosting(UserAccount(m.UserID), m.UserID, m.Currency, direction, m.Value, m.CreatedAt))
+
+		counterAccount := counterAccountOf(m.MutationType)
+		if counterAccount != "" {
+			entry.Postings = append(entry.Postings, newPosting(counterAccount, 0, m.Currency, direction.Opposite(), m.Value, m.CreatedAt))
+		}
+
+		if m.FXRate != "" {
+			hasFXRate = true
+		}
+	}
+
+	for currency, net := range entry.netByCurrency() {
+		if net == 0 {
+			continue
+		}
+		if !hasFXRate {
+			return JournalEntry{}, errs.ErrUnbalancedJournalEntry
+		}
+		direction := DEBIT
+		amount := net
+		if net < 0 {
+			direction = CREDIT
+			amount = -net
+		}
+		entry.Postings = append(entry.Postings, newPosting(FXAccount, 0, currency, direction, uint32(amount), entry.CreatedAt))
+	}
+
+	return entry, nil
+}
+
+// IsBalanced reports whether debits equal credits in every currency.
+func (je JournalEntry) IsBalanced() bool {
+	for _, net := range je.netByCurrency() {
+		if net != 0 {
+			return false
+		}
+	}
+	return len(je.Postings) > 0
+}
+
+// netByCurrency returns credits minus debits per currency.
+func (je JournalEntry) netByCurrency() map[string]int64 {
+	net := map[string]int64{}
+	for _, p := range je.Postings {
+		if p.Direction == CREDIT {
+			net[p.Currency] += int64(p.Amount)
+		} else {
+			net[p.Currency] -= int64(p.Amount)
+		}
+	}
+	return net
+}
+
+func (pd PostingDirection) Opposite() PostingDirection {
+	if pd == DEBIT {
+		return CREDIT
+	}
+	return DEBIT
+}
+
+func newPosting(account string, userID uint, currency string, direction PostingDirection, amount uint32, createdAt time.Time) Posting {
+	return Posting{
+		Account:   account,
+		UserID:    userID,
+		Currency:  currency,
+		Direction: direction,
+		Amount:    uint64(amount),
+		CreatedAt: createdAt,
+	}
+}
+
+func journalEntryType(mutationType MutationType) JournalEntryType {
+	switch mutationType {
+	case TOPUP:
+		return TOPUP_ENTRY
+	case REVERSAL_OUT, REVERSAL_IN:
+		return REVERSAL_ENTRY
+	case WITHDRAWAL:
+		return WITHDRAWAL_ENTRY
+	case ADJUSTMENT_CREDIT, ADJUSTMENT_DEBIT:
+		return ADJUSTMENT_ENTRY
+	case POT_CONTRIBUTION, POT_DISBURSEMENT, POT_REFUND:
+		return POT_ENTRY
+	case ESCROW_HOLD, ESCROW_RELEASE, ESCROW_REFUND:
+		return ESCROW_ENTRY
+	}
+	return TRANSFER_ENTRY
+}
+
+func systemCounterAccount(mutationType MutationType) string {
+	switch mutationType {
+	case TOPUP:
+		return TopupFundingAccount
+	case WITHDRAWAL:
+		return WithdrawalsAccount
+	case FEE:
+		return FeesAccount
+	case ADJUSTMENT_CREDIT, ADJUSTMENT_DEBIT:
+		return AdjustmentsAccount
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type LimitType string
+
+const (
+	OUTGOING_VALUE LimitType = "OUTGOING_VALUE"
+	OUTGOING_COUNT LimitType = "OUTGOING_COUNT"
+	TOPUP_VALUE    LimitType = "TOPUP_VALUE"
+)
+
+type LimitPeriod string
+
+const (
+	DAILY   LimitPeriod = "DAILY"
+	MONTHLY LimitPeriod = "MONTHLY"
+)
+
+// Limit caps the usage of one limit type within the current period.
+type Limit struct {
+	Type   LimitType
+	Period LimitPeriod
+	Max    uint64
+}
+
+// MutationTypes are the mutations counted by the limit, both for their value and count.
+func (l Limit) MutationTypes() []MutationType {
+	if l.Type == TOPUP_VALUE {
+		return []MutationType{TOPUP}
+	}
+	return OutgoingLimitMutationTypes
+}
+
+// PeriodStart returns the start of the UTC day or month containing now.
+func (l Limit) PeriodStart(now time.Time) time.Time {
+	now = now.UTC()
+	if l.Period == MONTHLY {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (l Limit) PeriodEnd(now time.Time) time.Time {
+	if l.Period == MONTHLY {
+		return l.PeriodStart(now).AddDate(0, 1, 0)
+	}
+	return l.PeriodStart(now).AddDate(0, 0, 1)
+}
+
+// CounterKey names the usage counter of a user for the period containing now. A daily
+// and a monthly limit of the same type start on the same day once a month, so the
+// period is part of the key.
+func (l Limit) CounterKey(userID uint, now time.Time) string {
+	return fmt.Sprintf("limit_%v_%v_%v_%v", userID, l.Type, l.Period, l.PeriodStart(now).Format("20060102"))
+}
+
+// LimitTotal is the value and count of mutations of a user in one currency.
+type LimitTotal struct {
+	Currency string
+	Value    int64
+	Count    int64
+}
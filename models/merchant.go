@@ -0,0 +1,125 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/utils"
+)
+
+type AccountType string
+
+const (
+	PERSONAL AccountType = "personal"
+	MERCHANT AccountType = "merchant"
+)
+
+func (u User) IsMerchant() bool {
+	return u.AccountType == MERCHANT
+}
+
+// paymentPayloadPrefix starts every payment payload and versions its format.
+const paymentPayloadPrefix = "AWP1"
+
+// PaymentCode is a dynamic payment code of Merchant, bound to Amount and OrderRef. It
+// is paid once, RefID being the transfer that paid it.
+type PaymentCode struct {
+	ID         string `gorm:"primaryKey;size:36"`
+	Merchant   User
+	MerchantID uint `gorm:"index:idx_merchant_id"`
+	Amount     uint32
+	Currency   string `gorm:"size:3"`
+	OrderRef   string `gorm:"size:64"`
+	ExpiresAt  time.Time
+	RefID      string `gorm:"size:36"`
+	CreatedAt  time.Time
+}
+
+func NewPaymentCode(merchant User, amount uint32, orderRef string, expiresAt time.Time) PaymentCode {
+	return PaymentCode{
+		ID:         utils.NewUUIDString(),
+		MerchantID: merchant.ID,
+		Amount:     amount,
+		Currency:   merchant.SettlementCurrency,
+		OrderRef:   orderRef,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  utils.TimeNowUTC(),
+	}
+}
+
+// PaymentPayload is what a payment QR code of a merchant holds. A static payload has
+// no CodeID nor Amount, the payer sets the amount, and may never expire. A dynamic
+// payload is the one of its payment code.
+type PaymentPayload struct {
+	CodeID    string `json:"id,omitempty"`
+	Merchant  string `json:"merchant"`
+	Currency  string `json:"currency"`
+	Amount    uint32 `json:"amount,omitempty"`
+	OrderRef  string `json:"order_ref,omitempty"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+func NewPaymentPayload(merchant User, code *PaymentCode) PaymentPayload {
+	payload := PaymentPayload{
+		Merchant: merchant.Username,
+		Currency: merchant.SettlementCurrency,
+	}
+	if code != nil {
+		payload.CodeID = code.ID
+		payload.Amount = code.Amount
+		payload.OrderRef = code.OrderRef
+		payload.ExpiresAt = code.ExpiresAt.Unix()
+	}
+	return payload
+}
+
+func (pp PaymentPayload) IsDynamic() bool {
+	return pp.CodeID != ""
+}
+
+// Encode returns the payload as "AWP1.<data>.<signature>", data being its base64url
+// JSON and signature the base64url HMAC-SHA256 of "AWP1.<data>" keyed with secret.
+func (pp PaymentPayload) Encode(secret string) string {
+	data, _ := json.Marshal(&pp)
+	signed := paymentPayloadPrefix + "." + base64.RawURLEncoding.EncodeToString(data)
+	return signed + "." + signPaymentPayload(secret, signed)
+}
+
+// DecodePaymentPayload verifies the signature of encoded and returns its payload,
+// unless it expired at now.
+func DecodePaymentPayload(secret string, encoded string, now time.Time) (payload PaymentPayload, err error) {
+	parts := strings.Split(strings.TrimSpace(encoded), ".")
+	if len(parts) != 3 || parts[0] != paymentPayloadPrefix {
+		return payload, errs.ErrInvalidPaymentPayload
+	}
+
+	signed := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(signPaymentPayload(secret, signed)), []byte(parts[2])) {
+		return payload, errs.ErrInvalidPaymentPayload
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return payload, errs.ErrInvalidPaymentPayload
+	}
+	err = json.Unmarshal(data, &payload)
+	if err != nil || payload.Merchant == "" {
+		return payload, errs.ErrInvalidPaymentPayload
+	}
+
+	if payload.ExpiresAt != 0 && !now.Before(time.Unix(payload.ExpiresAt, 0)) {
+		return payload, errs.ErrPaymentPayloadExpired
+	}
+	return payload, nil
+}
+
+func signPaymentPayload(secret string, signed string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
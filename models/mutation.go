@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"time"
 
 	"github.com/atrariksa/awallet/utils"
@@ -9,64 +10,238 @@ import (
 type Mutation struct {
 	ID           uint
 	User         User
-	UserID       uint   `gorm:"index:idx_user_id"`
+	UserID       uint   `gorm:"index:idx_user_id;index:idx_user_id_created_at,priority:1;index:idx_user_id_external_ref,unique,priority:1"`
 	RefID        string `gorm:"index:idx_ref_id"`
 	MutationType MutationType
+	Status       MutationStatus `gorm:"default:SETTLED"`
+	Currency     string         `gorm:"size:3"`
 	Value        uint32
-	CreatedAt    time.Time
+	FXRate       string
+	ReversalOf   string  `gorm:"index:idx_reversal_of"`
+	OrderRef     string  `gorm:"size:64"`
+	Memo         string  `gorm:"size:140"`
+	ExternalRef  *string `gorm:"size:64;index:idx_user_id_external_ref,unique,priority:2"`
+	Tags         []MutationTag
+	CreatedAt    time.Time `gorm:"index:idx_user_id_created_at,priority:2"`
 }
 
 type TopTransactionResult struct {
+	ID           uint
 	Username     string
 	RefID        string
 	MutationType MutationType
+	Currency     string
 	Value        uint32
+	Memo         string
+	ExternalRef  string
+	Tags         []string `gorm:"-"`
 }
 
 type MutationType string
 
 const (
-	INCOMING MutationType = "INCOMING"
-	TOPUP    MutationType = "TOPUP"
-	OUTGOING MutationType = "OUTGOING"
+	INCOMING     MutationType = "INCOMING"
+	TOPUP        MutationType = "TOPUP"
+	OUTGOING     MutationType = "OUTGOING"
+	REVERSAL_OUT MutationType = "REVERSAL_OUT"
+	REVERSAL_IN  MutationType = "REVERSAL_IN"
+	WITHDRAWAL   MutationType = "WITHDRAWAL"
+	FEE          MutationType = "FEE"
+	// ADJUSTMENT_CREDIT and ADJUSTMENT_DEBIT are manual corrections made by finance
+	// admins, balanced against the adjustments account.
+	ADJUSTMENT_CREDIT MutationType = "ADJUSTMENT_CREDIT"
+	ADJUSTMENT_DEBIT  MutationType = "ADJUSTMENT_DEBIT"
+	// POT_CONTRIBUTION moves money from the wallet of a member into a group pot,
+	// POT_DISBURSEMENT and POT_REFUND move it out to the owner or back to a contributor.
+	POT_CONTRIBUTION MutationType = "POT_CONTRIBUTION"
+	POT_DISBURSEMENT MutationType = "POT_DISBURSEMENT"
+	POT_REFUND       MutationType = "POT_REFUND"
+	// ESCROW_HOLD moves money of a payer into an escrow, ESCROW_RELEASE and
+	// ESCROW_REFUND move it out to the payee or back to the payer.
+	ESCROW_HOLD    MutationType = "ESCROW_HOLD"
+	ESCROW_RELEASE MutationType = "ESCROW_RELEASE"
+	ESCROW_REFUND  MutationType = "ESCROW_REFUND"
 )
 
-func NewTopupMutation(user User, amount uint32) Mutation {
-	mutation := Mutation{
-		UserID:       user.ID,
-		RefID:        utils.NewUUIDString(),
-		MutationType: TOPUP,
-		Value:        amount,
-		CreatedAt:    utils.TimeNowUTC(),
+type MutationStatus string
+
+const (
+	PENDING MutationStatus = "PENDING"
+	SETTLED MutationStatus = "SETTLED"
+	FAILED  MutationStatus = "FAILED"
+)
+
+// TransferMutationTypes are the types of the two rows written for any
+// movement between two users, sharing the same RefID.
+var TransferMutationTypes = []string{
+	string(INCOMING),
+	string(OUTGOING),
+	string(REVERSAL_OUT),
+	string(REVERSAL_IN),
+}
+
+// OutgoingLimitMutationTypes are the debits that reserve the outgoing limits of their
+// user, transfers, bulk ones included, pot contributions and escrow holds. The usage
+// of a limit is summed from them when its counter is missing.
+var OutgoingLimitMutationTypes = []MutationType{
+	OUTGOING,
+	POT_CONTRIBUTION,
+	ESCROW_HOLD,
+}
+
+func (mt MutationType) IsValid() bool {
+	switch mt {
+	case INCOMING, TOPUP, OUTGOING, REVERSAL_OUT, REVERSAL_IN, WITHDRAWAL, FEE, ADJUSTMENT_CREDIT, ADJUSTMENT_DEBIT,
+		POT_CONTRIBUTION, POT_DISBURSEMENT, POT_REFUND, ESCROW_HOLD, ESCROW_RELEASE, ESCROW_REFUND:
+		return true
 	}
-	return mutation
+	return false
 }
 
-func NewOutgoingMutation(user User, amount uint32) Mutation {
-	mutation := Mutation{
-		UserID:       user.ID,
-		RefID:        utils.NewUUIDString(),
-		MutationType: OUTGOING,
-		Value:        amount,
-		CreatedAt:    utils.TimeNowUTC(),
+// IsDebit reports whether the mutation takes money out of the wallet of its user.
+func (mt MutationType) IsDebit() bool {
+	switch mt {
+	case OUTGOING, REVERSAL_OUT, WITHDRAWAL, FEE, ADJUSTMENT_DEBIT, POT_CONTRIBUTION, ESCROW_HOLD:
+		return true
 	}
+	return false
+}
+
+func NewTopupMutation(user User, amount uint32, currency string) Mutation {
+	return newMutation(user, amount, currency, TOPUP)
+}
+
+// NewWithdrawalMutation records a pending withdrawal. Its amount stays on hold
+// until the withdrawal is settled or failed.
+func NewWithdrawalMutation(user User, amount uint32, currency string) Mutation {
+	mutation := newMutation(user, amount, currency, WITHDRAWAL)
+	mutation.Status = PENDING
+	return mutation
+}
+
+// NewPayoutMutation records the settled withdrawal paying out the balance left in a
+// wallet when its account is closed.
+func NewPayoutMutation(user User, amount uint32, currency string) Mutation {
+	return newMutation(user, amount, currency, WITHDRAWAL)
+}
+
+// NewFeeMutation records the fee charged to the sender of a transfer. It shares
+// the RefID of the outgoing mutation.
+func NewFeeMutation(outgoing Mutation, fee uint32) Mutation {
+	mutation := newMutation(User{ID: outgoing.UserID}, fee, outgoing.Currency, FEE)
+	mutation.RefID = outgoing.RefID
+	mutation.CreatedAt = outgoing.CreatedAt
 	return mutation
 }
 
-func NewIncomingMutation(user User, amount uint32) Mutation {
+// NewAdjustmentMutation records a manual credit or debit of the wallet of user.
+func NewAdjustmentMutation(user User, amount uint32, currency string, direction PostingDirection) Mutation {
+	if direction == DEBIT {
+		return newMutation(user, amount, currency, ADJUSTMENT_DEBIT)
+	}
+	return newMutation(user, amount, currency, ADJUSTMENT_CREDIT)
+}
+
+// NewPotContributionMutation records money of user moving into a pot.
+func NewPotContributionMutation(user User, amount uint32, currency string) Mutation {
+	return newMutation(user, amount, currency, POT_CONTRIBUTION)
+}
+
+// NewPotPayoutMutations records amount paid out of a pot to user, a disbursement or a
+// refund, in mutations of at most MaxUint32 sharing refID.
+func NewPotPayoutMutations(user User, amount uint64, currency string, mutationType MutationType, refID string) (mutations []Mutation) {
+	for remaining := amount; remaining > 0; {
+		value := uint32(math.MaxUint32)
+		if remaining < math.MaxUint32 {
+			value = uint32(remaining)
+		}
+		remaining -= uint64(value)
+
+		mutation := newMutation(user, value, currency, mutationType)
+		mutation.RefID = refID
+		mutations = append(mutations, mutation)
+	}
+	return
+}
+
+// NewEscrowHoldMutation records money of the payer moving into an escrow. Its RefID
+// identifies the escrow.
+func NewEscrowHoldMutation(payer User, amount uint32, currency string) Mutation {
+	return newMutation(payer, amount, currency, ESCROW_HOLD)
+}
+
+// NewEscrowSettlementMutation records the money of an escrow paid out to user, a
+// release to the payee or a refund to the payer.
+func NewEscrowSettlementMutation(user User, amount uint32, currency string, outcome EscrowStatus) Mutation {
+	if outcome == ESCROW_REFUNDED {
+		return newMutation(user, amount, currency, ESCROW_REFUND)
+	}
+	return newMutation(user, amount, currency, ESCROW_RELEASE)
+}
+
+func NewOutgoingMutation(user User, amount uint32, currency string) Mutation {
+	return newMutation(user, amount, currency, OUTGOING)
+}
+
+func NewIncomingMutation(user User, amount uint32, currency string) Mutation {
+	return newMutation(user, amount, currency, INCOMING)
+}
+
+func newMutation(user User, amount uint32, currency string, mutationType MutationType) Mutation {
 	mutation := Mutation{
 		UserID:       user.ID,
 		RefID:        utils.NewUUIDString(),
-		MutationType: INCOMING,
+		MutationType: mutationType,
+		Status:       SETTLED,
+		Currency:     currency,
 		Value:        amount,
 		CreatedAt:    utils.TimeNowUTC(),
 	}
 	return mutation
 }
 
-func NewTransferBalanceMutation(user User, amount uint32, destUser User) (outgoing Mutation, incoming Mutation) {
-	outgoing = NewOutgoingMutation(user, amount)
-	incoming = NewIncomingMutation(destUser, amount)
+func NewTransferBalanceMutation(user User, amount uint32, currency string, destUser User) (outgoing Mutation, incoming Mutation) {
+	return newTransferMutations(user, amount, currency, destUser, OUTGOING, INCOMING)
+}
+
+// NewConversionTransferMutation records a transfer debiting one currency and crediting
+// another. Both legs keep the rate used for the conversion.
+func NewConversionTransferMutation(user User, destUser User, params TransferParams) (outgoing Mutation, incoming Mutation) {
+	outgoing, incoming = NewTransferBalanceMutation(user, params.Amount, params.Currency, destUser)
+	incoming.Value = params.ToAmount
+	incoming.Currency = params.ToCurrency
+	outgoing.FXRate = params.FXRate
+	incoming.FXRate = params.FXRate
+	outgoing.OrderRef = params.OrderRef
+	incoming.OrderRef = params.OrderRef
+	return
+}
+
+// NewReversalMutation compensates the transfer recorded by original outgoing mutation.
+// The original recipient gets a REVERSAL_OUT and the original sender a REVERSAL_IN,
+// both linked to the original RefID. Each leg mirrors the amount and currency of the
+// original one, so a conversion is reversed at its original rate.
+func NewReversalMutation(originalOutgoing Mutation, originalIncoming Mutation) (reversalOut Mutation, reversalIn Mutation) {
+	reversalOut, reversalIn = newTransferMutations(
+		User{ID: originalIncoming.UserID},
+		originalIncoming.Value,
+		originalIncoming.Currency,
+		User{ID: originalOutgoing.UserID},
+		REVERSAL_OUT,
+		REVERSAL_IN,
+	)
+	reversalIn.Value = originalOutgoing.Value
+	reversalIn.Currency = originalOutgoing.Currency
+	reversalOut.FXRate = originalIncoming.FXRate
+	reversalIn.FXRate = originalOutgoing.FXRate
+	reversalOut.ReversalOf = originalOutgoing.RefID
+	reversalIn.ReversalOf = originalOutgoing.RefID
+	return
+}
+
+func newTransferMutations(user User, amount uint32, currency string, destUser User, outgoingType, incomingType MutationType) (outgoing Mutation, incoming Mutation) {
+	outgoing = newMutation(user, amount, currency, outgoingType)
+	incoming = newMutation(destUser, amount, currency, incomingType)
 	incoming.RefID = outgoing.RefID
 	incoming.CreatedAt = outgoing.CreatedAt
 	return
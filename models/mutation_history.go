@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+type MutationHistoryFilter struct {
+	UserID       uint
+	MutationType MutationType
+	Currency     string
+	From         *time.Time
+	To           *time.Time
+	MinAmount    *uint32
+	MaxAmount    *uint32
+	Tag          string
+	ExternalRef  string
+	Cursor       *MutationCursor
+	Limit        int
+}
+
+type MutationHistoryResult struct {
+	ID           uint
+	RefID        string
+	MutationType MutationType
+	Status       MutationStatus
+	Currency     string
+	Value        uint32
+	OrderRef     string
+	Memo         string
+	ExternalRef  string
+	Tags         []string `gorm:"-"`
+	CreatedAt    time.Time
+	Counterparty string
+}
+
+// MutationCursor points to the last mutation of a page. Mutations are listed
+// by created_at and id descending, so the next page starts right after it.
+type MutationCursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+func (mc MutationCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", mc.CreatedAt.UnixNano(), mc.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeMutationCursor parses a cursor made by Encode. Anything else, trailing bytes
+// included, is refused.
+func DecodeMutationCursor(cursor string) (*MutationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errs.ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return nil, errs.ErrInvalidCursor
+	}
+	unixNano, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errs.ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, errs.ErrInvalidCursor
+	}
+	mc := &MutationCursor{CreatedAt: time.Unix(0, unixNano).UTC(), ID: uint(id)}
+	// a cursor is only valid in the exact form Encode gives it
+	if mc.Encode() != cursor {
+		return nil, errs.ErrInvalidCursor
+	}
+	return mc, nil
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+const (
+	MaxMemoLength        = 140
+	MaxMutationTags      = 10
+	MaxExternalRefLength = 64
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// MutationTag is a tag a user put on one of its mutations, to find it again in its
+// history.
+type MutationTag struct {
+	ID         uint
+	MutationID uint   `gorm:"index:idx_mutation_id"`
+	UserID     uint   `gorm:"index:idx_user_id_tag,priority:1"`
+	Tag        string `gorm:"size:32;index:idx_user_id_tag,priority:2"`
+}
+
+// MutationNote tells why money moved: a free text Memo, Tags of the user and the
+// ExternalRef of its own system, unique among the mutations of the user.
+type MutationNote struct {
+	Memo        string
+	Tags        []string
+	ExternalRef string
+}
+
+// Normalize returns the note sanitized: the memo without control characters and
+// repeated spaces, the tags lowercased without duplicates, and the external reference
+// trimmed. It refuses what is too long, a tag other than letters, digits, "_" and "-",
+// and an external reference with spaces or control characters.
+func (mn MutationNote) Normalize() (note MutationNote, err error) {
+	note.Memo = SanitizeMemo(mn.Memo)
+	if utf8.RuneCountInString(note.Memo) > MaxMemoLength {
+		return note, errs.ErrInvalidMemo
+	}
+
+	for _, v := range mn.Tags {
+		tag := NormalizeTag(v)
+		if !tagPattern.MatchString(tag) {
+			return note, errs.ErrInvalidTag
+		}
+		if !containsString(note.Tags, tag) {
+			note.Tags = append(note.Tags, tag)
+		}
+	}
+	if len(note.Tags) > MaxMutationTags {
+		return note, errs.ErrTooManyTags
+	}
+
+	note.ExternalRef = strings.TrimSpace(mn.ExternalRef)
+	if utf8.RuneCountInString(note.ExternalRef) > MaxExternalRefLength ||
+		strings.IndexFunc(note.ExternalRef, func(r rune) bool { return unicode.IsSpace(r) || !unicode.IsPrint(r) }) >= 0 {
+		return note, errs.ErrInvalidExternalRef
+	}
+	return note, nil
+}
+
+// SanitizeMemo drops the control characters of memo and collapses its whitespace.
+func SanitizeMemo(memo string) string {
+	memo = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		if !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, memo)
+	return strings.Join(strings.Fields(memo), " ")
+}
+
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// SetNote puts note on the mutation, tagged for the user of the mutation.
+func (m *Mutation) SetNote(note MutationNote) {
+	m.Memo = note.Memo
+	if note.ExternalRef != "" {
+		externalRef := note.ExternalRef
+		m.ExternalRef = &externalRef
+	}
+	m.Tags = nil
+	for _, v := range note.Tags {
+		m.Tags = append(m.Tags, MutationTag{UserID: m.UserID, Tag: v})
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
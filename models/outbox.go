@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+)
+
+type EventType string
+
+const (
+	USER_CREATED       EventType = "UserCreated"
+	BALANCE_TOPPED_UP  EventType = "BalanceToppedUp"
+	TRANSFER_COMPLETED EventType = "TransferCompleted"
+)
+
+type OutboxEventStatus string
+
+const (
+	OUTBOX_PENDING   OutboxEventStatus = "PENDING"
+	OUTBOX_PUBLISHED OutboxEventStatus = "PUBLISHED"
+	// OUTBOX_FAILED events ran out of attempts, they no longer hold back the events
+	// of their users and are not published again.
+	OUTBOX_FAILED OutboxEventStatus = "FAILED"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the change it
+// describes, and published afterwards by the relay. Events of a user are published
+// in ID order. CounterpartyID is the other user of a transfer, whose events are
+// kept in order too. Pending events are published from NextAttemptAt on.
+type OutboxEvent struct {
+	ID             uint
+	EventID        string    `gorm:"size:36;index:idx_event_id,unique"`
+	EventType      EventType `gorm:"size:32"`
+	UserID         uint      `gorm:"index:idx_user_id"`
+	CounterpartyID uint
+	Payload        string            `gorm:"type:text"`
+	Status         OutboxEventStatus `gorm:"size:16;index:idx_status_next_attempt_at,priority:1"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index:idx_status_next_attempt_at,priority:2"`
+	LastError      string    `gorm:"type:text"`
+	PublishedAt    *time.Time
+	CreatedAt      time.Time
+}
+
+// Event is the envelope delivered to publishers. ID stays the same when an event is
+// delivered again, so consumers can drop duplicates.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       EventType       `json:"type"`
+	UserID     uint            `json:"user_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+type UserCreatedEvent struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Currency string `json:"currency"`
+}
+
+type BalanceToppedUpEvent struct {
+	RefID    string `json:"ref_id"`
+	UserID   uint   `json:"user_id"`
+	Currency string `json:"currency"`
+	Amount   uint32 `json:"amount"`
+}
+
+type TransferCompletedEvent struct {
+	RefID      string `json:"ref_id"`
+	FromUserID uint   `json:"from_user_id"`
+	ToUserID   uint   `json:"to_user_id"`
+	Currency   string `json:"currency"`
+	Amount     uint32 `json:"amount"`
+	Fee        uint32 `json:"fee"`
+	ToCurrency string `json:"to_currency"`
+	ToAmount   uint32 `json:"to_amount"`
+}
+
+func NewUserCreatedEvent(user User, currency string) OutboxEvent {
+	return newOutboxEvent(USER_CREATED, user.ID, 0, UserCreatedEvent{
+		UserID:   user.ID,
+		Username: user.Username,
+		Currency: currency,
+	})
+}
+
+func NewBalanceToppedUpEvent(topup Mutation) OutboxEvent {
+	return newOutboxEvent(BALANCE_TOPPED_UP, topup.UserID, 0, BalanceToppedUpEvent{
+		RefID:    topup.RefID,
+		UserID:   topup.UserID,
+		Currency: topup.Currency,
+		Amount:   topup.Value,
+	})
+}
+
+func NewTransferCompletedEvent(outgoing Mutation, incoming Mutation, fee uint32) OutboxEvent {
+	return newOutboxEvent(TRANSFER_COMPLETED, outgoing.UserID, incoming.UserID, TransferCompletedEvent{
+		RefID:      outgoing.RefID,
+		FromUserID: outgoing.UserID,
+		ToUserID:   incoming.UserID,
+		Currency:   outgoing.Currency,
+		Amount:     outgoing.Value,
+		Fee:        fee,
+		ToCurrency: incoming.Currency,
+		ToAmount:   incoming.Value,
+	})
+}
+
+func newOutboxEvent(eventType EventType, userID uint, counterpartyID uint, payload interface{}) OutboxEvent {
+	now := utils.TimeNowUTC()
+	bPayload, _ := json.Marshal(payload)
+	return OutboxEvent{
+		EventID:        utils.NewUUIDString(),
+		EventType:      eventType,
+		UserID:         userID,
+		CounterpartyID: counterpartyID,
+		Payload:        string(bPayload),
+		Status:         OUTBOX_PENDING,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+}
+
+func (oe OutboxEvent) Event() Event {
+	return Event{
+		ID:         oe.EventID,
+		Type:       oe.EventType,
+		UserID:     oe.UserID,
+		OccurredAt: oe.CreatedAt,
+		Payload:    json.RawMessage(oe.Payload),
+	}
+}
+
+// UserIDs are the users whose events must stay in order with this one.
+func (oe OutboxEvent) UserIDs() []uint {
+	if oe.CounterpartyID == 0 || oe.CounterpartyID == oe.UserID {
+		return []uint{oe.UserID}
+	}
+	return []uint{oe.UserID, oe.CounterpartyID}
+}
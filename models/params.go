@@ -0,0 +1,47 @@
+package models
+
+type TopupParams struct {
+	Amount         uint32
+	Currency       string
+	Note           MutationNote
+	IdempotencyKey *IdempotencyKey
+}
+
+type TransferParams struct {
+	Amount         uint32
+	Currency       string
+	ToUsername     string
+	QuoteID        string
+	IdempotencyKey *IdempotencyKey
+
+	// Note is put on the mutation of the sender. The recipient only sees its memo.
+	Note MutationNote
+
+	// PaymentRequestID is a pending payment request of the sender the transfer pays.
+	// It is marked paid within the same transaction.
+	PaymentRequestID string
+
+	// PaymentCodeID is a dynamic payment code of the destination merchant the transfer
+	// pays, OrderRef the merchant order it settles. The code is marked paid within the
+	// same transaction.
+	PaymentCodeID string
+	OrderRef      string
+
+	// Fee is charged to the sender on top of Amount, in Currency.
+	Fee uint32
+
+	// ToAmount and ToCurrency are credited to the destination wallet. They equal
+	// Amount and Currency unless the transfer converts with an FX quote.
+	ToAmount   uint32
+	ToCurrency string
+	FXRate     string
+}
+
+// AdjustmentParams credits or debits a wallet outside the regular flows. The
+// adjustment is recorded in Audit within the same transaction.
+type AdjustmentParams struct {
+	Amount    uint32
+	Currency  string
+	Direction PostingDirection
+	Audit     *AuditLog
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/utils"
+)
+
+type PaymentRequestStatus string
+
+const (
+	PAYMENT_REQUEST_PENDING   PaymentRequestStatus = "PENDING"
+	PAYMENT_REQUEST_PAID      PaymentRequestStatus = "PAID"
+	PAYMENT_REQUEST_DECLINED  PaymentRequestStatus = "DECLINED"
+	PAYMENT_REQUEST_CANCELLED PaymentRequestStatus = "CANCELLED"
+	PAYMENT_REQUEST_EXPIRED   PaymentRequestStatus = "EXPIRED"
+)
+
+func (ps PaymentRequestStatus) IsValid() bool {
+	switch ps {
+	case PAYMENT_REQUEST_PENDING, PAYMENT_REQUEST_PAID, PAYMENT_REQUEST_DECLINED,
+		PAYMENT_REQUEST_CANCELLED, PAYMENT_REQUEST_EXPIRED:
+		return true
+	}
+	return false
+}
+
+// PaymentRequest asks Payer to transfer Amount in Currency to Requester. Only a
+// pending request can change status, RefID is the transfer that paid it.
+type PaymentRequest struct {
+	ID          string `gorm:"primaryKey;size:36"`
+	Requester   User
+	RequesterID uint `gorm:"index:idx_requester_id_status,priority:1"`
+	Payer       User
+	PayerID     uint `gorm:"index:idx_payer_id_status,priority:1"`
+	Amount      uint32
+	Currency    string               `gorm:"size:3"`
+	Memo        string               `gorm:"size:140"`
+	Status      PaymentRequestStatus `gorm:"size:16;index:idx_payer_id_status,priority:2;index:idx_requester_id_status,priority:2;index:idx_status_expires_at,priority:1"`
+	ExpiresAt   time.Time            `gorm:"index:idx_status_expires_at,priority:2"`
+	RefID       string               `gorm:"size:36"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func NewPaymentRequest(requester User, payer User, amount uint32, currency string, memo string, ttl time.Duration) PaymentRequest {
+	now := utils.TimeNowUTC()
+	return PaymentRequest{
+		ID:          utils.NewUUIDString(),
+		RequesterID: requester.ID,
+		PayerID:     payer.ID,
+		Amount:      amount,
+		Currency:    currency,
+		Memo:        memo,
+		Status:      PAYMENT_REQUEST_PENDING,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func (pr PaymentRequest) IsExpired() bool {
+	return !utils.TimeNowUTC().Before(pr.ExpiresAt)
+}
+
+type PaymentRequestFilter struct {
+	Status PaymentRequestStatus
+	Cursor *PaymentRequestCursor
+	Limit  int
+}
+
+// PaymentRequestCursor points to the last payment request of a page. Requests are
+// listed by created_at and id descending, so the next page starts right after it.
+type PaymentRequestCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func (pc PaymentRequestCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%s", pc.CreatedAt.UnixNano(), pc.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodePaymentRequestCursor parses a cursor made by Encode, anything else is refused.
+func DecodePaymentRequestCursor(cursor string) (*PaymentRequestCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errs.ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, errs.ErrInvalidCursor
+	}
+	unixNano, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errs.ErrInvalidCursor
+	}
+	pc := &PaymentRequestCursor{CreatedAt: time.Unix(0, unixNano).UTC(), ID: parts[1]}
+	if pc.Encode() != cursor {
+		return nil, errs.ErrInvalidCursor
+	}
+	return pc, nil
+}
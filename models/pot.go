@@ -0,0 +1,108 @@
+package models
+
+import (
+	"math/bits"
+	"sort"
+	"time"
+)
+
+type PotStatus string
+
+const (
+	POT_OPEN      PotStatus = "OPEN"
+	POT_DISBURSED PotStatus = "DISBURSED"
+	POT_CLOSED    PotStatus = "CLOSED"
+)
+
+// Pot collects contributions of its members in its own wallet account, POT:<id> in
+// the ledger. Only an open pot takes contributions. Its owner disburses the balance to
+// their wallet, the pot is DISBURSED once it is empty, or closes it, refunding the
+// balance to the contributors.
+type Pot struct {
+	ID           uint
+	Owner        User
+	OwnerID      uint   `gorm:"index:idx_owner_id"`
+	Name         string `gorm:"size:64"`
+	Currency     string `gorm:"size:3"`
+	TargetAmount uint32
+	Balance      int64
+	Status       PotStatus `gorm:"size:16"`
+	Members      []PotMember
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// PotMember keeps the running totals of a member, so a pot is shared out without
+// summing its contributions.
+type PotMember struct {
+	ID          uint
+	PotID       uint `gorm:"index:idx_pot_id_user_id,unique,priority:1"`
+	User        User
+	UserID      uint `gorm:"index:idx_pot_id_user_id,unique,priority:2;index:idx_user_id"`
+	Contributed uint64
+	Refunded    uint64
+	CreatedAt   time.Time
+}
+
+type PotContribution struct {
+	ID        uint
+	PotID     uint `gorm:"index:idx_pot_id"`
+	User      User
+	UserID    uint
+	RefID     string `gorm:"size:36"`
+	Amount    uint32
+	CreatedAt time.Time
+}
+
+// PotRefund is the share of the balance of a closed pot given back to a member.
+type PotRefund struct {
+	UserID uint
+	RefID  string
+	Amount uint64
+}
+
+// IsMember reports whether userID belongs to the pot. Members must be loaded.
+func (p Pot) IsMember(userID uint) bool {
+	for _, v := range p.Members {
+		if v.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ProportionalShares splits amount between weights in proportion to them. The
+// units lost rounding down go one by one to the largest remainders, ties to the
+// earliest weight, so the shares always add up to amount. amount must not exceed
+// the sum of weights.
+func ProportionalShares(amount uint64, weights []uint64) []uint64 {
+	shares := make([]uint64, len(weights))
+	var total uint64
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 || amount == 0 {
+		return shares
+	}
+
+	remainders := make([]uint64, len(weights))
+	var given uint64
+	for i, w := range weights {
+		hi, lo := bits.Mul64(amount, w)
+		shares[i], remainders[i] = bits.Div64(hi, lo, total)
+		given += shares[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; given < amount; i++ {
+		shares[order[i]]++
+		given++
+	}
+	return shares
+}
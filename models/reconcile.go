@@ -0,0 +1,108 @@
+package models
+
+const (
+	BalanceField       = "balance"
+	HeldBalanceField   = "held_balance"
+	TotalOutgoingField = "total_outgoing"
+)
+
+// MutationTotal is the sum of mutation values of one wallet per type and status.
+type MutationTotal struct {
+	UserID       uint
+	Currency     string
+	MutationType MutationType
+	Status       MutationStatus
+	Value        int64
+}
+
+// WalletReconciliation holds the stored counters of a wallet next to the values
+// recomputed from its mutations.
+type WalletReconciliation struct {
+	WalletID              uint
+	UserID                uint
+	Username              string
+	Currency              string
+	Balance               int64
+	HeldBalance           int64
+	TotalOutgoing         int64
+	ExpectedBalance       int64
+	ExpectedHeldBalance   int64
+	ExpectedTotalOutgoing int64
+}
+
+func NewWalletReconciliation(wallet Wallet, username string, totalOutgoing uint64, totals []MutationTotal) WalletReconciliation {
+	wr := WalletReconciliation{
+		WalletID:      wallet.ID,
+		UserID:        wallet.UserID,
+		Username:      username,
+		Currency:      wallet.Currency,
+		Balance:       wallet.Balance,
+		HeldBalance:   wallet.HeldBalance,
+		TotalOutgoing: int64(totalOutgoing),
+	}
+	for _, v := range totals {
+		if v.UserID == wallet.UserID && v.Currency == wallet.Currency {
+			wr.Add(v)
+		}
+	}
+	return wr
+}
+
+// Add applies a mutation total the way UserBalanceRepoWrite applies its mutations.
+// Pending withdrawals are only held, failed ones never moved money.
+func (wr *WalletReconciliation) Add(total MutationTotal) {
+	switch {
+	case total.MutationType == WITHDRAWAL && total.Status == PENDING:
+		wr.ExpectedHeldBalance += total.Value
+	case total.MutationType == WITHDRAWAL && total.Status == FAILED:
+	case total.MutationType.IsDebit():
+		wr.ExpectedBalance -= total.Value
+	default:
+		wr.ExpectedBalance += total.Value
+	}
+
+	switch total.MutationType {
+	case OUTGOING:
+		wr.ExpectedTotalOutgoing += total.Value
+	case REVERSAL_IN:
+		wr.ExpectedTotalOutgoing -= total.Value
+	}
+}
+
+func (wr WalletReconciliation) Drifts() (drifts []ReconcileDrift) {
+	fields := []struct {
+		name     string
+		stored   int64
+		expected int64
+	}{
+		{BalanceField, wr.Balance, wr.ExpectedBalance},
+		{HeldBalanceField, wr.HeldBalance, wr.ExpectedHeldBalance},
+		{TotalOutgoingField, wr.TotalOutgoing, wr.ExpectedTotalOutgoing},
+	}
+	for _, v := range fields {
+		if v.stored == v.expected {
+			continue
+		}
+		drifts = append(drifts, ReconcileDrift{
+			UserID:     wr.UserID,
+			Username:   wr.Username,
+			Currency:   wr.Currency,
+			Field:      v.name,
+			Stored:     v.stored,
+			Expected:   v.expected,
+			Difference: v.stored - v.expected,
+		})
+	}
+	return
+}
+
+type ReconcileDrift struct {
+	UserID     uint   `json:"user_id"`
+	Username   string `json:"username"`
+	Currency   string `json:"currency"`
+	Field      string `json:"field"`
+	Stored     int64  `json:"stored"`
+	Expected   int64  `json:"expected"`
+	Difference int64  `json:"difference"`
+	Fixed      bool   `json:"fixed"`
+}
@@ -1,14 +1,249 @@
 package models
 
+import "time"
+
 type CreateUserRequest struct {
 	Username string `json:"username" valid:"required"`
+	Password string `json:"password" valid:"required,length(8|72)~Invalid password"`
+	Pin      string `json:"pin,omitempty" valid:"optional,numeric~Invalid PIN,length(6|6)~Invalid PIN"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username" valid:"required"`
+	Password string `json:"password" valid:"required"`
+}
+
+// UpdateCredentialsRequest sets or changes the password and/or the PIN. CurrentPassword
+// is required once the user has a password.
+type UpdateCredentialsRequest struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	Password        string `json:"password,omitempty" valid:"optional,length(8|72)~Invalid password"`
+	Pin             string `json:"pin,omitempty" valid:"optional,numeric~Invalid PIN,length(6|6)~Invalid PIN"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" valid:"required"`
 }
 
+// TopupBalanceRequest and TransferRequest may carry a memo, tags and an external
+// reference unique among the mutations of the user, see MutationNote.
 type TopupBalanceRequest struct {
-	Amount uint32 `json:"amount" valid:"required,range(0|9999999)~Invalid topup amount"`
+	Amount      uint32   `json:"amount" valid:"required,range(0|9999999)~Invalid topup amount"`
+	Currency    string   `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	Memo        string   `json:"memo,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	ExternalRef string   `json:"external_ref,omitempty"`
 }
 
 type TransferRequest struct {
-	ToUsername string `json:"to_username"`
-	Amount     uint32 `json:"amount" valid:"required~Invalid topup amount"`
+	ToUsername  string   `json:"to_username"`
+	Amount      uint32   `json:"amount" valid:"required~Invalid topup amount"`
+	Currency    string   `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	QuoteID     string   `json:"quote_id,omitempty"`
+	Memo        string   `json:"memo,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	ExternalRef string   `json:"external_ref,omitempty"`
+	// Pin is required when the sender has set a transaction PIN.
+	Pin string `json:"pin,omitempty"`
+}
+
+type TransferConfirmRequest struct {
+	ChallengeID string `json:"challenge_id" valid:"required"`
+	// Code is a TOTP code or a recovery code.
+	Code string `json:"code" valid:"required"`
+}
+
+type TwoFactorActivateRequest struct {
+	Code string `json:"code" valid:"required,numeric~Invalid code"`
+}
+
+// AdminActionRequest carries the reason every admin write must give.
+type AdminActionRequest struct {
+	Reason string `json:"reason" valid:"required~Reason is required"`
+}
+
+// AdminFreezeRequest freezes debits only with scope "debit", both directions by default.
+type AdminFreezeRequest struct {
+	Scope  string `json:"scope,omitempty" valid:"optional,in(debit|all)~Invalid freeze scope"`
+	Reason string `json:"reason" valid:"required~Reason is required"`
+}
+
+type AdminCloseRequest struct {
+	Payout bool   `json:"payout"`
+	Reason string `json:"reason" valid:"required~Reason is required"`
+}
+
+type AdminAdjustmentRequest struct {
+	Amount    uint32           `json:"amount" valid:"required~Invalid adjustment amount"`
+	Currency  string           `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	Direction PostingDirection `json:"direction" valid:"required,in(CREDIT|DEBIT)~Invalid direction"`
+	Reason    string           `json:"reason" valid:"required~Reason is required"`
+}
+
+type CreateWalletRequest struct {
+	Currency string `json:"currency" valid:"required,ISO4217~Invalid currency"`
+}
+
+type WithdrawRequest struct {
+	Amount   uint32 `json:"amount" valid:"required~Invalid withdrawal amount"`
+	Currency string `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	// Pin is required when the user has set a transaction PIN.
+	Pin string `json:"pin,omitempty"`
+	// Code is a TOTP or recovery code, required when the amount is above the
+	// two-factor threshold.
+	Code string `json:"code,omitempty"`
+}
+
+type WithdrawalCallbackRequest struct {
+	RefID  string         `json:"ref_id" valid:"required"`
+	Status MutationStatus `json:"status" valid:"required,in(SETTLED|FAILED)"`
+}
+
+type FXQuoteRequest struct {
+	FromCurrency string `json:"from_currency" valid:"required,ISO4217~Invalid currency"`
+	ToCurrency   string `json:"to_currency" valid:"required,ISO4217~Invalid currency"`
+	Amount       uint32 `json:"amount,omitempty"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string         `json:"url" valid:"required,requrl~Invalid url"`
+	Events []WebhookEvent `json:"events" valid:"required~Events are required"`
+}
+
+type CreatePaymentRequestRequest struct {
+	PayerUsername string `json:"payer_username" valid:"required~Payer username is required"`
+	Amount        uint32 `json:"amount" valid:"required~Invalid amount"`
+	Currency      string `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	Memo          string `json:"memo,omitempty" valid:"optional,runelength(0|140)~Memo is too long"`
+	// ExpiresAt defaults to the configured time to live of a payment request.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type AcceptPaymentRequestRequest struct {
+	// Pin is required when the payer has set a transaction PIN.
+	Pin string `json:"pin,omitempty"`
+	// Code is a TOTP or recovery code, required when the amount is above the
+	// two-factor threshold.
+	Code string `json:"code,omitempty"`
+}
+
+// CreateTransferScheduleRequest sets either RunAt, for a one-off transfer, or Cron.
+type CreateTransferScheduleRequest struct {
+	ToUsername string     `json:"to_username" valid:"required~Destination username is required"`
+	Amount     uint32     `json:"amount" valid:"required~Invalid amount"`
+	Currency   string     `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	RunAt      *time.Time `json:"run_at,omitempty"`
+	Cron       string     `json:"cron,omitempty"`
+	// Pin is required when the sender has set a transaction PIN.
+	Pin string `json:"pin,omitempty"`
+	// Code is a TOTP or recovery code, required when the amount is above the
+	// two-factor threshold.
+	Code string `json:"code,omitempty"`
+}
+
+// CreatePotRequest lists the usernames of the members besides the owner, who is
+// always a member.
+type CreatePotRequest struct {
+	Name            string   `json:"name" valid:"required~Name is required,runelength(1|64)~Name is too long"`
+	Currency        string   `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	TargetAmount    uint32   `json:"target_amount,omitempty"`
+	MemberUsernames []string `json:"member_usernames" valid:"required~Members are required"`
+}
+
+type ContributePotRequest struct {
+	Amount uint32 `json:"amount" valid:"required~Invalid amount"`
+	// Pin is required when the contributor has set a transaction PIN.
+	Pin string `json:"pin,omitempty"`
+	// Code is a TOTP or recovery code, required when the amount is above the
+	// two-factor threshold.
+	Code string `json:"code,omitempty"`
+}
+
+// DisbursePotRequest disburses the whole balance of the pot when Amount is not set.
+type DisbursePotRequest struct {
+	Amount uint32 `json:"amount,omitempty"`
+}
+
+type BulkTransferItemRequest struct {
+	ToUsername string `json:"to_username" valid:"required~Destination username is required"`
+	Amount     uint32 `json:"amount" valid:"required~Invalid amount"`
+	Reference  string `json:"reference,omitempty" valid:"optional,runelength(1|64)~Reference is too long"`
+}
+
+// CreateBulkTransferRequest pays Items from the wallet in Currency. Async runs the
+// bulk transfer in the background, its progress is then polled by its ID.
+type CreateBulkTransferRequest struct {
+	Mode     BulkTransferMode          `json:"mode" valid:"required~Mode is required,in(ALL_OR_NOTHING|BEST_EFFORT)~Invalid mode"`
+	Currency string                    `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	Items    []BulkTransferItemRequest `json:"items"`
+	Async    bool                      `json:"async,omitempty"`
+	// Pin is required when the sender has set a transaction PIN.
+	Pin string `json:"pin,omitempty"`
+	// Code is a TOTP or recovery code, required when the total amount is above the
+	// two-factor threshold.
+	Code string `json:"code,omitempty"`
+}
+
+// CreateEscrowRequest holds Amount for PayeeUsername until ReleaseAt, or for
+// ESCROW.DEFAULT_RELEASE_AFTER when it is not set.
+type CreateEscrowRequest struct {
+	PayeeUsername string     `json:"payee_username" valid:"required~Payee username is required"`
+	Amount        uint32     `json:"amount" valid:"required~Invalid amount"`
+	Currency      string     `json:"currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+	Memo          string     `json:"memo,omitempty" valid:"optional,runelength(1|140)~Memo is too long"`
+	ReleaseAt     *time.Time `json:"release_at,omitempty"`
+	// Pin is required when the payer has set a transaction PIN.
+	Pin string `json:"pin,omitempty"`
+	// Code is a TOTP or recovery code, required when the amount is above the
+	// two-factor threshold.
+	Code string `json:"code,omitempty"`
+}
+
+// AdminEscrowResolveRequest settles a disputed escrow, RELEASED paying the payee and
+// REFUNDED paying the payer back.
+type AdminEscrowResolveRequest struct {
+	Outcome EscrowStatus `json:"outcome" valid:"required~Outcome is required,in(RELEASED|REFUNDED)~Invalid outcome"`
+	Reason  string       `json:"reason" valid:"required~Reason is required"`
+}
+
+// MerchantRequest makes the account a merchant shown as DisplayName, paid in
+// SettlementCurrency or WALLET.DEFAULT_CURRENCY when it is not set. The account must
+// hold a wallet in that currency.
+type MerchantRequest struct {
+	DisplayName        string `json:"display_name" valid:"required~Display name is required,runelength(1|64)~Display name is too long"`
+	SettlementCurrency string `json:"settlement_currency,omitempty" valid:"optional,ISO4217~Invalid currency"`
+}
+
+// CreatePaymentCodeRequest asks for a payment QR code. Setting Amount binds the code to
+// it and OrderRef, the code is then paid once and expires at ExpiresAt or after
+// MERCHANT.DYNAMIC_TTL. Without Amount the code is static, the payer sets the amount,
+// and it expires only when ExpiresAt is set. Size is the width of the PNG in pixels.
+type CreatePaymentCodeRequest struct {
+	Amount    uint32     `json:"amount,omitempty"`
+	OrderRef  string     `json:"order_ref,omitempty" valid:"optional,runelength(1|64)~Order reference is too long"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Size      int        `json:"size,omitempty" valid:"optional,range(128|1024)~Invalid size"`
+}
+
+// PayRequest pays the merchant of a payment payload read from its QR code. Amount is
+// required by a static payload, a dynamic one carries its own.
+type PayRequest struct {
+	Payload string `json:"payload" valid:"required~Payload is required"`
+	Amount  uint32 `json:"amount,omitempty"`
+	// Pin is required when the payer has set a transaction PIN.
+	Pin string `json:"pin,omitempty"`
+	// Code is a TOTP or recovery code, required when the amount is above the
+	// two-factor threshold.
+	Code string `json:"code,omitempty"`
+}
+
+// UpdateTransferScheduleRequest changes the fields it sets. Status pauses or resumes
+// the schedule.
+type UpdateTransferScheduleRequest struct {
+	Amount uint32         `json:"amount,omitempty"`
+	RunAt  *time.Time     `json:"run_at,omitempty"`
+	Cron   string         `json:"cron,omitempty"`
+	Status ScheduleStatus `json:"status,omitempty" valid:"optional,in(ACTIVE|PAUSED)~Invalid status"`
+	Pin    string         `json:"pin,omitempty"`
+	Code   string         `json:"code,omitempty"`
 }
@@ -1,8 +1,18 @@
 package models
 
+import "time"
+
 type CreateUserResponse struct {
-	Token       string      `json:"token"`
-	UserDetails UserDetails `json:"user_details"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    int64       `json:"expires_in"`
+	UserDetails  UserDetails `json:"user_details"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
 }
 
 type UserDetails struct {
@@ -12,15 +22,510 @@ type UserDetails struct {
 }
 
 type ReadBalanceResponse struct {
-	Balance int64 `json:"balance"`
+	Currency         string `json:"currency"`
+	Balance          int64  `json:"balance"`
+	HeldBalance      int64  `json:"held_balance"`
+	AvailableBalance int64  `json:"available_balance"`
 }
 
 type TopTransactionsPerUser struct {
-	Username string `json:"username"`
-	Amount   int64  `json:"amount"`
+	Username    string   `json:"username"`
+	Currency    string   `json:"currency"`
+	Amount      int64    `json:"amount"`
+	Memo        string   `json:"memo,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	ExternalRef string   `json:"external_ref,omitempty"`
 }
 
 type TopUserResponse struct {
 	Username        string `json:"username"`
+	Currency        string `json:"currency"`
 	TransactedValue uint64 `json:"transacted_value"`
 }
+
+type WalletResponse struct {
+	Currency         string `json:"currency"`
+	Balance          int64  `json:"balance"`
+	HeldBalance      int64  `json:"held_balance"`
+	AvailableBalance int64  `json:"available_balance"`
+}
+
+type MutationHistoryItem struct {
+	RefID        string         `json:"ref_id"`
+	MutationType MutationType   `json:"type"`
+	Status       MutationStatus `json:"status"`
+	Currency     string         `json:"currency"`
+	Amount       uint32         `json:"amount"`
+	Counterparty string         `json:"counterparty,omitempty"`
+	OrderRef     string         `json:"order_ref,omitempty"`
+	Memo         string         `json:"memo,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+	ExternalRef  string         `json:"external_ref,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+type MutationHistoryResponse struct {
+	Data       []MutationHistoryItem `json:"data"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+type WithdrawResponse struct {
+	RefID    string         `json:"ref_id"`
+	Currency string         `json:"currency"`
+	Amount   uint32         `json:"amount"`
+	Status   MutationStatus `json:"status"`
+}
+
+type TransferResponse struct {
+	RefID      string `json:"ref_id"`
+	Currency   string `json:"currency"`
+	Amount     uint32 `json:"amount"`
+	Fee        uint32 `json:"fee"`
+	Total      uint64 `json:"total"`
+	ToCurrency string `json:"to_currency"`
+	ToAmount   uint32 `json:"to_amount"`
+}
+
+func NewTransferResponse(outgoing Mutation, incoming Mutation, fee uint32) TransferResponse {
+	return TransferResponse{
+		RefID:      outgoing.RefID,
+		Currency:   outgoing.Currency,
+		Amount:     outgoing.Value,
+		Fee:        fee,
+		Total:      uint64(outgoing.Value) + uint64(fee),
+		ToCurrency: incoming.Currency,
+		ToAmount:   incoming.Value,
+	}
+}
+
+type LimitsResponse struct {
+	Tier     string           `json:"tier"`
+	Currency string           `json:"currency"`
+	Limits   []RemainingLimit `json:"limits"`
+}
+
+type RemainingLimit struct {
+	Type      LimitType   `json:"type"`
+	Period    LimitPeriod `json:"period"`
+	Limit     uint64      `json:"limit"`
+	Used      uint64      `json:"used"`
+	Remaining uint64      `json:"remaining"`
+	ResetsAt  time.Time   `json:"resets_at"`
+}
+
+type FXQuoteResponse struct {
+	QuoteID         string    `json:"quote_id"`
+	FromCurrency    string    `json:"from_currency"`
+	ToCurrency      string    `json:"to_currency"`
+	Rate            string    `json:"rate"`
+	Amount          uint32    `json:"amount,omitempty"`
+	ConvertedAmount uint32    `json:"converted_amount,omitempty"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TransferChallengeResponse struct {
+	ChallengeID string    `json:"challenge_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type AdminUserResponse struct {
+	ID       uint             `json:"id"`
+	Username string           `json:"username"`
+	Tier     string           `json:"tier"`
+	Role     Role             `json:"role,omitempty"`
+	Status   AccountStatus    `json:"status"`
+	Wallets  []WalletResponse `json:"wallets"`
+}
+
+type AdjustmentResponse struct {
+	RefID        string       `json:"ref_id"`
+	MutationType MutationType `json:"mutation_type"`
+	Currency     string       `json:"currency"`
+	Amount       uint32       `json:"amount"`
+}
+
+type ReversalResponse struct {
+	RefID         string `json:"ref_id"`
+	ReversalRefID string `json:"reversal_ref_id"`
+}
+
+type AccountClosureResponse struct {
+	Status  AccountStatus      `json:"status"`
+	Payouts []WithdrawResponse `json:"payouts"`
+}
+
+type WebhookResponse struct {
+	ID     uint           `json:"id"`
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+	// Secret signs the deliveries, it is only returned when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewWebhookResponse(webhook Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    webhook.EventList(),
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+type WebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             string                `json:"id"`
+	WebhookID      uint                  `json:"webhook_id"`
+	Event          WebhookEvent          `json:"event"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+}
+
+func NewWebhookDeliveryResponse(delivery WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:             delivery.DeliveryID,
+		WebhookID:      delivery.WebhookID,
+		Event:          delivery.Event,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+	if delivery.Status == DELIVERY_PENDING {
+		nextAttemptAt := delivery.NextAttemptAt
+		resp.NextAttemptAt = &nextAttemptAt
+	}
+	return resp
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+type PaymentRequestResponse struct {
+	ID        string               `json:"id"`
+	Requester string               `json:"requester"`
+	Payer     string               `json:"payer"`
+	Amount    uint32               `json:"amount"`
+	Currency  string               `json:"currency"`
+	Memo      string               `json:"memo,omitempty"`
+	Status    PaymentRequestStatus `json:"status"`
+	ExpiresAt time.Time            `json:"expires_at"`
+	RefID     string               `json:"ref_id,omitempty"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+func NewPaymentRequestResponse(paymentRequest PaymentRequest) PaymentRequestResponse {
+	return PaymentRequestResponse{
+		ID:        paymentRequest.ID,
+		Requester: paymentRequest.Requester.Username,
+		Payer:     paymentRequest.Payer.Username,
+		Amount:    paymentRequest.Amount,
+		Currency:  paymentRequest.Currency,
+		Memo:      paymentRequest.Memo,
+		Status:    paymentRequest.Status,
+		ExpiresAt: paymentRequest.ExpiresAt,
+		RefID:     paymentRequest.RefID,
+		CreatedAt: paymentRequest.CreatedAt,
+	}
+}
+
+type PaymentRequestsResponse struct {
+	PaymentRequests []PaymentRequestResponse `json:"payment_requests"`
+	NextCursor      string                   `json:"next_cursor,omitempty"`
+}
+
+type TransferScheduleResponse struct {
+	ID         uint           `json:"id"`
+	ToUsername string         `json:"to_username"`
+	Amount     uint32         `json:"amount"`
+	Currency   string         `json:"currency"`
+	RunAt      *time.Time     `json:"run_at,omitempty"`
+	Cron       string         `json:"cron,omitempty"`
+	Status     ScheduleStatus `json:"status"`
+	NextRunAt  *time.Time     `json:"next_run_at,omitempty"`
+	// RetryAt is set while a failed run waits for its retry.
+	RetryAt   *time.Time                    `json:"retry_at,omitempty"`
+	Attempts  int                           `json:"attempts"`
+	LastRunAt *time.Time                    `json:"last_run_at,omitempty"`
+	LastRefID string                        `json:"last_ref_id,omitempty"`
+	LastError string                        `json:"last_error,omitempty"`
+	CreatedAt time.Time                     `json:"created_at"`
+	Runs      []TransferScheduleRunResponse `json:"runs,omitempty"`
+}
+
+func NewTransferScheduleResponse(schedule TransferSchedule) TransferScheduleResponse {
+	resp := TransferScheduleResponse{
+		ID:         schedule.ID,
+		ToUsername: schedule.ToUsername,
+		Amount:     schedule.Amount,
+		Currency:   schedule.Currency,
+		RunAt:      schedule.RunAt,
+		Cron:       schedule.Cron,
+		Status:     schedule.Status,
+		Attempts:   schedule.Attempts,
+		LastRunAt:  schedule.LastRunAt,
+		LastRefID:  schedule.LastRefID,
+		LastError:  schedule.LastError,
+		CreatedAt:  schedule.CreatedAt,
+	}
+	if schedule.Status == SCHEDULE_ACTIVE {
+		nextRunAt := schedule.NextRunAt
+		resp.NextRunAt = &nextRunAt
+		if schedule.DueAt.After(schedule.NextRunAt) {
+			retryAt := schedule.DueAt
+			resp.RetryAt = &retryAt
+		}
+	}
+	return resp
+}
+
+type TransferScheduleRunResponse struct {
+	OccurrenceAt time.Time `json:"occurrence_at"`
+	Attempt      int       `json:"attempt"`
+	RefID        string    `json:"ref_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func NewTransferScheduleRunResponse(run TransferScheduleRun) TransferScheduleRunResponse {
+	return TransferScheduleRunResponse{
+		OccurrenceAt: run.OccurrenceAt,
+		Attempt:      run.Attempt,
+		RefID:        run.RefID,
+		Error:        run.Error,
+		CreatedAt:    run.CreatedAt,
+	}
+}
+
+type TransferSchedulesResponse struct {
+	Schedules []TransferScheduleResponse `json:"schedules"`
+}
+
+type PotResponse struct {
+	ID           uint                `json:"id"`
+	Name         string              `json:"name"`
+	Owner        string              `json:"owner"`
+	Currency     string              `json:"currency"`
+	TargetAmount uint32              `json:"target_amount,omitempty"`
+	Balance      int64               `json:"balance"`
+	Status       PotStatus           `json:"status"`
+	CreatedAt    time.Time           `json:"created_at"`
+	Members      []PotMemberResponse `json:"members,omitempty"`
+}
+
+func NewPotResponse(pot Pot) PotResponse {
+	resp := PotResponse{
+		ID:           pot.ID,
+		Name:         pot.Name,
+		Owner:        pot.Owner.Username,
+		Currency:     pot.Currency,
+		TargetAmount: pot.TargetAmount,
+		Balance:      pot.Balance,
+		Status:       pot.Status,
+		CreatedAt:    pot.CreatedAt,
+	}
+	for _, v := range pot.Members {
+		resp.Members = append(resp.Members, PotMemberResponse{
+			Username:    v.User.Username,
+			Contributed: v.Contributed,
+			Refunded:    v.Refunded,
+		})
+	}
+	return resp
+}
+
+type PotMemberResponse struct {
+	Username    string `json:"username"`
+	Contributed uint64 `json:"contributed"`
+	Refunded    uint64 `json:"refunded"`
+}
+
+type PotsResponse struct {
+	Pots []PotResponse `json:"pots"`
+}
+
+type PotContributionResponse struct {
+	Username  string    `json:"username"`
+	RefID     string    `json:"ref_id"`
+	Amount    uint32    `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewPotContributionResponse(contribution PotContribution) PotContributionResponse {
+	return PotContributionResponse{
+		Username:  contribution.User.Username,
+		RefID:     contribution.RefID,
+		Amount:    contribution.Amount,
+		CreatedAt: contribution.CreatedAt,
+	}
+}
+
+type PotContributionsResponse struct {
+	Contributions []PotContributionResponse `json:"contributions"`
+}
+
+type PotDisbursementResponse struct {
+	RefID   string    `json:"ref_id"`
+	Amount  uint64    `json:"amount"`
+	Balance int64     `json:"balance"`
+	Status  PotStatus `json:"status"`
+}
+
+type PotRefundResponse struct {
+	Username string `json:"username"`
+	RefID    string `json:"ref_id"`
+	Amount   uint64 `json:"amount"`
+}
+
+type PotClosedResponse struct {
+	Refunds []PotRefundResponse `json:"refunds"`
+}
+
+type BulkTransferResponse struct {
+	ID             string                     `json:"id"`
+	Mode           BulkTransferMode           `json:"mode"`
+	Currency       string                     `json:"currency"`
+	Status         BulkTransferStatus         `json:"status"`
+	TotalItems     int                        `json:"total_items"`
+	ProcessedItems int                        `json:"processed_items"`
+	SucceededItems int                        `json:"succeeded_items"`
+	FailedItems    int                        `json:"failed_items"`
+	TotalAmount    uint64                     `json:"total_amount"`
+	Error          string                     `json:"error,omitempty"`
+	Items          []BulkTransferItemResponse `json:"items"`
+	CreatedAt      time.Time                  `json:"created_at"`
+	UpdatedAt      time.Time                  `json:"updated_at"`
+}
+
+func NewBulkTransferResponse(bulkTransfer BulkTransfer) BulkTransferResponse {
+	resp := BulkTransferResponse{
+		ID:             bulkTransfer.ID,
+		Mode:           bulkTransfer.Mode,
+		Currency:       bulkTransfer.Currency,
+		Status:         bulkTransfer.Status,
+		TotalItems:     bulkTransfer.TotalItems,
+		ProcessedItems: bulkTransfer.SucceededItems + bulkTransfer.FailedItems,
+		SucceededItems: bulkTransfer.SucceededItems,
+		FailedItems:    bulkTransfer.FailedItems,
+		TotalAmount:    bulkTransfer.TotalAmount,
+		Error:          bulkTransfer.Error,
+		Items:          []BulkTransferItemResponse{},
+		CreatedAt:      bulkTransfer.CreatedAt,
+		UpdatedAt:      bulkTransfer.UpdatedAt,
+	}
+	for _, v := range bulkTransfer.Items {
+		resp.Items = append(resp.Items, BulkTransferItemResponse{
+			Line:       v.Line,
+			ToUsername: v.ToUsername,
+			Amount:     v.Amount,
+			Reference:  v.Reference,
+			Status:     v.Status,
+			RefID:      v.RefID,
+			Fee:        v.Fee,
+			Error:      v.Error,
+		})
+	}
+	return resp
+}
+
+type BulkTransferItemResponse struct {
+	Line       int                    `json:"line"`
+	ToUsername string                 `json:"to_username"`
+	Amount     uint32                 `json:"amount"`
+	Reference  string                 `json:"reference,omitempty"`
+	Status     BulkTransferItemStatus `json:"status"`
+	RefID      string                 `json:"ref_id,omitempty"`
+	Fee        uint32                 `json:"fee"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type EscrowResponse struct {
+	RefID           string       `json:"ref_id"`
+	PayerUsername   string       `json:"payer_username"`
+	PayeeUsername   string       `json:"payee_username"`
+	Currency        string       `json:"currency"`
+	Amount          uint32       `json:"amount"`
+	Memo            string       `json:"memo,omitempty"`
+	Status          EscrowStatus `json:"status"`
+	ReleaseAt       time.Time    `json:"release_at"`
+	DisputedBy      EscrowParty  `json:"disputed_by,omitempty"`
+	SettledBy       EscrowParty  `json:"settled_by,omitempty"`
+	SettlementRefID string       `json:"settlement_ref_id,omitempty"`
+	SettledAt       *time.Time   `json:"settled_at,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+func NewEscrowResponse(escrow Escrow) EscrowResponse {
+	return EscrowResponse{
+		RefID:           escrow.RefID,
+		PayerUsername:   escrow.Payer.Username,
+		PayeeUsername:   escrow.Payee.Username,
+		Currency:        escrow.Currency,
+		Amount:          escrow.Amount,
+		Memo:            escrow.Memo,
+		Status:          escrow.Status,
+		ReleaseAt:       escrow.ReleaseAt,
+		DisputedBy:      escrow.DisputedBy,
+		SettledBy:       escrow.SettledBy,
+		SettlementRefID: escrow.SettlementRefID,
+		SettledAt:       escrow.SettledAt,
+		CreatedAt:       escrow.CreatedAt,
+	}
+}
+
+type EscrowsResponse struct {
+	Escrows []EscrowResponse `json:"escrows"`
+}
+
+type MerchantResponse struct {
+	Username           string `json:"username"`
+	DisplayName        string `json:"display_name"`
+	SettlementCurrency string `json:"settlement_currency"`
+}
+
+func NewMerchantResponse(merchant User) MerchantResponse {
+	return MerchantResponse{
+		Username:           merchant.Username,
+		DisplayName:        merchant.DisplayName,
+		SettlementCurrency: merchant.SettlementCurrency,
+	}
+}
+
+type PaymentCodeResponse struct {
+	Payload   string     `json:"payload"`
+	Amount    uint32     `json:"amount,omitempty"`
+	Currency  string     `json:"currency"`
+	OrderRef  string     `json:"order_ref,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type PayResponse struct {
+	RefID       string `json:"ref_id"`
+	Merchant    string `json:"merchant"`
+	DisplayName string `json:"display_name"`
+	OrderRef    string `json:"order_ref,omitempty"`
+	Currency    string `json:"currency"`
+	Amount      uint32 `json:"amount"`
+	Fee         uint32 `json:"fee"`
+	Total       uint64 `json:"total"`
+}
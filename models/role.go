@@ -0,0 +1,23 @@
+package models
+
+// Role grants access to the admin API. Users without a role are end users only.
+type Role string
+
+const (
+	// VIEWER can look up users, their balances and mutations.
+	VIEWER Role = "viewer"
+	// SUPPORT can also freeze, unfreeze and close accounts.
+	SUPPORT Role = "support"
+	// FINANCE can also adjust balances and reverse transfers.
+	FINANCE Role = "finance"
+	// ARBITRATOR settles disputed escrows, releasing or refunding them.
+	ARBITRATOR Role = "arbitrator"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case VIEWER, SUPPORT, FINANCE, ARBITRATOR:
+		return true
+	}
+	return false
+}
@@ -1,10 +1,83 @@
 package models
 
-import "github.com/golang-jwt/jwt"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+	"github.com/golang-jwt/jwt"
+)
 
 type JwtClaims struct {
 	ID       string `json:"id"`
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	// SessionID is shared by all tokens issued from the same login, so a whole
+	// session can be revoked at once.
+	SessionID string `json:"sid,omitempty"`
+	Role      Role   `json:"role,omitempty"`
 	jwt.StandardClaims
 }
+
+// RefreshToken is stored hashed. Each refresh marks the token as used and issues
+// a new one within the same session, so presenting a used token means it leaked.
+type RefreshToken struct {
+	ID        uint
+	User      User
+	UserID    uint   `gorm:"index:idx_user_id"`
+	TokenHash string `gorm:"index:idx_token_hash,unique;size:64"`
+	SessionID string `gorm:"index:idx_session_id;size:36"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// NewRefreshToken returns a random refresh token and its record for user.
+func NewRefreshToken(user User, sessionID string, ttl time.Duration) (token string, refreshToken RefreshToken, err error) {
+	bToken := make([]byte, 32)
+	_, err = rand.Read(bToken)
+	if err != nil {
+		return
+	}
+	token = base64.RawURLEncoding.EncodeToString(bToken)
+
+	now := utils.TimeNowUTC()
+	refreshToken = RefreshToken{
+		UserID:    user.ID,
+		TokenHash: HashRefreshToken(token),
+		SessionID: sessionID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	return
+}
+
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (rt RefreshToken) IsExpired() bool {
+	return !utils.TimeNowUTC().Before(rt.ExpiresAt)
+}
+
+// JWKSet is the JSON Web Key Set of the public keys tokens are signed with.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
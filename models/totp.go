@@ -0,0 +1,97 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+)
+
+// TOTP codes follow RFC 6238 with the defaults authenticator apps expect:
+// HMAC-SHA1, 6 digits and a 30 seconds period.
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30
+
+	// totpSkew is the number of periods a code is still accepted before or after
+	// the current one, to tolerate clock drift of the device.
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random base32 secret of 160 bits.
+func NewTOTPSecret() (string, error) {
+	bSecret := make([]byte, 20)
+	_, err := rand.Read(bSecret)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(bSecret), nil
+}
+
+// TOTPURI returns the otpauth URI authenticator apps enroll a secret with, usually shown as a QR code.
+func TOTPURI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprintf("%v", TOTPDigits))
+	query.Set("period", fmt.Sprintf("%v", TOTPPeriod))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPStep returns the time step of t.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / TOTPPeriod
+}
+
+// TOTPCode returns the code of secret for a time step.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000), nil
+}
+
+// VerifyTOTP checks code against secret at the current time. A code of a step not
+// after lastUsedStep is refused, so a code can only be used once. It returns the
+// step of the matching code.
+func VerifyTOTP(secret string, code string, lastUsedStep int64) (step int64, ok bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	now := TOTPStep(utils.TimeNowUTC())
+	for s := now - totpSkew; s <= now+totpSkew; s++ {
+		if s <= lastUsedStep {
+			continue
+		}
+		expected, err := TOTPCode(secret, s)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890"
+// in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// setClock makes utils.TimeNowUTC return now until the test ends.
+func setClock(t *testing.T, now time.Time) {
+	timeNowUTC := utils.TimeNowUTC
+	utils.TimeNowUTC = func() time.Time { return now }
+	t.Cleanup(func() { utils.TimeNowUTC = timeNowUTC })
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// the 8 digit codes of the RFC truncated to the last 6 digits
+	var tests = []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode at %v got %v", v.unix, err)
+		}
+		if got != v.want {
+			t.Errorf("TOTPCode at %v got %v, want %v", v.unix, got, v.want)
+		}
+	}
+
+	got, err := TOTPCode(strings.ToLower(rfc6238Secret), TOTPStep(time.Unix(59, 0)))
+	if err != nil || got != "287082" {
+		t.Errorf("TOTPCode of a lowercase secret got %v %v, want 287082", got, err)
+	}
+
+	_, err = TOTPCode("not base32!", 1)
+	if err == nil {
+		t.Errorf("TOTPCode of an invalid secret got no error")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0).UTC()
+	setClock(t, now)
+	step := TOTPStep(now)
+
+	var tests = []struct {
+		name         string
+		code         string
+		lastUsedStep int64
+		wantStep     int64
+		wantOK       bool
+	}{
+		{"current step", "050471", 0, step, true},
+		{"previous step within skew", codeAt(t, step-1), 0, step - 1, true},
+		{"next step within skew", codeAt(t, step+1), 0, step + 1, true},
+		{"two steps late", codeAt(t, step-2), 0, 0, false},
+		{"two steps early", codeAt(t, step+2), 0, 0, false},
+		{"step already used", "050471", step, 0, false},
+		{"later step than the last used", codeAt(t, step+1), step, step + 1, true},
+		{"wrong code", "000000", 0, 0, false},
+		{"too short", "50471", 0, 0, false},
+		{"too long", "0504710", 0, 0, false},
+	}
+	for _, v := range tests {
+		gotStep, gotOK := VerifyTOTP(rfc6238Secret, v.code, v.lastUsedStep)
+		if gotOK != v.wantOK || gotStep != v.wantStep {
+			t.Errorf("%v: got %v %v, want %v %v", v.name, gotStep, gotOK, v.wantStep, v.wantOK)
+		}
+	}
+}
+
+func TestVerifyTOTPFollowsClock(t *testing.T) {
+	code := codeAt(t, TOTPStep(time.Unix(59, 0)))
+
+	setClock(t, time.Unix(59, 0))
+	if _, ok := VerifyTOTP(rfc6238Secret, code, 0); !ok {
+		t.Errorf("code refused at its own time")
+	}
+
+	setClock(t, time.Unix(59+2*TOTPPeriod, 0))
+	if _, ok := VerifyTOTP(rfc6238Secret, code, 0); ok {
+		t.Errorf("code accepted two periods later")
+	}
+}
+
+func codeAt(t *testing.T, step int64) string {
+	code, err := TOTPCode(rfc6238Secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
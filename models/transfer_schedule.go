@@ -0,0 +1,102 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+)
+
+type ScheduleStatus string
+
+const (
+	SCHEDULE_ACTIVE ScheduleStatus = "ACTIVE"
+	SCHEDULE_PAUSED ScheduleStatus = "PAUSED"
+	// SCHEDULE_COMPLETED one-off schedules made their transfer.
+	SCHEDULE_COMPLETED ScheduleStatus = "COMPLETED"
+	// SCHEDULE_FAILED schedules stopped on an error retrying can not fix.
+	SCHEDULE_FAILED ScheduleStatus = "FAILED"
+)
+
+// TransferSchedule transfers Amount in Currency from its user to ToUsername once at
+// RunAt, or at every match of Cron. NextRunAt is the occurrence to run next, DueAt
+// when it is attempted, later than NextRunAt while a failed attempt waits for its
+// retry. Attempts counts the failed attempts of that occurrence.
+type TransferSchedule struct {
+	ID         uint
+	User       User
+	UserID     uint   `gorm:"index:idx_user_id"`
+	ToUsername string `gorm:"size:191"`
+	Amount     uint32
+	Currency   string `gorm:"size:3"`
+	RunAt      *time.Time
+	Cron       string         `gorm:"size:64"`
+	Status     ScheduleStatus `gorm:"size:16;index:idx_status_due_at,priority:1"`
+	NextRunAt  time.Time
+	DueAt      time.Time `gorm:"index:idx_status_due_at,priority:2"`
+	Attempts   int
+	LastRunAt  *time.Time
+	LastRefID  string `gorm:"size:36"`
+	LastError  string `gorm:"type:text"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (ts TransferSchedule) IsRecurring() bool {
+	return ts.Cron != ""
+}
+
+// FirstRunAt is the first occurrence of the schedule after now, the zero time when
+// there is none.
+func (ts TransferSchedule) FirstRunAt(now time.Time) (time.Time, error) {
+	if !ts.IsRecurring() {
+		if ts.RunAt == nil {
+			return time.Time{}, nil
+		}
+		return *ts.RunAt, nil
+	}
+	cron, err := ParseCron(ts.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cron.Next(now), nil
+}
+
+// Schedule sets the occurrence to run next, due right away.
+func (ts *TransferSchedule) Schedule(runAt time.Time) {
+	ts.NextRunAt = runAt
+	ts.DueAt = runAt
+	ts.Attempts = 0
+}
+
+// OccurrenceKey identifies the transfer of the occurrence at NextRunAt, it is used as
+// idempotency key so an occurrence is only transferred once.
+func (ts TransferSchedule) OccurrenceKey() string {
+	return fmt.Sprintf("schedule:%v:%v", ts.ID, ts.NextRunAt.Unix())
+}
+
+// TransferScheduleRun records an attempt of a schedule, RefID is set when the
+// transfer was made and Error when it failed.
+type TransferScheduleRun struct {
+	ID           uint
+	ScheduleID   uint `gorm:"index:idx_schedule_id"`
+	OccurrenceAt time.Time
+	Attempt      int
+	RefID        string `gorm:"size:36"`
+	Error        string `gorm:"type:text"`
+	CreatedAt    time.Time
+}
+
+func NewTransferScheduleRun(schedule TransferSchedule, refID string, cause error) TransferScheduleRun {
+	run := TransferScheduleRun{
+		ScheduleID:   schedule.ID,
+		OccurrenceAt: schedule.NextRunAt,
+		Attempt:      schedule.Attempts + 1,
+		RefID:        refID,
+		CreatedAt:    utils.TimeNowUTC(),
+	}
+	if cause != nil {
+		run.Error = cause.Error()
+	}
+	return run
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+)
+
+// RecoveryCodeCount is the number of recovery codes handed out when two-factor
+// authentication is activated.
+const RecoveryCodeCount = 10
+
+// TwoFactor is the TOTP enrollment of a user. It is pending until a first code
+// is verified.
+type TwoFactor struct {
+	ID     uint
+	User   User
+	UserID uint   `gorm:"index:idx_user_id,unique"`
+	Secret string `gorm:"size:64"`
+	// LastUsedStep is the time step of the last accepted code.
+	LastUsedStep int64
+	EnabledAt    *time.Time
+	CreatedAt    time.Time
+}
+
+func (tf TwoFactor) IsEnabled() bool {
+	return tf.EnabledAt != nil
+}
+
+// RecoveryCode is a single use code accepted instead of a TOTP code. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uint
+	User      User
+	UserID    uint   `gorm:"index:idx_user_id_code_hash,priority:1"`
+	CodeHash  string `gorm:"index:idx_user_id_code_hash,priority:2;size:64"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// NewRecoveryCodes returns n random codes shaped as "xxxxx-xxxxx" and their records for user.
+func NewRecoveryCodes(user User, n int) (codes []string, recoveryCodes []RecoveryCode, err error) {
+	now := utils.TimeNowUTC()
+	for i := 0; i < n; i++ {
+		bCode := make([]byte, 5)
+		_, err = rand.Read(bCode)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(bCode)
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		recoveryCodes = append(recoveryCodes, RecoveryCode{
+			UserID:    user.ID,
+			CodeHash:  HashRecoveryCode(code),
+			CreatedAt: now,
+		})
+	}
+	return
+}
+
+// HashRecoveryCode ignores case and dashes, so codes can be typed loosely.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// TransferChallenge holds a transfer above the two-factor threshold until it is
+// confirmed with a TOTP or recovery code.
+type TransferChallenge struct {
+	ID     string `gorm:"primaryKey;size:36"`
+	User   User
+	UserID uint `gorm:"index:idx_user_id"`
+	// Request is the JSON encoded transfer request, without its PIN.
+	Request        string `gorm:"type:text"`
+	IdempotencyKey string `gorm:"size:191"`
+	Attempts       int
+	ExpiresAt      time.Time
+	ConfirmedAt    *time.Time
+	CreatedAt      time.Time
+}
+
+func NewTransferChallenge(user User, req TransferRequest, idempotencyKey string, ttl time.Duration) (challenge TransferChallenge, err error) {
+	bReq, err := json.Marshal(&req)
+	if err != nil {
+		return
+	}
+	now := utils.TimeNowUTC()
+	challenge = TransferChallenge{
+		ID:             utils.NewUUIDString(),
+		UserID:         user.ID,
+		Request:        string(bReq),
+		IdempotencyKey: idempotencyKey,
+		ExpiresAt:      now.Add(ttl),
+		CreatedAt:      now,
+	}
+	return
+}
+
+func (tc TransferChallenge) IsExpired() bool {
+	return !utils.TimeNowUTC().Before(tc.ExpiresAt)
+}
+
+func (tc TransferChallenge) TransferRequest() (req TransferRequest, err error) {
+	err = json.Unmarshal([]byte(tc.Request), &req)
+	return
+}
@@ -1,20 +1,44 @@
 package models
 
+import "time"
+
 type User struct {
 	ID       uint
 	Username string `gorm:"index:idx_username,unique"`
-	Balance  int64
+	// Tier selects the transaction limits of the user, empty means LIMITS.DEFAULT_TIER.
+	Tier string `gorm:"size:32"`
+	// Role grants access to the admin API, empty for end users.
+	Role Role `gorm:"size:16"`
+	// Status is cached together with the user, so every money movement can check it.
+	Status AccountStatus `gorm:"size:16;default:active"`
+
+	// AccountType tells a shop taking payments from a personal account. A merchant is
+	// shown by DisplayName to its payers and is paid in SettlementCurrency.
+	AccountType        AccountType `gorm:"size:16;default:personal"`
+	DisplayName        string      `gorm:"size:64"`
+	SettlementCurrency string      `gorm:"size:3"`
+
+	// Credentials are never serialized, so they stay out of the user cache and
+	// are always read from the write database.
+	PasswordHash        string     `json:"-" gorm:"size:72"`
+	PinHash             string     `json:"-" gorm:"size:72"`
+	FailedLoginAttempts int        `json:"-"`
+	LoginLockedUntil    *time.Time `json:"-"`
+	FailedPinAttempts   int        `json:"-"`
+	PinLockedUntil      *time.Time `json:"-"`
 }
 
 type UserTotalOutgoing struct {
-	ID     uint
-	User   User
-	UserID uint   `gorm:"index:idx_user_id"`
-	Value  uint64 `gorm:"index:idx_value,sort:desc,type:btree"`
+	ID       uint
+	User     User
+	UserID   uint   `gorm:"index:idx_user_id;index:idx_user_id_currency,unique"`
+	Currency string `gorm:"index:idx_user_id_currency,unique;size:3"`
+	Value    uint64 `gorm:"index:idx_value,sort:desc,type:btree"`
 }
 
 type UserTotalOutgoingResult struct {
 	UserID   uint
 	Username string
+	Currency string
 	Value    uint64
 }
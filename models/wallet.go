@@ -0,0 +1,15 @@
+package models
+
+type Wallet struct {
+	ID       uint
+	User     User
+	UserID   uint   `gorm:"index:idx_user_id_currency,unique"`
+	Currency string `gorm:"index:idx_user_id_currency,unique;size:3"`
+	Balance  int64
+	// HeldBalance is the part of Balance reserved by pending withdrawals.
+	HeldBalance int64
+}
+
+func (w Wallet) AvailableBalance() int64 {
+	return w.Balance - w.HeldBalance
+}
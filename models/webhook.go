@@ -0,0 +1,189 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+// WebhookEvent is an event of an account a webhook can subscribe to.
+type WebhookEvent string
+
+const (
+	WEBHOOK_TOPUP             WebhookEvent = "topup"
+	WEBHOOK_TRANSFER_INCOMING WebhookEvent = "transfer.incoming"
+)
+
+func (we WebhookEvent) IsValid() bool {
+	switch we {
+	case WEBHOOK_TOPUP, WEBHOOK_TRANSFER_INCOMING:
+		return true
+	}
+	return false
+}
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookEventHeader     = "X-Webhook-Event"
+)
+
+// Webhook receives the events of its user at URL, signed with Secret. Events is a
+// comma separated list of WebhookEvent. A deleted webhook is kept for the history
+// of its deliveries.
+type Webhook struct {
+	ID        uint
+	User      User
+	UserID    uint `gorm:"index:idx_user_id"`
+	URL       string
+	Secret    string `json:"-" gorm:"size:64"`
+	Events    string
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func NewWebhook(user User, url string, events []WebhookEvent) (webhook Webhook, err error) {
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return
+	}
+
+	names := []string{}
+	for _, v := range events {
+		names = append(names, string(v))
+	}
+	return Webhook{
+		UserID:    user.ID,
+		URL:       url,
+		Secret:    hex.EncodeToString(secret),
+		Events:    strings.Join(names, ","),
+		CreatedAt: utils.TimeNowUTC(),
+	}, nil
+}
+
+func (w Webhook) EventList() (events []WebhookEvent) {
+	for _, v := range strings.Split(w.Events, ",") {
+		if v != "" {
+			events = append(events, WebhookEvent(v))
+		}
+	}
+	return
+}
+
+func (w Webhook) Subscribes(event WebhookEvent) bool {
+	for _, v := range w.EventList() {
+		if v == event {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DELIVERY_PENDING   WebhookDeliveryStatus = "PENDING"
+	DELIVERY_DELIVERED WebhookDeliveryStatus = "DELIVERED"
+	// DELIVERY_DEAD deliveries ran out of attempts, they are only sent again on replay.
+	DELIVERY_DEAD WebhookDeliveryStatus = "DEAD"
+)
+
+// WebhookDelivery is one event to send to one webhook. Pending deliveries are sent
+// from NextAttemptAt on.
+type WebhookDelivery struct {
+	ID             uint
+	DeliveryID     string `gorm:"size:36;index:idx_delivery_id,unique"`
+	Webhook        Webhook
+	WebhookID      uint `gorm:"index:idx_webhook_id"`
+	UserID         uint `gorm:"index:idx_user_id_status,priority:1"`
+	Event          WebhookEvent
+	Payload        string                `gorm:"type:text"`
+	Status         WebhookDeliveryStatus `gorm:"size:16;index:idx_user_id_status,priority:2;index:idx_status_next_attempt_at,priority:1"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index:idx_status_next_attempt_at,priority:2"`
+	LastStatusCode int
+	LastError      string `gorm:"type:text"`
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+}
+
+// WebhookPayload is the body posted to a webhook.
+type WebhookPayload struct {
+	ID         string          `json:"id"`
+	Event      WebhookEvent    `json:"event"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func NewWebhookDelivery(webhook Webhook, event WebhookEvent, data interface{}) WebhookDelivery {
+	now := utils.TimeNowUTC()
+	bData, _ := json.Marshal(data)
+	deliveryID := utils.NewUUIDString()
+	bPayload, _ := json.Marshal(&WebhookPayload{
+		ID:         deliveryID,
+		Event:      event,
+		OccurredAt: now,
+		Data:       bData,
+	})
+	return WebhookDelivery{
+		DeliveryID:    deliveryID,
+		WebhookID:     webhook.ID,
+		UserID:        webhook.UserID,
+		Event:         event,
+		Payload:       string(bPayload),
+		Status:        DELIVERY_PENDING,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+// SignWebhook returns the signature of body sent at timestamp, as "sha256=" followed
+// by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks signature and refuses a timestamp further than
+// tolerance from now, so a captured request can not be replayed later.
+func VerifyWebhookSignature(secret string, timestamp string, body []byte, signature string, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid webhook timestamp")
+	}
+	if math.Abs(float64(utils.TimeNowUTC().Unix()-ts)) > tolerance.Seconds() {
+		return fmt.Errorf("Webhook timestamp out of tolerance")
+	}
+	if !hmac.Equal([]byte(SignWebhook(secret, ts, body)), []byte(signature)) {
+		return fmt.Errorf("Invalid webhook signature")
+	}
+	return nil
+}
+
+// WebhookBackoff is the wait after a failed attempt: base doubled for every
+// attempt made before, capped at max.
+func WebhookBackoff(attempts int, base time.Duration, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
@@ -0,0 +1,21 @@
+package repos
+
+import (
+	"log"
+
+	"github.com/atrariksa/awallet/models"
+	"gorm.io/gorm"
+)
+
+// createAuditLog records audit within tx, so the audited change and its record
+// commit together. Audit logs are never updated nor deleted.
+func createAuditLog(tx *gorm.DB, audit *models.AuditLog) error {
+	if audit == nil {
+		return nil
+	}
+	err := tx.Debug().Create(audit).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
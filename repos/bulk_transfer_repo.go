@@ -0,0 +1,130 @@
+package repos
+
+import (
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+type BulkTransferRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IBulkTransferRepo interface {
+	CreateBulkTransfer(bulkTransfer *models.BulkTransfer) error
+	GetBulkTransfer(bulkTransfer *models.BulkTransfer) error
+	ClaimBulkTransfers(limit int, staleAfter time.Duration) (bulkTransfers []models.BulkTransfer, err error)
+	SaveProgress(bulkTransfer *models.BulkTransfer, items ...models.BulkTransferItem) error
+}
+
+// CreateBulkTransfer stores a bulk transfer with its items.
+func (br *BulkTransferRepo) CreateBulkTransfer(bulkTransfer *models.BulkTransfer) error {
+	err := br.DBWrite.Debug().Omit("User").Create(bulkTransfer).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// GetBulkTransfer reads a bulk transfer by ID with its user and items.
+func (br *BulkTransferRepo) GetBulkTransfer(bulkTransfer *models.BulkTransfer) error {
+	err := br.DBWrite.Debug().
+		Preload("User").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("line") }).
+		Where("id = ?", bulkTransfer.ID).
+		First(bulkTransfer).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+// ClaimBulkTransfers sets up to limit pending bulk transfers to PROCESSING and returns
+// them with their items. A bulk transfer left PROCESSING with no progress for
+// staleAfter, by a worker or a request that stopped, is claimed again. A bulk
+// transfer another worker claimed first is left out.
+func (br *BulkTransferRepo) ClaimBulkTransfers(limit int, staleAfter time.Duration) (bulkTransfers []models.BulkTransfer, err error) {
+	now := utils.TimeNowUTC()
+
+	candidates := []models.BulkTransfer{}
+	err = br.DBWrite.Debug().
+		Where("status = ? OR (status = ? AND updated_at <= ?)",
+			models.BULK_TRANSFER_PENDING, models.BULK_TRANSFER_PROCESSING, now.Add(-staleAfter)).
+		Order("created_at").
+		Limit(limit).
+		Find(&candidates).Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, v := range candidates {
+		result := br.DBWrite.Debug().Model(&models.BulkTransfer{}).
+			Where("id = ? AND status = ? AND updated_at = ?", v.ID, v.Status, v.UpdatedAt).
+			Updates(map[string]interface{}{
+				"status":     models.BULK_TRANSFER_PROCESSING,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			log.Println(result.Error)
+			return bulkTransfers, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		bulkTransfer := models.BulkTransfer{ID: v.ID}
+		err = br.GetBulkTransfer(&bulkTransfer)
+		if err != nil {
+			return
+		}
+		bulkTransfers = append(bulkTransfers, bulkTransfer)
+	}
+	return
+}
+
+// SaveProgress stores the status and counters of a bulk transfer with the results of
+// items, in one transaction so the counters match the items.
+func (br *BulkTransferRepo) SaveProgress(bulkTransfer *models.BulkTransfer, items ...models.BulkTransferItem) (err error) {
+	tx := br.DBWrite.Begin()
+
+	for _, v := range items {
+		err = tx.Debug().Model(&models.BulkTransferItem{ID: v.ID}).Updates(map[string]interface{}{
+			"status":     v.Status,
+			"ref_id":     v.RefID,
+			"fee":        v.Fee,
+			"error":      v.Error,
+			"updated_at": v.UpdatedAt,
+		}).Error
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+			return
+		}
+	}
+
+	bulkTransfer.UpdatedAt = utils.TimeNowUTC()
+	err = tx.Debug().Model(&models.BulkTransfer{ID: bulkTransfer.ID}).Updates(map[string]interface{}{
+		"status":          bulkTransfer.Status,
+		"succeeded_items": bulkTransfer.SucceededItems,
+		"failed_items":    bulkTransfer.FailedItems,
+		"error":           bulkTransfer.Error,
+		"updated_at":      bulkTransfer.UpdatedAt,
+	}).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+	}
+	return
+}
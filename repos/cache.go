@@ -2,11 +2,19 @@ package repos
 
 import (
 	"sync"
+	"time"
 
 	"github.com/atrariksa/awallet/configs"
 	"github.com/go-redis/redis"
 )
 
+// delIfEqualScript deletes KEYS[1] only while it holds ARGV[1], in one step.
+var delIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
 type Cache struct {
 	cfg *configs.Config
 	rc  *redis.Client
@@ -24,6 +32,10 @@ type ICache interface {
 	Get(key string) (val []byte, err error)
 	Set(key string, val []byte) (err error)
 	Del(key string) (err error)
+	SetNX(key string, val int64, expiration time.Duration) (ok bool, err error)
+	DelIfEqual(key string, val int64) (ok bool, err error)
+	IncrBy(key string, val int64) (result int64, err error)
+	Expire(key string, expiration time.Duration) (ok bool, err error)
 }
 
 func (c *Cache) Get(key string) (val []byte, err error) {
@@ -46,3 +58,32 @@ func (c *Cache) Del(key string) (err error) {
 	c.Unlock()
 	return
 }
+
+func (c *Cache) SetNX(key string, val int64, expiration time.Duration) (ok bool, err error) {
+	c.Lock()
+	ok, err = c.rc.SetNX(key, val, expiration).Result()
+	c.Unlock()
+	return
+}
+
+// DelIfEqual deletes key when it holds val, so a lock is only released by its holder.
+func (c *Cache) DelIfEqual(key string, val int64) (ok bool, err error) {
+	c.Lock()
+	deleted, err := delIfEqualScript.Run(c.rc, []string{key}, val).Int64()
+	c.Unlock()
+	return deleted == 1, err
+}
+
+func (c *Cache) IncrBy(key string, val int64) (result int64, err error) {
+	c.Lock()
+	result, err = c.rc.IncrBy(key, val).Result()
+	c.Unlock()
+	return
+}
+
+func (c *Cache) Expire(key string, expiration time.Duration) (ok bool, err error) {
+	c.Lock()
+	ok, err = c.rc.Expire(key, expiration).Result()
+	c.Unlock()
+	return
+}
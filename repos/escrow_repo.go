@@ -0,0 +1,85 @@
+package repos
+
+import (
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EscrowRepoRead struct {
+	DBRead *gorm.DB
+	Cache  ICache
+}
+
+type IEscrowRepoRead interface {
+	GetEscrow(escrow *models.Escrow) error
+	GetEscrows(userID uint, status models.EscrowStatus, limit int) (escrows []models.Escrow, err error)
+	GetDueEscrows(retryAfter time.Time, limit int) (escrows []models.Escrow, err error)
+}
+
+// GetEscrow reads an escrow by RefID with its payer and payee.
+func (er *EscrowRepoRead) GetEscrow(escrow *models.Escrow) error {
+	err := er.DBRead.Debug().
+		Preload("Payer").
+		Preload("Payee").
+		Where("ref_id = ?", escrow.RefID).
+		First(escrow).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+// GetEscrows returns the latest escrows userID pays or is paid by, only those in
+// status when it is set.
+func (er *EscrowRepoRead) GetEscrows(userID uint, status models.EscrowStatus, limit int) (escrows []models.Escrow, err error) {
+	query := er.DBRead.Debug().
+		Preload("Payer").
+		Preload("Payee").
+		Where("payer_id = ? OR payee_id = ?", userID, userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err = query.Order("created_at DESC").Limit(limit).Find(&escrows).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// GetDueEscrows returns held escrows past their release time with their payer and
+// payee, oldest first. An escrow whose release failed after retryAfter is left out.
+func (er *EscrowRepoRead) GetDueEscrows(retryAfter time.Time, limit int) (escrows []models.Escrow, err error) {
+	err = er.DBRead.Debug().
+		Preload("Payer").
+		Preload("Payee").
+		Where("status = ? AND release_at <= ?", models.ESCROW_HELD, utils.TimeNowUTC()).
+		Where("release_attempted_at IS NULL OR release_attempted_at <= ?", retryAfter).
+		Order("release_at").
+		Limit(limit).
+		Find(&escrows).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// lockEscrow locks an escrow within tx.
+func lockEscrow(tx *gorm.DB, refID string) (escrow models.Escrow, err error) {
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ref_id = ?", refID).
+		First(&escrow).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return escrow, errs.ErrEscrowNotFound
+		}
+		log.Println(err)
+	}
+	return
+}
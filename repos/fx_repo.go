@@ -0,0 +1,101 @@
+package repos
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"github.com/go-redis/redis"
+	"gorm.io/gorm"
+)
+
+const (
+	FXQuotePrefix = "fx_quote_%v"
+)
+
+type FXRepoRead struct {
+	DBRead *gorm.DB
+	Cache  ICache
+}
+
+type IFXRepoRead interface {
+	GetQuote(quote *models.FXQuote) error
+}
+
+func (fr *FXRepoRead) GetQuote(quote *models.FXQuote) error {
+	key := fmt.Sprintf(FXQuotePrefix, quote.ID)
+	bQuote, err := fr.Cache.Get(key)
+	if err == redis.Nil {
+		err = fr.DBRead.Debug().Where(&models.FXQuote{ID: quote.ID}).First(quote).Error
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+
+		bQuote, err = json.Marshal(&quote)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+
+		fr.Cache.Set(key, bQuote)
+		return nil
+	}
+
+	if bQuote != nil {
+		err = json.Unmarshal(bQuote, &quote)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ */
+
+type FXRepoWrite struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IFXRepoWrite interface {
+	CreateQuote(quote *models.FXQuote) error
+}
+
+func (fr *FXRepoWrite) CreateQuote(quote *models.FXQuote) error {
+	err := fr.DBWrite.Debug().Create(quote).Error
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	bQuote, err := json.Marshal(&quote)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	fr.Cache.Set(fmt.Sprintf(FXQuotePrefix, quote.ID), bQuote)
+	return nil
+}
+
+// useFXQuote consumes a quote within tx, so a locked rate backs a single transfer.
+func useFXQuote(tx *gorm.DB, quoteID string) error {
+	useQuote := tx.Debug().Model(&models.FXQuote{}).
+		Where("id = ? AND used_at IS NULL", quoteID).
+		UpdateColumn("used_at", utils.TimeNowUTC())
+	if useQuote.Error != nil {
+		log.Println(useQuote.Error)
+		return useQuote.Error
+	}
+
+	if useQuote.RowsAffected == 0 {
+		return errs.ErrFXQuoteUsed
+	}
+	return nil
+}
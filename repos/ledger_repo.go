@@ -0,0 +1,50 @@
+package repos
+
+import (
+	"log"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"gorm.io/gorm"
+)
+
+// postJournalEntry records the journal entry of mutations within tx. It is called
+// next to every wallet update, so the postings and the wallet projection can not
+// drift apart.
+func postJournalEntry(tx *gorm.DB, mutations ...models.Mutation) (err error) {
+	entry, err := models.NewJournalEntry(mutations...)
+	return createJournalEntry(tx, entry, err)
+}
+
+// postPotJournalEntry records the journal entry of mutations moving money into or out
+// of pot potID within tx.
+func postPotJournalEntry(tx *gorm.DB, potID uint, mutations ...models.Mutation) (err error) {
+	entry, err := models.NewPotJournalEntry(potID, mutations...)
+	return createJournalEntry(tx, entry, err)
+}
+
+// postEscrowJournalEntry records the journal entry of mutations moving money into or
+// out of the escrow held under escrowRefID within tx.
+func postEscrowJournalEntry(tx *gorm.DB, escrowRefID string, mutations ...models.Mutation) (err error) {
+	entry, err := models.NewEscrowJournalEntry(escrowRefID, mutations...)
+	return createJournalEntry(tx, entry, err)
+}
+
+func createJournalEntry(tx *gorm.DB, entry models.JournalEntry, err error) error {
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if !entry.IsBalanced() {
+		err = errs.ErrUnbalancedJournalEntry
+		log.Println(err)
+		return err
+	}
+
+	err = tx.Debug().Create(&entry).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package repos
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"gorm.io/gorm"
+)
+
+// LimitRepo keeps the usage counters of transaction limits in the cache and
+// recomputes them from mutations when a counter is missing. Sums are read from
+// the write database, as replica lag would let a burst through.
+type LimitRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type ILimitRepo interface {
+	GetTotals(userID uint, mutationTypes []models.MutationType, from time.Time) (data []models.LimitTotal, err error)
+	GetCounter(key string) (val int64, err error)
+	InitCounter(key string, val int64, expiresAt time.Time) error
+	IncrCounter(key string, val int64) (result int64, err error)
+}
+
+// GetTotals sums the settled and pending mutations of the types made by a user since
+// from, per currency. Failed mutations, like a failed withdrawal, are not counted.
+func (lr *LimitRepo) GetTotals(userID uint, mutationTypes []models.MutationType, from time.Time) (data []models.LimitTotal, err error) {
+	err = lr.DBWrite.Debug().
+		Model(&models.Mutation{}).
+		Select("currency, SUM(value) as value, COUNT(*) as count").
+		Where("user_id = ? AND mutation_type IN ? AND status != ?", userID, mutationTypes, models.FAILED).
+		Where("created_at >= ?", from).
+		Group("currency").
+		Scan(&data).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// GetCounter returns redis.Nil when the counter does not exist.
+func (lr *LimitRepo) GetCounter(key string) (val int64, err error) {
+	bVal, err := lr.Cache.Get(key)
+	if err != nil {
+		return
+	}
+	return strconv.ParseInt(string(bVal), 10, 64)
+}
+
+// InitCounter creates the counter unless a concurrent request already did.
+func (lr *LimitRepo) InitCounter(key string, val int64, expiresAt time.Time) error {
+	_, err := lr.Cache.SetNX(key, val, time.Until(expiresAt))
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (lr *LimitRepo) IncrCounter(key string, val int64) (result int64, err error) {
+	result, err = lr.Cache.IncrBy(key, val)
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
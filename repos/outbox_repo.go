@@ -0,0 +1,109 @@
+package repos
+
+import (
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxRelayLockKey = "outbox_relay_lock"
+)
+
+type OutboxRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IOutboxRepo interface {
+	GetPendingEvents(limit int) (events []models.OutboxEvent, err error)
+	MarkPublished(event models.OutboxEvent) error
+	RecordFailure(event models.OutboxEvent) error
+	AcquireRelayLock(instanceID int64, ttl time.Duration) (ok bool, err error)
+	ReleaseRelayLock(instanceID int64) error
+}
+
+// createOutboxEvents records events within tx, so they are only published when the
+// change they describe is committed.
+func createOutboxEvents(tx *gorm.DB, events ...models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	err := tx.Debug().Create(&events).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// GetPendingEvents returns the oldest pending events due to be published, in the
+// order they were written. An event waiting for its next attempt holds back the
+// later events of its users, they are left out.
+func (or *OutboxRepo) GetPendingEvents(limit int) (events []models.OutboxEvent, err error) {
+	now := utils.TimeNowUTC()
+	err = or.DBWrite.Debug().
+		Where("status = ? AND next_attempt_at <= ?", models.OUTBOX_PENDING, now).
+		Where(`NOT EXISTS (SELECT 1 FROM outbox_events w WHERE w.status = ? AND w.next_attempt_at > ? AND w.id < outbox_events.id
+			AND (w.user_id IN (outbox_events.user_id, outbox_events.counterparty_id)
+			OR (w.counterparty_id <> 0 AND w.counterparty_id IN (outbox_events.user_id, outbox_events.counterparty_id))))`,
+			models.OUTBOX_PENDING, now).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+func (or *OutboxRepo) MarkPublished(event models.OutboxEvent) error {
+	err := or.DBWrite.Debug().Model(&models.OutboxEvent{ID: event.ID}).Updates(map[string]interface{}{
+		"status":       models.OUTBOX_PUBLISHED,
+		"published_at": utils.TimeNowUTC(),
+		"attempts":     gorm.Expr("attempts + 1"),
+		"last_error":   "",
+	}).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// RecordFailure saves the status, attempts, next attempt and last error of event
+// after a failed attempt.
+func (or *OutboxRepo) RecordFailure(event models.OutboxEvent) error {
+	err := or.DBWrite.Debug().Model(&models.OutboxEvent{ID: event.ID}).Updates(map[string]interface{}{
+		"status":          event.Status,
+		"attempts":        event.Attempts,
+		"next_attempt_at": event.NextAttemptAt,
+		"last_error":      event.LastError,
+	}).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// AcquireRelayLock lets a single relay publish at a time, running relays more than
+// once would break the order of events. The lock expires after ttl in case its
+// holder dies.
+func (or *OutboxRepo) AcquireRelayLock(instanceID int64, ttl time.Duration) (ok bool, err error) {
+	ok, err = or.Cache.SetNX(OutboxRelayLockKey, instanceID, ttl)
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// ReleaseRelayLock releases the lock when instanceID holds it. A relay outliving its
+// lock does not release the lock of the relay that took over.
+func (or *OutboxRepo) ReleaseRelayLock(instanceID int64) error {
+	_, err := or.Cache.DelIfEqual(OutboxRelayLockKey, instanceID)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
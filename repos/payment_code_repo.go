@@ -0,0 +1,60 @@
+package repos
+
+import (
+	"log"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentCodeRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IPaymentCodeRepo interface {
+	CreatePaymentCode(paymentCode *models.PaymentCode) error
+}
+
+func (pr *PaymentCodeRepo) CreatePaymentCode(paymentCode *models.PaymentCode) error {
+	err := pr.DBWrite.Debug().Omit("Merchant").Create(paymentCode).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// payPaymentCode marks the unexpired payment code of merchantID paid by the transfer
+// refID within tx. A code is paid once, with the amount and currency it is bound to.
+func payPaymentCode(tx *gorm.DB, merchantID uint, params models.TransferParams, refID string) error {
+	var code models.PaymentCode
+	err := tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND merchant_id = ?", params.PaymentCodeID, merchantID).
+		First(&code).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.ErrInvalidPaymentPayload
+		}
+		log.Println(err)
+		return err
+	}
+
+	if code.RefID != "" {
+		return errs.ErrPaymentCodeUsed
+	}
+	if !code.ExpiresAt.After(utils.TimeNowUTC()) {
+		return errs.ErrPaymentPayloadExpired
+	}
+	if code.Amount != params.ToAmount || code.Currency != params.ToCurrency {
+		return errs.ErrPaymentAmountMismatch
+	}
+
+	err = tx.Debug().Model(&code).Update("ref_id", refID).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
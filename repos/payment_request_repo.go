@@ -0,0 +1,168 @@
+package repos
+
+import (
+	"log"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+type PaymentRequestRepoRead struct {
+	DBRead *gorm.DB
+	Cache  ICache
+}
+
+type IPaymentRequestRepoRead interface {
+	GetPaymentRequest(paymentRequest *models.PaymentRequest) error
+	GetIncoming(payerID uint, filter models.PaymentRequestFilter) (paymentRequests []models.PaymentRequest, err error)
+	GetOutgoing(requesterID uint, filter models.PaymentRequestFilter) (paymentRequests []models.PaymentRequest, err error)
+}
+
+// GetPaymentRequest reads a payment request with its requester and payer.
+func (pr *PaymentRequestRepoRead) GetPaymentRequest(paymentRequest *models.PaymentRequest) error {
+	err := pr.DBRead.Debug().
+		Preload("Requester").
+		Preload("Payer").
+		Where(&models.PaymentRequest{ID: paymentRequest.ID}).
+		First(paymentRequest).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+// GetIncoming returns the latest payment requests addressed to payerID, with their
+// requester, a page of filter.
+func (pr *PaymentRequestRepoRead) GetIncoming(payerID uint, filter models.PaymentRequestFilter) (paymentRequests []models.PaymentRequest, err error) {
+	query := pr.DBRead.Debug().Preload("Requester").Where("payer_id = ?", payerID)
+	return pr.getPage(query, filter)
+}
+
+// GetOutgoing returns the latest payment requests of requesterID, with their payer,
+// a page of filter.
+func (pr *PaymentRequestRepoRead) GetOutgoing(requesterID uint, filter models.PaymentRequestFilter) (paymentRequests []models.PaymentRequest, err error) {
+	query := pr.DBRead.Debug().Preload("Payer").Where("requester_id = ?", requesterID)
+	return pr.getPage(query, filter)
+}
+
+// getPage reads the page of filter from query, of any status when filter.Status is empty.
+func (pr *PaymentRequestRepoRead) getPage(query *gorm.DB, filter models.PaymentRequestFilter) (paymentRequests []models.PaymentRequest, err error) {
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(created_at < ? or (created_at = ? and id < ?))",
+			filter.Cursor.CreatedAt, filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+	err = query.
+		Order("created_at desc").
+		Order("id desc").
+		Limit(filter.Limit).
+		Find(&paymentRequests).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+/*
+ */
+
+type PaymentRequestRepoWrite struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IPaymentRequestRepoWrite interface {
+	CreatePaymentRequest(paymentRequest *models.PaymentRequest) error
+	Decline(id string, payerID uint) (bool, error)
+	Cancel(id string, requesterID uint) (bool, error)
+	ExpirePending(limit int) (int64, error)
+}
+
+func (pr *PaymentRequestRepoWrite) CreatePaymentRequest(paymentRequest *models.PaymentRequest) error {
+	err := pr.DBWrite.Debug().Omit("Requester", "Payer").Create(paymentRequest).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// Decline reports false when the request is not a pending one of payerID.
+func (pr *PaymentRequestRepoWrite) Decline(id string, payerID uint) (bool, error) {
+	return pr.closePending(pr.DBWrite.Where("payer_id = ?", payerID), id, models.PAYMENT_REQUEST_DECLINED)
+}
+
+// Cancel reports false when the request is not a pending one of requesterID.
+func (pr *PaymentRequestRepoWrite) Cancel(id string, requesterID uint) (bool, error) {
+	return pr.closePending(pr.DBWrite.Where("requester_id = ?", requesterID), id, models.PAYMENT_REQUEST_CANCELLED)
+}
+
+// ExpirePending moves up to limit pending requests past their expiry to EXPIRED and
+// returns how many were moved.
+func (pr *PaymentRequestRepoWrite) ExpirePending(limit int) (int64, error) {
+	ids := []string{}
+	err := pr.DBWrite.Debug().Model(&models.PaymentRequest{}).
+		Where("status = ? AND expires_at <= ?", models.PAYMENT_REQUEST_PENDING, utils.TimeNowUTC()).
+		Order("expires_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// the status is checked again, a request may have been paid meanwhile
+	result := pr.DBWrite.Debug().Model(&models.PaymentRequest{}).
+		Where("id IN ? AND status = ?", ids, models.PAYMENT_REQUEST_PENDING).
+		Updates(map[string]interface{}{
+			"status":     models.PAYMENT_REQUEST_EXPIRED,
+			"updated_at": utils.TimeNowUTC(),
+		})
+	if result.Error != nil {
+		log.Println(result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+func (pr *PaymentRequestRepoWrite) closePending(query *gorm.DB, id string, status models.PaymentRequestStatus) (bool, error) {
+	result := query.Debug().Model(&models.PaymentRequest{}).
+		Where("id = ? AND status = ?", id, models.PAYMENT_REQUEST_PENDING).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"updated_at": utils.TimeNowUTC(),
+		})
+	if result.Error != nil {
+		log.Println(result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// payPaymentRequest marks a pending, unexpired request of payerID paid by the transfer
+// refID, within tx. The transfer fails when the request was settled otherwise meanwhile.
+func payPaymentRequest(tx *gorm.DB, id string, payerID uint, refID string) error {
+	now := utils.TimeNowUTC()
+	pay := tx.Debug().Model(&models.PaymentRequest{}).
+		Where("id = ? AND payer_id = ? AND status = ? AND expires_at > ?", id, payerID, models.PAYMENT_REQUEST_PENDING, now).
+		Updates(map[string]interface{}{
+			"status":     models.PAYMENT_REQUEST_PAID,
+			"ref_id":     refID,
+			"updated_at": now,
+		})
+	if pay.Error != nil {
+		log.Println(pay.Error)
+		return pay.Error
+	}
+
+	if pay.RowsAffected == 0 {
+		return errs.ErrPaymentRequestNotPending
+	}
+	return nil
+}
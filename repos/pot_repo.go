@@ -0,0 +1,417 @@
+package repos
+
+import (
+	"log"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PotRepoRead struct {
+	DBRead *gorm.DB
+	Cache  ICache
+}
+
+type IPotRepoRead interface {
+	GetPot(pot *models.Pot) error
+	GetPots(userID uint) (pots []models.Pot, err error)
+	GetContributions(potID uint, limit int) (contributions []models.PotContribution, err error)
+}
+
+// GetPot reads a pot by ID with its owner and members.
+func (pr *PotRepoRead) GetPot(pot *models.Pot) error {
+	err := pr.DBRead.Debug().
+		Preload("Owner").
+		Preload("Members", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Members.User").
+		Where(&models.Pot{ID: pot.ID}).
+		First(pot).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+// GetPots returns the pots userID is a member of, newest first.
+func (pr *PotRepoRead) GetPots(userID uint) (pots []models.Pot, err error) {
+	err = pr.DBRead.Debug().
+		Preload("Owner").
+		Joins("join pot_members pm on pm.pot_id = pots.id").
+		Where("pm.user_id = ?", userID).
+		Order("pots.id DESC").
+		Find(&pots).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// GetContributions returns the latest contributions to a pot with their contributor.
+func (pr *PotRepoRead) GetContributions(potID uint, limit int) (contributions []models.PotContribution, err error) {
+	err = pr.DBRead.Debug().
+		Preload("User").
+		Where("pot_id = ?", potID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&contributions).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+/*
+ */
+
+type PotRepoWrite struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IPotRepoWrite interface {
+	CreatePot(pot *models.Pot) error
+	Contribute(user models.User, potID uint, amount uint32) (contribution models.PotContribution, err error)
+	Disburse(owner models.User, potID uint, amount uint64) (resp models.PotDisbursementResponse, err error)
+	Close(owner models.User, potID uint) (refunds []models.PotRefund, err error)
+}
+
+// CreatePot stores a pot with its members.
+func (pr *PotRepoWrite) CreatePot(pot *models.Pot) (err error) {
+	tx := pr.DBWrite.Begin()
+
+	err = tx.Debug().Omit("Owner", "Members").Create(pot).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	for i := range pot.Members {
+		pot.Members[i].PotID = pot.ID
+	}
+	err = tx.Debug().Omit("User").Create(&pot.Members).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+	}
+	return
+}
+
+// Contribute moves amount from the wallet of user, a member of the pot, into the pot.
+// The pot is locked, so its balance and the totals of its members stay in step.
+func (pr *PotRepoWrite) Contribute(user models.User, potID uint, amount uint32) (contribution models.PotContribution, err error) {
+
+	tx := pr.DBWrite.Begin()
+
+	pot, err := lockOpenPot(tx, potID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = lockAccount(tx, user.ID, models.DEBIT)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	mutation := models.NewPotContributionMutation(user, amount, pot.Currency)
+	err = tx.Debug().Create(&mutation).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = debitWallet(tx, user.ID, pot.Currency, uint64(amount))
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	addContributed := tx.Debug().Model(&models.PotMember{}).
+		Where("pot_id = ? AND user_id = ?", pot.ID, user.ID).
+		UpdateColumn("contributed", gorm.Expr("contributed + ?", amount))
+	err = addContributed.Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if addContributed.RowsAffected == 0 {
+		err = errs.ErrPotNotFound
+		tx.Rollback()
+		return
+	}
+
+	contribution = models.PotContribution{
+		PotID:     pot.ID,
+		UserID:    user.ID,
+		RefID:     mutation.RefID,
+		Amount:    amount,
+		CreatedAt: mutation.CreatedAt,
+	}
+	err = tx.Debug().Omit("User").Create(&contribution).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = updatePot(tx, pot.ID, map[string]interface{}{
+		"balance": gorm.Expr("balance + ?", amount),
+	})
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = postPotJournalEntry(tx, pot.ID, mutation)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	pr.Cache.Del(walletKey(user.ID, pot.Currency))
+
+	return contribution, nil
+}
+
+// Disburse pays amount of the pot, its whole balance when amount is 0, to the wallet of
+// its owner. A pot disbursed down to zero is DISBURSED and takes no more contributions.
+func (pr *PotRepoWrite) Disburse(owner models.User, potID uint, amount uint64) (resp models.PotDisbursementResponse, err error) {
+
+	tx := pr.DBWrite.Begin()
+
+	pot, err := lockOwnPot(tx, potID, owner.ID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if amount == 0 {
+		amount = uint64(pot.Balance)
+	}
+	if amount == 0 || amount > uint64(pot.Balance) {
+		err = errs.ErrInsufficientPotFunds
+		tx.Rollback()
+		return
+	}
+
+	err = lockAccount(tx, owner.ID, models.CREDIT)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	mutations := models.NewPotPayoutMutations(owner, amount, pot.Currency, models.POT_DISBURSEMENT, utils.NewUUIDString())
+	err = tx.Debug().Create(&mutations).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	for _, v := range mutations {
+		err = creditWallet(tx, owner.ID, pot.Currency, v.Value)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	pot.Balance -= int64(amount)
+	if pot.Balance == 0 {
+		pot.Status = models.POT_DISBURSED
+	}
+	err = updatePot(tx, pot.ID, map[string]interface{}{
+		"balance": pot.Balance,
+		"status":  pot.Status,
+	})
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = postPotJournalEntry(tx, pot.ID, mutations...)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	pr.Cache.Del(walletKey(owner.ID, pot.Currency))
+
+	resp = models.PotDisbursementResponse{
+		RefID:   mutations[0].RefID,
+		Amount:  amount,
+		Balance: pot.Balance,
+		Status:  pot.Status,
+	}
+	return resp, nil
+}
+
+// Close refunds the balance of the pot to its contributors, in proportion to what each
+// of them contributed, and sets it to CLOSED. The refunds share one RefID. The pot is
+// not closed while a contributor owed a refund can not be credited, a frozen account
+// has to be unfrozen first. A closed account can not be owed one, accounts with open
+// pots are not closed.
+func (pr *PotRepoWrite) Close(owner models.User, potID uint) (refunds []models.PotRefund, err error) {
+
+	tx := pr.DBWrite.Begin()
+
+	pot, err := lockOwnPot(tx, potID, owner.ID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	members := []models.PotMember{}
+	err = tx.Debug().Where("pot_id = ?", pot.ID).Order("id").Find(&members).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	weights := make([]uint64, len(members))
+	for i, v := range members {
+		weights[i] = v.Contributed
+	}
+	shares := models.ProportionalShares(uint64(pot.Balance), weights)
+
+	refID := utils.NewUUIDString()
+	mutations := []models.Mutation{}
+	for i, member := range members {
+		if shares[i] == 0 {
+			continue
+		}
+
+		err = lockAccount(tx, member.UserID, models.CREDIT)
+		if err != nil {
+			if err == errs.ErrAccountFrozen || err == errs.ErrAccountClosed {
+				err = errs.ErrPotRefundUnavailable
+			}
+			tx.Rollback()
+			return
+		}
+
+		refundMutations := models.NewPotPayoutMutations(models.User{ID: member.UserID}, shares[i], pot.Currency, models.POT_REFUND, refID)
+		err = tx.Debug().Create(&refundMutations).Error
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+			return
+		}
+
+		for _, v := range refundMutations {
+			err = creditWallet(tx, member.UserID, pot.Currency, v.Value)
+			if err != nil {
+				tx.Rollback()
+				return
+			}
+		}
+
+		err = tx.Debug().Model(&member).
+			UpdateColumn("refunded", gorm.Expr("refunded + ?", shares[i])).Error
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+			return
+		}
+
+		mutations = append(mutations, refundMutations...)
+		refunds = append(refunds, models.PotRefund{UserID: member.UserID, RefID: refID, Amount: shares[i]})
+	}
+
+	err = updatePot(tx, pot.ID, map[string]interface{}{
+		"balance": 0,
+		"status":  models.POT_CLOSED,
+	})
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if len(mutations) > 0 {
+		err = postPotJournalEntry(tx, pot.ID, mutations...)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	for _, v := range refunds {
+		pr.Cache.Del(walletKey(v.UserID, pot.Currency))
+	}
+
+	return refunds, nil
+}
+
+// lockOpenPot locks an open pot within tx.
+func lockOpenPot(tx *gorm.DB, potID uint) (pot models.Pot, err error) {
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&pot, potID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return pot, errs.ErrPotNotFound
+		}
+		log.Println(err)
+		return
+	}
+
+	if pot.Status != models.POT_OPEN {
+		err = errs.ErrPotNotOpen
+	}
+	return
+}
+
+// lockOwnPot locks an open pot of ownerID within tx.
+func lockOwnPot(tx *gorm.DB, potID uint, ownerID uint) (pot models.Pot, err error) {
+	pot, err = lockOpenPot(tx, potID)
+	if err == nil && pot.OwnerID != ownerID {
+		err = errs.ErrNotPotOwner
+	}
+	return
+}
+
+func updatePot(tx *gorm.DB, potID uint, updates map[string]interface{}) error {
+	updates["updated_at"] = utils.TimeNowUTC()
+	err := tx.Debug().Model(&models.Pot{ID: potID}).UpdateColumns(updates).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
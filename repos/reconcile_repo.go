@@ -0,0 +1,167 @@
+package repos
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/atrariksa/awallet/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReconcileRepo reads and repairs the wallet counters maintained by
+// UserBalanceRepoWrite. It works on the write database only, as replica lag
+// would be reported as drift.
+type ReconcileRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IReconcileRepo interface {
+	GetWalletReconciliations(afterWalletID uint, limit int) (data []models.WalletReconciliation, err error)
+	FixWallet(walletID uint, actor string) (fixed []models.ReconcileDrift, err error)
+}
+
+// GetWalletReconciliations recomputes up to limit wallets ordered by id, starting
+// after afterWalletID.
+func (rr *ReconcileRepo) GetWalletReconciliations(afterWalletID uint, limit int) (data []models.WalletReconciliation, err error) {
+
+	var wallets []models.Wallet
+	err = rr.DBWrite.Debug().
+		Preload("User").
+		Where("id > ?", afterWalletID).
+		Order("id").
+		Limit(limit).
+		Find(&wallets).Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if len(wallets) == 0 {
+		return
+	}
+
+	userIDs := make([]uint, 0, len(wallets))
+	for _, v := range wallets {
+		userIDs = append(userIDs, v.UserID)
+	}
+
+	var totalOutgoings []models.UserTotalOutgoing
+	err = rr.DBWrite.Debug().Where("user_id IN ?", userIDs).Find(&totalOutgoings).Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	totals, err := getMutationTotals(rr.DBWrite.Debug().Where("user_id IN ?", userIDs))
+	if err != nil {
+		return
+	}
+
+	for _, w := range wallets {
+		var totalOutgoing uint64
+		for _, v := range totalOutgoings {
+			if v.UserID == w.UserID && v.Currency == w.Currency {
+				totalOutgoing = v.Value
+			}
+		}
+		data = append(data, models.NewWalletReconciliation(w, w.User.Username, totalOutgoing, totals))
+	}
+
+	return
+}
+
+// FixWallet locks the wallet, recomputes it within the same transaction and
+// overwrites every drifted counter, writing an audit log per correction.
+func (rr *ReconcileRepo) FixWallet(walletID uint, actor string) (fixed []models.ReconcileDrift, err error) {
+
+	tx := rr.DBWrite.Begin()
+
+	wallet := models.Wallet{}
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("User").
+		First(&wallet, walletID).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	totalOutgoing := models.UserTotalOutgoing{}
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&models.UserTotalOutgoing{UserID: wallet.UserID, Currency: wallet.Currency}).
+		Limit(1).
+		Find(&totalOutgoing).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	totals, err := getMutationTotals(tx.Debug().Where("user_id = ? AND currency = ?", wallet.UserID, wallet.Currency))
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	reconciliation := models.NewWalletReconciliation(wallet, wallet.User.Username, totalOutgoing.Value, totals)
+	for _, drift := range reconciliation.Drifts() {
+		switch drift.Field {
+		case models.TotalOutgoingField:
+			err = tx.Debug().Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"value": drift.Expected}),
+			}).Create(&models.UserTotalOutgoing{UserID: wallet.UserID, Currency: wallet.Currency, Value: uint64(drift.Expected)}).Error
+		default:
+			err = tx.Debug().Model(&wallet).UpdateColumn(drift.Field, drift.Expected).Error
+		}
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+			return nil, err
+		}
+
+		drift.Fixed = true
+		detail, _ := json.Marshal(drift)
+		err = tx.Debug().Create(&models.AuditLog{
+			Actor:  actor,
+			Action: models.RECONCILE_FIX,
+			UserID: wallet.UserID,
+			Detail: string(detail),
+		}).Error
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+			return nil, err
+		}
+
+		fixed = append(fixed, drift)
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	rr.Cache.Del(walletKey(wallet.UserID, wallet.Currency))
+	rr.Cache.Del(topUserKey(wallet.Currency))
+
+	return fixed, nil
+}
+
+func getMutationTotals(query *gorm.DB) (totals []models.MutationTotal, err error) {
+	err = query.
+		Model(&models.Mutation{}).
+		Select("user_id, currency, mutation_type, status, SUM(value) as value").
+		Group("user_id, currency, mutation_type, status").
+		Scan(&totals).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
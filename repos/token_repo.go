@@ -0,0 +1,161 @@
+package repos
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"github.com/go-redis/redis"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	RevokedTokenPrefix   = "revoked_token_%v"
+	RevokedSessionPrefix = "revoked_session_%v"
+)
+
+type TokenRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type ITokenRepo interface {
+	CreateRefreshToken(refreshToken *models.RefreshToken) error
+	RotateRefreshToken(tokenHash string, next *models.RefreshToken) (current models.RefreshToken, err error)
+	RevokeSession(sessionID string, ttl time.Duration) error
+	RevokeToken(tokenID string, ttl time.Duration) error
+	IsRevoked(tokenID string, sessionID string) (bool, error)
+}
+
+func (tr *TokenRepo) CreateRefreshToken(refreshToken *models.RefreshToken) error {
+	err := tr.DBWrite.Debug().Create(refreshToken).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// RotateRefreshToken marks the refresh token as used and stores next in its session.
+// A token that was already used or revoked revokes its whole session and returns
+// ErrRefreshTokenReused along with the token.
+func (tr *TokenRepo) RotateRefreshToken(tokenHash string, next *models.RefreshToken) (current models.RefreshToken, err error) {
+
+	tx := tr.DBWrite.Begin()
+
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("User").
+		Where(&models.RefreshToken{TokenHash: tokenHash}).
+		First(&current).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrInvalidRefreshToken
+		}
+		return
+	}
+
+	now := utils.TimeNowUTC()
+	if current.UsedAt != nil || current.RevokedAt != nil {
+		err = tx.Debug().Model(&models.RefreshToken{}).
+			Where("session_id = ? AND revoked_at IS NULL", current.SessionID).
+			UpdateColumn("revoked_at", now).Error
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+			return
+		}
+
+		err = tx.Debug().Commit().Error
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+			return
+		}
+		return current, errs.ErrRefreshTokenReused
+	}
+
+	if current.IsExpired() {
+		err = errs.ErrInvalidRefreshToken
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Model(&current).UpdateColumn("used_at", now).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	next.UserID = current.UserID
+	next.SessionID = current.SessionID
+	err = tx.Debug().Create(next).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	return current, nil
+}
+
+// RevokeSession revokes the refresh tokens of a session and, for ttl, the access
+// tokens issued within it.
+func (tr *TokenRepo) RevokeSession(sessionID string, ttl time.Duration) error {
+	err := tr.DBWrite.Debug().Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		UpdateColumn("revoked_at", utils.TimeNowUTC()).Error
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	_, err = tr.Cache.SetNX(fmt.Sprintf(RevokedSessionPrefix, sessionID), 1, ttl)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// RevokeToken revokes an access token by its jti until it expires anyway.
+func (tr *TokenRepo) RevokeToken(tokenID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	_, err := tr.Cache.SetNX(fmt.Sprintf(RevokedTokenPrefix, tokenID), 1, ttl)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (tr *TokenRepo) IsRevoked(tokenID string, sessionID string) (bool, error) {
+	keys := []string{fmt.Sprintf(RevokedTokenPrefix, tokenID)}
+	if sessionID != "" {
+		keys = append(keys, fmt.Sprintf(RevokedSessionPrefix, sessionID))
+	}
+	for _, key := range keys {
+		_, err := tr.Cache.Get(key)
+		if err == nil {
+			return true, nil
+		}
+		if err != redis.Nil {
+			log.Println(err)
+			return false, err
+		}
+	}
+	return false, nil
+}
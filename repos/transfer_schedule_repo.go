@@ -0,0 +1,224 @@
+package repos
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"github.com/go-redis/redis"
+	"gorm.io/gorm"
+)
+
+const (
+	SchedulerLeaderKey = "scheduler_leader"
+)
+
+type TransferScheduleRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type ITransferScheduleRepo interface {
+	CreateSchedule(schedule *models.TransferSchedule) error
+	GetSchedules(userID uint) (schedules []models.TransferSchedule, err error)
+	GetSchedule(schedule *models.TransferSchedule) error
+	UpdateSchedule(schedule models.TransferSchedule) error
+	DeleteSchedule(userID uint, scheduleID uint) (deleted bool, err error)
+	GetRuns(scheduleID uint, limit int) (runs []models.TransferScheduleRun, err error)
+	GetDueSchedules(limit int) (schedules []models.TransferSchedule, err error)
+	RecordRun(schedule models.TransferSchedule, occurrenceAt time.Time, run models.TransferScheduleRun) error
+	AcquireLeadership(instanceID int64, ttl time.Duration) (bool, error)
+	ReleaseLeadership(instanceID int64) error
+}
+
+func (sr *TransferScheduleRepo) CreateSchedule(schedule *models.TransferSchedule) error {
+	err := sr.DBWrite.Debug().Omit("User").Create(schedule).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (sr *TransferScheduleRepo) GetSchedules(userID uint) (schedules []models.TransferSchedule, err error) {
+	err = sr.DBWrite.Debug().Where("user_id = ?", userID).Order("id").Find(&schedules).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// GetSchedule reads a schedule by ID and UserID.
+func (sr *TransferScheduleRepo) GetSchedule(schedule *models.TransferSchedule) error {
+	err := sr.DBWrite.Debug().
+		Where("id = ? AND user_id = ?", schedule.ID, schedule.UserID).
+		First(schedule).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+// UpdateSchedule saves the settings and the next occurrence of a schedule changed by
+// its user.
+func (sr *TransferScheduleRepo) UpdateSchedule(schedule models.TransferSchedule) error {
+	err := sr.DBWrite.Debug().Model(&models.TransferSchedule{ID: schedule.ID}).Updates(map[string]interface{}{
+		"amount":      schedule.Amount,
+		"run_at":      schedule.RunAt,
+		"cron":        schedule.Cron,
+		"status":      schedule.Status,
+		"next_run_at": schedule.NextRunAt,
+		"due_at":      schedule.DueAt,
+		"attempts":    schedule.Attempts,
+		"updated_at":  utils.TimeNowUTC(),
+	}).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// DeleteSchedule removes a schedule of user with its runs.
+func (sr *TransferScheduleRepo) DeleteSchedule(userID uint, scheduleID uint) (deleted bool, err error) {
+	tx := sr.DBWrite.Debug().Begin()
+
+	result := tx.Where("id = ? AND user_id = ?", scheduleID, userID).Delete(&models.TransferSchedule{})
+	if result.Error != nil {
+		err = result.Error
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Where("schedule_id = ?", scheduleID).Delete(&models.TransferScheduleRun{}).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	return true, nil
+}
+
+// GetRuns returns the latest runs of a schedule.
+func (sr *TransferScheduleRepo) GetRuns(scheduleID uint, limit int) (runs []models.TransferScheduleRun, err error) {
+	err = sr.DBWrite.Debug().Where("schedule_id = ?", scheduleID).Order("id DESC").Limit(limit).Find(&runs).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// GetDueSchedules returns active schedules whose attempt is due, with their user.
+func (sr *TransferScheduleRepo) GetDueSchedules(limit int) (schedules []models.TransferSchedule, err error) {
+	err = sr.DBWrite.Debug().
+		Preload("User").
+		Where("status = ? AND due_at <= ?", models.SCHEDULE_ACTIVE, utils.TimeNowUTC()).
+		Order("due_at").
+		Limit(limit).
+		Find(&schedules).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// RecordRun stores run and the state schedule moves to after it. The schedule is
+// left alone when its user changed or paused it while it was running at occurrenceAt.
+func (sr *TransferScheduleRepo) RecordRun(schedule models.TransferSchedule, occurrenceAt time.Time, run models.TransferScheduleRun) error {
+	tx := sr.DBWrite.Debug().Begin()
+
+	err := tx.Create(&run).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Model(&models.TransferSchedule{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", schedule.ID, models.SCHEDULE_ACTIVE, occurrenceAt).
+		Updates(map[string]interface{}{
+			"status":      schedule.Status,
+			"next_run_at": schedule.NextRunAt,
+			"due_at":      schedule.DueAt,
+			"attempts":    schedule.Attempts,
+			"last_run_at": schedule.LastRunAt,
+			"last_ref_id": schedule.LastRefID,
+			"last_error":  schedule.LastError,
+			"updated_at":  utils.TimeNowUTC(),
+		}).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// AcquireLeadership makes instanceID the leader of the schedulers for ttl, or extends
+// its lead when it is the leader already. It reports whether instanceID leads. The
+// lead of an instance that stops renewing it passes to another one after ttl.
+func (sr *TransferScheduleRepo) AcquireLeadership(instanceID int64, ttl time.Duration) (bool, error) {
+	ok, err := sr.Cache.SetNX(SchedulerLeaderKey, instanceID, ttl)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	if ok {
+		return true, nil
+	}
+
+	leader, err := sr.Cache.Get(SchedulerLeaderKey)
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		log.Println(err)
+		return false, err
+	}
+	if string(leader) != strconv.FormatInt(instanceID, 10) {
+		return false, nil
+	}
+
+	ok, err = sr.Cache.Expire(SchedulerLeaderKey, ttl)
+	if err != nil {
+		log.Println(err)
+	}
+	return ok, err
+}
+
+// ReleaseLeadership hands the lead over right away when instanceID leads.
+func (sr *TransferScheduleRepo) ReleaseLeadership(instanceID int64) error {
+	leader, err := sr.Cache.Get(SchedulerLeaderKey)
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		log.Println(err)
+		return err
+	}
+	if string(leader) != strconv.FormatInt(instanceID, 10) {
+		return nil
+	}
+
+	err = sr.Cache.Del(SchedulerLeaderKey)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
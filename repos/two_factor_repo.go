@@ -0,0 +1,205 @@
+package repos
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"github.com/go-redis/redis"
+	"gorm.io/gorm"
+)
+
+const (
+	TwoFactorFailuresPrefix = "two_factor_failures_%v"
+)
+
+type TwoFactorRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type ITwoFactorRepo interface {
+	GetTwoFactor(twoFactor *models.TwoFactor) error
+	SaveTwoFactor(twoFactor *models.TwoFactor) error
+	ActivateTwoFactor(twoFactor models.TwoFactor, step int64, recoveryCodes []models.RecoveryCode) (activated bool, err error)
+	UseTOTPStep(userID uint, step int64) (bool, error)
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	CreateChallenge(challenge *models.TransferChallenge) error
+	GetChallenge(challenge *models.TransferChallenge) error
+	RecordChallengeAttempt(challengeID string) error
+	ConfirmChallenge(challengeID string) (bool, error)
+	GetCodeFailures(userID uint) (int64, error)
+	RecordCodeFailure(userID uint, window time.Duration) error
+	ClearCodeFailures(userID uint) error
+}
+
+func (tr *TwoFactorRepo) GetTwoFactor(twoFactor *models.TwoFactor) error {
+	err := tr.DBWrite.Debug().Where(twoFactor).First(twoFactor).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+func (tr *TwoFactorRepo) SaveTwoFactor(twoFactor *models.TwoFactor) error {
+	err := tr.DBWrite.Debug().Save(twoFactor).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// ActivateTwoFactor enables a pending enrollment and replaces the recovery codes of
+// the user. It reports false when the enrollment was activated concurrently.
+func (tr *TwoFactorRepo) ActivateTwoFactor(twoFactor models.TwoFactor, step int64, recoveryCodes []models.RecoveryCode) (activated bool, err error) {
+	tx := tr.DBWrite.Debug().Begin()
+
+	result := tx.Model(&models.TwoFactor{}).
+		Where("id = ? AND enabled_at IS NULL", twoFactor.ID).
+		Updates(map[string]interface{}{
+			"enabled_at":     utils.TimeNowUTC(),
+			"last_used_step": step,
+		})
+	if result.Error != nil {
+		err = result.Error
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Where("user_id = ?", twoFactor.UserID).Delete(&models.RecoveryCode{}).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Create(&recoveryCodes).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	activated = true
+	return
+}
+
+// UseTOTPStep records step as the last used one, unless a code of that step or a
+// later one was already accepted.
+func (tr *TwoFactorRepo) UseTOTPStep(userID uint, step int64) (bool, error) {
+	result := tr.DBWrite.Debug().
+		Model(&models.TwoFactor{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		log.Println(result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (tr *TwoFactorRepo) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := tr.DBWrite.Debug().
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", utils.TimeNowUTC())
+	if result.Error != nil {
+		log.Println(result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (tr *TwoFactorRepo) CreateChallenge(challenge *models.TransferChallenge) error {
+	err := tr.DBWrite.Debug().Create(challenge).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (tr *TwoFactorRepo) GetChallenge(challenge *models.TransferChallenge) error {
+	err := tr.DBWrite.Debug().Where(challenge).First(challenge).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+func (tr *TwoFactorRepo) RecordChallengeAttempt(challengeID string) error {
+	err := tr.DBWrite.Debug().
+		Model(&models.TransferChallenge{}).
+		Where("id = ?", challengeID).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// ConfirmChallenge marks the challenge as confirmed. It reports false when it already was,
+// so a challenge releases its transfer only once.
+func (tr *TwoFactorRepo) ConfirmChallenge(challengeID string) (bool, error) {
+	result := tr.DBWrite.Debug().
+		Model(&models.TransferChallenge{}).
+		Where("id = ? AND confirmed_at IS NULL", challengeID).
+		Update("confirmed_at", utils.TimeNowUTC())
+	if result.Error != nil {
+		log.Println(result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetCodeFailures returns how many wrong two-factor codes userID sent within the
+// current lockout window.
+func (tr *TwoFactorRepo) GetCodeFailures(userID uint) (int64, error) {
+	bVal, err := tr.Cache.Get(fmt.Sprintf(TwoFactorFailuresPrefix, userID))
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		log.Println(err)
+		return 0, err
+	}
+	return strconv.ParseInt(string(bVal), 10, 64)
+}
+
+// RecordCodeFailure counts a wrong two-factor code of userID. The count expires
+// window after the first failure.
+func (tr *TwoFactorRepo) RecordCodeFailure(userID uint, window time.Duration) error {
+	key := fmt.Sprintf(TwoFactorFailuresPrefix, userID)
+	failures, err := tr.Cache.IncrBy(key, 1)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if failures == 1 {
+		_, err = tr.Cache.Expire(key, window)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return err
+}
+
+func (tr *TwoFactorRepo) ClearCodeFailures(userID uint) error {
+	err := tr.Cache.Del(fmt.Sprintf(TwoFactorFailuresPrefix, userID))
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"strings"
 
 	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
 	"github.com/go-redis/redis"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -21,15 +25,559 @@ type UserBalanceRepoWrite struct {
 }
 
 type IUserBalanceRepoWrite interface {
-	Topup(user models.User, amount uint32) error
-	Transfer(user models.User, amount uint32, destUser models.User) error
+	Topup(user models.User, params models.TopupParams) error
+	Transfer(user models.User, destUser models.User, params models.TransferParams) (resp models.TransferResponse, err error)
+	TransferBatch(user models.User, destUsers []models.User, params []models.TransferParams, idempotencyKey *models.IdempotencyKey) (resps []models.TransferResponse, err error)
+	ReverseTransfer(refID string, allowNegativeBalance bool, audit *models.AuditLog) (reversalRefID string, err error)
+	Withdraw(user models.User, amount uint32, currency string) (mutation models.Mutation, err error)
+	CompleteWithdrawal(refID string, status models.MutationStatus) error
+	HoldEscrow(payer models.User, payee models.User, params models.EscrowParams) (escrow models.Escrow, err error)
+	SettleEscrow(refID string, party models.EscrowParty, outcome models.EscrowStatus, audit *models.AuditLog) (escrow models.Escrow, err error)
+	MarkEscrowReleaseAttempted(refID string) error
+	DisputeEscrow(refID string, party models.EscrowParty) (escrow models.Escrow, err error)
+	Adjust(user models.User, params models.AdjustmentParams) (mutation models.Mutation, err error)
+	CloseAccount(user models.User, payout bool, audit *models.AuditLog) (payouts []models.Mutation, err error)
 }
 
-func (ur *UserBalanceRepoWrite) Topup(user models.User, amount uint32) (err error) {
+func (ur *UserBalanceRepoWrite) Topup(user models.User, params models.TopupParams) (err error) {
 
-	mutation := models.NewTopupMutation(user, amount)
+	mutation := models.NewTopupMutation(user, params.Amount, params.Currency)
+	mutation.SetNote(params.Note)
 	tx := ur.DBWrite.Begin()
 
+	err = ur.saveIdempotencyKey(tx, params.IdempotencyKey)
+	if err != nil {
+		tx.Rollback()
+		return ur.checkIdempotencyKey(params.IdempotencyKey, err)
+	}
+
+	err = lockAccount(tx, user.ID, models.CREDIT)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = createMutations(tx, &mutation)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = topupWallet(tx, user.ID, params.Currency, params.Amount)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = postJournalEntry(tx, mutation)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	topupEvent := models.NewBalanceToppedUpEvent(mutation)
+	err = createOutboxEvents(tx, topupEvent)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = createWebhookDeliveries(tx, user.ID, models.WEBHOOK_TOPUP, json.RawMessage(topupEvent.Payload))
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	ur.Cache.Del(walletKey(user.ID, params.Currency))
+
+	return nil
+}
+
+// Transfer debits params.Amount plus params.Fee in params.Currency from user and credits
+// params.ToAmount in params.ToCurrency to destUser. The fee is recorded as a FEE mutation
+// sharing the RefID of the transfer and posted to the house fee account. An FX quote used
+// for the conversion is consumed, and a payment request paid by the transfer is marked
+// paid, within the same transaction.
+func (ur *UserBalanceRepoWrite) Transfer(user models.User, destUser models.User, params models.TransferParams) (resp models.TransferResponse, err error) {
+
+	tx := ur.DBWrite.Begin()
+
+	err = ur.saveIdempotencyKey(tx, params.IdempotencyKey)
+	if err != nil {
+		tx.Rollback()
+		err = ur.checkIdempotencyKey(params.IdempotencyKey, err)
+		return
+	}
+
+	resp, err = transfer(tx, user, destUser, params)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = ur.saveIdempotentResponse(tx, params.IdempotencyKey, resp)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	ur.clearTransferCache(user, destUser, params)
+
+	return resp, nil
+}
+
+// TransferBatch makes the transfers of user to destUsers, params[i] going to destUsers[i],
+// in one transaction: either all of them are made or none. idempotencyKey, when not nil,
+// keeps a batch from being made twice, a replay loads the responses of the first run.
+func (ur *UserBalanceRepoWrite) TransferBatch(user models.User, destUsers []models.User, params []models.TransferParams, idempotencyKey *models.IdempotencyKey) (resps []models.TransferResponse, err error) {
+
+	tx := ur.DBWrite.Begin()
+
+	err = ur.saveIdempotencyKey(tx, idempotencyKey)
+	if err != nil {
+		tx.Rollback()
+		err = ur.checkIdempotencyKey(idempotencyKey, err)
+		if err == errs.ErrIdempotencyReplay {
+			json.Unmarshal(idempotencyKey.ResponseBody, &resps)
+		}
+		return
+	}
+
+	for i := range params {
+		var resp models.TransferResponse
+		resp, err = transfer(tx, user, destUsers[i], params[i])
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		resps = append(resps, resp)
+	}
+
+	err = ur.saveIdempotentResponse(tx, idempotencyKey, resps)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	for i := range params {
+		ur.clearTransferCache(user, destUsers[i], params[i])
+	}
+
+	return resps, nil
+}
+
+// transfer records a transfer of user to destUser within tx.
+func transfer(tx *gorm.DB, user models.User, destUser models.User, params models.TransferParams) (resp models.TransferResponse, err error) {
+
+	err = lockTransferAccounts(tx, user.ID, destUser.ID)
+	if err != nil {
+		return
+	}
+
+	mutationOutgoing, mutationIncoming := models.NewConversionTransferMutation(user, destUser, params)
+	mutationOutgoing.SetNote(params.Note)
+	mutationIncoming.Memo = mutationOutgoing.Memo
+	mutations := []models.Mutation{mutationOutgoing, mutationIncoming}
+	if params.Fee > 0 {
+		mutations = append(mutations, models.NewFeeMutation(mutationOutgoing, params.Fee))
+	}
+
+	if params.QuoteID != "" {
+		err = useFXQuote(tx, params.QuoteID)
+		if err != nil {
+			return
+		}
+	}
+
+	if params.PaymentRequestID != "" {
+		err = payPaymentRequest(tx, params.PaymentRequestID, user.ID, mutationOutgoing.RefID)
+		if err != nil {
+			return
+		}
+	}
+
+	if params.PaymentCodeID != "" {
+		err = payPaymentCode(tx, destUser.ID, params, mutationOutgoing.RefID)
+		if err != nil {
+			return
+		}
+	}
+
+	err = createMutations(tx, &mutations)
+	if err != nil {
+		return
+	}
+
+	err = debitWallet(tx, user.ID, params.Currency, uint64(params.Amount)+uint64(params.Fee))
+	if err != nil {
+		return
+	}
+
+	err = addTotalOutgoing(tx, user.ID, params.Currency, params.Amount)
+	if err != nil {
+		return
+	}
+
+	err = creditWallet(tx, destUser.ID, params.ToCurrency, params.ToAmount)
+	if err != nil {
+		return
+	}
+
+	err = postJournalEntry(tx, mutations...)
+	if err != nil {
+		return
+	}
+
+	transferEvent := models.NewTransferCompletedEvent(mutationOutgoing, mutationIncoming, params.Fee)
+	err = createOutboxEvents(tx, transferEvent)
+	if err != nil {
+		return
+	}
+
+	err = createWebhookDeliveries(tx, destUser.ID, models.WEBHOOK_TRANSFER_INCOMING, json.RawMessage(transferEvent.Payload))
+	if err != nil {
+		return
+	}
+
+	return models.NewTransferResponse(mutationOutgoing, mutationIncoming, params.Fee), nil
+}
+
+// createMutations stores mutations, a mutation or a slice of them, with their tags
+// within tx. An external reference the user already used is refused.
+func createMutations(tx *gorm.DB, mutations interface{}) error {
+	err := tx.Debug().Create(mutations).Error
+	if err != nil {
+		log.Println(err)
+		if strings.Contains(err.Error(), DuplicateKey) {
+			return errs.ErrDuplicateExternalRef
+		}
+	}
+	return err
+}
+
+func (ur *UserBalanceRepoWrite) clearTransferCache(user models.User, destUser models.User, params models.TransferParams) {
+	ur.Cache.Del(walletKey(user.ID, params.Currency))
+	ur.Cache.Del(walletKey(destUser.ID, params.ToCurrency))
+	ur.Cache.Del(topUserKey(params.Currency))
+	ur.Cache.Del(fmt.Sprintf(TopTrsPrefix, user.Username))
+}
+
+// ReverseTransfer moves the amount of a transfer back from its recipient to its sender.
+// The fee of the transfer is kept by the house account on purpose, it paid for a
+// transfer that was made.
+// The original mutations are locked so the same transfer can not be reversed twice concurrently.
+// The sender and the recipient are locked too, a closed one is ErrAccountClosed.
+// A non nil audit is recorded for the sender with the reversal ref id.
+func (ur *UserBalanceRepoWrite) ReverseTransfer(refID string, allowNegativeBalance bool, audit *models.AuditLog) (reversalRefID string, err error) {
+
+	tx := ur.DBWrite.Begin()
+
+	var originals []models.Mutation
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ref_id = ?", refID).
+		Where("mutation_type IN ?", []string{string(models.OUTGOING), string(models.INCOMING)}).
+		Find(&originals).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	var originalOutgoing, originalIncoming models.Mutation
+	for _, v := range originals {
+		if v.MutationType == models.OUTGOING {
+			originalOutgoing = v
+		} else {
+			originalIncoming = v
+		}
+	}
+
+	if originalOutgoing.ID == 0 || originalIncoming.ID == 0 {
+		err = errs.ErrTransferNotFound
+		tx.Rollback()
+		return
+	}
+
+	var reversed int64
+	err = tx.Debug().Model(&models.Mutation{}).Where("reversal_of = ?", refID).Count(&reversed).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if reversed > 0 {
+		err = errs.ErrTransferAlreadyReversed
+		tx.Rollback()
+		return
+	}
+
+	err = lockOpenAccounts(tx, originalOutgoing.UserID, originalIncoming.UserID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	user := models.User{ID: originalOutgoing.UserID}
+	err = tx.Debug().First(&user).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	destUser := models.User{ID: originalIncoming.UserID}
+	err = tx.Debug().First(&destUser).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	reversalOut, reversalIn := models.NewReversalMutation(originalOutgoing, originalIncoming)
+
+	err = tx.Debug().Create(&reversalOut).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if allowNegativeBalance {
+		deductBalance := tx.Debug().Model(&models.Wallet{}).
+			Where("user_id = ? AND currency = ?", destUser.ID, reversalOut.Currency).
+			UpdateColumn("balance", gorm.Expr("balance - ?", reversalOut.Value))
+		err = deductBalance.Error
+		if err != nil {
+			log.Println(err)
+		} else if deductBalance.RowsAffected == 0 {
+			err = errs.ErrWalletNotFound
+		}
+	} else {
+		err = debitWallet(tx, destUser.ID, reversalOut.Currency, uint64(reversalOut.Value))
+	}
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Create(&reversalIn).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = creditWallet(tx, user.ID, reversalIn.Currency, reversalIn.Value)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = postJournalEntry(tx, reversalOut, reversalIn)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	updateTotalOutgoing := tx.Debug().Model(&models.UserTotalOutgoing{}).
+		Where("user_id = ? AND currency = ? AND value >= ?", user.ID, reversalIn.Currency, reversalIn.Value).
+		UpdateColumn("value", gorm.Expr("value - ?", reversalIn.Value))
+	err = updateTotalOutgoing.Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if updateTotalOutgoing.RowsAffected == 0 {
+		err = errs.ErrInternalServer
+		tx.Rollback()
+		return
+	}
+
+	if audit != nil {
+		audit.UserID = user.ID
+		audit.RefID = reversalOut.RefID
+	}
+	err = createAuditLog(tx, audit)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	ur.Cache.Del(walletKey(user.ID, reversalIn.Currency))
+	ur.Cache.Del(walletKey(destUser.ID, reversalOut.Currency))
+	ur.Cache.Del(topUserKey(reversalIn.Currency))
+	ur.Cache.Del(fmt.Sprintf(TopTrsPrefix, user.Username))
+	ur.Cache.Del(fmt.Sprintf(TopTrsPrefix, destUser.Username))
+
+	return reversalOut.RefID, nil
+}
+
+// Withdraw puts the amount on hold and records a pending withdrawal. The balance
+// itself is only deducted once the withdrawal is settled.
+func (ur *UserBalanceRepoWrite) Withdraw(user models.User, amount uint32, currency string) (mutation models.Mutation, err error) {
+
+	mutation = models.NewWithdrawalMutation(user, amount, currency)
+	tx := ur.DBWrite.Begin()
+
+	err = lockAccount(tx, user.ID, models.DEBIT)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Create(&mutation).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	holdBalance := tx.Debug().Model(&models.Wallet{}).
+		Where("user_id = ? AND currency = ?", user.ID, currency).
+		Where("balance - held_balance >= ?", amount).
+		UpdateColumn("held_balance", gorm.Expr("held_balance + ?", amount))
+	err = holdBalance.Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if holdBalance.RowsAffected == 0 {
+		err = errs.ErrInsufficientBalance
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	ur.Cache.Del(walletKey(user.ID, currency))
+
+	return mutation, nil
+}
+
+// CompleteWithdrawal releases the hold of a pending withdrawal. A settled withdrawal
+// deducts the held amount from the balance and is posted to the ledger, a failed one
+// gives it back. Holds themselves are not posted, they only live on the wallet.
+func (ur *UserBalanceRepoWrite) CompleteWithdrawal(refID string, status models.MutationStatus) (err error) {
+
+	tx := ur.DBWrite.Begin()
+
+	mutation := models.Mutation{}
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&models.Mutation{RefID: refID, MutationType: models.WITHDRAWAL}).
+		First(&mutation).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrWithdrawalNotFound
+		}
+		return
+	}
+
+	if mutation.Status != models.PENDING {
+		err = errs.ErrWithdrawalAlreadyCompleted
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Model(&mutation).UpdateColumn("status", status).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	updates := map[string]interface{}{
+		"held_balance": gorm.Expr("held_balance - ?", mutation.Value),
+	}
+	if status == models.SETTLED {
+		updates["balance"] = gorm.Expr("balance - ?", mutation.Value)
+	}
+	err = tx.Debug().Model(&models.Wallet{}).
+		Where("user_id = ? AND currency = ?", mutation.UserID, mutation.Currency).
+		UpdateColumns(updates).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if status == models.SETTLED {
+		mutation.Status = status
+		err = postJournalEntry(tx, mutation)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	ur.Cache.Del(walletKey(mutation.UserID, mutation.Currency))
+
+	return nil
+}
+
+// HoldEscrow moves the amount of params from the wallet of payer into an escrow for
+// payee. The RefID of the hold identifies the escrow. The payer and the payee are
+// locked like the accounts of a transfer, a payee that can not receive money is
+// ErrDestinationUnavailable.
+func (ur *UserBalanceRepoWrite) HoldEscrow(payer models.User, payee models.User, params models.EscrowParams) (escrow models.Escrow, err error) {
+
+	mutation := models.NewEscrowHoldMutation(payer, params.Amount, params.Currency)
+	tx := ur.DBWrite.Begin()
+
+	err = lockTransferAccounts(tx, payer.ID, payee.ID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
 	err = tx.Debug().Create(&mutation).Error
 	if err != nil {
 		log.Println(err)
@@ -37,13 +585,37 @@ func (ur *UserBalanceRepoWrite) Topup(user models.User, amount uint32) (err erro
 		return
 	}
 
-	err = tx.Debug().Model(&user).UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error
+	err = debitWallet(tx, payer.ID, params.Currency, uint64(params.Amount))
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	escrow = models.Escrow{
+		RefID:     mutation.RefID,
+		PayerID:   payer.ID,
+		PayeeID:   payee.ID,
+		Currency:  params.Currency,
+		Amount:    params.Amount,
+		Memo:      params.Memo,
+		Status:    models.ESCROW_HELD,
+		ReleaseAt: params.ReleaseAt,
+		CreatedAt: mutation.CreatedAt,
+		UpdatedAt: mutation.CreatedAt,
+	}
+	err = tx.Debug().Omit("Payer", "Payee").Create(&escrow).Error
 	if err != nil {
 		log.Println(err)
 		tx.Rollback()
 		return
 	}
 
+	err = postEscrowJournalEntry(tx, escrow.RefID, mutation)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
 	err = tx.Debug().Commit().Error
 	if err != nil {
 		log.Println(err)
@@ -51,66 +623,238 @@ func (ur *UserBalanceRepoWrite) Topup(user models.User, amount uint32) (err erro
 		return
 	}
 
-	ur.Cache.Del(user.Username)
+	ur.Cache.Del(walletKey(payer.ID, params.Currency))
 
-	return nil
+	escrow.Payer = payer
+	escrow.Payee = payee
+	return escrow, nil
+}
+
+// lockEscrowAccounts locks the payer and the payee of escrow in ID order, like
+// lockTransferAccounts, so a closure of either waits for the settlement. The one
+// paid, userID, is ErrDestinationUnavailable when it can not be credited.
+func lockEscrowAccounts(tx *gorm.DB, escrow models.Escrow, userID uint) error {
+	lockPaidUser := func() error {
+		err := lockAccount(tx, userID, models.CREDIT)
+		if err == errs.ErrAccountFrozen || err == errs.ErrAccountClosed {
+			return errs.ErrDestinationUnavailable
+		}
+		return err
+	}
+	otherID := escrow.PayerID
+	if userID == escrow.PayerID {
+		otherID = escrow.PayeeID
+	}
+	lockOtherUser := func() error {
+		err := tx.Debug().
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&models.User{}, otherID).Error
+		if err != nil {
+			log.Println(err)
+		}
+		return err
+	}
+
+	if otherID < userID {
+		err := lockOtherUser()
+		if err != nil {
+			return err
+		}
+		return lockPaidUser()
+	}
+
+	err := lockPaidUser()
+	if err != nil {
+		return err
+	}
+	return lockOtherUser()
 }
 
-func (ur *UserBalanceRepoWrite) Transfer(user models.User, amount uint32, destUser models.User) (err error) {
+// SettleEscrow pays the money of an escrow out, to the payee when outcome is RELEASED
+// or back to the payer when it is REFUNDED. The escrow is locked and checked against
+// party first, so it is settled once. Both parties are locked, and the account paid
+// is refused when it can not receive money. A non nil audit is recorded with the settlement ref id.
+func (ur *UserBalanceRepoWrite) SettleEscrow(refID string, party models.EscrowParty, outcome models.EscrowStatus, audit *models.AuditLog) (escrow models.Escrow, err error) {
 
-	mutationOutgoing, mutationIncoming := models.NewTransferBalanceMutation(user, amount, destUser)
 	tx := ur.DBWrite.Begin()
 
-	err = tx.Debug().Create(&mutationOutgoing).Error
+	escrow, err = lockEscrow(tx, refID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = escrow.CheckSettlement(party, outcome, utils.TimeNowUTC())
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	userID := escrow.PayeeID
+	if outcome == models.ESCROW_REFUNDED {
+		userID = escrow.PayerID
+	}
+	err = lockEscrowAccounts(tx, escrow, userID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	mutation := models.NewEscrowSettlementMutation(models.User{ID: userID}, escrow.Amount, escrow.Currency, outcome)
+	err = tx.Debug().Create(&mutation).Error
 	if err != nil {
 		log.Println(err)
 		tx.Rollback()
 		return
 	}
 
-	deductBalance := tx.Debug().Model(&user).Where("balance >= ?", amount).UpdateColumn("balance", gorm.Expr("balance - ?", amount))
-	if deductBalance.Error != nil {
+	err = creditWallet(tx, userID, escrow.Currency, escrow.Amount)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	escrow.Status = outcome
+	escrow.SettledBy = party
+	escrow.SettlementRefID = mutation.RefID
+	escrow.SettledAt = &mutation.CreatedAt
+	escrow.UpdatedAt = mutation.CreatedAt
+	err = tx.Debug().Model(&models.Escrow{RefID: escrow.RefID}).Updates(map[string]interface{}{
+		"status":            escrow.Status,
+		"settled_by":        escrow.SettledBy,
+		"settlement_ref_id": escrow.SettlementRefID,
+		"settled_at":        escrow.SettledAt,
+		"updated_at":        escrow.UpdatedAt,
+	}).Error
+	if err != nil {
 		log.Println(err)
 		tx.Rollback()
 		return
 	}
 
-	if deductBalance.RowsAffected == 0 {
-		err = errs.ErrInsufficientBalance
+	err = postEscrowJournalEntry(tx, escrow.RefID, mutation)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if audit != nil {
+		audit.RefID = mutation.RefID
+	}
+	err = createAuditLog(tx, audit)
+	if err != nil {
 		tx.Rollback()
 		return
 	}
 
-	updateTotalOutgoing := tx.Debug().Model(&models.UserTotalOutgoing{}).Where("user_id = ?", user.ID).UpdateColumn("value", gorm.Expr("value + ?", amount))
-	if updateTotalOutgoing.Error != nil {
+	err = tx.Debug().Commit().Error
+	if err != nil {
 		log.Println(err)
 		tx.Rollback()
 		return
 	}
 
-	if updateTotalOutgoing.RowsAffected == 0 {
-		err = errs.ErrInternalServer
+	ur.Cache.Del(walletKey(userID, escrow.Currency))
+
+	return escrow, nil
+}
+
+// MarkEscrowReleaseAttempted records a failed release of a held escrow at its deadline.
+func (ur *UserBalanceRepoWrite) MarkEscrowReleaseAttempted(refID string) error {
+	err := ur.DBWrite.Debug().Model(&models.Escrow{}).
+		Where("ref_id = ? AND status = ?", refID, models.ESCROW_HELD).
+		UpdateColumn("release_attempted_at", utils.TimeNowUTC()).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// DisputeEscrow stops a held escrow from being released at its deadline, leaving it
+// to the parties or an arbitrator.
+func (ur *UserBalanceRepoWrite) DisputeEscrow(refID string, party models.EscrowParty) (escrow models.Escrow, err error) {
+
+	tx := ur.DBWrite.Begin()
+
+	escrow, err = lockEscrow(tx, refID)
+	if err != nil {
 		tx.Rollback()
 		return
 	}
 
-	err = tx.Debug().Create(&mutationIncoming).Error
+	err = escrow.CheckDispute(party)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	escrow.Status = models.ESCROW_DISPUTED
+	escrow.DisputedBy = party
+	escrow.UpdatedAt = utils.TimeNowUTC()
+	err = tx.Debug().Model(&models.Escrow{RefID: escrow.RefID}).Updates(map[string]interface{}{
+		"status":      escrow.Status,
+		"disputed_by": escrow.DisputedBy,
+		"updated_at":  escrow.UpdatedAt,
+	}).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
 	if err != nil {
 		log.Println(err)
 		tx.Rollback()
 		return
 	}
+	return escrow, nil
+}
+
+// Adjust credits or debits the wallet of user outside the regular flows, balanced in
+// the ledger against the adjustments account. A credit opens the wallet if needed,
+// a debit can not exceed the available balance. The user is locked first, a closed
+// account is ErrAccountClosed.
+func (ur *UserBalanceRepoWrite) Adjust(user models.User, params models.AdjustmentParams) (mutation models.Mutation, err error) {
+
+	mutation = models.NewAdjustmentMutation(user, params.Amount, params.Currency, params.Direction)
+	tx := ur.DBWrite.Begin()
+
+	err = lockOpenAccounts(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
 
-	addBalance := tx.Debug().Model(&destUser).UpdateColumn("balance", gorm.Expr("balance + ?", amount))
-	err = addBalance.Error
+	err = tx.Debug().Create(&mutation).Error
 	if err != nil {
 		log.Println(err)
 		tx.Rollback()
 		return
 	}
 
-	if addBalance.RowsAffected == 0 {
-		err = errs.ErrDestinationUserNotFound
+	if params.Direction == models.DEBIT {
+		err = debitWallet(tx, user.ID, params.Currency, uint64(params.Amount))
+	} else {
+		err = topupWallet(tx, user.ID, params.Currency, params.Amount)
+	}
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = postJournalEntry(tx, mutation)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if params.Audit != nil {
+		params.Audit.RefID = mutation.RefID
+	}
+	err = createAuditLog(tx, params.Audit)
+	if err != nil {
 		tx.Rollback()
 		return
 	}
@@ -122,14 +866,206 @@ func (ur *UserBalanceRepoWrite) Transfer(user models.User, amount uint32, destUs
 		return
 	}
 
-	ur.Cache.Del(user.Username)
-	ur.Cache.Del(destUser.Username)
-	ur.Cache.Del(TopUser)
-	ur.Cache.Del(fmt.Sprintf(TopTrsPrefix, user.Username))
+	ur.Cache.Del(walletKey(user.ID, params.Currency))
+
+	return mutation, nil
+}
+
+// checkNoOpenPotsOrEscrows refuses to close the account of userID while it owns an
+// open pot or is owed a refund from one, or is the payer or payee of an escrow not
+// settled yet. Pots and escrows lock the accounts they pay into, after the account
+// is locked here they can not pay into it unseen.
+func checkNoOpenPotsOrEscrows(tx *gorm.DB, userID uint) error {
+	var count int64
+	err := tx.Debug().Model(&models.Pot{}).
+		Where("status = ?", models.POT_OPEN).
+		Where("owner_id = ? OR EXISTS (SELECT 1 FROM pot_members pm WHERE pm.pot_id = pots.id AND pm.user_id = ? AND pm.contributed > 0)", userID, userID).
+		Count(&count).Error
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if count > 0 {
+		return errs.ErrAccountHasOpenPots
+	}
 
+	err = tx.Debug().Model(&models.Escrow{}).
+		Where("status IN ?", []models.EscrowStatus{models.ESCROW_HELD, models.ESCROW_DISPUTED}).
+		Where("payer_id = ? OR payee_id = ?", userID, userID).
+		Count(&count).Error
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if count > 0 {
+		return errs.ErrAccountHasOpenEscrows
+	}
 	return nil
 }
 
+// CloseAccount sets the account of user to CLOSED. Wallets with money on hold or a
+// negative balance can not be closed. A positive balance is refused too, unless
+// payout is set, then it is paid out in settled withdrawals of at most MaxUint32.
+// An account with open pots or escrows is refused, they would pay into it later.
+// The user and its wallets are locked, so no movement slips in before the closure.
+func (ur *UserBalanceRepoWrite) CloseAccount(user models.User, payout bool, audit *models.AuditLog) (payouts []models.Mutation, err error) {
+
+	tx := ur.DBWrite.Begin()
+
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&user, user.ID).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if user.Status == models.CLOSED {
+		err = errs.ErrAccountClosed
+		tx.Rollback()
+		return
+	}
+
+	err = checkNoOpenPotsOrEscrows(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	wallets := []models.Wallet{}
+	err = tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", user.ID).
+		Find(&wallets).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	for _, wallet := range wallets {
+		if wallet.HeldBalance != 0 || wallet.Balance < 0 || (wallet.Balance > 0 && !payout) {
+			err = errs.ErrBalanceNotZero
+			tx.Rollback()
+			return
+		}
+
+		for remaining := wallet.Balance; remaining > 0; {
+			amount := uint32(math.MaxUint32)
+			if remaining < math.MaxUint32 {
+				amount = uint32(remaining)
+			}
+			remaining -= int64(amount)
+
+			mutation := models.NewPayoutMutation(user, amount, wallet.Currency)
+			err = tx.Debug().Create(&mutation).Error
+			if err != nil {
+				log.Println(err)
+				tx.Rollback()
+				return
+			}
+
+			err = debitWallet(tx, user.ID, wallet.Currency, uint64(amount))
+			if err != nil {
+				tx.Rollback()
+				return
+			}
+
+			err = postJournalEntry(tx, mutation)
+			if err != nil {
+				tx.Rollback()
+				return
+			}
+			payouts = append(payouts, mutation)
+		}
+	}
+
+	err = tx.Debug().Model(&user).UpdateColumn("status", models.CLOSED).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = createAuditLog(tx, audit)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Debug().Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	ur.Cache.Del(user.Username)
+	for _, wallet := range wallets {
+		ur.Cache.Del(walletKey(user.ID, wallet.Currency))
+	}
+
+	return payouts, nil
+}
+
+// saveIdempotencyKey stores the key within the mutation transaction, so a key is only
+// persisted when its mutation is committed. A concurrent request with the same key
+// blocks on the unique index until the first transaction finishes.
+func (ur *UserBalanceRepoWrite) saveIdempotencyKey(tx *gorm.DB, idempotencyKey *models.IdempotencyKey) (err error) {
+	if idempotencyKey == nil {
+		return nil
+	}
+	err = tx.Debug().Create(idempotencyKey).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// saveIdempotentResponse stores the response body of a request whose result is
+// known before it is committed, so a replay returns the same body.
+func (ur *UserBalanceRepoWrite) saveIdempotentResponse(tx *gorm.DB, idempotencyKey *models.IdempotencyKey, resp interface{}) (err error) {
+	if idempotencyKey == nil {
+		return nil
+	}
+	idempotencyKey.ResponseBody, err = json.Marshal(resp)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	err = tx.Debug().Model(idempotencyKey).UpdateColumn("response_body", idempotencyKey.ResponseBody).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// checkIdempotencyKey loads the stored result of a duplicate key into idempotencyKey
+// and returns ErrIdempotencyReplay, or ErrIdempotencyKeyMismatch when the key was
+// used for a different request.
+func (ur *UserBalanceRepoWrite) checkIdempotencyKey(idempotencyKey *models.IdempotencyKey, saveErr error) (err error) {
+	if !strings.Contains(saveErr.Error(), DuplicateKey) {
+		return saveErr
+	}
+
+	stored := models.IdempotencyKey{}
+	err = ur.DBWrite.Debug().
+		Where(&models.IdempotencyKey{UserID: idempotencyKey.UserID, Key: idempotencyKey.Key}).
+		First(&stored).Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if stored.RequestHash != idempotencyKey.RequestHash {
+		return errs.ErrIdempotencyKeyMismatch
+	}
+
+	*idempotencyKey = stored
+	return errs.ErrIdempotencyReplay
+}
+
 /*
  */
 
@@ -140,6 +1076,7 @@ type UserBalanceRepoRead struct {
 
 type IUserBalanceRepoRead interface {
 	GetTopTransactionResult(user models.User) (data []models.TopTransactionResult, err error)
+	GetMutations(filter models.MutationHistoryFilter) (data []models.MutationHistoryResult, err error)
 }
 
 func (ubr *UserBalanceRepoRead) GetTopTransactionResult(user models.User) (data []models.TopTransactionResult, err error) {
@@ -158,13 +1095,16 @@ func (ubr *UserBalanceRepoRead) GetTopTransactionResult(user models.User) (data
 		userJoinMutation := ubr.DBRead.Debug().
 			Table("users as u").
 			Order("m2.value desc").
-			Select("u.username, m2.ref_id , m2.mutation_type ,m2.value").
+			Select("m2.id, u.username, m2.ref_id , m2.mutation_type, m2.currency, m2.value, m2.memo, "+
+				"coalesce(m2.external_ref, '') as external_ref").
 			Joins("join mutations m on m.user_id = u.id").
 			Joins(
 				"join (?) as m2 on m2.ref_id = m.ref_id "+
 					"and m.user_id != m2.user_id",
 				userMutations,
 			).
+			// the fee of a transfer shares its ref id, only the counterpart leg is joined
+			Where("m.mutation_type IN ?", models.TransferMutationTypes).
 			Scan(&data)
 
 		err = userJoinMutation.Error
@@ -173,6 +1113,19 @@ func (ubr *UserBalanceRepoRead) GetTopTransactionResult(user models.User) (data
 			return
 		}
 
+		ids := make([]uint, len(data))
+		for i, v := range data {
+			ids[i] = v.ID
+		}
+		var tags map[uint][]string
+		tags, err = ubr.getMutationTags(ids)
+		if err != nil {
+			return
+		}
+		for i, v := range data {
+			data[i].Tags = tags[v.ID]
+		}
+
 		bData, err = json.Marshal(&data)
 		if err != nil {
 			log.Println(err)
@@ -192,3 +1145,91 @@ func (ubr *UserBalanceRepoRead) GetTopTransactionResult(user models.User) (data
 
 	return
 }
+
+// GetMutations lists mutations of a user newest first using keyset pagination on
+// (created_at, id), so deep pages cost the same as the first one.
+func (ubr *UserBalanceRepoRead) GetMutations(filter models.MutationHistoryFilter) (data []models.MutationHistoryResult, err error) {
+
+	query := ubr.DBRead.Debug().
+		Table("mutations as m").
+		Select("m.id, m.ref_id, m.mutation_type, m.status, m.currency, m.value, m.order_ref, m.memo, "+
+			"coalesce(m.external_ref, '') as external_ref, m.created_at, u.username as counterparty").
+		Joins("left join mutations c on c.ref_id = m.ref_id "+
+			"and c.user_id != m.user_id "+
+			"and c.mutation_type IN ?",
+			models.TransferMutationTypes).
+		Joins("left join users u on u.id = c.user_id").
+		Where("m.user_id = ?", filter.UserID)
+
+	if filter.MutationType != "" {
+		query = query.Where("m.mutation_type = ?", filter.MutationType)
+	}
+	if filter.Currency != "" {
+		query = query.Where("m.currency = ?", filter.Currency)
+	}
+	if filter.From != nil {
+		query = query.Where("m.created_at >= ?", filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("m.created_at < ?", filter.To)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("m.value >= ?", filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("m.value <= ?", filter.MaxAmount)
+	}
+	if filter.Tag != "" {
+		query = query.Where("exists (select 1 from mutation_tags t where t.mutation_id = m.id and t.tag = ?)", filter.Tag)
+	}
+	if filter.ExternalRef != "" {
+		query = query.Where("m.external_ref = ?", filter.ExternalRef)
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(m.created_at < ? or (m.created_at = ? and m.id < ?))",
+			filter.Cursor.CreatedAt, filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+
+	err = query.
+		Order("m.created_at desc").
+		Order("m.id desc").
+		Limit(filter.Limit).
+		Scan(&data).Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	ids := make([]uint, len(data))
+	for i, v := range data {
+		ids[i] = v.ID
+	}
+	tags, err := ubr.getMutationTags(ids)
+	if err != nil {
+		return
+	}
+	for i, v := range data {
+		data[i].Tags = tags[v.ID]
+	}
+
+	return
+}
+
+// getMutationTags returns the tags of the mutations ids by mutation ID.
+func (ubr *UserBalanceRepoRead) getMutationTags(ids []uint) (tags map[uint][]string, err error) {
+	tags = map[uint][]string{}
+	if len(ids) == 0 {
+		return
+	}
+
+	var mutationTags []models.MutationTag
+	err = ubr.DBRead.Debug().Where("mutation_id IN ?", ids).Order("id").Find(&mutationTags).Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, v := range mutationTags {
+		tags[v.MutationID] = append(tags[v.MutationID], v.Tag)
+	}
+	return
+}
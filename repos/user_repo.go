@@ -2,18 +2,27 @@ package repos
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"time"
 
+	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
 	"github.com/go-redis/redis"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
-	DuplicateKey string = "1062"
-	TopUser      string = "top_user"
+	DuplicateKey  string = "1062"
+	TopUserPrefix string = "top_user_%v"
 )
 
+func topUserKey(currency string) string {
+	return fmt.Sprintf(TopUserPrefix, currency)
+}
+
 type UserRepoRead struct {
 	DBRead *gorm.DB
 	Cache  ICache
@@ -21,7 +30,7 @@ type UserRepoRead struct {
 
 type IUserRepoRead interface {
 	GetUser(user *models.User) error
-	GetListTopUser() (data []models.UserTotalOutgoingResult, err error)
+	GetListTopUser(currency string) (data []models.UserTotalOutgoingResult, err error)
 }
 
 func (ur *UserRepoRead) GetUser(user *models.User) error {
@@ -53,9 +62,10 @@ func (ur *UserRepoRead) GetUser(user *models.User) error {
 	return nil
 }
 
-func (ur *UserRepoRead) GetListTopUser() (data []models.UserTotalOutgoingResult, err error) {
+func (ur *UserRepoRead) GetListTopUser(currency string) (data []models.UserTotalOutgoingResult, err error) {
 
-	bData, err := ur.Cache.Get(TopUser)
+	key := topUserKey(currency)
+	bData, err := ur.Cache.Get(key)
 	if err == redis.Nil {
 
 		userOutgoing := ur.DBRead.Debug().
@@ -64,6 +74,7 @@ func (ur *UserRepoRead) GetListTopUser() (data []models.UserTotalOutgoingResult,
 			Order("value desc").
 			Select("utg.*, u.*").
 			Joins("join users u on utg.user_id = u.id").
+			Where("utg.currency = ?", currency).
 			Scan(&data)
 
 		err = userOutgoing.Error
@@ -78,7 +89,7 @@ func (ur *UserRepoRead) GetListTopUser() (data []models.UserTotalOutgoingResult,
 			return
 		}
 
-		ur.Cache.Set(TopUser, bData)
+		ur.Cache.Set(key, bData)
 		return
 	}
 
@@ -101,10 +112,18 @@ type UserRepoWrite struct {
 }
 
 type IUserRepoWrite interface {
-	Create(user *models.User) error
+	Create(user *models.User, currency string) error
+	GetCredentials(user *models.User) error
+	UpdateCredentials(user models.User, revokeRefreshTokens bool) error
+	RecordCredentialFailure(user *models.User, credential models.Credential, maxAttempts int, lockout time.Duration) (locked bool, err error)
+	ResetCredentialFailures(user *models.User, credential models.Credential) error
+	SetStatus(user models.User, status models.AccountStatus, audit models.AuditLog) error
+	SetRole(user models.User, role models.Role, audit models.AuditLog) error
+	SetMerchant(user models.User, displayName string, settlementCurrency string) error
 }
 
-func (ur *UserRepoWrite) Create(user *models.User) error {
+// Create registers a user together with an empty wallet in the given currency.
+func (ur *UserRepoWrite) Create(user *models.User, currency string) error {
 
 	tx := ur.DBWrite.Debug().Begin()
 
@@ -114,7 +133,19 @@ func (ur *UserRepoWrite) Create(user *models.User) error {
 		return err
 	}
 
-	err = tx.Create(&models.UserTotalOutgoing{UserID: user.ID}).Error
+	err = tx.Create(&models.Wallet{UserID: user.ID, Currency: currency}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Create(&models.UserTotalOutgoing{UserID: user.ID, Currency: currency}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = createOutboxEvents(tx, models.NewUserCreatedEvent(*user, currency))
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -135,3 +166,153 @@ func (ur *UserRepoWrite) Create(user *models.User) error {
 
 	return nil
 }
+
+// GetCredentials loads the user with its credentials and lockout state. They are
+// not cached, so it always reads the write database.
+func (ur *UserRepoWrite) GetCredentials(user *models.User) error {
+	err := ur.DBWrite.Debug().Where(user).First(user).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+// UpdateCredentials stores the password and PIN hashes of user. With revokeRefreshTokens,
+// as when the password changes, every refresh token of the user is revoked along.
+func (ur *UserRepoWrite) UpdateCredentials(user models.User, revokeRefreshTokens bool) error {
+	tx := ur.DBWrite.Debug().Begin()
+
+	err := tx.Model(&models.User{ID: user.ID}).
+		Select("password_hash", "pin_hash").
+		Updates(&user).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return err
+	}
+
+	if revokeRefreshTokens {
+		err = tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			UpdateColumn("revoked_at", utils.TimeNowUTC()).Error
+		if err != nil {
+			log.Println(err)
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+	}
+	return err
+}
+
+// RecordCredentialFailure counts a failed attempt of credential. Reaching maxAttempts
+// locks the credential for lockout and starts counting again from zero.
+func (ur *UserRepoWrite) RecordCredentialFailure(user *models.User, credential models.Credential, maxAttempts int, lockout time.Duration) (locked bool, err error) {
+	tx := ur.DBWrite.Debug().Begin()
+
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(user, user.ID).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	attempts := user.FailedAttempts(credential) + 1
+	updates := map[string]interface{}{credential.AttemptsColumn(): attempts}
+	locked = maxAttempts > 0 && attempts >= maxAttempts
+	if locked {
+		updates[credential.AttemptsColumn()] = 0
+		updates[credential.LockedUntilColumn()] = utils.TimeNowUTC().Add(lockout)
+	}
+
+	err = tx.Model(user).Updates(updates).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		log.Println(err)
+		locked = false
+	}
+	return
+}
+
+func (ur *UserRepoWrite) ResetCredentialFailures(user *models.User, credential models.Credential) error {
+	err := ur.DBWrite.Debug().Model(user).Updates(map[string]interface{}{
+		credential.AttemptsColumn():    0,
+		credential.LockedUntilColumn(): nil,
+	}).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (ur *UserRepoWrite) SetStatus(user models.User, status models.AccountStatus, audit models.AuditLog) error {
+	return ur.update(user, map[string]interface{}{"status": status}, &audit)
+}
+
+func (ur *UserRepoWrite) SetRole(user models.User, role models.Role, audit models.AuditLog) error {
+	return ur.update(user, map[string]interface{}{"role": role}, &audit)
+}
+
+// SetMerchant makes user a merchant, or changes its merchant settings.
+func (ur *UserRepoWrite) SetMerchant(user models.User, displayName string, settlementCurrency string) error {
+	return ur.update(user, map[string]interface{}{
+		"account_type":        models.MERCHANT,
+		"display_name":        displayName,
+		"settlement_currency": settlementCurrency,
+	}, nil)
+}
+
+// update updates columns of user together with its audit log, when audit is not nil,
+// and drops the cached user so the change applies right away. A closed account is
+// final and is left untouched.
+func (ur *UserRepoWrite) update(user models.User, updates map[string]interface{}, audit *models.AuditLog) (err error) {
+	tx := ur.DBWrite.Debug().Begin()
+
+	current := models.User{}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, user.ID).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if current.Status == models.CLOSED {
+		tx.Rollback()
+		return errs.ErrAccountClosed
+	}
+
+	err = tx.Model(&models.User{ID: user.ID}).Updates(updates).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	if audit != nil {
+		err = createAuditLog(tx, audit)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	ur.Cache.Del(user.Username)
+	return
+}
@@ -0,0 +1,238 @@
+package repos
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/go-redis/redis"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	WalletPrefix = "wallet_%v_%v"
+)
+
+func walletKey(userID uint, currency string) string {
+	return fmt.Sprintf(WalletPrefix, userID, currency)
+}
+
+type WalletRepoRead struct {
+	DBRead *gorm.DB
+	Cache  ICache
+}
+
+type IWalletRepoRead interface {
+	GetWallet(wallet *models.Wallet) error
+	GetWallets(user models.User) (data []models.Wallet, err error)
+}
+
+func (wr *WalletRepoRead) GetWallet(wallet *models.Wallet) error {
+	key := walletKey(wallet.UserID, wallet.Currency)
+	bWallet, err := wr.Cache.Get(key)
+	if err == redis.Nil {
+		err = wr.DBRead.Debug().
+			Where(&models.Wallet{UserID: wallet.UserID, Currency: wallet.Currency}).
+			First(wallet).Error
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+
+		bWallet, err = json.Marshal(&wallet)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+
+		wr.Cache.Set(key, bWallet)
+		return nil
+	}
+
+	if bWallet != nil {
+		err = json.Unmarshal(bWallet, &wallet)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (wr *WalletRepoRead) GetWallets(user models.User) (data []models.Wallet, err error) {
+	err = wr.DBRead.Debug().
+		Where(&models.Wallet{UserID: user.ID}).
+		Order("currency").
+		Find(&data).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+/*
+ */
+
+type WalletRepoWrite struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IWalletRepoWrite interface {
+	Create(wallet *models.Wallet) error
+}
+
+func (wr *WalletRepoWrite) Create(wallet *models.Wallet) error {
+	err := wr.DBWrite.Debug().Create(wallet).Error
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	wr.Cache.Del(walletKey(wallet.UserID, wallet.Currency))
+	return nil
+}
+
+// lockAccount locks the user of userID within tx and checks its status lets money
+// move in direction, out of the account for a DEBIT and into it for a CREDIT. The
+// status read before the transaction may be outdated, CloseAccount takes the same
+// lock, so money can not move in or out of an account being closed.
+func lockAccount(tx *gorm.DB, userID uint, direction models.PostingDirection) error {
+	user := models.User{}
+	err := tx.Debug().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "status").
+		First(&user, userID).Error
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	allowed := user.Status.CanCredit()
+	if direction == models.DEBIT {
+		allowed = user.Status.CanDebit()
+	}
+	if allowed {
+		return nil
+	}
+	if user.Status == models.CLOSED {
+		return errs.ErrAccountClosed
+	}
+	return errs.ErrAccountFrozen
+}
+
+// lockTransferAccounts locks the sender and the recipient of a transfer with
+// lockAccount, in ID order so two transfers between the same accounts do not deadlock.
+// A recipient that can not be credited is ErrDestinationUnavailable.
+func lockTransferAccounts(tx *gorm.DB, userID uint, destUserID uint) error {
+	lockDestUser := func() error {
+		err := lockAccount(tx, destUserID, models.CREDIT)
+		if err == errs.ErrAccountFrozen || err == errs.ErrAccountClosed {
+			return errs.ErrDestinationUnavailable
+		}
+		return err
+	}
+
+	if destUserID < userID {
+		err := lockDestUser()
+		if err != nil {
+			return err
+		}
+		return lockAccount(tx, userID, models.DEBIT)
+	}
+
+	err := lockAccount(tx, userID, models.DEBIT)
+	if err != nil {
+		return err
+	}
+	return lockDestUser()
+}
+
+// lockOpenAccounts locks the users of userIDs within tx, in ID order so two corrections
+// of the same accounts do not deadlock, and refuses a closed one. An admin correction
+// is not refused by a freeze, it is often the reason for one.
+func lockOpenAccounts(tx *gorm.DB, userIDs ...uint) error {
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	for _, userID := range userIDs {
+		user := models.User{}
+		err := tx.Debug().
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "status").
+			First(&user, userID).Error
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if user.Status == models.CLOSED {
+			return errs.ErrAccountClosed
+		}
+	}
+	return nil
+}
+
+// debitWallet deducts amount from the available balance of a wallet within tx.
+func debitWallet(tx *gorm.DB, userID uint, currency string, amount uint64) error {
+	deductBalance := tx.Debug().Model(&models.Wallet{}).
+		Where("user_id = ? AND currency = ?", userID, currency).
+		Where("balance - held_balance >= ?", amount).
+		UpdateColumn("balance", gorm.Expr("balance - ?", amount))
+	if deductBalance.Error != nil {
+		log.Println(deductBalance.Error)
+		return deductBalance.Error
+	}
+
+	if deductBalance.RowsAffected == 0 {
+		return errs.ErrInsufficientBalance
+	}
+	return nil
+}
+
+// creditWallet adds amount to an existing wallet within tx. Money can only be sent
+// to a wallet the destination user holds in the same currency.
+func creditWallet(tx *gorm.DB, userID uint, currency string, amount uint32) error {
+	addBalance := tx.Debug().Model(&models.Wallet{}).
+		Where("user_id = ? AND currency = ?", userID, currency).
+		UpdateColumn("balance", gorm.Expr("balance + ?", amount))
+	if addBalance.Error != nil {
+		log.Println(addBalance.Error)
+		return addBalance.Error
+	}
+
+	if addBalance.RowsAffected == 0 {
+		return errs.ErrCurrencyMismatch
+	}
+	return nil
+}
+
+// topupWallet adds amount to a wallet within tx, opening the wallet when the user
+// does not hold one in that currency yet.
+func topupWallet(tx *gorm.DB, userID uint, currency string, amount uint32) error {
+	err := tx.Debug().
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"balance": gorm.Expr("balance + ?", amount)}),
+		}).
+		Create(&models.Wallet{UserID: userID, Currency: currency, Balance: int64(amount)}).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// addTotalOutgoing accumulates the outgoing value of a user per currency within tx.
+func addTotalOutgoing(tx *gorm.DB, userID uint, currency string, amount uint32) error {
+	err := tx.Debug().
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"value": gorm.Expr("value + ?", amount)}),
+		}).
+		Create(&models.UserTotalOutgoing{UserID: userID, Currency: currency, Value: uint64(amount)}).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
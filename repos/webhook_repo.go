@@ -0,0 +1,193 @@
+package repos
+
+import (
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+type WebhookRepo struct {
+	DBWrite *gorm.DB
+	Cache   ICache
+}
+
+type IWebhookRepo interface {
+	CreateWebhook(webhook *models.Webhook) error
+	GetWebhooks(userID uint) (webhooks []models.Webhook, err error)
+	DeleteWebhook(userID uint, webhookID uint) (deleted bool, err error)
+	GetDeliveries(userID uint, status models.WebhookDeliveryStatus, limit int) (deliveries []models.WebhookDelivery, err error)
+	GetDueDeliveries(limit int) (deliveries []models.WebhookDelivery, err error)
+	ClaimDelivery(delivery models.WebhookDelivery, lease time.Duration) (bool, error)
+	RecordDeliveryResult(delivery models.WebhookDelivery) error
+	ReplayDelivery(userID uint, deliveryID string) (replayed bool, err error)
+	GetDelivery(delivery *models.WebhookDelivery) error
+}
+
+// createWebhookDeliveries queues event for every webhook of user subscribed to it,
+// within tx, so deliveries only exist for committed changes.
+func createWebhookDeliveries(tx *gorm.DB, userID uint, event models.WebhookEvent, data interface{}) error {
+	webhooks := []models.Webhook{}
+	err := tx.Debug().Where("user_id = ?", userID).Find(&webhooks).Error
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	for _, v := range webhooks {
+		if v.Subscribes(event) {
+			deliveries = append(deliveries, models.NewWebhookDelivery(v, event, data))
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	err = tx.Debug().Omit("Webhook").Create(&deliveries).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (wr *WebhookRepo) CreateWebhook(webhook *models.Webhook) error {
+	err := wr.DBWrite.Debug().Omit("User").Create(webhook).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (wr *WebhookRepo) GetWebhooks(userID uint) (webhooks []models.Webhook, err error) {
+	err = wr.DBWrite.Debug().Where("user_id = ?", userID).Order("id").Find(&webhooks).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// DeleteWebhook removes a webhook of user. It is soft deleted, its deliveries still
+// refer to it. Its pending deliveries go to the dead letters, there is nowhere left
+// to send them.
+func (wr *WebhookRepo) DeleteWebhook(userID uint, webhookID uint) (deleted bool, err error) {
+	tx := wr.DBWrite.Debug().Begin()
+
+	result := tx.Where("id = ? AND user_id = ?", webhookID, userID).Delete(&models.Webhook{})
+	if result.Error != nil {
+		err = result.Error
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Model(&models.WebhookDelivery{}).
+		Where("webhook_id = ? AND status = ?", webhookID, models.DELIVERY_PENDING).
+		Updates(map[string]interface{}{
+			"status":     models.DELIVERY_DEAD,
+			"last_error": "Webhook deleted",
+		}).Error
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	return true, nil
+}
+
+// GetDeliveries returns the latest deliveries of user, of any status when status is empty.
+func (wr *WebhookRepo) GetDeliveries(userID uint, status models.WebhookDeliveryStatus, limit int) (deliveries []models.WebhookDelivery, err error) {
+	query := wr.DBWrite.Debug().Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err = query.Order("id DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+func (wr *WebhookRepo) GetDelivery(delivery *models.WebhookDelivery) error {
+	err := wr.DBWrite.Debug().Where(delivery).First(delivery).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+	}
+	return err
+}
+
+// GetDueDeliveries returns pending deliveries whose next attempt is due, with their webhook.
+func (wr *WebhookRepo) GetDueDeliveries(limit int) (deliveries []models.WebhookDelivery, err error) {
+	err = wr.DBWrite.Debug().
+		Preload("Webhook").
+		Where("status = ? AND next_attempt_at <= ?", models.DELIVERY_PENDING, utils.TimeNowUTC()).
+		Order("id").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// ClaimDelivery counts the attempt about to be made and postpones the next one by
+// lease, so a concurrent dispatcher does not send the same delivery meanwhile. It
+// reports false when another dispatcher claimed it first.
+func (wr *WebhookRepo) ClaimDelivery(delivery models.WebhookDelivery, lease time.Duration) (bool, error) {
+	result := wr.DBWrite.Debug().Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", delivery.ID, models.DELIVERY_PENDING, delivery.Attempts).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": utils.TimeNowUTC().Add(lease),
+		})
+	if result.Error != nil {
+		log.Println(result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (wr *WebhookRepo) RecordDeliveryResult(delivery models.WebhookDelivery) error {
+	err := wr.DBWrite.Debug().Model(&models.WebhookDelivery{ID: delivery.ID}).Updates(map[string]interface{}{
+		"status":           delivery.Status,
+		"next_attempt_at":  delivery.NextAttemptAt,
+		"last_status_code": delivery.LastStatusCode,
+		"last_error":       delivery.LastError,
+		"delivered_at":     delivery.DeliveredAt,
+	}).Error
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// ReplayDelivery sends a delivered or dead delivery of user again, with a fresh
+// count of attempts. A pending delivery is left alone.
+func (wr *WebhookRepo) ReplayDelivery(userID uint, deliveryID string) (replayed bool, err error) {
+	result := wr.DBWrite.Debug().Model(&models.WebhookDelivery{}).
+		Where("delivery_id = ? AND user_id = ? AND status <> ?", deliveryID, userID, models.DELIVERY_PENDING).
+		Where("EXISTS (SELECT 1 FROM webhooks w WHERE w.id = webhook_deliveries.webhook_id AND w.deleted_at IS NULL)").
+		Updates(map[string]interface{}{
+			"status":          models.DELIVERY_PENDING,
+			"attempts":        0,
+			"next_attempt_at": utils.TimeNowUTC(),
+			"last_error":      "",
+		})
+	if result.Error != nil {
+		log.Println(result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"gorm.io/gorm"
+)
+
+type AdminService struct {
+	UserRepoRead       repos.IUserRepoRead
+	UserRepoWrite      repos.IUserRepoWrite
+	UserBalanceService IUserBalanceService
+}
+
+type IAdminService interface {
+	GetUser(username string) (resp models.AdminUserResponse, err error)
+	GetMutations(username string, filter models.MutationHistoryFilter) (resp models.MutationHistoryResponse, err error)
+	SetStatus(actor string, username string, status models.AccountStatus, reason string) error
+	CloseAccount(actor string, username string, payout bool, reason string) (resp models.AccountClosureResponse, err error)
+	Adjust(actor string, username string, req models.AdminAdjustmentRequest) (resp models.AdjustmentResponse, err error)
+	ReverseTransfer(actor string, refID string, reason string) (resp models.ReversalResponse, err error)
+	GrantRole(actor string, username string, role models.Role) error
+}
+
+func (as *AdminService) GetUser(username string) (resp models.AdminUserResponse, err error) {
+	user, err := as.getUser(username)
+	if err != nil {
+		return
+	}
+
+	wallets, err := as.UserBalanceService.GetWallets(user)
+	if err != nil {
+		return
+	}
+
+	resp = models.AdminUserResponse{
+		ID:       user.ID,
+		Username: user.Username,
+		Tier:     user.Tier,
+		Role:     user.Role,
+		Status:   user.Status,
+		Wallets:  wallets,
+	}
+	return
+}
+
+func (as *AdminService) GetMutations(username string, filter models.MutationHistoryFilter) (resp models.MutationHistoryResponse, err error) {
+	user, err := as.getUser(username)
+	if err != nil {
+		return
+	}
+	return as.UserBalanceService.GetMutations(user, filter)
+}
+
+// SetStatus freezes or unfreezes the account of username. Closing an account goes
+// through CloseAccount, which settles its balance first.
+func (as *AdminService) SetStatus(actor string, username string, status models.AccountStatus, reason string) (err error) {
+	if status == models.CLOSED {
+		return errs.ErrInvalidAccountStatus
+	}
+
+	user, err := as.getUser(username)
+	if err != nil {
+		return
+	}
+
+	current := user.Status
+	if current == "" {
+		current = models.ACTIVE
+	}
+	if current == models.CLOSED {
+		return errs.ErrAccountClosed
+	}
+	if !current.CanBecome(status) {
+		return errs.ErrInvalidAccountStatus
+	}
+
+	action := models.ADMIN_FREEZE
+	if status == models.ACTIVE {
+		action = models.ADMIN_UNFREEZE
+	}
+	audit := models.NewAuditLog(actor, action, user.ID, reason, map[string]interface{}{
+		"status": map[string]models.AccountStatus{"from": current, "to": status},
+	})
+
+	err = as.UserRepoWrite.SetStatus(user, status, audit)
+	if err != nil {
+		if err == errs.ErrAccountClosed {
+			return
+		}
+		return errs.ErrInternalServer
+	}
+	return
+}
+
+// CloseAccount closes the account of username for good. Its balance must be zero,
+// or paid out when payout is set.
+func (as *AdminService) CloseAccount(actor string, username string, payout bool, reason string) (resp models.AccountClosureResponse, err error) {
+	user, err := as.getUser(username)
+	if err != nil {
+		return
+	}
+
+	audit := models.NewAuditLog(actor, models.ADMIN_CLOSE, user.ID, reason, map[string]interface{}{
+		"status": map[string]models.AccountStatus{"from": user.Status, "to": models.CLOSED},
+		"payout": payout,
+	})
+	payouts, err := as.UserBalanceService.CloseAccount(user, payout, &audit)
+	if err != nil {
+		return
+	}
+
+	resp = models.AccountClosureResponse{
+		Status:  models.CLOSED,
+		Payouts: []models.WithdrawResponse{},
+	}
+	for _, v := range payouts {
+		resp.Payouts = append(resp.Payouts, models.WithdrawResponse{
+			RefID:    v.RefID,
+			Currency: v.Currency,
+			Amount:   v.Value,
+			Status:   v.Status,
+		})
+	}
+	return
+}
+
+func (as *AdminService) Adjust(actor string, username string, req models.AdminAdjustmentRequest) (resp models.AdjustmentResponse, err error) {
+	user, err := as.getUser(username)
+	if err != nil {
+		return
+	}
+
+	if user.Status == models.CLOSED {
+		err = errs.ErrAccountClosed
+		return
+	}
+
+	audit := models.NewAuditLog(actor, models.ADMIN_ADJUSTMENT, user.ID, req.Reason, map[string]interface{}{
+		"direction": req.Direction,
+		"amount":    req.Amount,
+		"currency":  req.Currency,
+	})
+	mutation, err := as.UserBalanceService.Adjust(user, models.AdjustmentParams{
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Direction: req.Direction,
+		Audit:     &audit,
+	})
+	if err != nil {
+		return
+	}
+
+	resp = models.AdjustmentResponse{
+		RefID:        mutation.RefID,
+		MutationType: mutation.MutationType,
+		Currency:     mutation.Currency,
+		Amount:       mutation.Value,
+	}
+	return
+}
+
+func (as *AdminService) ReverseTransfer(actor string, refID string, reason string) (resp models.ReversalResponse, err error) {
+	audit := models.NewAuditLog(actor, models.ADMIN_REVERSAL, 0, reason, map[string]interface{}{
+		"reversal_of": refID,
+	})
+	reversalRefID, err := as.UserBalanceService.ReverseTransfer(refID, &audit)
+	if err != nil {
+		return
+	}
+
+	resp = models.ReversalResponse{
+		RefID:         refID,
+		ReversalRefID: reversalRefID,
+	}
+	return
+}
+
+// GrantRole gives role to the user, or takes its role away when role is empty. It
+// applies to tokens issued afterwards.
+func (as *AdminService) GrantRole(actor string, username string, role models.Role) (err error) {
+	if role != "" && !role.IsValid() {
+		return errs.ErrInvalidRole
+	}
+
+	user, err := as.getUser(username)
+	if err != nil {
+		return
+	}
+
+	audit := models.NewAuditLog(actor, models.ROLE_GRANT, user.ID, "", map[string]interface{}{
+		"role": map[string]models.Role{"from": user.Role, "to": role},
+	})
+	err = as.UserRepoWrite.SetRole(user, role, audit)
+	if err != nil {
+		if err == errs.ErrAccountClosed {
+			return
+		}
+		return errs.ErrInternalServer
+	}
+	return
+}
+
+func (as *AdminService) getUser(username string) (user models.User, err error) {
+	user = models.User{Username: username}
+	err = as.UserRepoRead.GetUser(&user)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return models.User{}, errs.ErrUserNotFound
+		}
+		return models.User{}, errs.ErrInternalServer
+	}
+	return
+}
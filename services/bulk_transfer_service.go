@@ -0,0 +1,209 @@
+package services
+
+import (
+	"encoding/json"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultBulkTransferMaxItems = 500
+
+	// bulkTransferPath scopes the idempotency keys of bulk transfers.
+	bulkTransferPath = "bulk_transfer"
+)
+
+type BulkTransferService struct {
+	BulkTransferRepo   repos.IBulkTransferRepo
+	UserBalanceService IUserBalanceService
+	MaxItems           int
+}
+
+type IBulkTransferService interface {
+	Create(user models.User, req models.CreateBulkTransferRequest) (resp models.BulkTransferResponse, err error)
+	Get(user models.User, bulkTransferID string) (resp models.BulkTransferResponse, err error)
+	Process(bulkTransfer models.BulkTransfer) (models.BulkTransfer, error)
+	ItemLimit() int
+}
+
+// Create stores a bulk transfer of user from the wallet in the currency of req. An
+// asynchronous bulk transfer is left PENDING for a worker, any other is processed
+// before Create returns.
+func (bs *BulkTransferService) Create(user models.User, req models.CreateBulkTransferRequest) (resp models.BulkTransferResponse, err error) {
+	if len(req.Items) == 0 {
+		err = errs.ErrEmptyBulkTransfer
+		return
+	}
+	if len(req.Items) > bs.ItemLimit() {
+		err = errs.ErrTooManyBulkTransferItems
+		return
+	}
+
+	wallet, err := bs.UserBalanceService.GetBalanceByUsername(user.Username, req.Currency)
+	if err != nil {
+		return
+	}
+
+	bulkTransfer := models.NewBulkTransfer(user, req.Mode, wallet.Currency, req.Items)
+	if !req.Async {
+		bulkTransfer.Status = models.BULK_TRANSFER_PROCESSING
+	}
+	err = bs.BulkTransferRepo.CreateBulkTransfer(&bulkTransfer)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	if !req.Async {
+		bulkTransfer.User = user
+		bulkTransfer, err = bs.Process(bulkTransfer)
+		if err != nil {
+			err = errs.ErrInternalServer
+			return
+		}
+	}
+
+	resp = models.NewBulkTransferResponse(bulkTransfer)
+	return
+}
+
+// Get returns a bulk transfer of user with the result of each item so far.
+func (bs *BulkTransferService) Get(user models.User, bulkTransferID string) (resp models.BulkTransferResponse, err error) {
+	bulkTransfer := models.BulkTransfer{ID: bulkTransferID}
+	err = bs.BulkTransferRepo.GetBulkTransfer(&bulkTransfer)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrBulkTransferNotFound
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+
+	if bulkTransfer.UserID != user.ID {
+		err = errs.ErrBulkTransferNotFound
+		return
+	}
+
+	resp = models.NewBulkTransferResponse(bulkTransfer)
+	return
+}
+
+// Process makes the transfers of a PROCESSING bulk transfer, with its User and Items
+// loaded, and returns it with their results. The error is about saving the results,
+// a refused transfer is a result. A bulk transfer processed again, after its worker
+// stopped, does not make a transfer twice.
+func (bs *BulkTransferService) Process(bulkTransfer models.BulkTransfer) (models.BulkTransfer, error) {
+	if bulkTransfer.Mode == models.ALL_OR_NOTHING {
+		return bs.processAllOrNothing(bulkTransfer)
+	}
+	return bs.processBestEffort(bulkTransfer)
+}
+
+// ItemLimit is the most items a bulk transfer can have.
+func (bs *BulkTransferService) ItemLimit() int {
+	if bs.MaxItems <= 0 {
+		return DefaultBulkTransferMaxItems
+	}
+	return bs.MaxItems
+}
+
+// processAllOrNothing makes all the transfers in one transaction. When one of them is
+// refused none is made, every item fails with its own error or the one of the batch.
+func (bs *BulkTransferService) processAllOrNothing(bulkTransfer models.BulkTransfer) (models.BulkTransfer, error) {
+	params := make([]models.TransferParams, len(bulkTransfer.Items))
+	for i, v := range bulkTransfer.Items {
+		params[i] = bs.transferParams(bulkTransfer, v)
+	}
+
+	idempotencyKey, err := models.NewIdempotencyKey(bulkTransfer.User, bulkTransfer.IdempotencyKey(), bulkTransferPath, params)
+	if err != nil {
+		return bulkTransfer, err
+	}
+
+	resps, itemErrs, err := bs.UserBalanceService.TransferBatch(bulkTransfer.User, params, idempotencyKey)
+	if err == errs.ErrIdempotencyReplay {
+		err = nil
+	}
+
+	bulkTransfer.SucceededItems = 0
+	bulkTransfer.FailedItems = 0
+	if err == nil {
+		for i := range bulkTransfer.Items {
+			bulkTransfer.Succeed(i, resps[i])
+		}
+		bulkTransfer.Status = models.BULK_TRANSFER_COMPLETED
+	} else {
+		for i := range bulkTransfer.Items {
+			cause := err
+			if itemErrs != nil && itemErrs[i] != nil {
+				cause = itemErrs[i]
+			}
+			bulkTransfer.Fail(i, cause)
+		}
+		bulkTransfer.Status = models.BULK_TRANSFER_FAILED
+		bulkTransfer.Error = err.Error()
+	}
+
+	err = bs.BulkTransferRepo.SaveProgress(&bulkTransfer, bulkTransfer.Items...)
+	return bulkTransfer, err
+}
+
+// processBestEffort makes the pending transfers one by one, saving the result of each
+// as it goes so the progress can be polled.
+func (bs *BulkTransferService) processBestEffort(bulkTransfer models.BulkTransfer) (models.BulkTransfer, error) {
+	for i, v := range bulkTransfer.Items {
+		if v.Status != models.BULK_ITEM_PENDING {
+			continue
+		}
+
+		resp, cause := bs.transferItem(bulkTransfer, v)
+		if cause != nil {
+			bulkTransfer.Fail(i, cause)
+		} else {
+			bulkTransfer.Succeed(i, resp)
+		}
+
+		err := bs.BulkTransferRepo.SaveProgress(&bulkTransfer, bulkTransfer.Items[i])
+		if err != nil {
+			return bulkTransfer, err
+		}
+	}
+
+	bulkTransfer.Status = models.BULK_TRANSFER_COMPLETED
+	err := bs.BulkTransferRepo.SaveProgress(&bulkTransfer)
+	return bulkTransfer, err
+}
+
+// transferItem makes the transfer of an item of a best-effort bulk transfer, or loads
+// the one made before the bulk transfer was processed again.
+func (bs *BulkTransferService) transferItem(bulkTransfer models.BulkTransfer, item models.BulkTransferItem) (resp models.TransferResponse, err error) {
+	if item.ToUsername == bulkTransfer.User.Username {
+		err = errs.ErrTransferToSelf
+		return
+	}
+
+	params := bs.transferParams(bulkTransfer, item)
+	params.IdempotencyKey, err = models.NewIdempotencyKey(bulkTransfer.User, bulkTransfer.ItemIdempotencyKey(item), bulkTransferPath, params)
+	if err != nil {
+		return
+	}
+
+	resp, err = bs.UserBalanceService.Transfer(bulkTransfer.User, params)
+	if err == errs.ErrIdempotencyReplay {
+		json.Unmarshal(params.IdempotencyKey.ResponseBody, &resp)
+		err = nil
+	}
+	return
+}
+
+func (bs *BulkTransferService) transferParams(bulkTransfer models.BulkTransfer, item models.BulkTransferItem) models.TransferParams {
+	return models.TransferParams{
+		Amount:     item.Amount,
+		Currency:   bulkTransfer.Currency,
+		ToUsername: item.ToUsername,
+	}
+}
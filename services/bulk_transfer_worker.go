@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/repos"
+)
+
+const (
+	DefaultBulkTransferBatchSize    = 10
+	DefaultBulkTransferPollInterval = 5 * time.Second
+	DefaultBulkTransferStaleAfter   = 10 * time.Minute
+)
+
+// BulkTransferWorker processes the asynchronous bulk transfers. Workers can run side
+// by side, a bulk transfer is claimed by one of them. One left PROCESSING without
+// progress for StaleAfter is claimed again and carries on where it stopped.
+type BulkTransferWorker struct {
+	BulkTransferRepo    repos.IBulkTransferRepo
+	BulkTransferService IBulkTransferService
+	BatchSize           int
+	PollInterval        time.Duration
+	StaleAfter          time.Duration
+}
+
+// Run processes bulk transfers until ctx is done.
+func (bw *BulkTransferWorker) Run(ctx context.Context) {
+	for {
+		processed, err := bw.RunOnce()
+		if err != nil {
+			log.Println(err)
+		}
+		if processed > 0 {
+			log.Printf("%v bulk transfers processed\n", processed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(bw.pollInterval()):
+		}
+	}
+}
+
+// RunOnce processes a batch of claimed bulk transfers and returns how many were processed.
+func (bw *BulkTransferWorker) RunOnce() (processed int, err error) {
+	bulkTransfers, err := bw.BulkTransferRepo.ClaimBulkTransfers(bw.batchSize(), bw.staleAfter())
+	if err != nil {
+		return
+	}
+
+	for _, v := range bulkTransfers {
+		_, err = bw.BulkTransferService.Process(v)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+func (bw *BulkTransferWorker) batchSize() int {
+	if bw.BatchSize <= 0 {
+		return DefaultBulkTransferBatchSize
+	}
+	return bw.BatchSize
+}
+
+func (bw *BulkTransferWorker) pollInterval() time.Duration {
+	if bw.PollInterval <= 0 {
+		return DefaultBulkTransferPollInterval
+	}
+	return bw.PollInterval
+}
+
+func (bw *BulkTransferWorker) staleAfter() time.Duration {
+	if bw.StaleAfter <= 0 {
+		return DefaultBulkTransferStaleAfter
+	}
+	return bw.StaleAfter
+}
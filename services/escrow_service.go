@@ -0,0 +1,310 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultEscrowReleaseAfter         = 7 * 24 * time.Hour
+	DefaultEscrowReleaseRetryInterval = time.Hour
+	DefaultEscrowsLimit               = 50
+)
+
+type EscrowService struct {
+	UserRepoRead       repos.IUserRepoRead
+	EscrowRead         repos.IEscrowRepoRead
+	UserBalanceWrite   repos.IUserBalanceRepoWrite
+	UserBalanceService IUserBalanceService
+	LimitService       ILimitService
+
+	// DefaultReleaseAfter is how long an escrow that does not set its release time is
+	// held, MaxReleaseAfter the furthest release time an escrow can set. A
+	// MaxReleaseAfter of 0 means unbounded.
+	DefaultReleaseAfter time.Duration
+	MaxReleaseAfter     time.Duration
+	// ReleaseRetryInterval is the wait before a failed release at the deadline is
+	// tried again.
+	ReleaseRetryInterval time.Duration
+}
+
+type IEscrowService interface {
+	Create(user models.User, req models.CreateEscrowRequest) (resp models.EscrowResponse, err error)
+	List(user models.User, status models.EscrowStatus) (resp models.EscrowsResponse, err error)
+	Get(user models.User, refID string) (resp models.EscrowResponse, err error)
+	Release(user models.User, refID string) (resp models.EscrowResponse, err error)
+	Refund(user models.User, refID string) (resp models.EscrowResponse, err error)
+	Dispute(user models.User, refID string) (resp models.EscrowResponse, err error)
+	Inspect(refID string) (resp models.EscrowResponse, err error)
+	Resolve(actor string, refID string, outcome models.EscrowStatus, reason string) (resp models.EscrowResponse, err error)
+	ReleaseDue(limit int) (released int, err error)
+}
+
+// Create holds the amount of req from the wallet of user for its payee. The payee must
+// be able to receive money in the currency held. A hold counts against the outgoing
+// limits of user like a transfer.
+func (es *EscrowService) Create(user models.User, req models.CreateEscrowRequest) (resp models.EscrowResponse, err error) {
+	releaseAfter := es.defaultReleaseAfter()
+	if req.ReleaseAt != nil {
+		releaseAfter = req.ReleaseAt.Sub(utils.TimeNowUTC())
+		if releaseAfter <= 0 || (es.MaxReleaseAfter > 0 && releaseAfter > es.MaxReleaseAfter) {
+			err = errs.ErrInvalidReleaseAt
+			return
+		}
+	}
+
+	err = checkAccountStatus(es.UserRepoRead, user, models.AccountStatus.CanDebit)
+	if err != nil {
+		return
+	}
+
+	wallet, err := es.UserBalanceService.GetBalanceByUsername(user.Username, req.Currency)
+	if err != nil {
+		return
+	}
+
+	payee := models.User{Username: req.PayeeUsername}
+	err = es.UserRepoRead.GetUser(&payee)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+		err = errs.ErrInternalServer
+		return
+	}
+	err = nil
+	if payee.ID == 0 || payee.Status == models.CLOSED {
+		err = errs.ErrDestinationUserNotFound
+		return
+	}
+	if payee.ID == user.ID {
+		err = errs.ErrTransferToSelf
+		return
+	}
+	if !payee.Status.CanCredit() {
+		err = errs.ErrDestinationUnavailable
+		return
+	}
+
+	_, err = es.UserBalanceService.GetBalanceByUsername(payee.Username, wallet.Currency)
+	if err != nil {
+		if err == errs.ErrWalletNotFound {
+			err = errs.ErrCurrencyMismatch
+		}
+		return
+	}
+
+	var reservation LimitReservation
+	if es.LimitService != nil {
+		reservation, err = es.LimitService.ReserveTransfer(user, req.Amount, wallet.Currency)
+		if err != nil {
+			return
+		}
+	}
+
+	escrow, err := es.UserBalanceWrite.HoldEscrow(user, payee, models.EscrowParams{
+		PayeeUsername: payee.Username,
+		Amount:        req.Amount,
+		Currency:      wallet.Currency,
+		Memo:          req.Memo,
+		ReleaseAt:     utils.TimeNowUTC().Add(releaseAfter),
+	})
+	if err != nil {
+		if es.LimitService != nil {
+			es.LimitService.Release(reservation)
+		}
+		err = escrowError(err)
+		return
+	}
+
+	resp = models.NewEscrowResponse(escrow)
+	return
+}
+
+// List returns the latest escrows user pays or is paid by, only those in status when
+// it is set.
+func (es *EscrowService) List(user models.User, status models.EscrowStatus) (resp models.EscrowsResponse, err error) {
+	escrows, err := es.EscrowRead.GetEscrows(user.ID, status, DefaultEscrowsLimit)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp.Escrows = []models.EscrowResponse{}
+	for _, v := range escrows {
+		resp.Escrows = append(resp.Escrows, models.NewEscrowResponse(v))
+	}
+	return
+}
+
+// Get returns an escrow user pays or is paid by.
+func (es *EscrowService) Get(user models.User, refID string) (resp models.EscrowResponse, err error) {
+	escrow, _, err := es.getEscrow(user, refID)
+	if err != nil {
+		return
+	}
+	return models.NewEscrowResponse(escrow), nil
+}
+
+// Release pays an escrow to its payee, confirmed by user, its payer.
+func (es *EscrowService) Release(user models.User, refID string) (resp models.EscrowResponse, err error) {
+	escrow, party, err := es.getEscrow(user, refID)
+	if err != nil {
+		return
+	}
+	return es.settle(escrow, party, models.ESCROW_RELEASED, nil)
+}
+
+// Refund pays an escrow back to its payer, confirmed by user, its payee.
+func (es *EscrowService) Refund(user models.User, refID string) (resp models.EscrowResponse, err error) {
+	escrow, party, err := es.getEscrow(user, refID)
+	if err != nil {
+		return
+	}
+	return es.settle(escrow, party, models.ESCROW_REFUNDED, nil)
+}
+
+// Dispute keeps an escrow user pays or is paid by from being released at its deadline.
+func (es *EscrowService) Dispute(user models.User, refID string) (resp models.EscrowResponse, err error) {
+	escrow, party, err := es.getEscrow(user, refID)
+	if err != nil {
+		return
+	}
+
+	disputed, err := es.UserBalanceWrite.DisputeEscrow(escrow.RefID, party)
+	if err != nil {
+		err = escrowError(err)
+		return
+	}
+
+	disputed.Payer = escrow.Payer
+	disputed.Payee = escrow.Payee
+	resp = models.NewEscrowResponse(disputed)
+	return
+}
+
+// Inspect returns any escrow, for an arbitrator.
+func (es *EscrowService) Inspect(refID string) (resp models.EscrowResponse, err error) {
+	escrow, err := es.readEscrow(refID)
+	if err != nil {
+		return
+	}
+	return models.NewEscrowResponse(escrow), nil
+}
+
+// Resolve settles an escrow with outcome on behalf of an arbitrator, recording the
+// decision and its reason in the audit log.
+func (es *EscrowService) Resolve(actor string, refID string, outcome models.EscrowStatus, reason string) (resp models.EscrowResponse, err error) {
+	escrow, err := es.readEscrow(refID)
+	if err != nil {
+		return
+	}
+
+	audit := models.NewAuditLog(actor, models.ESCROW_RESOLVE, escrow.PayerID, reason, map[string]interface{}{
+		"escrow":  escrow.RefID,
+		"outcome": outcome,
+		"status":  escrow.Status,
+	})
+	return es.settle(escrow, models.ESCROW_ARBITRATOR, outcome, &audit)
+}
+
+// ReleaseDue releases up to limit held escrows past their release time to their payee
+// and returns how many were released. An escrow that can not be released, like one
+// whose payee was frozen meanwhile, is tried again after ReleaseRetryInterval, the
+// escrows due after it are released meanwhile. A disputed one is left to an arbitrator.
+func (es *EscrowService) ReleaseDue(limit int) (released int, err error) {
+	retryAfter := utils.TimeNowUTC().Add(-es.releaseRetryInterval())
+	escrows, err := es.EscrowRead.GetDueEscrows(retryAfter, limit)
+	if err != nil {
+		return
+	}
+
+	for _, v := range escrows {
+		_, err := es.settle(v, models.ESCROW_DEADLINE, models.ESCROW_RELEASED, nil)
+		if err != nil {
+			log.Println(v.RefID, err)
+			if err := es.UserBalanceWrite.MarkEscrowReleaseAttempted(v.RefID); err != nil {
+				log.Println(v.RefID, err)
+			}
+			continue
+		}
+		released++
+	}
+	return released, nil
+}
+
+// settle pays escrow out with outcome. The payer and payee of escrow must be loaded.
+func (es *EscrowService) settle(escrow models.Escrow, party models.EscrowParty, outcome models.EscrowStatus, audit *models.AuditLog) (resp models.EscrowResponse, err error) {
+	settled, err := es.UserBalanceWrite.SettleEscrow(escrow.RefID, party, outcome, audit)
+	if err != nil {
+		err = escrowError(err)
+		return
+	}
+
+	settled.Payer = escrow.Payer
+	settled.Payee = escrow.Payee
+	resp = models.NewEscrowResponse(settled)
+	return
+}
+
+// getEscrow reads an escrow with the party user is to it. An escrow user is not a
+// party to is not found.
+func (es *EscrowService) getEscrow(user models.User, refID string) (escrow models.Escrow, party models.EscrowParty, err error) {
+	escrow, err = es.readEscrow(refID)
+	if err != nil {
+		return
+	}
+
+	party, ok := escrow.PartyOf(user.ID)
+	if !ok {
+		err = errs.ErrEscrowNotFound
+	}
+	return
+}
+
+func (es *EscrowService) readEscrow(refID string) (escrow models.Escrow, err error) {
+	escrow = models.Escrow{RefID: refID}
+	err = es.EscrowRead.GetEscrow(&escrow)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrEscrowNotFound
+			return
+		}
+		err = errs.ErrInternalServer
+	}
+	return
+}
+
+func (es *EscrowService) releaseRetryInterval() time.Duration {
+	if es.ReleaseRetryInterval <= 0 {
+		return DefaultEscrowReleaseRetryInterval
+	}
+	return es.ReleaseRetryInterval
+}
+
+func (es *EscrowService) defaultReleaseAfter() time.Duration {
+	if es.DefaultReleaseAfter <= 0 {
+		return DefaultEscrowReleaseAfter
+	}
+	return es.DefaultReleaseAfter
+}
+
+// escrowError keeps the errors an escrow movement is refused with, others are internal.
+func escrowError(err error) error {
+	switch err {
+	case errs.ErrEscrowNotFound,
+		errs.ErrEscrowNotHeld,
+		errs.ErrEscrowActionNotAllowed,
+		errs.ErrInsufficientBalance,
+		errs.ErrCurrencyMismatch,
+		errs.ErrDestinationUnavailable,
+		errs.ErrAccountFrozen,
+		errs.ErrAccountClosed:
+		return err
+	}
+	return errs.ErrInternalServer
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	DefaultEscrowSweepInterval = time.Minute
+	DefaultEscrowSweepBatch    = 100
+)
+
+// EscrowSweeper releases the held escrows past their release time to their payee.
+// Sweepers can run side by side, an escrow is settled once.
+type EscrowSweeper struct {
+	EscrowService IEscrowService
+	Interval      time.Duration
+	BatchSize     int
+}
+
+// Run sweeps until ctx is done.
+func (es *EscrowSweeper) Run(ctx context.Context) {
+	for {
+		released, err := es.SweepOnce()
+		if err != nil {
+			log.Println(err)
+		}
+		if released > 0 {
+			log.Printf("%v escrows released\n", released)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(es.interval()):
+		}
+	}
+}
+
+// SweepOnce releases a batch of due escrows and returns how many were released.
+func (es *EscrowSweeper) SweepOnce() (released int, err error) {
+	return es.EscrowService.ReleaseDue(es.batchSize())
+}
+
+func (es *EscrowSweeper) interval() time.Duration {
+	if es.Interval <= 0 {
+		return DefaultEscrowSweepInterval
+	}
+	return es.Interval
+}
+
+func (es *EscrowSweeper) batchSize() int {
+	if es.BatchSize <= 0 {
+		return DefaultEscrowSweepBatch
+	}
+	return es.BatchSize
+}
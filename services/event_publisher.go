@@ -0,0 +1,79 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+)
+
+// EventPublisher delivers domain events to downstream consumers. Publish returns
+// nil only once the event is accepted, otherwise it is delivered again later.
+type EventPublisher interface {
+	Publish(event models.Event) error
+}
+
+// WriterPublisher writes every event as a JSON line to Writer, e.g. os.Stdout or a file.
+type WriterPublisher struct {
+	Writer io.Writer
+	sync.Mutex
+}
+
+func (wp *WriterPublisher) Publish(event models.Event) error {
+	bEvent, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+
+	wp.Lock()
+	defer wp.Unlock()
+	_, err = wp.Writer.Write(append(bEvent, '\n'))
+	return err
+}
+
+// HTTPPublisher posts every event as JSON to URL. Any status other than 2xx is a
+// failure. Headers X-Event-ID and X-Event-Type let the receiver drop duplicates
+// without reading the body.
+type HTTPPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPPublisher(url string, timeout time.Duration) *HTTPPublisher {
+	return &HTTPPublisher{
+		URL:    url,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (hp *HTTPPublisher) Publish(event models.Event) error {
+	bEvent, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hp.URL, bytes.NewReader(bEvent))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID)
+	req.Header.Set("X-Event-Type", string(event.Type))
+
+	resp, err := hp.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Event %v rejected with status %v", event.ID, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atrariksa/awallet/configs"
+)
+
+const (
+	FeeTypeNone       = "none"
+	FeeTypeFlat       = "flat"
+	FeeTypePercentage = "percentage"
+	FeeTypeTiered     = "tiered"
+)
+
+type FeeCalculator interface {
+	CalculateFee(amount uint32) uint32
+}
+
+// FeeSchedule computes transfer fees from the FEE section of the config.
+type FeeSchedule struct {
+	Tiers []FeeRule
+	Min   uint32
+	Max   uint32
+}
+
+// FeeRule charges Flat or Percentage (in percent) of amounts from MinAmount on.
+type FeeRule struct {
+	MinAmount  uint32
+	Flat       uint32
+	Percentage *big.Rat
+}
+
+// NewFeeSchedule builds the fee schedule of cfg. A flat or percentage schedule is
+// a single rule starting at 0.
+func NewFeeSchedule(cfg *configs.Config) (*FeeSchedule, error) {
+	fs := &FeeSchedule{Min: cfg.Fee.Min, Max: cfg.Fee.Max}
+
+	switch strings.ToLower(cfg.Fee.Type) {
+	case "", FeeTypeNone:
+		fs.Min, fs.Max = 0, 0
+	case FeeTypeFlat:
+		fs.Tiers = []FeeRule{{Flat: cfg.Fee.Flat}}
+	case FeeTypePercentage:
+		percentage, err := parsePercentage(cfg.Fee.Percentage)
+		if err != nil {
+			return nil, err
+		}
+		fs.Tiers = []FeeRule{{Percentage: percentage}}
+	case FeeTypeTiered:
+		for _, v := range cfg.Fee.Tiers {
+			rule, err := parseFeeRule(v)
+			if err != nil {
+				return nil, err
+			}
+			fs.Tiers = append(fs.Tiers, rule)
+		}
+		sort.Slice(fs.Tiers, func(i, j int) bool {
+			return fs.Tiers[i].MinAmount < fs.Tiers[j].MinAmount
+		})
+	default:
+		return nil, fmt.Errorf(`Unknown fee type "%v"`, cfg.Fee.Type)
+	}
+
+	if fs.Max != 0 && fs.Min > fs.Max {
+		return nil, fmt.Errorf("Minimum fee %v is above maximum fee %v", fs.Min, fs.Max)
+	}
+
+	return fs, nil
+}
+
+// CalculateFee applies the rule of the highest tier reached by amount, rounding a
+// percentage down, and then the min/max caps. Without a rule no fee is charged.
+func (fs *FeeSchedule) CalculateFee(amount uint32) uint32 {
+	var rule *FeeRule
+	for i := range fs.Tiers {
+		if fs.Tiers[i].MinAmount <= amount {
+			rule = &fs.Tiers[i]
+		}
+	}
+	if rule == nil {
+		return 0
+	}
+
+	fee := uint64(rule.Flat)
+	if rule.Percentage != nil {
+		value := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(amount)), rule.Percentage)
+		value.Quo(value, big.NewRat(100, 1))
+		fee = new(big.Int).Quo(value.Num(), value.Denom()).Uint64()
+	}
+
+	if fee < uint64(fs.Min) {
+		fee = uint64(fs.Min)
+	}
+	if fs.Max != 0 && fee > uint64(fs.Max) {
+		fee = uint64(fs.Max)
+	}
+	return uint32(fee)
+}
+
+func parseFeeRule(value string) (rule FeeRule, err error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+	if len(parts) != 2 {
+		return rule, fmt.Errorf(`Invalid fee tier "%v"`, value)
+	}
+
+	minAmount, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return rule, fmt.Errorf(`Invalid fee tier "%v"`, value)
+	}
+	rule.MinAmount = uint32(minAmount)
+
+	if strings.HasSuffix(parts[1], "%") {
+		rule.Percentage, err = parsePercentage(strings.TrimSuffix(parts[1], "%"))
+		return
+	}
+
+	flat, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return rule, fmt.Errorf(`Invalid fee tier "%v"`, value)
+	}
+	rule.Flat = uint32(flat)
+	return
+}
+
+func parsePercentage(value string) (*big.Rat, error) {
+	percentage, ok := new(big.Rat).SetString(value)
+	if !ok || percentage.Sign() < 0 || percentage.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, fmt.Errorf(`Invalid fee percentage "%v"`, value)
+	}
+	return percentage, nil
+}
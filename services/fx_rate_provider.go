@@ -0,0 +1,111 @@
+package services
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+// FXRateProvider returns how many units of currency "to" one unit of currency "from" buys.
+type FXRateProvider interface {
+	GetRate(from string, to string) (rate *big.Rat, err error)
+}
+
+// FileFXRateProvider reads rates from a JSON file shaped as {"USD": {"IDR": "15500.5"}}.
+// The file is reloaded when it changes, and a missing pair falls back to the inverse
+// of the opposite pair.
+type FileFXRateProvider struct {
+	path    string
+	modTime time.Time
+	rates   map[string]map[string]string
+	sync.Mutex
+}
+
+func NewFileFXRateProvider(path string) *FileFXRateProvider {
+	return &FileFXRateProvider{
+		path: path,
+	}
+}
+
+func (fp *FileFXRateProvider) GetRate(from string, to string) (rate *big.Rat, err error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	fp.Lock()
+	defer fp.Unlock()
+
+	err = fp.load()
+	if err != nil {
+		log.Println(err)
+		return nil, errs.ErrFXRateNotFound
+	}
+
+	if v, ok := fp.rates[from][to]; ok {
+		return parseRate(v)
+	}
+
+	if v, ok := fp.rates[to][from]; ok {
+		rate, err = parseRate(v)
+		if err != nil {
+			return
+		}
+		return new(big.Rat).Inv(rate), nil
+	}
+
+	return nil, errs.ErrFXRateNotFound
+}
+
+func (fp *FileFXRateProvider) load() error {
+	info, err := os.Stat(fp.path)
+	if err != nil {
+		return err
+	}
+	if fp.rates != nil && info.ModTime().Equal(fp.modTime) {
+		return nil
+	}
+
+	bRates, err := ioutil.ReadFile(fp.path)
+	if err != nil {
+		return err
+	}
+
+	rates := map[string]map[string]string{}
+	err = json.Unmarshal(bRates, &rates)
+	if err != nil {
+		return err
+	}
+
+	fp.rates = rates
+	fp.modTime = info.ModTime()
+	return nil
+}
+
+func parseRate(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(value)
+	if !ok || rate.Sign() <= 0 {
+		return nil, errs.ErrFXRateNotFound
+	}
+	return rate, nil
+}
+
+// convertAmount converts value from one currency to another with the current rate, rounding down.
+func convertAmount(provider FXRateProvider, value int64, from string, to string) (int64, error) {
+	if from == to || value == 0 {
+		return value, nil
+	}
+
+	rate, err := provider.GetRate(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(value), rate)
+	return new(big.Int).Quo(converted.Num(), converted.Denom()).Int64(), nil
+}
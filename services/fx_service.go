@@ -0,0 +1,50 @@
+package services
+
+import (
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+)
+
+type FXService struct {
+	FXRateProvider FXRateProvider
+	FXRepoWrite    repos.IFXRepoWrite
+
+	// Currencies lists the ISO-4217 codes users can hold wallets in.
+	Currencies []string
+	// QuoteTTL is how long a quoted rate stays valid for a transfer.
+	QuoteTTL time.Duration
+}
+
+type IFXService interface {
+	CreateQuote(user models.User, fromCurrency string, toCurrency string) (quote models.FXQuote, err error)
+}
+
+func (fs *FXService) CreateQuote(user models.User, fromCurrency string, toCurrency string) (quote models.FXQuote, err error) {
+	if !fs.isSupported(fromCurrency) || !fs.isSupported(toCurrency) {
+		return models.FXQuote{}, errs.ErrUnsupportedCurrency
+	}
+
+	rate, err := fs.FXRateProvider.GetRate(fromCurrency, toCurrency)
+	if err != nil {
+		return
+	}
+
+	quote = models.NewFXQuote(user, fromCurrency, toCurrency, rate, fs.QuoteTTL)
+	err = fs.FXRepoWrite.CreateQuote(&quote)
+	if err != nil {
+		return models.FXQuote{}, errs.ErrInternalServer
+	}
+	return
+}
+
+func (fs *FXService) isSupported(currency string) bool {
+	for _, v := range fs.Currencies {
+		if v == currency {
+			return true
+		}
+	}
+	return false
+}
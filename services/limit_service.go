@@ -0,0 +1,226 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/atrariksa/awallet/configs"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+	"github.com/go-redis/redis"
+)
+
+type LimitService struct {
+	UserRepoRead   repos.IUserRepoRead
+	LimitRepo      repos.ILimitRepo
+	FXRateProvider FXRateProvider
+
+	// DefaultCurrency is the currency limit values are expressed in. Amounts in
+	// other currencies are converted with the current rate before counting.
+	DefaultCurrency string
+	DefaultTier     string
+	Tiers           map[string]configs.TierLimits
+}
+
+type ILimitService interface {
+	ReserveTopup(user models.User, amount uint32, currency string) (reservation LimitReservation, err error)
+	ReserveTransfer(user models.User, amount uint32, currency string) (reservation LimitReservation, err error)
+	Release(reservation LimitReservation)
+	GetRemainingLimits(user models.User) (resp models.LimitsResponse, err error)
+}
+
+// LimitReservation holds the counter increments made for an operation, so they
+// can be given back when the operation fails.
+type LimitReservation []LimitIncrement
+
+type LimitIncrement struct {
+	Key   string
+	Value int64
+}
+
+func (ls *LimitService) ReserveTopup(user models.User, amount uint32, currency string) (reservation LimitReservation, err error) {
+	value, err := ls.toDefaultCurrency(int64(amount), currency)
+	if err != nil {
+		return
+	}
+	return ls.reserve(user, map[models.LimitType]int64{models.TOPUP_VALUE: value})
+}
+
+func (ls *LimitService) ReserveTransfer(user models.User, amount uint32, currency string) (reservation LimitReservation, err error) {
+	value, err := ls.toDefaultCurrency(int64(amount), currency)
+	if err != nil {
+		return
+	}
+	return ls.reserve(user, map[models.LimitType]int64{
+		models.OUTGOING_VALUE: value,
+		models.OUTGOING_COUNT: 1,
+	})
+}
+
+func (ls *LimitService) Release(reservation LimitReservation) {
+	for _, v := range reservation {
+		ls.LimitRepo.IncrCounter(v.Key, -v.Value)
+	}
+}
+
+func (ls *LimitService) GetRemainingLimits(user models.User) (resp models.LimitsResponse, err error) {
+	tier, limits, err := ls.getLimits(user)
+	if err != nil {
+		return
+	}
+
+	now := utils.TimeNowUTC()
+	resp = models.LimitsResponse{Tier: tier, Currency: ls.DefaultCurrency, Limits: []models.RemainingLimit{}}
+	for _, limit := range limits {
+		var used int64
+		used, _, err = ls.getUsage(user.ID, limit, now)
+		if err != nil {
+			return models.LimitsResponse{}, errs.ErrInternalServer
+		}
+		if used < 0 {
+			used = 0
+		}
+
+		remaining := uint64(0)
+		if uint64(used) < limit.Max {
+			remaining = limit.Max - uint64(used)
+		}
+
+		resp.Limits = append(resp.Limits, models.RemainingLimit{
+			Type:      limit.Type,
+			Period:    limit.Period,
+			Limit:     limit.Max,
+			Used:      uint64(used),
+			Remaining: remaining,
+			ResetsAt:  limit.PeriodEnd(now),
+		})
+	}
+	return
+}
+
+// reserve adds the amounts to the counters of every matching limit and undoes all
+// of them as soon as one limit is exceeded. When the cache is unavailable the
+// check falls back to the mutations, without reserving anything.
+func (ls *LimitService) reserve(user models.User, amounts map[models.LimitType]int64) (reservation LimitReservation, err error) {
+	_, limits, err := ls.getLimits(user)
+	if err != nil {
+		return
+	}
+
+	now := utils.TimeNowUTC()
+	for _, limit := range limits {
+		amount, ok := amounts[limit.Type]
+		if !ok {
+			continue
+		}
+
+		used, cached, usageErr := ls.getUsage(user.ID, limit, now)
+		if usageErr != nil {
+			ls.Release(reservation)
+			return nil, errs.ErrInternalServer
+		}
+
+		if cached {
+			key := limit.CounterKey(user.ID, now)
+			result, incrErr := ls.LimitRepo.IncrCounter(key, amount)
+			if incrErr == nil {
+				reservation = append(reservation, LimitIncrement{Key: key, Value: amount})
+				used = result - amount
+			}
+		}
+
+		if used+amount > int64(limit.Max) {
+			ls.Release(reservation)
+			return nil, errs.ErrLimitExceeded
+		}
+	}
+	return
+}
+
+// getUsage reads the counter of a limit, creating it from the mutations of the
+// period when it is missing. cached is false when the counter could not be used.
+func (ls *LimitService) getUsage(userID uint, limit models.Limit, now time.Time) (used int64, cached bool, err error) {
+	key := limit.CounterKey(userID, now)
+	used, err = ls.LimitRepo.GetCounter(key)
+	if err == nil {
+		return used, true, nil
+	}
+	cacheErr := err
+
+	totals, err := ls.LimitRepo.GetTotals(userID, limit.MutationTypes(), limit.PeriodStart(now))
+	if err != nil {
+		return
+	}
+
+	used = 0
+	for _, v := range totals {
+		if limit.Type == models.OUTGOING_COUNT {
+			used += v.Count
+			continue
+		}
+		var value int64
+		value, err = ls.toDefaultCurrency(v.Value, v.Currency)
+		if err != nil {
+			return
+		}
+		used += value
+	}
+
+	if cacheErr != redis.Nil {
+		log.Println(cacheErr)
+		return used, false, nil
+	}
+
+	err = ls.LimitRepo.InitCounter(key, used, limit.PeriodEnd(now))
+	if err != nil {
+		return used, false, nil
+	}
+	used, err = ls.LimitRepo.GetCounter(key)
+	if err != nil {
+		return
+	}
+	return used, true, nil
+}
+
+// getLimits returns the tier of the user and its configured limits.
+func (ls *LimitService) getLimits(user models.User) (tier string, limits []models.Limit, err error) {
+	if user.Tier == "" {
+		err = ls.UserRepoRead.GetUser(&user)
+		if err != nil {
+			log.Println(err)
+			return "", nil, errs.ErrInternalServer
+		}
+	}
+
+	tier = strings.ToUpper(user.Tier)
+	if tier == "" {
+		tier = strings.ToUpper(ls.DefaultTier)
+	}
+
+	tierLimits, ok := ls.Tiers[strings.ToLower(tier)]
+	if !ok {
+		tier = strings.ToUpper(ls.DefaultTier)
+		tierLimits = ls.Tiers[strings.ToLower(tier)]
+	}
+
+	for _, v := range []models.Limit{
+		{Type: models.OUTGOING_VALUE, Period: models.DAILY, Max: tierLimits.DailyOutgoingValue},
+		{Type: models.OUTGOING_VALUE, Period: models.MONTHLY, Max: tierLimits.MonthlyOutgoingValue},
+		{Type: models.OUTGOING_COUNT, Period: models.DAILY, Max: tierLimits.DailyOutgoingCount},
+		{Type: models.OUTGOING_COUNT, Period: models.MONTHLY, Max: tierLimits.MonthlyOutgoingCount},
+		{Type: models.TOPUP_VALUE, Period: models.DAILY, Max: tierLimits.DailyTopupValue},
+		{Type: models.TOPUP_VALUE, Period: models.MONTHLY, Max: tierLimits.MonthlyTopupValue},
+	} {
+		if v.Max > 0 {
+			limits = append(limits, v)
+		}
+	}
+	return
+}
+
+func (ls *LimitService) toDefaultCurrency(value int64, currency string) (int64, error) {
+	return convertAmount(ls.FXRateProvider, value, currency, ls.DefaultCurrency)
+}
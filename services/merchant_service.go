@@ -0,0 +1,221 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+const DefaultPaymentCodeTTL = 15 * time.Minute
+
+type MerchantService struct {
+	UserRepoRead       repos.IUserRepoRead
+	UserRepoWrite      repos.IUserRepoWrite
+	PaymentCodeRepo    repos.IPaymentCodeRepo
+	UserBalanceService IUserBalanceService
+
+	// PayloadSecret signs the payment payloads. DynamicTTL is how long a dynamic
+	// payment code that does not set its expiry is valid, MaxTTL the furthest expiry it
+	// can set. A MaxTTL of 0 means unbounded.
+	PayloadSecret string
+	DynamicTTL    time.Duration
+	MaxTTL        time.Duration
+}
+
+type IMerchantService interface {
+	Register(user models.User, req models.MerchantRequest) (resp models.MerchantResponse, err error)
+	Get(user models.User) (resp models.MerchantResponse, err error)
+	CreatePaymentCode(user models.User, req models.CreatePaymentCodeRequest) (resp models.PaymentCodeResponse, err error)
+	PreparePayment(user models.User, req models.PayRequest) (params models.TransferParams, merchant models.User, err error)
+	Pay(user models.User, merchant models.User, params models.TransferParams) (resp models.PayResponse, err error)
+}
+
+// Register makes user a merchant with the settings of req, or changes them.
+func (ms *MerchantService) Register(user models.User, req models.MerchantRequest) (resp models.MerchantResponse, err error) {
+	wallet, err := ms.UserBalanceService.GetBalanceByUsername(user.Username, req.SettlementCurrency)
+	if err != nil {
+		return
+	}
+
+	err = ms.UserRepoWrite.SetMerchant(user, req.DisplayName, wallet.Currency)
+	if err != nil {
+		if err != errs.ErrAccountClosed {
+			err = errs.ErrInternalServer
+		}
+		return
+	}
+
+	user.DisplayName = req.DisplayName
+	user.SettlementCurrency = wallet.Currency
+	resp = models.NewMerchantResponse(user)
+	return
+}
+
+// Get returns the merchant settings of user.
+func (ms *MerchantService) Get(user models.User) (resp models.MerchantResponse, err error) {
+	merchant, err := ms.getMerchant(user.Username)
+	if err != nil {
+		return
+	}
+	return models.NewMerchantResponse(merchant), nil
+}
+
+// CreatePaymentCode returns the signed payload of a payment code of user, a merchant
+// able to receive money. A code bound to an amount is stored, so it is paid once. An
+// order reference needs an amount, as a static code can be paid any number of times.
+func (ms *MerchantService) CreatePaymentCode(user models.User, req models.CreatePaymentCodeRequest) (resp models.PaymentCodeResponse, err error) {
+	if req.Amount == 0 && req.OrderRef != "" {
+		err = errs.ErrOrderRefWithoutAmount
+		return
+	}
+
+	merchant, err := ms.getMerchant(user.Username)
+	if err != nil {
+		return
+	}
+	if !merchant.Status.CanCredit() {
+		err = accountStatusError(merchant.Status)
+		return
+	}
+
+	now := utils.TimeNowUTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		err = errs.ErrInvalidExpiry
+		return
+	}
+
+	payload := models.NewPaymentPayload(merchant, nil)
+	if req.ExpiresAt != nil {
+		payload.ExpiresAt = req.ExpiresAt.Unix()
+	}
+
+	if req.Amount > 0 {
+		expiresAt := now.Add(ms.dynamicTTL())
+		if req.ExpiresAt != nil {
+			expiresAt = *req.ExpiresAt
+			if ms.MaxTTL > 0 && expiresAt.Sub(now) > ms.MaxTTL {
+				err = errs.ErrInvalidExpiry
+				return
+			}
+		}
+
+		code := models.NewPaymentCode(merchant, req.Amount, req.OrderRef, expiresAt)
+		err = ms.PaymentCodeRepo.CreatePaymentCode(&code)
+		if err != nil {
+			err = errs.ErrInternalServer
+			return
+		}
+		payload = models.NewPaymentPayload(merchant, &code)
+	}
+
+	resp = models.PaymentCodeResponse{
+		Payload:  payload.Encode(ms.PayloadSecret),
+		Amount:   payload.Amount,
+		Currency: payload.Currency,
+		OrderRef: payload.OrderRef,
+	}
+	if payload.ExpiresAt != 0 {
+		expiresAt := time.Unix(payload.ExpiresAt, 0).UTC()
+		resp.ExpiresAt = &expiresAt
+	}
+	return
+}
+
+// PreparePayment verifies the payload of req and returns the transfer of user paying
+// its merchant, with the amount to authorize.
+func (ms *MerchantService) PreparePayment(user models.User, req models.PayRequest) (params models.TransferParams, merchant models.User, err error) {
+	payload, err := models.DecodePaymentPayload(ms.PayloadSecret, req.Payload, utils.TimeNowUTC())
+	if err != nil {
+		return
+	}
+
+	amount := req.Amount
+	if payload.IsDynamic() {
+		if amount != 0 && amount != payload.Amount {
+			err = errs.ErrPaymentAmountMismatch
+			return
+		}
+		amount = payload.Amount
+	}
+	if amount == 0 {
+		err = errs.ErrPaymentAmountMismatch
+		return
+	}
+
+	merchant, err = ms.getMerchant(payload.Merchant)
+	if err != nil {
+		if err == errs.ErrNotMerchant {
+			err = errs.ErrInvalidPaymentPayload
+		}
+		return
+	}
+	if merchant.ID == user.ID {
+		err = errs.ErrTransferToSelf
+		return
+	}
+
+	params = models.TransferParams{
+		Amount:        amount,
+		Currency:      payload.Currency,
+		ToUsername:    merchant.Username,
+		PaymentCodeID: payload.CodeID,
+		OrderRef:      payload.OrderRef,
+	}
+	return
+}
+
+// Pay makes the transfer of user to merchant prepared with PreparePayment. A retry of
+// a payment with the same idempotency key returns the first payment along with
+// ErrIdempotencyReplay.
+func (ms *MerchantService) Pay(user models.User, merchant models.User, params models.TransferParams) (resp models.PayResponse, err error) {
+	transfer, err := ms.UserBalanceService.Transfer(user, params)
+	if err == errs.ErrIdempotencyReplay {
+		if jsonErr := json.Unmarshal(params.IdempotencyKey.ResponseBody, &transfer); jsonErr != nil {
+			log.Println(jsonErr)
+			return resp, errs.ErrInternalServer
+		}
+	} else if err != nil {
+		return
+	}
+
+	resp = models.PayResponse{
+		RefID:       transfer.RefID,
+		Merchant:    merchant.Username,
+		DisplayName: merchant.DisplayName,
+		OrderRef:    params.OrderRef,
+		Currency:    transfer.Currency,
+		Amount:      transfer.Amount,
+		Fee:         transfer.Fee,
+		Total:       transfer.Total,
+	}
+	return resp, err
+}
+
+// getMerchant reads the account of username, which must be a merchant.
+func (ms *MerchantService) getMerchant(username string) (merchant models.User, err error) {
+	merchant = models.User{Username: username}
+	err = ms.UserRepoRead.GetUser(&merchant)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+		err = errs.ErrInternalServer
+		return
+	}
+	err = nil
+	if merchant.ID == 0 || merchant.Status == models.CLOSED || !merchant.IsMerchant() {
+		err = errs.ErrNotMerchant
+	}
+	return
+}
+
+func (ms *MerchantService) dynamicTTL() time.Duration {
+	if ms.DynamicTTL <= 0 {
+		return DefaultPaymentCodeTTL
+	}
+	return ms.DynamicTTL
+}
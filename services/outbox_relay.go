@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+)
+
+const (
+	DefaultRelayBatchSize     = 100
+	DefaultRelayPollInterval  = time.Second
+	DefaultRelayLockTTL       = time.Minute
+	DefaultRelayMaxAttempts   = 10
+	DefaultRelayRetryInterval = time.Minute
+)
+
+// OutboxRelay publishes the events of the outbox. An event is marked published
+// only after Publisher accepted it, so it is delivered at least once. When an event
+// fails, the later events of its users wait for the next batch, so every user sees
+// its events in the order they were written.
+//
+// A failed event is retried after RetryInterval, MaxAttempts times in all. It then
+// fails for good, so it does not hold back the events of its users forever.
+type OutboxRelay struct {
+	OutboxRepo repos.IOutboxRepo
+	Publisher  EventPublisher
+	// InstanceID tells the relays apart, it must be unique among them.
+	InstanceID   int64
+	BatchSize    int
+	PollInterval time.Duration
+	// LockTTL must be longer than a batch takes to publish.
+	LockTTL       time.Duration
+	MaxAttempts   int
+	RetryInterval time.Duration
+}
+
+// Run relays batches until ctx is done. A full batch is followed by the next one
+// right away, otherwise the relay waits PollInterval.
+func (or *OutboxRelay) Run(ctx context.Context) {
+	for {
+		published, err := or.RelayOnce()
+		if err != nil {
+			log.Println(err)
+		}
+		if published > 0 {
+			log.Printf("%v events published\n", published)
+		}
+
+		wait := or.pollInterval()
+		if published == or.batchSize() {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending events and returns how many were
+// published. It does nothing while another relay holds the lock.
+func (or *OutboxRelay) RelayOnce() (published int, err error) {
+	ok, err := or.OutboxRepo.AcquireRelayLock(or.InstanceID, or.lockTTL())
+	if err != nil || !ok {
+		return
+	}
+	defer or.OutboxRepo.ReleaseRelayLock(or.InstanceID)
+
+	events, err := or.OutboxRepo.GetPendingEvents(or.batchSize())
+	if err != nil {
+		return
+	}
+
+	blocked := map[uint]bool{}
+	for _, event := range events {
+		// a skipped event holds back its other user as well
+		if isBlocked(blocked, event) {
+			block(blocked, event)
+			continue
+		}
+
+		err = or.Publisher.Publish(event.Event())
+		if err != nil {
+			log.Println(err)
+			or.recordFailure(event, err)
+			block(blocked, event)
+			continue
+		}
+
+		// an event published but not marked is published again, the later events
+		// of its users must follow it
+		err = or.OutboxRepo.MarkPublished(event)
+		if err != nil {
+			block(blocked, event)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
+
+// recordFailure counts a failed attempt of event, and fails it once it ran out of
+// attempts.
+func (or *OutboxRelay) recordFailure(event models.OutboxEvent, cause error) {
+	event.Attempts++
+	event.LastError = cause.Error()
+	if event.Attempts >= or.maxAttempts() {
+		event.Status = models.OUTBOX_FAILED
+	} else {
+		event.NextAttemptAt = utils.TimeNowUTC().Add(or.retryInterval())
+	}
+	or.OutboxRepo.RecordFailure(event)
+}
+
+func (or *OutboxRelay) batchSize() int {
+	if or.BatchSize <= 0 {
+		return DefaultRelayBatchSize
+	}
+	return or.BatchSize
+}
+
+func (or *OutboxRelay) pollInterval() time.Duration {
+	if or.PollInterval <= 0 {
+		return DefaultRelayPollInterval
+	}
+	return or.PollInterval
+}
+
+func (or *OutboxRelay) lockTTL() time.Duration {
+	if or.LockTTL <= 0 {
+		return DefaultRelayLockTTL
+	}
+	return or.LockTTL
+}
+
+func (or *OutboxRelay) maxAttempts() int {
+	if or.MaxAttempts <= 0 {
+		return DefaultRelayMaxAttempts
+	}
+	return or.MaxAttempts
+}
+
+func (or *OutboxRelay) retryInterval() time.Duration {
+	if or.RetryInterval <= 0 {
+		return DefaultRelayRetryInterval
+	}
+	return or.RetryInterval
+}
+
+func isBlocked(blocked map[uint]bool, event models.OutboxEvent) bool {
+	for _, userID := range event.UserIDs() {
+		if blocked[userID] {
+			return true
+		}
+	}
+	return false
+}
+
+func block(blocked map[uint]bool, event models.OutboxEvent) {
+	for _, userID := range event.UserIDs() {
+		blocked[userID] = true
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPaymentRequestTTL = 24 * time.Hour
+)
+
+type PaymentRequestService struct {
+	UserRepoRead        repos.IUserRepoRead
+	PaymentRequestRead  repos.IPaymentRequestRepoRead
+	PaymentRequestWrite repos.IPaymentRequestRepoWrite
+	UserBalanceService  IUserBalanceService
+
+	// DefaultTTL is the expiry of a request that does not set one, MaxTTL the
+	// furthest expiry a request can set. A MaxTTL of 0 means unbounded.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+type IPaymentRequestService interface {
+	Create(user models.User, req models.CreatePaymentRequestRequest) (resp models.PaymentRequestResponse, err error)
+	ListIncoming(user models.User, filter models.PaymentRequestFilter) (resp models.PaymentRequestsResponse, err error)
+	ListOutgoing(user models.User, filter models.PaymentRequestFilter) (resp models.PaymentRequestsResponse, err error)
+	GetPending(user models.User, id string) (paymentRequest models.PaymentRequest, err error)
+	Accept(user models.User, paymentRequest models.PaymentRequest) (resp models.TransferResponse, err error)
+	Decline(user models.User, id string) error
+	Cancel(user models.User, id string) error
+}
+
+// Create addresses a payment request of user to the payer of req. The requester must
+// hold a wallet in the currency asked for.
+func (ps *PaymentRequestService) Create(user models.User, req models.CreatePaymentRequestRequest) (resp models.PaymentRequestResponse, err error) {
+	ttl := ps.defaultTTL()
+	if req.ExpiresAt != nil {
+		ttl = req.ExpiresAt.Sub(utils.TimeNowUTC())
+		if ttl <= 0 || (ps.MaxTTL > 0 && ttl > ps.MaxTTL) {
+			err = errs.ErrInvalidExpiry
+			return
+		}
+	}
+
+	wallet, err := ps.UserBalanceService.GetBalanceByUsername(user.Username, req.Currency)
+	if err != nil {
+		return
+	}
+
+	payer := models.User{Username: req.PayerUsername}
+	err = ps.UserRepoRead.GetUser(&payer)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+		err = errs.ErrInternalServer
+		return
+	}
+	if payer.ID == 0 || payer.Status == models.CLOSED {
+		err = errs.ErrDestinationUserNotFound
+		return
+	}
+
+	paymentRequest := models.NewPaymentRequest(user, payer, req.Amount, wallet.Currency, req.Memo, ttl)
+	err = ps.PaymentRequestWrite.CreatePaymentRequest(&paymentRequest)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	paymentRequest.Requester = user
+	paymentRequest.Payer = payer
+	resp = models.NewPaymentRequestResponse(paymentRequest)
+	return
+}
+
+// ListIncoming returns a page of the latest payment requests addressed to user, of
+// any status when filter.Status is empty.
+func (ps *PaymentRequestService) ListIncoming(user models.User, filter models.PaymentRequestFilter) (resp models.PaymentRequestsResponse, err error) {
+	limit := filter.Limit
+	// one more request tells whether there is a next page
+	filter.Limit = limit + 1
+
+	paymentRequests, err := ps.PaymentRequestRead.GetIncoming(user.ID, filter)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	for i := range paymentRequests {
+		paymentRequests[i].Payer = user
+	}
+	return newPaymentRequestsResponse(paymentRequests, limit), nil
+}
+
+// ListOutgoing returns a page of the latest payment requests of user, of any status
+// when filter.Status is empty.
+func (ps *PaymentRequestService) ListOutgoing(user models.User, filter models.PaymentRequestFilter) (resp models.PaymentRequestsResponse, err error) {
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	paymentRequests, err := ps.PaymentRequestRead.GetOutgoing(user.ID, filter)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	for i := range paymentRequests {
+		paymentRequests[i].Requester = user
+	}
+	return newPaymentRequestsResponse(paymentRequests, limit), nil
+}
+
+// newPaymentRequestsResponse pages paymentRequests, read one past limit.
+func newPaymentRequestsResponse(paymentRequests []models.PaymentRequest, limit int) (resp models.PaymentRequestsResponse) {
+	resp.PaymentRequests = []models.PaymentRequestResponse{}
+	for k, v := range paymentRequests {
+		if k == limit {
+			last := paymentRequests[k-1]
+			resp.NextCursor = models.PaymentRequestCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+			break
+		}
+		resp.PaymentRequests = append(resp.PaymentRequests, models.NewPaymentRequestResponse(v))
+	}
+	return
+}
+
+// GetPending returns a payment request addressed to user that can still be paid.
+func (ps *PaymentRequestService) GetPending(user models.User, id string) (paymentRequest models.PaymentRequest, err error) {
+	paymentRequest = models.PaymentRequest{ID: id}
+	err = ps.PaymentRequestRead.GetPaymentRequest(&paymentRequest)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrPaymentRequestNotFound
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+
+	if paymentRequest.PayerID != user.ID {
+		err = errs.ErrPaymentRequestNotFound
+		return
+	}
+	if paymentRequest.Status != models.PAYMENT_REQUEST_PENDING {
+		err = errs.ErrPaymentRequestNotPending
+		return
+	}
+	if paymentRequest.IsExpired() {
+		err = errs.ErrPaymentRequestExpired
+		return
+	}
+	return
+}
+
+// Accept pays a pending payment request with a transfer from user to its requester.
+// The request is marked paid within the transaction of the transfer.
+func (ps *PaymentRequestService) Accept(user models.User, paymentRequest models.PaymentRequest) (resp models.TransferResponse, err error) {
+	return ps.UserBalanceService.Transfer(user, models.TransferParams{
+		Amount:           paymentRequest.Amount,
+		Currency:         paymentRequest.Currency,
+		ToUsername:       paymentRequest.Requester.Username,
+		PaymentRequestID: paymentRequest.ID,
+	})
+}
+
+func (ps *PaymentRequestService) Decline(user models.User, id string) error {
+	declined, err := ps.PaymentRequestWrite.Decline(id, user.ID)
+	if err != nil {
+		return errs.ErrInternalServer
+	}
+	if !declined {
+		return ps.notPendingError(id, func(v models.PaymentRequest) bool { return v.PayerID == user.ID })
+	}
+	return nil
+}
+
+func (ps *PaymentRequestService) Cancel(user models.User, id string) error {
+	cancelled, err := ps.PaymentRequestWrite.Cancel(id, user.ID)
+	if err != nil {
+		return errs.ErrInternalServer
+	}
+	if !cancelled {
+		return ps.notPendingError(id, func(v models.PaymentRequest) bool { return v.RequesterID == user.ID })
+	}
+	return nil
+}
+
+// notPendingError tells a request user can not see from one that was settled already.
+func (ps *PaymentRequestService) notPendingError(id string, visible func(models.PaymentRequest) bool) error {
+	paymentRequest := models.PaymentRequest{ID: id}
+	err := ps.PaymentRequestRead.GetPaymentRequest(&paymentRequest)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.ErrPaymentRequestNotFound
+		}
+		return errs.ErrInternalServer
+	}
+	if !visible(paymentRequest) {
+		return errs.ErrPaymentRequestNotFound
+	}
+	return errs.ErrPaymentRequestNotPending
+}
+
+func (ps *PaymentRequestService) defaultTTL() time.Duration {
+	if ps.DefaultTTL <= 0 {
+		return DefaultPaymentRequestTTL
+	}
+	return ps.DefaultTTL
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/repos"
+)
+
+const (
+	DefaultPaymentRequestSweepInterval = time.Minute
+	DefaultPaymentRequestSweepBatch    = 500
+)
+
+// PaymentRequestSweeper expires the pending payment requests past their expiry, so
+// they leave the pending list of their payer. Sweepers can run side by side, a
+// request is only expired while it is still pending.
+type PaymentRequestSweeper struct {
+	PaymentRequestWrite repos.IPaymentRequestRepoWrite
+	Interval            time.Duration
+	BatchSize           int
+}
+
+// Run sweeps until ctx is done.
+func (ps *PaymentRequestSweeper) Run(ctx context.Context) {
+	for {
+		_, err := ps.SweepOnce()
+		if err != nil {
+			log.Println(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ps.interval()):
+		}
+	}
+}
+
+// SweepOnce expires every due payment request and returns how many were expired.
+func (ps *PaymentRequestSweeper) SweepOnce() (expired int64, err error) {
+	for {
+		n, err := ps.PaymentRequestWrite.ExpirePending(ps.batchSize())
+		expired += n
+		if err != nil || n < int64(ps.batchSize()) {
+			return expired, err
+		}
+	}
+}
+
+func (ps *PaymentRequestSweeper) interval() time.Duration {
+	if ps.Interval <= 0 {
+		return DefaultPaymentRequestSweepInterval
+	}
+	return ps.Interval
+}
+
+func (ps *PaymentRequestSweeper) batchSize() int {
+	if ps.BatchSize <= 0 {
+		return DefaultPaymentRequestSweepBatch
+	}
+	return ps.BatchSize
+}
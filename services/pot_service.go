@@ -0,0 +1,267 @@
+package services
+
+import (
+	"log"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+const DefaultPotContributionsLimit = 50
+
+type PotService struct {
+	UserRepoRead       repos.IUserRepoRead
+	PotRead            repos.IPotRepoRead
+	PotWrite           repos.IPotRepoWrite
+	UserBalanceService IUserBalanceService
+	LimitService       ILimitService
+}
+
+type IPotService interface {
+	Create(user models.User, req models.CreatePotRequest) (resp models.PotResponse, err error)
+	List(user models.User) (resp models.PotsResponse, err error)
+	Get(user models.User, potID uint) (resp models.PotResponse, err error)
+	GetContributions(user models.User, potID uint) (resp models.PotContributionsResponse, err error)
+	Contribute(user models.User, potID uint, amount uint32) (resp models.PotContributionResponse, err error)
+	Disburse(user models.User, potID uint, amount uint32) (resp models.PotDisbursementResponse, err error)
+	Close(user models.User, potID uint) (resp models.PotClosedResponse, err error)
+}
+
+// Create opens a pot owned by user with the members of req. The owner must hold a
+// wallet in the currency of the pot, disbursements are paid into it.
+func (ps *PotService) Create(user models.User, req models.CreatePotRequest) (resp models.PotResponse, err error) {
+	wallet, err := ps.UserBalanceService.GetBalanceByUsername(user.Username, req.Currency)
+	if err != nil {
+		return
+	}
+
+	members := []models.PotMember{{UserID: user.ID, User: user}}
+	added := map[uint]bool{user.ID: true}
+	for _, username := range req.MemberUsernames {
+		member := models.User{Username: username}
+		err = ps.UserRepoRead.GetUser(&member)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			log.Println(err)
+			err = errs.ErrInternalServer
+			return
+		}
+		err = nil
+		if member.ID == 0 || member.Status == models.CLOSED {
+			err = errs.ErrPotMemberNotFound
+			return
+		}
+
+		if added[member.ID] {
+			continue
+		}
+		added[member.ID] = true
+		members = append(members, models.PotMember{UserID: member.ID, User: member})
+	}
+
+	now := utils.TimeNowUTC()
+	for i := range members {
+		members[i].CreatedAt = now
+	}
+	pot := models.Pot{
+		OwnerID:      user.ID,
+		Name:         req.Name,
+		Currency:     wallet.Currency,
+		TargetAmount: req.TargetAmount,
+		Status:       models.POT_OPEN,
+		Members:      members,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	err = ps.PotWrite.CreatePot(&pot)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	pot.Owner = user
+	resp = models.NewPotResponse(pot)
+	return
+}
+
+// List returns the pots user is a member of, without their members.
+func (ps *PotService) List(user models.User) (resp models.PotsResponse, err error) {
+	pots, err := ps.PotRead.GetPots(user.ID)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp.Pots = []models.PotResponse{}
+	for _, v := range pots {
+		resp.Pots = append(resp.Pots, models.NewPotResponse(v))
+	}
+	return
+}
+
+// Get returns a pot of which user is a member, with what every member contributed.
+func (ps *PotService) Get(user models.User, potID uint) (resp models.PotResponse, err error) {
+	pot, err := ps.getPot(user, potID)
+	if err != nil {
+		return
+	}
+	return models.NewPotResponse(pot), nil
+}
+
+// GetContributions returns the latest contributions to a pot of which user is a member.
+func (ps *PotService) GetContributions(user models.User, potID uint) (resp models.PotContributionsResponse, err error) {
+	pot, err := ps.getPot(user, potID)
+	if err != nil {
+		return
+	}
+
+	contributions, err := ps.PotRead.GetContributions(pot.ID, DefaultPotContributionsLimit)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp.Contributions = []models.PotContributionResponse{}
+	for _, v := range contributions {
+		resp.Contributions = append(resp.Contributions, models.NewPotContributionResponse(v))
+	}
+	return
+}
+
+// Contribute moves amount from the wallet of user into an open pot of which user is
+// a member. A contribution counts against the outgoing limits of user like a transfer.
+func (ps *PotService) Contribute(user models.User, potID uint, amount uint32) (resp models.PotContributionResponse, err error) {
+	err = checkAccountStatus(ps.UserRepoRead, user, models.AccountStatus.CanDebit)
+	if err != nil {
+		return
+	}
+
+	pot, err := ps.getPot(user, potID)
+	if err != nil {
+		return
+	}
+	if pot.Status != models.POT_OPEN {
+		err = errs.ErrPotNotOpen
+		return
+	}
+
+	_, err = ps.UserBalanceService.GetBalanceByUsername(user.Username, pot.Currency)
+	if err != nil {
+		return
+	}
+
+	var reservation LimitReservation
+	if ps.LimitService != nil {
+		reservation, err = ps.LimitService.ReserveTransfer(user, amount, pot.Currency)
+		if err != nil {
+			return
+		}
+	}
+
+	contribution, err := ps.PotWrite.Contribute(user, pot.ID, amount)
+	if err != nil {
+		if ps.LimitService != nil {
+			ps.LimitService.Release(reservation)
+		}
+		err = potError(err)
+		return
+	}
+
+	contribution.User = user
+	resp = models.NewPotContributionResponse(contribution)
+	return
+}
+
+// Disburse pays amount of a pot owned by user, its whole balance when amount is 0, to
+// the wallet of user.
+func (ps *PotService) Disburse(user models.User, potID uint, amount uint32) (resp models.PotDisbursementResponse, err error) {
+	err = checkAccountStatus(ps.UserRepoRead, user, models.AccountStatus.CanCredit)
+	if err != nil {
+		return
+	}
+
+	pot, err := ps.getPot(user, potID)
+	if err != nil {
+		return
+	}
+	if pot.OwnerID != user.ID {
+		err = errs.ErrNotPotOwner
+		return
+	}
+
+	resp, err = ps.PotWrite.Disburse(user, pot.ID, uint64(amount))
+	if err != nil {
+		err = potError(err)
+	}
+	return
+}
+
+// Close refunds the balance of a pot owned by user to its contributors, in proportion
+// to their contributions, and closes it.
+func (ps *PotService) Close(user models.User, potID uint) (resp models.PotClosedResponse, err error) {
+	pot, err := ps.getPot(user, potID)
+	if err != nil {
+		return
+	}
+	if pot.OwnerID != user.ID {
+		err = errs.ErrNotPotOwner
+		return
+	}
+
+	refunds, err := ps.PotWrite.Close(user, pot.ID)
+	if err != nil {
+		err = potError(err)
+		return
+	}
+
+	usernames := map[uint]string{}
+	for _, v := range pot.Members {
+		usernames[v.UserID] = v.User.Username
+	}
+	resp.Refunds = []models.PotRefundResponse{}
+	for _, v := range refunds {
+		resp.Refunds = append(resp.Refunds, models.PotRefundResponse{
+			Username: usernames[v.UserID],
+			RefID:    v.RefID,
+			Amount:   v.Amount,
+		})
+	}
+	return
+}
+
+// getPot reads a pot with its members. A pot user is not a member of is not found.
+func (ps *PotService) getPot(user models.User, potID uint) (pot models.Pot, err error) {
+	pot = models.Pot{ID: potID}
+	err = ps.PotRead.GetPot(&pot)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrPotNotFound
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+
+	if !pot.IsMember(user.ID) {
+		err = errs.ErrPotNotFound
+	}
+	return
+}
+
+// potError keeps the errors a pot movement is refused with, others are internal.
+func potError(err error) error {
+	switch err {
+	case errs.ErrPotNotFound,
+		errs.ErrPotNotOpen,
+		errs.ErrNotPotOwner,
+		errs.ErrInsufficientPotFunds,
+		errs.ErrInsufficientBalance,
+		errs.ErrAccountFrozen,
+		errs.ErrAccountClosed,
+		errs.ErrPotRefundUnavailable:
+		return err
+	}
+	return errs.ErrInternalServer
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+)
+
+const (
+	ReconcileActor            = "reconcile"
+	DefaultReconcileBatchSize = 500
+)
+
+type ReconcileService struct {
+	ReconcileRepo repos.IReconcileRepo
+	BatchSize     int
+}
+
+type IReconcileService interface {
+	Reconcile(fix bool) (drifts []models.ReconcileDrift, err error)
+}
+
+// Reconcile walks all wallets in batches and returns every counter that does not
+// match its mutations. With fix, drifted wallets are repaired one by one and the
+// repaired drifts are marked as fixed.
+func (rs *ReconcileService) Reconcile(fix bool) (drifts []models.ReconcileDrift, err error) {
+	batchSize := rs.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultReconcileBatchSize
+	}
+
+	var lastWalletID uint
+	for {
+		var reconciliations []models.WalletReconciliation
+		reconciliations, err = rs.ReconcileRepo.GetWalletReconciliations(lastWalletID, batchSize)
+		if err != nil {
+			return
+		}
+
+		if len(reconciliations) == 0 {
+			return
+		}
+		lastWalletID = reconciliations[len(reconciliations)-1].WalletID
+
+		for _, v := range reconciliations {
+			walletDrifts := v.Drifts()
+			if len(walletDrifts) == 0 {
+				continue
+			}
+
+			if fix {
+				var fixed []models.ReconcileDrift
+				fixed, err = rs.ReconcileRepo.FixWallet(v.WalletID, ReconcileActor)
+				if err != nil {
+					return
+				}
+				for i := range walletDrifts {
+					for _, f := range fixed {
+						if f.Field == walletDrifts[i].Field {
+							walletDrifts[i].Fixed = true
+						}
+					}
+				}
+			}
+
+			drifts = append(drifts, walletDrifts...)
+		}
+	}
+}
+
+// CheckReconcileReportFormat tells whether the report can be written as format, "json"
+// or "csv", so a bad format fails before anything is fixed.
+func CheckReconcileReportFormat(format string) error {
+	if format != "json" && format != "csv" {
+		return fmt.Errorf(`Unknown report format "%v"`, format)
+	}
+	return nil
+}
+
+// WriteReconcileReport writes drifts to w as "json" or "csv".
+func WriteReconcileReport(w io.Writer, format string, drifts []models.ReconcileDrift) error {
+	err := CheckReconcileReportFormat(format)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		if drifts == nil {
+			drifts = []models.ReconcileDrift{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(drifts)
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"user_id", "username", "currency", "field", "stored", "expected", "difference", "fixed"})
+		for _, v := range drifts {
+			writer.Write([]string{
+				strconv.FormatUint(uint64(v.UserID), 10),
+				v.Username,
+				v.Currency,
+				v.Field,
+				strconv.FormatInt(v.Stored, 10),
+				strconv.FormatInt(v.Expected, 10),
+				strconv.FormatInt(v.Difference, 10),
+				strconv.FormatBool(v.Fixed),
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return nil
+}
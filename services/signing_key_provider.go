@@ -0,0 +1,245 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atrariksa/awallet/configs"
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/utils"
+	"github.com/golang-jwt/jwt"
+)
+
+const keyActivationLayout = "20060102"
+
+// SigningKeyProvider holds the keys tokens are signed and verified with.
+type SigningKeyProvider interface {
+	// SigningKey returns the key new tokens are signed with.
+	SigningKey() (key SigningKey, err error)
+	// VerificationKey returns the key of token, picked by its "kid" header.
+	VerificationKey(token *jwt.Token) (key interface{}, err error)
+	// JWKS returns the public keys for services validating tokens offline.
+	JWKS() (models.JWKSet, error)
+}
+
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Key    interface{}
+}
+
+func NewSigningKeyProvider(cfg *configs.Config) (SigningKeyProvider, error) {
+	switch strings.ToUpper(cfg.JWT.Algorithm) {
+	case "", jwt.SigningMethodHS256.Alg():
+		return &HMACKeyProvider{Secret: cfg.JWT.Secret}, nil
+	case jwt.SigningMethodRS256.Alg():
+		return NewFileKeyProvider(cfg.JWT.KeysDir, jwt.SigningMethodRS256)
+	case jwt.SigningMethodES256.Alg():
+		return NewFileKeyProvider(cfg.JWT.KeysDir, jwt.SigningMethodES256)
+	}
+	return nil, fmt.Errorf(`Unsupported JWT algorithm "%v"`, cfg.JWT.Algorithm)
+}
+
+// HMACKeyProvider signs with the shared JWT.SECRET. It publishes no keys.
+type HMACKeyProvider struct {
+	Secret string
+}
+
+func (hp *HMACKeyProvider) SigningKey() (SigningKey, error) {
+	return SigningKey{Method: jwt.SigningMethodHS256, Key: []byte(hp.Secret)}, nil
+}
+
+func (hp *HMACKeyProvider) VerificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("Error Handling Token")
+	}
+	return []byte(hp.Secret), nil
+}
+
+func (hp *HMACKeyProvider) JWKS() (models.JWKSet, error) {
+	return models.JWKSet{Keys: []models.JWK{}}, nil
+}
+
+// FileKeyProvider loads PEM private keys named "<kid>.pem" from a directory. A kid
+// starting with a date such as "20261001-a" becomes the signing key on that day
+// (UTC), the most recent active key signs. Every key in the directory verifies, so
+// a key is retired by removing its file once the tokens it signed have expired.
+// The directory is reloaded when it changes.
+type FileKeyProvider struct {
+	dir     string
+	method  jwt.SigningMethod
+	modTime time.Time
+	keys    []fileKey
+	sync.Mutex
+}
+
+type fileKey struct {
+	id          string
+	activatesAt time.Time
+	privateKey  interface{}
+	publicKey   interface{}
+}
+
+func NewFileKeyProvider(dir string, method jwt.SigningMethod) (*FileKeyProvider, error) {
+	fp := &FileKeyProvider{dir: dir, method: method}
+	fp.Lock()
+	defer fp.Unlock()
+	err := fp.load()
+	if err != nil {
+		return nil, err
+	}
+	return fp, nil
+}
+
+func (fp *FileKeyProvider) SigningKey() (key SigningKey, err error) {
+	fp.Lock()
+	defer fp.Unlock()
+
+	err = fp.load()
+	if err != nil {
+		log.Println(err)
+	}
+
+	now := utils.TimeNowUTC()
+	for i := len(fp.keys) - 1; i >= 0; i-- {
+		if !fp.keys[i].activatesAt.After(now) {
+			return SigningKey{ID: fp.keys[i].id, Method: fp.method, Key: fp.keys[i].privateKey}, nil
+		}
+	}
+	return key, errors.New("No active signing key")
+}
+
+func (fp *FileKeyProvider) VerificationKey(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != fp.method.Alg() {
+		return nil, fmt.Errorf("Error Handling Token")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	fp.Lock()
+	defer fp.Unlock()
+
+	err := fp.load()
+	if err != nil {
+		log.Println(err)
+	}
+
+	for _, v := range fp.keys {
+		if v.id == kid {
+			return v.publicKey, nil
+		}
+	}
+	return nil, errs.ErrUnauthorized
+}
+
+func (fp *FileKeyProvider) JWKS() (jwks models.JWKSet, err error) {
+	fp.Lock()
+	defer fp.Unlock()
+
+	err = fp.load()
+	if err != nil {
+		log.Println(err)
+	}
+
+	jwks.Keys = []models.JWK{}
+	for _, v := range fp.keys {
+		jwk := models.JWK{Kid: v.id, Use: "sig", Alg: fp.method.Alg()}
+		switch publicKey := v.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (publicKey.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = publicKey.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(padBytes(publicKey.X.Bytes(), size))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(padBytes(publicKey.Y.Bytes(), size))
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+// load keeps the keys loaded last when the directory can not be read, so a bad
+// deployment of a key file does not invalidate every token.
+func (fp *FileKeyProvider) load() error {
+	info, err := os.Stat(fp.dir)
+	if err != nil {
+		return err
+	}
+	if fp.keys != nil && info.ModTime().Equal(fp.modTime) {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(fp.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	var keys []fileKey
+	for _, path := range paths {
+		bKey, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		key := fileKey{id: strings.TrimSuffix(filepath.Base(path), ".pem")}
+		if len(key.id) >= len(keyActivationLayout) {
+			key.activatesAt, _ = time.Parse(keyActivationLayout, key.id[:len(keyActivationLayout)])
+		}
+
+		switch fp.method {
+		case jwt.SigningMethodRS256:
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(bKey)
+			if err != nil {
+				return fmt.Errorf("%v: %v", path, err)
+			}
+			key.privateKey, key.publicKey = privateKey, &privateKey.PublicKey
+		case jwt.SigningMethodES256:
+			privateKey, err := jwt.ParseECPrivateKeyFromPEM(bKey)
+			if err != nil {
+				return fmt.Errorf("%v: %v", path, err)
+			}
+			key.privateKey, key.publicKey = privateKey, &privateKey.PublicKey
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("No keys found in %v", fp.dir)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].activatesAt.Equal(keys[j].activatesAt) {
+			return keys[i].id < keys[j].id
+		}
+		return keys[i].activatesAt.Before(keys[j].activatesAt)
+	})
+
+	fp.keys = keys
+	fp.modTime = info.ModTime()
+	return nil
+}
+
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -4,36 +4,179 @@ import (
 	"time"
 
 	"github.com/atrariksa/awallet/configs"
+	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 )
 
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
 type TokenService struct {
-	cfg *configs.Config
+	cfg       *configs.Config
+	TokenRepo repos.ITokenRepo
+	Keys      SigningKeyProvider
 }
 
-func NewTokenService(cfg *configs.Config) TokenService {
+func NewTokenService(cfg *configs.Config, tokenRepo repos.ITokenRepo, keys SigningKeyProvider) TokenService {
 	return TokenService{
-		cfg: cfg,
+		cfg:       cfg,
+		TokenRepo: tokenRepo,
+		Keys:      keys,
 	}
 }
 
 type ITokenService interface {
-	CreateToken(user models.User) (string, error)
+	CreateToken(user models.User, sessionID string) (string, error)
+	IssueTokens(user models.User) (resp models.TokenResponse, err error)
+	RefreshTokens(refreshToken string) (resp models.TokenResponse, err error)
+	ValidateToken(signedString string) (claims *models.JwtClaims, err error)
+	Logout(claims *models.JwtClaims) error
+	JWKS() (models.JWKSet, error)
 }
 
-func (ts *TokenService) CreateToken(user models.User) (signedString string, err error) {
+func (ts *TokenService) CreateToken(user models.User, sessionID string) (signedString string, err error) {
 	uuidStr := uuid.New().String()
+	now := time.Now()
 	claims := &models.JwtClaims{
-		ID:       uuidStr,
-		UserID:   user.ID,
-		Username: user.Username,
+		ID:        uuidStr,
+		UserID:    user.ID,
+		Username:  user.Username,
+		SessionID: sessionID,
+		Role:      user.Role,
 		StandardClaims: jwt.StandardClaims{
-			IssuedAt: time.Now().Unix(),
+			Id:        uuidStr,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ts.accessTokenTTL()).Unix(),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedString, err = token.SignedString([]byte(ts.cfg.JWT.Secret))
+
+	key, err := ts.Keys.SigningKey()
+	if err != nil {
+		return
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signedString, err = token.SignedString(key.Key)
 	return
 }
+
+// IssueTokens starts a new session for user with an access and a refresh token.
+func (ts *TokenService) IssueTokens(user models.User) (resp models.TokenResponse, err error) {
+	sessionID := uuid.New().String()
+
+	refreshToken, record, err := models.NewRefreshToken(user, sessionID, ts.refreshTokenTTL())
+	if err != nil {
+		return
+	}
+
+	err = ts.TokenRepo.CreateRefreshToken(&record)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	return ts.tokenResponse(user, sessionID, refreshToken)
+}
+
+// RefreshTokens exchanges a refresh token for a new access and refresh token. A
+// refresh token can be used once, using it again revokes its session.
+func (ts *TokenService) RefreshTokens(refreshToken string) (resp models.TokenResponse, err error) {
+	nextToken, next, err := models.NewRefreshToken(models.User{}, "", ts.refreshTokenTTL())
+	if err != nil {
+		return
+	}
+
+	current, err := ts.TokenRepo.RotateRefreshToken(models.HashRefreshToken(refreshToken), &next)
+	if err != nil {
+		if err == errs.ErrRefreshTokenReused {
+			ts.TokenRepo.RevokeSession(current.SessionID, ts.accessTokenTTL())
+			return
+		}
+		if err == errs.ErrInvalidRefreshToken {
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+
+	return ts.tokenResponse(current.User, current.SessionID, nextToken)
+}
+
+func (ts *TokenService) ValidateToken(signedString string) (claims *models.JwtClaims, err error) {
+	token, err := jwt.ParseWithClaims(signedString, &models.JwtClaims{}, ts.Keys.VerificationKey)
+	if err != nil || token == nil || !token.Valid {
+		return nil, errs.ErrUnauthorized
+	}
+
+	claims, ok := token.Claims.(*models.JwtClaims)
+	if !ok {
+		return nil, errs.ErrInternalServer
+	}
+
+	// tokens issued before expiry was introduced never expire
+	if claims.ExpiresAt == 0 {
+		return nil, errs.ErrUnauthorized
+	}
+
+	revoked, err := ts.TokenRepo.IsRevoked(claims.ID, claims.SessionID)
+	if err != nil || revoked {
+		return nil, errs.ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// Logout revokes the access token and the session it was issued in.
+func (ts *TokenService) Logout(claims *models.JwtClaims) error {
+	err := ts.TokenRepo.RevokeToken(claims.ID, time.Until(time.Unix(claims.ExpiresAt, 0)))
+	if err != nil {
+		return errs.ErrInternalServer
+	}
+
+	if claims.SessionID != "" {
+		err = ts.TokenRepo.RevokeSession(claims.SessionID, ts.accessTokenTTL())
+		if err != nil {
+			return errs.ErrInternalServer
+		}
+	}
+	return nil
+}
+
+func (ts *TokenService) JWKS() (models.JWKSet, error) {
+	return ts.Keys.JWKS()
+}
+
+func (ts *TokenService) tokenResponse(user models.User, sessionID string, refreshToken string) (resp models.TokenResponse, err error) {
+	token, err := ts.CreateToken(user, sessionID)
+	if err != nil {
+		return
+	}
+
+	return models.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ts.accessTokenTTL().Seconds()),
+	}, nil
+}
+
+func (ts *TokenService) accessTokenTTL() time.Duration {
+	if ts.cfg.JWT.ExpiresMinutes <= 0 {
+		return DefaultAccessTokenTTL
+	}
+	return time.Duration(ts.cfg.JWT.ExpiresMinutes) * time.Minute
+}
+
+func (ts *TokenService) refreshTokenTTL() time.Duration {
+	if ts.cfg.JWT.RefreshTokenTTL <= 0 {
+		return DefaultRefreshTokenTTL
+	}
+	return ts.cfg.JWT.RefreshTokenTTL
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+	"gorm.io/gorm"
+)
+
+const DefaultScheduleRunsLimit = 20
+
+type TransferScheduleService struct {
+	UserRepoRead         repos.IUserRepoRead
+	TransferScheduleRepo repos.ITransferScheduleRepo
+	UserBalanceService   IUserBalanceService
+}
+
+type ITransferScheduleService interface {
+	Create(user models.User, req models.CreateTransferScheduleRequest) (resp models.TransferScheduleResponse, err error)
+	List(user models.User) (resp models.TransferSchedulesResponse, err error)
+	Get(user models.User, scheduleID uint) (resp models.TransferScheduleResponse, err error)
+	Update(user models.User, scheduleID uint, req models.UpdateTransferScheduleRequest) (resp models.TransferScheduleResponse, err error)
+	Delete(user models.User, scheduleID uint) error
+}
+
+// Create schedules transfers of user to req.ToUsername, once at req.RunAt or at every
+// match of req.Cron.
+func (ss *TransferScheduleService) Create(user models.User, req models.CreateTransferScheduleRequest) (resp models.TransferScheduleResponse, err error) {
+	wallet, err := ss.UserBalanceService.GetBalanceByUsername(user.Username, req.Currency)
+	if err != nil {
+		return
+	}
+
+	destUser := models.User{Username: req.ToUsername}
+	err = ss.UserRepoRead.GetUser(&destUser)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println(err)
+		err = errs.ErrInternalServer
+		return
+	}
+	if destUser.ID == 0 {
+		err = errs.ErrDestinationUserNotFound
+		return
+	}
+
+	now := utils.TimeNowUTC()
+	schedule := models.TransferSchedule{
+		UserID:     user.ID,
+		ToUsername: destUser.Username,
+		Amount:     req.Amount,
+		Currency:   wallet.Currency,
+		Status:     models.SCHEDULE_ACTIVE,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	err = ss.setTiming(&schedule, req.RunAt, req.Cron, now)
+	if err != nil {
+		return
+	}
+
+	err = ss.TransferScheduleRepo.CreateSchedule(&schedule)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp = models.NewTransferScheduleResponse(schedule)
+	return
+}
+
+func (ss *TransferScheduleService) List(user models.User) (resp models.TransferSchedulesResponse, err error) {
+	schedules, err := ss.TransferScheduleRepo.GetSchedules(user.ID)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp.Schedules = []models.TransferScheduleResponse{}
+	for _, v := range schedules {
+		resp.Schedules = append(resp.Schedules, models.NewTransferScheduleResponse(v))
+	}
+	return
+}
+
+// Get returns a schedule of user with its latest runs.
+func (ss *TransferScheduleService) Get(user models.User, scheduleID uint) (resp models.TransferScheduleResponse, err error) {
+	schedule, err := ss.getSchedule(user, scheduleID)
+	if err != nil {
+		return
+	}
+
+	runs, err := ss.TransferScheduleRepo.GetRuns(schedule.ID, DefaultScheduleRunsLimit)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp = models.NewTransferScheduleResponse(schedule)
+	for _, v := range runs {
+		resp.Runs = append(resp.Runs, models.NewTransferScheduleRunResponse(v))
+	}
+	return
+}
+
+// Update changes the amount or the timing of a schedule, or pauses and resumes it. A
+// resumed or retimed schedule starts over from its next occurrence, a failed one can
+// be resumed once its cause is fixed.
+func (ss *TransferScheduleService) Update(user models.User, scheduleID uint, req models.UpdateTransferScheduleRequest) (resp models.TransferScheduleResponse, err error) {
+	schedule, err := ss.getSchedule(user, scheduleID)
+	if err != nil {
+		return
+	}
+
+	if schedule.Status == models.SCHEDULE_COMPLETED {
+		err = errs.ErrInvalidScheduleStatus
+		return
+	}
+	if req.Status == models.SCHEDULE_PAUSED && schedule.Status == models.SCHEDULE_FAILED {
+		err = errs.ErrInvalidScheduleStatus
+		return
+	}
+
+	if req.Amount > 0 {
+		schedule.Amount = req.Amount
+	}
+
+	now := utils.TimeNowUTC()
+	retimed := req.RunAt != nil || req.Cron != ""
+	if retimed {
+		err = ss.setTiming(&schedule, req.RunAt, req.Cron, now)
+		if err != nil {
+			return
+		}
+	}
+
+	resumed := req.Status == models.SCHEDULE_ACTIVE && schedule.Status != models.SCHEDULE_ACTIVE
+	if req.Status != "" {
+		schedule.Status = req.Status
+	}
+	if resumed && !retimed {
+		runAt, err := schedule.FirstRunAt(now)
+		if err != nil {
+			return resp, err
+		}
+		if runAt.IsZero() {
+			return resp, errs.ErrInvalidSchedule
+		}
+		// a one-off schedule resumed after its time runs right away
+		if runAt.Before(now) {
+			runAt = now.Truncate(time.Second)
+		}
+		schedule.Schedule(runAt)
+	}
+
+	err = ss.TransferScheduleRepo.UpdateSchedule(schedule)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp = models.NewTransferScheduleResponse(schedule)
+	return
+}
+
+func (ss *TransferScheduleService) Delete(user models.User, scheduleID uint) error {
+	deleted, err := ss.TransferScheduleRepo.DeleteSchedule(user.ID, scheduleID)
+	if err != nil {
+		return errs.ErrInternalServer
+	}
+	if !deleted {
+		return errs.ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (ss *TransferScheduleService) getSchedule(user models.User, scheduleID uint) (schedule models.TransferSchedule, err error) {
+	schedule = models.TransferSchedule{ID: scheduleID, UserID: user.ID}
+	err = ss.TransferScheduleRepo.GetSchedule(&schedule)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrScheduleNotFound
+			return
+		}
+		err = errs.ErrInternalServer
+	}
+	return
+}
+
+// setTiming makes schedule a one-off at runAt or a recurring one on cron, and sets
+// its first occurrence.
+func (ss *TransferScheduleService) setTiming(schedule *models.TransferSchedule, runAt *time.Time, cron string, now time.Time) error {
+	if (runAt == nil) == (cron == "") {
+		return errs.ErrInvalidSchedule
+	}
+
+	schedule.RunAt = nil
+	schedule.Cron = cron
+	if runAt != nil {
+		// stored times keep whole seconds, the occurrence is compared with them
+		at := runAt.UTC().Truncate(time.Second)
+		if !at.After(now) {
+			return errs.ErrInvalidSchedule
+		}
+		schedule.RunAt = &at
+	}
+
+	first, err := schedule.FirstRunAt(now)
+	if err != nil {
+		return err
+	}
+	if first.IsZero() {
+		return errs.ErrInvalidSchedule
+	}
+	schedule.Schedule(first)
+	return nil
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+)
+
+const (
+	DefaultSchedulerBatchSize     = 100
+	DefaultSchedulerPollInterval  = 10 * time.Second
+	DefaultSchedulerLockTTL       = 30 * time.Second
+	DefaultSchedulerMaxAttempts   = 3
+	DefaultSchedulerRetryInterval = time.Hour
+
+	// scheduledTransferPath scopes the idempotency keys of scheduled transfers.
+	scheduledTransferPath = "schedule"
+)
+
+// TransferScheduler makes the due transfers of the schedules. Only the leader of the
+// running schedulers does, the lead is held in Redis and passes to another scheduler
+// when the leader stops. Every occurrence is transferred with its own idempotency
+// key, so it is transferred once even when two schedulers overlap on a lead change.
+//
+// A failed attempt is retried after RetryInterval, MaxAttempts times in all. A
+// recurring schedule then skips to its next occurrence, a one-off schedule fails.
+// Errors retrying can not fix, like a closed destination, fail the schedule right away.
+type TransferScheduler struct {
+	TransferScheduleRepo repos.ITransferScheduleRepo
+	UserBalanceService   IUserBalanceService
+	// InstanceID tells the schedulers apart, it must be unique among them.
+	InstanceID    int64
+	BatchSize     int
+	PollInterval  time.Duration
+	LockTTL       time.Duration
+	MaxAttempts   int
+	RetryInterval time.Duration
+}
+
+// Run schedules until ctx is done, and gives the lead up afterwards.
+func (ts *TransferScheduler) Run(ctx context.Context) {
+	defer ts.TransferScheduleRepo.ReleaseLeadership(ts.InstanceID)
+	for {
+		ran, err := ts.RunOnce()
+		if err != nil {
+			log.Println(err)
+		}
+		if ran > 0 {
+			log.Printf("%v scheduled transfers run\n", ran)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ts.pollInterval()):
+		}
+	}
+}
+
+// RunOnce runs the due schedules when this scheduler leads and returns how many
+// were run.
+func (ts *TransferScheduler) RunOnce() (ran int, err error) {
+	leader, err := ts.TransferScheduleRepo.AcquireLeadership(ts.InstanceID, ts.lockTTL())
+	if err != nil || !leader {
+		return
+	}
+
+	schedules, err := ts.TransferScheduleRepo.GetDueSchedules(ts.batchSize())
+	if err != nil {
+		return
+	}
+
+	for _, schedule := range schedules {
+		// the lead is renewed before every transfer, a scheduler that lost it while
+		// the batch ran stops before overlapping with the new leader
+		leader, err = ts.TransferScheduleRepo.AcquireLeadership(ts.InstanceID, ts.lockTTL())
+		if err != nil || !leader {
+			return
+		}
+
+		err = ts.run(schedule)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+func (ts *TransferScheduler) run(schedule models.TransferSchedule) error {
+	occurrenceAt := schedule.NextRunAt
+	refID, cause := ts.transfer(schedule)
+	run := models.NewTransferScheduleRun(schedule, refID, cause)
+
+	now := utils.TimeNowUTC()
+	schedule.LastRunAt = &now
+	schedule.LastError = ""
+	if cause == nil {
+		schedule.LastRefID = refID
+		ts.scheduleNext(&schedule, now)
+	} else {
+		schedule.LastError = cause.Error()
+		schedule.Attempts++
+		if !isRetryable(cause) {
+			schedule.Status = models.SCHEDULE_FAILED
+		} else if schedule.Attempts < ts.maxAttempts() {
+			schedule.DueAt = now.Add(ts.retryInterval())
+		} else if schedule.IsRecurring() {
+			ts.scheduleNext(&schedule, now)
+		} else {
+			schedule.Status = models.SCHEDULE_FAILED
+		}
+	}
+
+	return ts.TransferScheduleRepo.RecordRun(schedule, occurrenceAt, run)
+}
+
+// transfer makes the transfer of the current occurrence of schedule. An occurrence
+// transferred before is not transferred again.
+func (ts *TransferScheduler) transfer(schedule models.TransferSchedule) (refID string, err error) {
+	params := models.TransferParams{
+		Amount:     schedule.Amount,
+		Currency:   schedule.Currency,
+		ToUsername: schedule.ToUsername,
+	}
+	params.IdempotencyKey, err = models.NewIdempotencyKey(schedule.User, schedule.OccurrenceKey(), scheduledTransferPath, params)
+	if err != nil {
+		return
+	}
+
+	resp, err := ts.UserBalanceService.Transfer(schedule.User, params)
+	if err == errs.ErrIdempotencyReplay {
+		json.Unmarshal(params.IdempotencyKey.ResponseBody, &resp)
+		err = nil
+	}
+	if err == errs.ErrIdempotencyKeyMismatch {
+		// the schedule was changed after its occurrence was transferred
+		err = nil
+	}
+	return resp.RefID, err
+}
+
+// scheduleNext moves schedule to its first occurrence after now, occurrences missed
+// while no scheduler ran are skipped. A one-off schedule is completed.
+func (ts *TransferScheduler) scheduleNext(schedule *models.TransferSchedule, now time.Time) {
+	if !schedule.IsRecurring() {
+		schedule.Status = models.SCHEDULE_COMPLETED
+		return
+	}
+
+	next, err := schedule.FirstRunAt(now)
+	if err != nil || next.IsZero() {
+		schedule.Status = models.SCHEDULE_COMPLETED
+		return
+	}
+	schedule.Schedule(next)
+}
+
+// isRetryable tells the errors a later attempt may not run into, like a balance
+// too low, from those that stay.
+func isRetryable(err error) bool {
+	switch err {
+	case errs.ErrDestinationUserNotFound,
+		errs.ErrDestinationUnavailable,
+		errs.ErrCurrencyMismatch,
+		errs.ErrUnsupportedCurrency,
+		errs.ErrAccountClosed:
+		return false
+	}
+	return true
+}
+
+func (ts *TransferScheduler) batchSize() int {
+	if ts.BatchSize <= 0 {
+		return DefaultSchedulerBatchSize
+	}
+	return ts.BatchSize
+}
+
+func (ts *TransferScheduler) pollInterval() time.Duration {
+	if ts.PollInterval <= 0 {
+		return DefaultSchedulerPollInterval
+	}
+	return ts.PollInterval
+}
+
+func (ts *TransferScheduler) lockTTL() time.Duration {
+	if ts.LockTTL <= 0 {
+		return DefaultSchedulerLockTTL
+	}
+	return ts.LockTTL
+}
+
+func (ts *TransferScheduler) maxAttempts() int {
+	if ts.MaxAttempts <= 0 {
+		return DefaultSchedulerMaxAttempts
+	}
+	return ts.MaxAttempts
+}
+
+func (ts *TransferScheduler) retryInterval() time.Duration {
+	if ts.RetryInterval <= 0 {
+		return DefaultSchedulerRetryInterval
+	}
+	return ts.RetryInterval
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"gorm.io/gorm"
+)
+
+const DefaultTwoFactorLockoutDuration = 15 * time.Minute
+
+type TwoFactorService struct {
+	TwoFactorRepo  repos.ITwoFactorRepo
+	FXRateProvider FXRateProvider
+
+	// DefaultCurrency is the currency of TransferThreshold.
+	DefaultCurrency string
+	// Issuer names the wallet in authenticator apps.
+	Issuer string
+	// TransferThreshold is the amount above which a transfer must be confirmed
+	// with a two-factor code, 0 disables the challenge.
+	TransferThreshold uint64
+	ChallengeTTL      time.Duration
+	// MaxAttempts is the number of wrong codes after which a challenge is refused, and
+	// after which any code of the user is refused until LockoutDuration has passed
+	// since its first wrong one.
+	MaxAttempts     int
+	LockoutDuration time.Duration
+}
+
+type ITwoFactorService interface {
+	Enroll(user models.User) (resp models.TwoFactorEnrollResponse, err error)
+	Activate(user models.User, code string) (resp models.RecoveryCodesResponse, err error)
+	RequiresTransferChallenge(user models.User, amount uint32, currency string) (bool, error)
+	CreateTransferChallenge(user models.User, req models.TransferRequest, idempotencyKey string) (resp models.TransferChallengeResponse, err error)
+	ConfirmTransferChallenge(user models.User, challengeID string, code string) (req models.TransferRequest, idempotencyKey string, err error)
+	VerifyTransferCode(user models.User, code string) error
+}
+
+// Enroll generates a new TOTP secret for user. It only takes effect once activated
+// with a code, and replaces any pending enrollment.
+func (ts *TwoFactorService) Enroll(user models.User) (resp models.TwoFactorEnrollResponse, err error) {
+	twoFactor := models.TwoFactor{UserID: user.ID}
+	err = ts.TwoFactorRepo.GetTwoFactor(&twoFactor)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return resp, errs.ErrInternalServer
+	}
+	if twoFactor.IsEnabled() {
+		return resp, errs.ErrTwoFactorAlreadyEnabled
+	}
+
+	twoFactor.Secret, err = models.NewTOTPSecret()
+	if err != nil {
+		return resp, errs.ErrInternalServer
+	}
+	twoFactor.LastUsedStep = 0
+	err = ts.TwoFactorRepo.SaveTwoFactor(&twoFactor)
+	if err != nil {
+		return resp, errs.ErrInternalServer
+	}
+
+	resp = models.TwoFactorEnrollResponse{
+		Secret:     twoFactor.Secret,
+		OTPAuthURI: models.TOTPURI(ts.Issuer, user.Username, twoFactor.Secret),
+	}
+	return
+}
+
+// Activate enables the pending enrollment of user with a first valid code and
+// returns its recovery codes. They are shown only once.
+func (ts *TwoFactorService) Activate(user models.User, code string) (resp models.RecoveryCodesResponse, err error) {
+	twoFactor := models.TwoFactor{UserID: user.ID}
+	err = ts.TwoFactorRepo.GetTwoFactor(&twoFactor)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return resp, errs.ErrTwoFactorNotEnrolled
+		}
+		return resp, errs.ErrInternalServer
+	}
+	if twoFactor.IsEnabled() {
+		return resp, errs.ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok := models.VerifyTOTP(twoFactor.Secret, code, twoFactor.LastUsedStep)
+	if !ok {
+		return resp, errs.ErrInvalidTwoFactorCode
+	}
+
+	codes, recoveryCodes, err := models.NewRecoveryCodes(user, models.RecoveryCodeCount)
+	if err != nil {
+		return resp, errs.ErrInternalServer
+	}
+
+	activated, err := ts.TwoFactorRepo.ActivateTwoFactor(twoFactor, step, recoveryCodes)
+	if err != nil {
+		return resp, errs.ErrInternalServer
+	}
+	if !activated {
+		return resp, errs.ErrTwoFactorAlreadyEnabled
+	}
+
+	resp.RecoveryCodes = codes
+	return
+}
+
+// RequiresTransferChallenge reports whether a transfer of amount must be confirmed
+// with a two-factor code. Amounts are compared in the default currency.
+func (ts *TwoFactorService) RequiresTransferChallenge(user models.User, amount uint32, currency string) (bool, error) {
+	if ts.TransferThreshold == 0 {
+		return false, nil
+	}
+	if currency == "" {
+		currency = ts.DefaultCurrency
+	}
+
+	value, err := convertAmount(ts.FXRateProvider, int64(amount), currency, ts.DefaultCurrency)
+	if err != nil {
+		if err == errs.ErrFXRateNotFound {
+			return false, err
+		}
+		return false, errs.ErrInternalServer
+	}
+	return uint64(value) > ts.TransferThreshold, nil
+}
+
+// CreateTransferChallenge holds req until it is confirmed. Users who did not
+// activate two-factor authentication can not make such a transfer.
+func (ts *TwoFactorService) CreateTransferChallenge(user models.User, req models.TransferRequest, idempotencyKey string) (resp models.TransferChallengeResponse, err error) {
+	twoFactor := models.TwoFactor{UserID: user.ID}
+	err = ts.TwoFactorRepo.GetTwoFactor(&twoFactor)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return resp, errs.ErrInternalServer
+	}
+	if !twoFactor.IsEnabled() {
+		return resp, errs.ErrTwoFactorRequired
+	}
+
+	challenge, err := models.NewTransferChallenge(user, req, idempotencyKey, ts.ChallengeTTL)
+	if err != nil {
+		return resp, errs.ErrInternalServer
+	}
+	err = ts.TwoFactorRepo.CreateChallenge(&challenge)
+	if err != nil {
+		return resp, errs.ErrInternalServer
+	}
+
+	resp = models.TransferChallengeResponse{
+		ChallengeID: challenge.ID,
+		ExpiresAt:   challenge.ExpiresAt,
+	}
+	return
+}
+
+// ConfirmTransferChallenge checks code, a TOTP or recovery code, and releases the
+// transfer held by the challenge. A challenge can be confirmed once.
+func (ts *TwoFactorService) ConfirmTransferChallenge(user models.User, challengeID string, code string) (req models.TransferRequest, idempotencyKey string, err error) {
+	challenge := models.TransferChallenge{ID: challengeID}
+	err = ts.TwoFactorRepo.GetChallenge(&challenge)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrChallengeNotFound
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+	if challenge.UserID != user.ID {
+		err = errs.ErrChallengeNotFound
+		return
+	}
+	if challenge.ConfirmedAt != nil {
+		err = errs.ErrChallengeUsed
+		return
+	}
+	if challenge.IsExpired() || (ts.MaxAttempts > 0 && challenge.Attempts >= ts.MaxAttempts) {
+		err = errs.ErrChallengeExpired
+		return
+	}
+
+	err = ts.verifyCode(user, code)
+	if err != nil {
+		if err == errs.ErrInvalidTwoFactorCode {
+			ts.TwoFactorRepo.RecordChallengeAttempt(challenge.ID)
+		}
+		return
+	}
+
+	confirmed, err := ts.TwoFactorRepo.ConfirmChallenge(challenge.ID)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+	if !confirmed {
+		err = errs.ErrChallengeUsed
+		return
+	}
+
+	req, err = challenge.TransferRequest()
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+	idempotencyKey = challenge.IdempotencyKey
+	return
+}
+
+// VerifyTransferCode checks code, a TOTP or recovery code, for a transfer above the
+// threshold that is confirmed in the same request instead of with a challenge.
+func (ts *TwoFactorService) VerifyTransferCode(user models.User, code string) error {
+	if code == "" {
+		return errs.ErrTwoFactorRequired
+	}
+	return ts.verifyCode(user, code)
+}
+
+// verifyCode accepts a TOTP code of the active enrollment of user, or one of its
+// unused recovery codes. Wrong codes are counted per user, whether sent with a
+// challenge or in the transfer itself, and lock two-factor codes at MaxAttempts.
+func (ts *TwoFactorService) verifyCode(user models.User, code string) error {
+	if ts.MaxAttempts > 0 {
+		failures, err := ts.TwoFactorRepo.GetCodeFailures(user.ID)
+		if err != nil {
+			return errs.ErrInternalServer
+		}
+		if failures >= int64(ts.MaxAttempts) {
+			return errs.ErrTwoFactorLocked
+		}
+	}
+
+	err := ts.checkCode(user, code)
+	if err == errs.ErrInvalidTwoFactorCode {
+		ts.TwoFactorRepo.RecordCodeFailure(user.ID, ts.lockoutDuration())
+	} else if err == nil {
+		ts.TwoFactorRepo.ClearCodeFailures(user.ID)
+	}
+	return err
+}
+
+func (ts *TwoFactorService) checkCode(user models.User, code string) error {
+	twoFactor := models.TwoFactor{UserID: user.ID}
+	err := ts.TwoFactorRepo.GetTwoFactor(&twoFactor)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.ErrTwoFactorRequired
+		}
+		return errs.ErrInternalServer
+	}
+	if !twoFactor.IsEnabled() {
+		return errs.ErrTwoFactorRequired
+	}
+
+	step, ok := models.VerifyTOTP(twoFactor.Secret, code, twoFactor.LastUsedStep)
+	if ok {
+		used, err := ts.TwoFactorRepo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return errs.ErrInternalServer
+		}
+		if !used {
+			return errs.ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := ts.TwoFactorRepo.UseRecoveryCode(user.ID, models.HashRecoveryCode(code))
+	if err != nil {
+		return errs.ErrInternalServer
+	}
+	if !used {
+		return errs.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (ts *TwoFactorService) lockoutDuration() time.Duration {
+	if ts.LockoutDuration <= 0 {
+		return DefaultTwoFactorLockoutDuration
+	}
+	return ts.LockoutDuration
+}
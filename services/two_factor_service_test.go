@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+)
+
+// fakeTwoFactorRepo keeps one active enrollment and the wrong code count in memory.
+type fakeTwoFactorRepo struct {
+	repos.ITwoFactorRepo
+	twoFactor models.TwoFactor
+	failures  int64
+}
+
+func (fr *fakeTwoFactorRepo) GetTwoFactor(twoFactor *models.TwoFactor) error {
+	*twoFactor = fr.twoFactor
+	return nil
+}
+
+func (fr *fakeTwoFactorRepo) UseTOTPStep(userID uint, step int64) (bool, error) {
+	fr.twoFactor.LastUsedStep = step
+	return true, nil
+}
+
+func (fr *fakeTwoFactorRepo) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	return false, nil
+}
+
+func (fr *fakeTwoFactorRepo) GetCodeFailures(userID uint) (int64, error) {
+	return fr.failures, nil
+}
+
+func (fr *fakeTwoFactorRepo) RecordCodeFailure(userID uint, window time.Duration) error {
+	fr.failures++
+	return nil
+}
+
+func (fr *fakeTwoFactorRepo) ClearCodeFailures(userID uint) error {
+	fr.failures = 0
+	return nil
+}
+
+func TestVerifyTransferCodeLocksAfterMaxAttempts(t *testing.T) {
+	secret, err := models.NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	repo := &fakeTwoFactorRepo{twoFactor: models.TwoFactor{UserID: 1, Secret: secret, EnabledAt: &now}}
+	ts := TwoFactorService{TwoFactorRepo: repo, MaxAttempts: 3}
+	user := models.User{ID: 1}
+
+	for i := 0; i < 3; i++ {
+		err = ts.VerifyTransferCode(user, "000000x")
+		if err != errs.ErrInvalidTwoFactorCode {
+			t.Fatalf("attempt %v: got %v, want %v", i+1, err, errs.ErrInvalidTwoFactorCode)
+		}
+	}
+
+	code, err := models.TOTPCode(secret, models.TOTPStep(utils.TimeNowUTC()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ts.VerifyTransferCode(user, code)
+	if err != errs.ErrTwoFactorLocked {
+		t.Fatalf("got %v, want a valid code refused with %v", err, errs.ErrTwoFactorLocked)
+	}
+
+	repo.failures = 0
+	err = ts.VerifyTransferCode(user, code)
+	if err != nil {
+		t.Fatalf("got %v, want the code accepted once the lockout expired", err)
+	}
+}
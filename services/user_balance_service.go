@@ -2,6 +2,7 @@ package services
 
 import (
 	"log"
+	"strings"
 
 	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
@@ -13,16 +14,44 @@ type UserBalanceService struct {
 	UserRepoRead     repos.IUserRepoRead
 	UserBalanceWrite repos.IUserBalanceRepoWrite
 	UserBalanceRead  repos.IUserBalanceRepoRead
+	WalletRead       repos.IWalletRepoRead
+	WalletWrite      repos.IWalletRepoWrite
+	FXRead           repos.IFXRepoRead
+	FeeCalculator    FeeCalculator
+	LimitService     ILimitService
+
+	// DefaultCurrency is used when a request does not carry a currency code.
+	DefaultCurrency string
+	// Currencies lists the ISO-4217 codes users can hold wallets in.
+	Currencies []string
+
+	// AllowNegativeReversal lets a reversal proceed even when the recipient
+	// already spent the transferred amount, leaving a negative balance.
+	AllowNegativeReversal bool
 }
 
 type IUserBalanceService interface {
-	GetBalanceByUsername(username string) (balance int64, err error)
-	TopupBalance(user models.User, amount uint32) error
-	Transfer(user models.User, amount uint32, destUsername string) error
+	GetBalanceByUsername(username string, currency string) (wallet models.Wallet, err error)
+	GetWallets(user models.User) (data []models.WalletResponse, err error)
+	CreateWallet(user models.User, currency string) (wallet models.Wallet, err error)
+	TopupBalance(user models.User, params models.TopupParams) error
+	Transfer(user models.User, params models.TransferParams) (resp models.TransferResponse, err error)
+	TransferBatch(user models.User, params []models.TransferParams, idempotencyKey *models.IdempotencyKey) (resps []models.TransferResponse, itemErrs []error, err error)
 	GetTopTransactionsPerUser(user models.User) (data []models.TopTransactionsPerUser, err error)
+	GetMutations(user models.User, filter models.MutationHistoryFilter) (resp models.MutationHistoryResponse, err error)
+	ReverseTransfer(refID string, audit *models.AuditLog) (reversalRefID string, err error)
+	Withdraw(user models.User, amount uint32, currency string) (mutation models.Mutation, err error)
+	CompleteWithdrawal(refID string, status models.MutationStatus) error
+	Adjust(user models.User, params models.AdjustmentParams) (mutation models.Mutation, err error)
+	CloseAccount(user models.User, payout bool, audit *models.AuditLog) (payouts []models.Mutation, err error)
 }
 
-func (us *UserBalanceService) GetBalanceByUsername(username string) (balance int64, err error) {
+func (us *UserBalanceService) GetBalanceByUsername(username string, currency string) (wallet models.Wallet, err error) {
+
+	currency, err = us.resolveCurrency(currency)
+	if err != nil {
+		return
+	}
 
 	user := &models.User{Username: username}
 	err = us.UserRepoRead.GetUser(user)
@@ -33,36 +62,385 @@ func (us *UserBalanceService) GetBalanceByUsername(username string) (balance int
 
 	if user.ID == 0 {
 		log.Println("Alert, user not found but have valid token!")
-		return 0, errs.ErrUnauthorized
+		return models.Wallet{}, errs.ErrUnauthorized
+	}
+
+	wallet = models.Wallet{UserID: user.ID, Currency: currency}
+	err = us.WalletRead.GetWallet(&wallet)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return models.Wallet{}, errs.ErrWalletNotFound
+		}
+		return models.Wallet{}, errs.ErrInternalServer
+	}
+	return
+}
+
+func (us *UserBalanceService) GetWallets(user models.User) (data []models.WalletResponse, err error) {
+	wallets, err := us.WalletRead.GetWallets(user)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	data = []models.WalletResponse{}
+	for _, v := range wallets {
+		data = append(data, models.WalletResponse{
+			Currency:         v.Currency,
+			Balance:          v.Balance,
+			HeldBalance:      v.HeldBalance,
+			AvailableBalance: v.AvailableBalance(),
+		})
+	}
+	return
+}
+
+func (us *UserBalanceService) CreateWallet(user models.User, currency string) (wallet models.Wallet, err error) {
+	currency, err = us.resolveCurrency(currency)
+	if err != nil {
+		return
 	}
 
-	balance = user.Balance
+	wallet = models.Wallet{UserID: user.ID, Currency: currency}
+	err = us.WalletWrite.Create(&wallet)
+	if err != nil {
+		if strings.Contains(err.Error(), repos.DuplicateKey) {
+			return models.Wallet{}, errs.ErrWalletAlreadyExists
+		}
+		return models.Wallet{}, errs.ErrInternalServer
+	}
 	return
 }
 
-func (us *UserBalanceService) TopupBalance(user models.User, amount uint32) (err error) {
-	err = us.UserBalanceWrite.Topup(user, amount)
+func (us *UserBalanceService) TopupBalance(user models.User, params models.TopupParams) (err error) {
+	params.Currency, err = us.resolveCurrency(params.Currency)
 	if err != nil {
+		return
+	}
+
+	params.Note, err = params.Note.Normalize()
+	if err != nil {
+		return
+	}
+
+	err = us.checkCanCredit(user)
+	if err != nil {
+		return
+	}
+
+	var reservation LimitReservation
+	if us.LimitService != nil {
+		reservation, err = us.LimitService.ReserveTopup(user, params.Amount, params.Currency)
+		if err != nil {
+			return
+		}
+	}
+
+	err = us.UserBalanceWrite.Topup(user, params)
+	if err != nil {
+		if us.LimitService != nil {
+			us.LimitService.Release(reservation)
+		}
+		if err == errs.ErrIdempotencyReplay ||
+			err == errs.ErrIdempotencyKeyMismatch ||
+			err == errs.ErrDuplicateExternalRef ||
+			err == errs.ErrAccountFrozen ||
+			err == errs.ErrAccountClosed {
+			return
+		}
 		err = errs.ErrInternalServer
 		return
 	}
 	return
 }
 
-func (us *UserBalanceService) Transfer(user models.User, amount uint32, destUsername string) (err error) {
-	destUser := models.User{Username: destUsername}
+func (us *UserBalanceService) Transfer(user models.User, params models.TransferParams) (resp models.TransferResponse, err error) {
+	params.Currency, err = us.resolveCurrency(params.Currency)
+	if err != nil {
+		return
+	}
+
+	params.Note, err = params.Note.Normalize()
+	if err != nil {
+		return
+	}
+
+	err = us.checkCanDebit(user)
+	if err != nil {
+		return
+	}
+
+	destUser, err := us.prepareTransfer(user, &params)
+	if err != nil {
+		return
+	}
+
+	var reservation LimitReservation
+	if us.LimitService != nil {
+		reservation, err = us.LimitService.ReserveTransfer(user, params.Amount, params.Currency)
+		if err != nil {
+			return
+		}
+	}
+
+	resp, err = us.UserBalanceWrite.Transfer(user, destUser, params)
+	if err != nil && us.LimitService != nil {
+		us.LimitService.Release(reservation)
+	}
+	return
+}
+
+// TransferBatch makes all the transfers of params or none of them. Every transfer is
+// checked like a single one first, itemErrs then holds the error of each transfer
+// refused and err is ErrBulkTransferItemsInvalid. Each transfer counts against the
+// limits of user.
+func (us *UserBalanceService) TransferBatch(user models.User, params []models.TransferParams, idempotencyKey *models.IdempotencyKey) (resps []models.TransferResponse, itemErrs []error, err error) {
+	err = us.checkCanDebit(user)
+	if err != nil {
+		return
+	}
+
+	destUsers := make([]models.User, len(params))
+	itemErrs = make([]error, len(params))
+	invalid := false
+	for i := range params {
+		params[i].Currency, itemErrs[i] = us.resolveCurrency(params[i].Currency)
+		if itemErrs[i] == nil {
+			params[i].Note, itemErrs[i] = params[i].Note.Normalize()
+		}
+		if itemErrs[i] == nil {
+			destUsers[i], itemErrs[i] = us.prepareTransfer(user, &params[i])
+		}
+		if itemErrs[i] == errs.ErrInternalServer {
+			return nil, nil, itemErrs[i]
+		}
+		if itemErrs[i] == nil && destUsers[i].ID == user.ID {
+			itemErrs[i] = errs.ErrTransferToSelf
+		}
+		if itemErrs[i] != nil {
+			invalid = true
+		}
+	}
+	if invalid {
+		return nil, itemErrs, errs.ErrBulkTransferItemsInvalid
+	}
+
+	var reservations []LimitReservation
+	release := func() {
+		for _, v := range reservations {
+			us.LimitService.Release(v)
+		}
+	}
+	if us.LimitService != nil {
+		for _, v := range params {
+			reservation, err := us.LimitService.ReserveTransfer(user, v.Amount, v.Currency)
+			if err != nil {
+				release()
+				return nil, nil, err
+			}
+			reservations = append(reservations, reservation)
+		}
+	}
+
+	resps, err = us.UserBalanceWrite.TransferBatch(user, destUsers, params, idempotencyKey)
+	if err != nil && us.LimitService != nil {
+		release()
+	}
+	return resps, nil, err
+}
+
+// prepareTransfer checks the destination of a transfer of user and sets its amount to
+// credit and its fee in params.
+func (us *UserBalanceService) prepareTransfer(user models.User, params *models.TransferParams) (destUser models.User, err error) {
+	destUser = models.User{Username: params.ToUsername}
 	err = us.UserRepoRead.GetUser(&destUser)
 	if err != nil && err != gorm.ErrRecordNotFound {
 		log.Println(err)
+		err = errs.ErrInternalServer
 		return
 	}
 
 	if destUser.ID == 0 {
-		return errs.ErrDestinationUserNotFound
+		err = errs.ErrDestinationUserNotFound
+		return
+	}
+
+	if !destUser.Status.CanCredit() {
+		err = errs.ErrDestinationUnavailable
+		return
 	}
 
-	err = us.UserBalanceWrite.Transfer(user, amount, destUser)
+	params.ToAmount = params.Amount
+	params.ToCurrency = params.Currency
+	if params.QuoteID != "" {
+		err = us.applyFXQuote(user, params)
+		if err != nil {
+			return
+		}
+	}
+
+	destWallet := models.Wallet{UserID: destUser.ID, Currency: params.ToCurrency}
+	err = us.WalletRead.GetWallet(&destWallet)
 	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrCurrencyMismatch
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+
+	if us.FeeCalculator != nil {
+		params.Fee = us.FeeCalculator.CalculateFee(params.Amount)
+	}
+	return
+}
+
+// applyFXQuote converts the transfer amount with the rate locked by a quote of the sender.
+func (us *UserBalanceService) applyFXQuote(user models.User, params *models.TransferParams) (err error) {
+	quote := models.FXQuote{ID: params.QuoteID}
+	err = us.FXRead.GetQuote(&quote)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.ErrFXQuoteNotFound
+		}
+		return errs.ErrInternalServer
+	}
+
+	if quote.UserID != user.ID {
+		return errs.ErrFXQuoteNotFound
+	}
+
+	if quote.IsExpired() {
+		return errs.ErrFXQuoteExpired
+	}
+
+	if quote.FromCurrency != params.Currency {
+		return errs.ErrFXQuoteMismatch
+	}
+
+	params.ToAmount, err = models.ConvertAmount(params.Amount, quote.Rate)
+	if err != nil {
+		return
+	}
+	params.ToCurrency = quote.ToCurrency
+	params.FXRate = quote.Rate
+	return
+}
+
+// checkCanDebit refuses to move money out of a frozen or closed account.
+func (us *UserBalanceService) checkCanDebit(user models.User) error {
+	return checkAccountStatus(us.UserRepoRead, user, models.AccountStatus.CanDebit)
+}
+
+// checkCanCredit refuses to move money into a fully frozen or closed account.
+func (us *UserBalanceService) checkCanCredit(user models.User) error {
+	return checkAccountStatus(us.UserRepoRead, user, models.AccountStatus.CanCredit)
+}
+
+// checkAccountStatus refuses the move of money allowed does not permit from the
+// status of user. The status is read from the cached user, the one in the token may
+// be outdated. The repos check it again under a lock when the money moves.
+func checkAccountStatus(userRepoRead repos.IUserRepoRead, user models.User, allowed func(models.AccountStatus) bool) error {
+	current := models.User{Username: user.Username}
+	err := userRepoRead.GetUser(&current)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.ErrUnauthorized
+		}
+		return errs.ErrInternalServer
+	}
+	if !allowed(current.Status) {
+		return accountStatusError(current.Status)
+	}
+	return nil
+}
+
+func accountStatusError(status models.AccountStatus) error {
+	if status == models.CLOSED {
+		return errs.ErrAccountClosed
+	}
+	return errs.ErrAccountFrozen
+}
+
+// resolveCurrency falls back to the default currency and rejects currencies
+// users can not hold a wallet in.
+func (us *UserBalanceService) resolveCurrency(currency string) (string, error) {
+	if currency == "" {
+		return us.DefaultCurrency, nil
+	}
+	for _, v := range us.Currencies {
+		if v == currency {
+			return currency, nil
+		}
+	}
+	return "", errs.ErrUnsupportedCurrency
+}
+
+func (us *UserBalanceService) ReverseTransfer(refID string, audit *models.AuditLog) (reversalRefID string, err error) {
+	reversalRefID, err = us.UserBalanceWrite.ReverseTransfer(refID, us.AllowNegativeReversal, audit)
+	if err != nil {
+		if err == errs.ErrTransferNotFound ||
+			err == errs.ErrTransferAlreadyReversed ||
+			err == errs.ErrInsufficientBalance ||
+			err == errs.ErrAccountClosed ||
+			err == errs.ErrWalletNotFound {
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+	return
+}
+
+func (us *UserBalanceService) Withdraw(user models.User, amount uint32, currency string) (mutation models.Mutation, err error) {
+	currency, err = us.resolveCurrency(currency)
+	if err != nil {
+		return
+	}
+
+	err = us.checkCanDebit(user)
+	if err != nil {
+		return
+	}
+
+	mutation, err = us.UserBalanceWrite.Withdraw(user, amount, currency)
+	if err != nil {
+		if err == errs.ErrInsufficientBalance ||
+			err == errs.ErrAccountFrozen ||
+			err == errs.ErrAccountClosed {
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+	return
+}
+
+func (us *UserBalanceService) Adjust(user models.User, params models.AdjustmentParams) (mutation models.Mutation, err error) {
+	params.Currency, err = us.resolveCurrency(params.Currency)
+	if err != nil {
+		return
+	}
+
+	mutation, err = us.UserBalanceWrite.Adjust(user, params)
+	if err != nil {
+		if err == errs.ErrInsufficientBalance || err == errs.ErrAccountClosed {
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+	return
+}
+
+func (us *UserBalanceService) CompleteWithdrawal(refID string, status models.MutationStatus) (err error) {
+	err = us.UserBalanceWrite.CompleteWithdrawal(refID, status)
+	if err != nil {
+		if err == errs.ErrWithdrawalNotFound || err == errs.ErrWithdrawalAlreadyCompleted {
+			return
+		}
+		err = errs.ErrInternalServer
 		return
 	}
 	return
@@ -76,9 +454,66 @@ func (us *UserBalanceService) GetTopTransactionsPerUser(user models.User) (data
 			amount = amount * -1
 		}
 		data = append(data, models.TopTransactionsPerUser{
-			Username: v.Username,
-			Amount:   amount,
+			Username:    v.Username,
+			Currency:    v.Currency,
+			Amount:      amount,
+			Memo:        v.Memo,
+			Tags:        v.Tags,
+			ExternalRef: v.ExternalRef,
+		})
+	}
+	return
+}
+
+func (us *UserBalanceService) GetMutations(user models.User, filter models.MutationHistoryFilter) (resp models.MutationHistoryResponse, err error) {
+	filter.UserID = user.ID
+	limit := filter.Limit
+	// fetch one extra row to know whether there is a next page
+	filter.Limit = limit + 1
+
+	dataMutations, err := us.UserBalanceRead.GetMutations(filter)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp.Data = []models.MutationHistoryItem{}
+	for k, v := range dataMutations {
+		if k == limit {
+			last := dataMutations[k-1]
+			resp.NextCursor = models.MutationCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+			break
+		}
+		resp.Data = append(resp.Data, models.MutationHistoryItem{
+			RefID:        v.RefID,
+			MutationType: v.MutationType,
+			Status:       v.Status,
+			Currency:     v.Currency,
+			Amount:       v.Value,
+			Counterparty: v.Counterparty,
+			OrderRef:     v.OrderRef,
+			Memo:         v.Memo,
+			Tags:         v.Tags,
+			ExternalRef:  v.ExternalRef,
+			CreatedAt:    v.CreatedAt,
 		})
 	}
 	return
 }
+
+// CloseAccount closes the account of user. Its wallets must be empty, unless payout
+// is set, then every remaining balance is paid out as a settled withdrawal.
+func (us *UserBalanceService) CloseAccount(user models.User, payout bool, audit *models.AuditLog) (payouts []models.Mutation, err error) {
+	payouts, err = us.UserBalanceWrite.CloseAccount(user, payout, audit)
+	if err != nil {
+		if err == errs.ErrBalanceNotZero ||
+			err == errs.ErrAccountClosed ||
+			err == errs.ErrAccountHasOpenPots ||
+			err == errs.ErrAccountHasOpenEscrows {
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+	return
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/atrariksa/awallet/errs"
 	"github.com/atrariksa/awallet/models"
@@ -13,14 +15,31 @@ import (
 type UserService struct {
 	UserRepoRead  repos.IUserRepoRead
 	UserRepoWrite repos.IUserRepoWrite
+
+	// DefaultCurrency is the currency of the wallet opened for new users
+	// and of the top users list when no currency is requested.
+	DefaultCurrency string
+
+	// MaxFailedAttempts locks a password or PIN for LockoutDuration after that
+	// many consecutive failures, 0 disables lockout.
+	MaxFailedAttempts int
+	LockoutDuration   time.Duration
 }
 
 type IUserService interface {
-	CreateUser(username string) (user models.User, err error)
-	GetListTopUser() (data []models.TopUserResponse, err error)
+	CreateUser(username string, password string, pin string) (user models.User, err error)
+	Login(username string, password string) (user models.User, err error)
+	VerifyTransferPin(user models.User, pin string) error
+	UpdateCredentials(user models.User, req models.UpdateCredentialsRequest) error
+	GetListTopUser(currency string) (data []models.TopUserResponse, err error)
 }
 
-func (us *UserService) CreateUser(username string) (user models.User, err error) {
+var (
+	dummyCredentialHash     string
+	dummyCredentialHashOnce sync.Once
+)
+
+func (us *UserService) CreateUser(username string, password string, pin string) (user models.User, err error) {
 
 	user.Username = username
 	err = us.UserRepoRead.GetUser(&user)
@@ -33,8 +52,21 @@ func (us *UserService) CreateUser(username string) (user models.User, err error)
 		return models.User{}, errs.ErrUserAlreadyExists
 	}
 
+	user.PasswordHash, err = models.HashCredential(password)
+	if err != nil {
+		log.Println(err)
+		return models.User{}, errs.ErrInternalServer
+	}
+	if pin != "" {
+		user.PinHash, err = models.HashCredential(pin)
+		if err != nil {
+			log.Println(err)
+			return models.User{}, errs.ErrInternalServer
+		}
+	}
+
 	// user = models.User{Username: username}
-	err = us.UserRepoWrite.Create(&user)
+	err = us.UserRepoWrite.Create(&user, us.DefaultCurrency)
 	if err != nil {
 		log.Println(err)
 		if strings.Contains(err.Error(), repos.DuplicateKey) {
@@ -45,8 +77,134 @@ func (us *UserService) CreateUser(username string) (user models.User, err error)
 	return
 }
 
-func (us *UserService) GetListTopUser() (data []models.TopUserResponse, err error) {
-	dataResult, err := us.UserRepoRead.GetListTopUser()
+// Login checks the password of username. Unknown users and users without a
+// password are answered like a wrong password, after the same hashing work.
+func (us *UserService) Login(username string, password string) (user models.User, err error) {
+	user = models.User{Username: username}
+	err = us.UserRepoWrite.GetCredentials(&user)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return models.User{}, errs.ErrInternalServer
+	}
+
+	if user.ID == 0 || !user.HasPassword() {
+		dummyCredentialHashOnce.Do(func() {
+			dummyCredentialHash, _ = models.HashCredential(username)
+		})
+		models.CheckCredential(dummyCredentialHash, password)
+		return models.User{}, errs.ErrInvalidCredentials
+	}
+
+	err = us.verifyCredential(&user, models.PASSWORD, password)
+	if err != nil {
+		return models.User{}, err
+	}
+	return
+}
+
+// VerifyTransferPin checks the transaction PIN of user. It only applies to users
+// who set a PIN.
+func (us *UserService) VerifyTransferPin(user models.User, pin string) (err error) {
+	user = models.User{ID: user.ID}
+	err = us.UserRepoWrite.GetCredentials(&user)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.ErrUnauthorized
+		}
+		return errs.ErrInternalServer
+	}
+
+	if !user.HasPin() {
+		return nil
+	}
+	if pin == "" {
+		return errs.ErrPinRequired
+	}
+	return us.verifyCredential(&user, models.PIN, pin)
+}
+
+// UpdateCredentials sets or changes the password and/or the PIN of user. Once a
+// password is set, changing either one requires it. A new password ends the sessions
+// of the user, its refresh tokens are revoked.
+func (us *UserService) UpdateCredentials(user models.User, req models.UpdateCredentialsRequest) (err error) {
+	if req.Password == "" && req.Pin == "" {
+		return errs.ErrNoCredentials
+	}
+
+	user = models.User{ID: user.ID}
+	err = us.UserRepoWrite.GetCredentials(&user)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.ErrUnauthorized
+		}
+		return errs.ErrInternalServer
+	}
+
+	if user.HasPassword() {
+		err = us.verifyCredential(&user, models.PASSWORD, req.CurrentPassword)
+		if err != nil {
+			return
+		}
+	}
+
+	if req.Password != "" {
+		user.PasswordHash, err = models.HashCredential(req.Password)
+		if err != nil {
+			log.Println(err)
+			return errs.ErrInternalServer
+		}
+	}
+	if req.Pin != "" {
+		user.PinHash, err = models.HashCredential(req.Pin)
+		if err != nil {
+			log.Println(err)
+			return errs.ErrInternalServer
+		}
+	}
+
+	err = us.UserRepoWrite.UpdateCredentials(user, req.Password != "")
+	if err != nil {
+		return errs.ErrInternalServer
+	}
+	return
+}
+
+// verifyCredential checks secret against the credential of user, counting failures
+// towards the lockout and clearing them on success.
+func (us *UserService) verifyCredential(user *models.User, credential models.Credential, secret string) (err error) {
+	errLocked, errInvalid, hash := errs.ErrAccountLocked, errs.ErrInvalidCredentials, user.PasswordHash
+	if credential == models.PIN {
+		errLocked, errInvalid, hash = errs.ErrPinLocked, errs.ErrInvalidPin, user.PinHash
+	}
+
+	if user.IsLocked(credential) {
+		return errLocked
+	}
+
+	if models.CheckCredential(hash, secret) {
+		if user.FailedAttempts(credential) > 0 {
+			err = us.UserRepoWrite.ResetCredentialFailures(user, credential)
+			if err != nil {
+				return errs.ErrInternalServer
+			}
+		}
+		return nil
+	}
+
+	locked, err := us.UserRepoWrite.RecordCredentialFailure(user, credential, us.MaxFailedAttempts, us.LockoutDuration)
+	if err != nil {
+		return errs.ErrInternalServer
+	}
+	if locked {
+		return errLocked
+	}
+	return errInvalid
+}
+
+func (us *UserService) GetListTopUser(currency string) (data []models.TopUserResponse, err error) {
+	if currency == "" {
+		currency = us.DefaultCurrency
+	}
+	dataResult, err := us.UserRepoRead.GetListTopUser(currency)
 	if err != nil {
 		return
 	}
@@ -54,6 +212,7 @@ func (us *UserService) GetListTopUser() (data []models.TopUserResponse, err erro
 		data = append(data,
 			models.TopUserResponse{
 				Username:        v.Username,
+				Currency:        v.Currency,
 				TransactedValue: v.Value,
 			},
 		)
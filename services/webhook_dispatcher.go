@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"github.com/atrariksa/awallet/utils"
+)
+
+const (
+	DefaultWebhookBatchSize    = 50
+	DefaultWebhookPollInterval = time.Second
+	DefaultWebhookMaxAttempts  = 8
+	DefaultWebhookBaseBackoff  = 10 * time.Second
+	DefaultWebhookMaxBackoff   = time.Hour
+)
+
+// WebhookDispatcher sends due webhook deliveries, signed with the secret of their
+// webhook. A failed attempt is retried with exponential backoff, a delivery out of
+// attempts is dead and only sent again on replay. Dispatchers can run side by side,
+// every attempt is claimed first.
+type WebhookDispatcher struct {
+	WebhookRepo  repos.IWebhookRepo
+	Client       *http.Client
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+// Run dispatches until ctx is done.
+func (wd *WebhookDispatcher) Run(ctx context.Context) {
+	for {
+		_, err := wd.DispatchOnce()
+		if err != nil {
+			log.Println(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wd.pollInterval()):
+		}
+	}
+}
+
+// DispatchOnce makes an attempt for every due delivery and returns how many were delivered.
+func (wd *WebhookDispatcher) DispatchOnce() (delivered int, err error) {
+	deliveries, err := wd.WebhookRepo.GetDueDeliveries(wd.batchSize())
+	if err != nil {
+		return
+	}
+
+	for _, delivery := range deliveries {
+		// the attempt may not outlast its claim
+		claimed, err := wd.WebhookRepo.ClaimDelivery(delivery, 2*wd.Client.Timeout)
+		if err != nil || !claimed {
+			continue
+		}
+		delivery.Attempts++
+
+		statusCode, err := wd.send(delivery)
+		now := utils.TimeNowUTC()
+		delivery.LastStatusCode = statusCode
+		delivery.LastError = ""
+		if err == nil {
+			delivery.Status = models.DELIVERY_DELIVERED
+			delivery.DeliveredAt = &now
+			delivered++
+		} else {
+			delivery.LastError = err.Error()
+			delivery.NextAttemptAt = now.Add(models.WebhookBackoff(delivery.Attempts, wd.baseBackoff(), wd.maxBackoff()))
+			if delivery.Attempts >= wd.maxAttempts() {
+				delivery.Status = models.DELIVERY_DEAD
+			}
+		}
+		wd.WebhookRepo.RecordDeliveryResult(delivery)
+	}
+	return delivered, nil
+}
+
+// send posts the payload of delivery to its webhook. Any status other than 2xx is
+// a failure.
+func (wd *WebhookDispatcher) send(delivery models.WebhookDelivery) (statusCode int, err error) {
+	body := []byte(delivery.Payload)
+	timestamp := utils.TimeNowUTC().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(models.WebhookDeliveryHeader, delivery.DeliveryID)
+	req.Header.Set(models.WebhookEventHeader, string(delivery.Event))
+	req.Header.Set(models.WebhookTimestampHeader, fmt.Sprintf("%v", timestamp))
+	req.Header.Set(models.WebhookSignatureHeader, models.SignWebhook(delivery.Webhook.Secret, timestamp, body))
+
+	resp, err := wd.Client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("Webhook responded with status %v", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (wd *WebhookDispatcher) batchSize() int {
+	if wd.BatchSize <= 0 {
+		return DefaultWebhookBatchSize
+	}
+	return wd.BatchSize
+}
+
+func (wd *WebhookDispatcher) pollInterval() time.Duration {
+	if wd.PollInterval <= 0 {
+		return DefaultWebhookPollInterval
+	}
+	return wd.PollInterval
+}
+
+func (wd *WebhookDispatcher) maxAttempts() int {
+	if wd.MaxAttempts <= 0 {
+		return DefaultWebhookMaxAttempts
+	}
+	return wd.MaxAttempts
+}
+
+func (wd *WebhookDispatcher) baseBackoff() time.Duration {
+	if wd.BaseBackoff <= 0 {
+		return DefaultWebhookBaseBackoff
+	}
+	return wd.BaseBackoff
+}
+
+func (wd *WebhookDispatcher) maxBackoff() time.Duration {
+	if wd.MaxBackoff <= 0 {
+		return DefaultWebhookMaxBackoff
+	}
+	return wd.MaxBackoff
+}
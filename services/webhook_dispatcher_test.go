@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+)
+
+// fakeWebhookRepo serves deliveries from memory and keeps the recorded results.
+type fakeWebhookRepo struct {
+	repos.IWebhookRepo
+	deliveries []models.WebhookDelivery
+	results    []models.WebhookDelivery
+}
+
+func (fr *fakeWebhookRepo) GetDueDeliveries(limit int) ([]models.WebhookDelivery, error) {
+	return fr.deliveries, nil
+}
+
+func (fr *fakeWebhookRepo) ClaimDelivery(delivery models.WebhookDelivery, lease time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (fr *fakeWebhookRepo) RecordDeliveryResult(delivery models.WebhookDelivery) error {
+	fr.results = append(fr.results, delivery)
+	return nil
+}
+
+func TestWebhookDispatcherSignsDeliveries(t *testing.T) {
+	webhook := models.Webhook{ID: 1, UserID: 1, Secret: "secret"}
+	delivery := models.NewWebhookDelivery(webhook, models.WEBHOOK_TOPUP, map[string]int{"amount": 100})
+
+	received := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(models.WebhookDeliveryHeader) != delivery.DeliveryID ||
+			r.Header.Get(models.WebhookEventHeader) != string(models.WEBHOOK_TOPUP) ||
+			string(body) != delivery.Payload {
+			received <- fmt.Errorf("unexpected request %v %v", r.Header, string(body))
+			w.WriteHeader(400)
+			return
+		}
+		received <- models.VerifyWebhookSignature(
+			webhook.Secret,
+			r.Header.Get(models.WebhookTimestampHeader),
+			body,
+			r.Header.Get(models.WebhookSignatureHeader),
+			time.Minute,
+		)
+		w.WriteHeader(204)
+	}))
+	defer server.Close()
+
+	webhook.URL = server.URL
+	delivery.Webhook = webhook
+	repo := &fakeWebhookRepo{deliveries: []models.WebhookDelivery{delivery}}
+	wd := WebhookDispatcher{WebhookRepo: repo, Client: server.Client()}
+
+	delivered, err := wd.DispatchOnce()
+	if err != nil || delivered != 1 {
+		t.Fatalf("DispatchOnce got %v %v, want 1 delivered", delivered, err)
+	}
+	err = <-received
+	if err != nil {
+		t.Errorf("webhook received %v", err)
+	}
+	result := repo.results[0]
+	if result.Status != models.DELIVERY_DELIVERED || result.LastStatusCode != 204 || result.DeliveredAt == nil {
+		t.Errorf("got %v %v, want a delivered delivery", result.Status, result.LastStatusCode)
+	}
+}
+
+func TestWebhookDispatcherRetriesFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	webhook := models.Webhook{ID: 1, UserID: 1, URL: server.URL, Secret: "secret"}
+	delivery := models.NewWebhookDelivery(webhook, models.WEBHOOK_TOPUP, nil)
+	delivery.Webhook = webhook
+	lastAttempt := delivery
+	lastAttempt.Attempts = 2
+
+	repo := &fakeWebhookRepo{deliveries: []models.WebhookDelivery{delivery, lastAttempt}}
+	wd := WebhookDispatcher{WebhookRepo: repo, Client: server.Client(), MaxAttempts: 3, BaseBackoff: time.Minute, MaxBackoff: time.Hour}
+
+	delivered, _ := wd.DispatchOnce()
+	if delivered != 0 {
+		t.Fatalf("DispatchOnce got %v delivered, want 0", delivered)
+	}
+	retried, dead := repo.results[0], repo.results[1]
+	if retried.Status != models.DELIVERY_PENDING || retried.Attempts != 1 || retried.LastStatusCode != 500 ||
+		!retried.NextAttemptAt.After(delivery.NextAttemptAt) {
+		t.Errorf("got %v after %v attempts, want a pending delivery retried later", retried.Status, retried.Attempts)
+	}
+	if dead.Status != models.DELIVERY_DEAD || dead.Attempts != 3 {
+		t.Errorf("got %v after %v attempts, want a dead delivery", dead.Status, dead.Attempts)
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(204)
+	}))
+	defer server.Close()
+
+	_, err := NewWebhookClient(time.Second, false).Post(server.URL, "application/json", nil)
+	if err == nil {
+		t.Errorf("posting to %v got no error", server.URL)
+	}
+
+	resp, err := NewWebhookClient(time.Second, true).Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("posting to %v with private urls allowed got %v", server.URL, err)
+	}
+	resp.Body.Close()
+}
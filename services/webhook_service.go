@@ -0,0 +1,134 @@
+package services
+
+import (
+	"log"
+
+	"github.com/atrariksa/awallet/errs"
+	"github.com/atrariksa/awallet/models"
+	"github.com/atrariksa/awallet/repos"
+	"gorm.io/gorm"
+)
+
+const DefaultWebhookDeliveriesLimit = 50
+
+type WebhookService struct {
+	WebhookRepo repos.IWebhookRepo
+	// AllowPrivateURLs lets webhooks post to loopback and private networks, for
+	// local development only.
+	AllowPrivateURLs bool
+}
+
+type IWebhookService interface {
+	Register(user models.User, req models.CreateWebhookRequest) (resp models.WebhookResponse, err error)
+	List(user models.User) (resp models.WebhooksResponse, err error)
+	Delete(user models.User, webhookID uint) error
+	ListDeliveries(user models.User, status models.WebhookDeliveryStatus) (resp models.WebhookDeliveriesResponse, err error)
+	Replay(user models.User, deliveryID string) (resp models.WebhookDeliveryResponse, err error)
+}
+
+// Register adds a webhook for the events of user. Its secret is only returned here.
+func (ws *WebhookService) Register(user models.User, req models.CreateWebhookRequest) (resp models.WebhookResponse, err error) {
+	for _, v := range req.Events {
+		if !v.IsValid() {
+			err = errs.ErrInvalidWebhookEvent
+			return
+		}
+	}
+
+	if !ws.AllowPrivateURLs {
+		err = checkWebhookURL(req.URL)
+		if err != nil {
+			return
+		}
+	}
+
+	webhook, err := models.NewWebhook(user, req.URL, req.Events)
+	if err != nil {
+		log.Println(err)
+		err = errs.ErrInternalServer
+		return
+	}
+	err = ws.WebhookRepo.CreateWebhook(&webhook)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp = models.NewWebhookResponse(webhook)
+	resp.Secret = webhook.Secret
+	return
+}
+
+func (ws *WebhookService) List(user models.User) (resp models.WebhooksResponse, err error) {
+	webhooks, err := ws.WebhookRepo.GetWebhooks(user.ID)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp.Webhooks = []models.WebhookResponse{}
+	for _, v := range webhooks {
+		resp.Webhooks = append(resp.Webhooks, models.NewWebhookResponse(v))
+	}
+	return
+}
+
+func (ws *WebhookService) Delete(user models.User, webhookID uint) error {
+	deleted, err := ws.WebhookRepo.DeleteWebhook(user.ID, webhookID)
+	if err != nil {
+		return errs.ErrInternalServer
+	}
+	if !deleted {
+		return errs.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries of user, the dead letters only with
+// status DEAD.
+func (ws *WebhookService) ListDeliveries(user models.User, status models.WebhookDeliveryStatus) (resp models.WebhookDeliveriesResponse, err error) {
+	deliveries, err := ws.WebhookRepo.GetDeliveries(user.ID, status, DefaultWebhookDeliveriesLimit)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	resp.Deliveries = []models.WebhookDeliveryResponse{}
+	for _, v := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, models.NewWebhookDeliveryResponse(v))
+	}
+	return
+}
+
+// Replay queues a delivered or dead delivery of user to be sent again.
+func (ws *WebhookService) Replay(user models.User, deliveryID string) (resp models.WebhookDeliveryResponse, err error) {
+	replayed, err := ws.WebhookRepo.ReplayDelivery(user.ID, deliveryID)
+	if err != nil {
+		err = errs.ErrInternalServer
+		return
+	}
+
+	delivery := models.WebhookDelivery{DeliveryID: deliveryID, UserID: user.ID}
+	err = ws.WebhookRepo.GetDelivery(&delivery)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = errs.ErrDeliveryNotFound
+			return
+		}
+		err = errs.ErrInternalServer
+		return
+	}
+
+	if !replayed {
+		if delivery.Status == models.DELIVERY_PENDING {
+			err = errs.ErrDeliveryPending
+			return
+		}
+		// the webhook of the delivery was deleted
+		err = errs.ErrWebhookNotFound
+		return
+	}
+
+	resp = models.NewWebhookDeliveryResponse(delivery)
+	return
+}
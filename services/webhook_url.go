@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+// privateNetworks are the ranges net.IP does not tell apart on its own: private
+// networks, shared address space and "this" network.
+var privateNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+)
+
+func parseCIDRs(cidrs ...string) (networks []*net.IPNet) {
+	for _, v := range cidrs {
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return
+}
+
+// isPublicIP reports whether ip is reachable on the internet, not a loopback,
+// private, link-local, multicast or unspecified address.
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return false
+	}
+	for _, v := range privateNetworks {
+		if v.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookURL refuses a webhook url that is not http or https, or whose host
+// resolves to an address that is not public, so webhooks can not reach the
+// internal network of the server.
+func checkWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errs.ErrWebhookURLNotAllowed
+	}
+
+	ips := []net.IP{net.ParseIP(u.Hostname())}
+	if ips[0] == nil {
+		ips, err = net.LookupIP(u.Hostname())
+		if err != nil || len(ips) == 0 {
+			return errs.ErrWebhookURLNotAllowed
+		}
+	}
+	for _, v := range ips {
+		if !isPublicIP(v) {
+			return errs.ErrWebhookURLNotAllowed
+		}
+	}
+	return nil
+}
+
+// NewWebhookClient returns the client posting webhooks within timeout. Unless
+// allowPrivateURLs, it only connects to public addresses, checked on the resolved
+// address of every connection so a host can not resolve to a public address when
+// registered and to an internal one when posted to, nor redirect there.
+func NewWebhookClient(timeout time.Duration, allowPrivateURLs bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateURLs {
+		dialer.Control = func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("Webhook address %v is not public", host)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// through a proxy, the address checked would be the one of the proxy
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
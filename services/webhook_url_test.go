@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/atrariksa/awallet/errs"
+)
+
+func TestCheckWebhookURL(t *testing.T) {
+	var tests = []struct {
+		url     string
+		wantErr error
+	}{
+		{"https://93.184.216.34/hook", nil},
+		{"http://[2606:2800:220:1:248:1893:25c8:1946]:8080/hook", nil},
+		{"ftp://93.184.216.34/hook", errs.ErrWebhookURLNotAllowed},
+		{"https:///hook", errs.ErrWebhookURLNotAllowed},
+		{"http://localhost/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://127.0.0.1/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://[::1]/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://0.0.0.0/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://10.1.2.3/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://172.16.0.1/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://192.168.1.1/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://100.64.0.1/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://169.254.169.254/latest/meta-data", errs.ErrWebhookURLNotAllowed},
+		{"http://[fe80::1]/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://[fd00::1]/hook", errs.ErrWebhookURLNotAllowed},
+		{"http://[::ffff:127.0.0.1]/hook", errs.ErrWebhookURLNotAllowed},
+	}
+	for _, v := range tests {
+		err := checkWebhookURL(v.url)
+		if err != v.wantErr {
+			t.Errorf("checkWebhookURL(%q) got %v, want %v", v.url, err, v.wantErr)
+		}
+	}
+}